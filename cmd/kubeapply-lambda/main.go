@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/segmentio/kubeapply/pkg/cluster"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	kaevents "github.com/segmentio/kubeapply/pkg/events"
 	"github.com/segmentio/kubeapply/pkg/pullreq"
 	"github.com/segmentio/kubeapply/pkg/stats"
@@ -22,11 +24,24 @@ var (
 	sess        *session.Session
 	statsClient stats.StatsClient
 
-	automerge       bool
-	debug           bool
-	strictCheck     bool
-	greenCIRequired bool
-	reviewRequired  bool
+	automerge            bool
+	debug                bool
+	strictCheck          bool
+	greenCIRequired      bool
+	reviewRequired       bool
+	incrementalDiffs     bool
+	dryRunSummaries      bool
+	diffOptional         bool
+	scopeDirectives      bool
+	clusterStats         bool
+	statusJSON           bool
+	maxConcurrentApplies int
+	maxDiffLines         int
+	groupDiffs           bool
+	clientSettings       pullreq.GHPullRequestClientSettings
+	repoSettings         map[string]kaevents.RepoSettings
+	summaryMode          kube.SummaryMode
+	publisher            kaevents.Publisher
 
 	logsURL = getLogsURL()
 )
@@ -39,6 +54,17 @@ var (
 	// Optional, defaults to false.
 	automergeStr = os.Getenv("KUBEAPPLY_AUTOMERGE")
 
+	// Number of commits to fetch when cloning the repo.
+	//
+	// Optional, defaults to 1.
+	cloneDepthStr = os.Getenv("KUBEAPPLY_CLONE_DEPTH")
+
+	// Path to a directory with override templates for pull request comments. See
+	// pullreq.LoadCommentTemplates for details.
+	//
+	// Optional, defaults to "" (use the built-in templates only)
+	commentTemplatesDir = os.Getenv("KUBEAPPLY_COMMENT_TEMPLATES_DIR")
+
 	// An SSM parameter where a Datadog API key is stored.
 	//
 	// Optional, defaults to "" (don't export stats to Datadog)
@@ -77,15 +103,124 @@ var (
 	// Optional, defaults to false.
 	strictCheckStr = os.Getenv("KUBEAPPLY_STRICT_CHECK")
 
+	// Whether to clone the full history of the repo instead of doing a shallow clone.
+	//
+	// Optional, defaults to false.
+	fullCloneStr = os.Getenv("KUBEAPPLY_FULL_CLONE")
+
 	// Whether a green CI is required to apply. Ideally, should be set to "true",
 	// but based on how long the CI takes, might be easier to have it be "false" in
 	// non-production environments.
 	greenCIRequiredStr = os.Getenv("KUBEAPPLY_GREEN_CI_REQUIRED")
 
+	// Whether diffs should only cover the subpaths that have changed since the last diff of
+	// the same pull request in each cluster.
+	//
+	// Optional, defaults to false.
+	incrementalDiffsStr = os.Getenv("KUBEAPPLY_INCREMENTAL_DIFFS")
+
+	// Whether diffs should also run a dry-run apply and summarize the resources that would be
+	// created, updated, and left unchanged.
+	//
+	// Optional, defaults to false.
+	dryRunSummariesStr = os.Getenv("KUBEAPPLY_DRY_RUN_SUMMARIES")
+
+	// Whether the automerge workflow can be completed without a kubeapply diff before each
+	// apply. This removes a safety check and should only be used if changes are validated
+	// elsewhere.
+	//
+	// Optional, defaults to false.
+	diffOptionalStr = os.Getenv("KUBEAPPLY_DIFF_OPTIONAL")
+
+	// Whether the default clusters and subpath for commands can be set via labels or a fenced
+	// block in the pull request body.
+	//
+	// Optional, defaults to false.
+	scopeDirectivesStr = os.Getenv("KUBEAPPLY_SCOPE_DIRECTIVES")
+
+	// Whether to also emit diff and apply stats for each cluster, tagged with the cluster's
+	// env, region, and name.
+	//
+	// Optional, defaults to false.
+	clusterStatsStr = os.Getenv("KUBEAPPLY_CLUSTER_STATS")
+
+	// Whether to include a JSON summary of the workloads in each cluster in status comments.
+	//
+	// Optional, defaults to false.
+	statusJSONStr = os.Getenv("KUBEAPPLY_STATUS_JSON")
+
+	// Maximum number of clusters to apply in parallel.
+	//
+	// Optional, defaults to 1.
+	maxConcurrentAppliesStr = os.Getenv("KUBEAPPLY_MAX_CONCURRENT_APPLIES")
+
+	// Maximum number of raw diff lines shown for each resource in diff comments.
+	//
+	// Optional, defaults to 0 (only clip diffs by length).
+	maxDiffLinesStr = os.Getenv("KUBEAPPLY_MAX_DIFF_LINES_PER_RESOURCE")
+
+	// Whether to group the resources in diff comments by namespace.
+	//
+	// Optional, defaults to false.
+	groupDiffsByNamespaceStr = os.Getenv("KUBEAPPLY_GROUP_DIFFS_BY_NAMESPACE")
+
+	// Whether to only check out the directories with changed files when cloning the repo.
+	//
+	// Optional, defaults to false.
+	sparseCheckoutStr = os.Getenv("KUBEAPPLY_SPARSE_CHECKOUT")
+
 	// Whether a review is required to apply. Generally "true" in production and
 	// otherwise "false".
 	reviewRequiredStr = os.Getenv("KUBEAPPLY_REVIEW_REQUIRED")
 
+	// JSON object with per-repo overrides of the settings above, keyed by "owner/repo", e.g.
+	// {"segmentio/repo": {"env": "production", "automerge": true}}. Useful for serving
+	// webhooks from multiple repos in a single lambda.
+	//
+	// Optional, defaults to "" (use the same settings for all repos)
+	repoSettingsStr = os.Getenv("KUBEAPPLY_REPO_SETTINGS")
+
+	// An SSM parameter where a Slack incoming webhook URL is stored. If set, then the
+	// results of applies are posted to Slack.
+	//
+	// Optional, defaults to "" (don't send Slack notifications)
+	slackWebhookURLSSMParam = os.Getenv("KUBEAPPLY_SLACK_WEBHOOK_URL_SSM_PARAM")
+
+	// Comma-separated list of environments to send Slack notifications for.
+	//
+	// Optional, if blank then notifications are sent for all environments.
+	slackEnvsStr = os.Getenv("KUBEAPPLY_SLACK_ENVS")
+
+	// Comma-separated list of commands (e.g., "apply") that can't be run via pull request
+	// comments.
+	//
+	// Optional, if blank then all commands are allowed.
+	disabledCommandsStr = os.Getenv("KUBEAPPLY_DISABLED_COMMANDS")
+
+	// Go template for the commit messages of automerged pull requests. See
+	// pullreq.MergeMessageData for the available fields.
+	//
+	// Optional, defaults to "Merged by kubeapply (pull request {{ .PullRequestNum }})".
+	mergeMessageTemplate = os.Getenv("KUBEAPPLY_MERGE_MESSAGE_TEMPLATE")
+
+	// Name of an EventBridge bus that structured events with the results of each diff and
+	// apply are published to.
+	//
+	// Optional, if blank then no events are published.
+	eventBridgeBus = os.Getenv("KUBEAPPLY_EVENTBRIDGE_BUS")
+
+	// Prefix for the contexts of the statuses set in Github. Useful if running multiple
+	// kubeapply deployments against the same repo.
+	//
+	// Optional, defaults to "kubeapply".
+	statusContextPrefix = os.Getenv("KUBEAPPLY_STATUS_CONTEXT_PREFIX")
+
+	// How cluster summaries are generated for status comments; either "full" or "basic". The
+	// basic mode only requires kubectl, so it can be used in images without python.
+	//
+	// Optional, defaults to "full".
+	summaryModeStr = os.Getenv("KUBEAPPLY_SUMMARY_MODE")
+
 	// SSM parameter used for fetching webhook secret.
 	webhookSecretSSMParam = os.Getenv("KUBEAPPLY_WEBHOOK_SECRET_SSM_PARAM")
 )
@@ -93,6 +228,7 @@ var (
 // Final, decrypted secrets
 var (
 	githubAccessToken string
+	slackWebhookURL   string
 	webhookSecret     string
 )
 
@@ -152,11 +288,24 @@ func init() {
 		log.Fatalf("No github token or app key information provided")
 	}
 
+	if commentTemplatesDir != "" {
+		if err := pullreq.LoadCommentTemplates(commentTemplatesDir); err != nil {
+			log.Fatalf("Error loading comment templates: %+v", err)
+		}
+	}
+
 	webhookSecret, err = util.GetSSMValue(ctx, sess, webhookSecretSSMParam)
 	if err != nil {
 		panic(err)
 	}
 
+	if slackWebhookURLSSMParam != "" {
+		slackWebhookURL, err = util.GetSSMValue(ctx, sess, slackWebhookURLSSMParam)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	if strings.ToLower(debugStr) == "true" {
 		debug = true
 	}
@@ -173,9 +322,80 @@ func init() {
 		reviewRequired = true
 	}
 
+	if strings.ToLower(incrementalDiffsStr) == "true" {
+		incrementalDiffs = true
+	}
+
+	if strings.ToLower(dryRunSummariesStr) == "true" {
+		dryRunSummaries = true
+	}
+
+	if strings.ToLower(diffOptionalStr) == "true" {
+		diffOptional = true
+	}
+
+	if strings.ToLower(scopeDirectivesStr) == "true" {
+		scopeDirectives = true
+	}
+
+	if strings.ToLower(clusterStatsStr) == "true" {
+		clusterStats = true
+	}
+
+	if strings.ToLower(statusJSONStr) == "true" {
+		statusJSON = true
+	}
+
+	if strings.ToLower(groupDiffsByNamespaceStr) == "true" {
+		groupDiffs = true
+	}
+
+	if maxConcurrentAppliesStr != "" {
+		maxConcurrentApplies, err = strconv.Atoi(maxConcurrentAppliesStr)
+		if err != nil {
+			log.Fatalf("Error parsing max concurrent applies: %+v", err)
+		}
+	}
+
+	if maxDiffLinesStr != "" {
+		maxDiffLines, err = strconv.Atoi(maxDiffLinesStr)
+		if err != nil {
+			log.Fatalf("Error parsing max diff lines per resource: %+v", err)
+		}
+	}
+
 	if strings.ToLower(automergeStr) == "true" {
 		automerge = true
 	}
+
+	if cloneDepthStr != "" {
+		clientSettings.CloneDepth, err = strconv.Atoi(cloneDepthStr)
+		if err != nil {
+			log.Fatalf("Error parsing clone depth: %+v", err)
+		}
+	}
+
+	if strings.ToLower(fullCloneStr) == "true" {
+		clientSettings.FullClone = true
+	}
+
+	if strings.ToLower(sparseCheckoutStr) == "true" {
+		clientSettings.SparseCheckout = true
+	}
+
+	if eventBridgeBus != "" {
+		publisher = kaevents.NewEventBridgePublisher(sess, eventBridgeBus)
+	}
+
+	repoSettings, err = kaevents.ParseRepoSettings(repoSettingsStr)
+	if err != nil {
+		log.Fatalf("Error parsing repo settings: %+v", err)
+	}
+
+	summaryMode, err = kube.ParseSummaryMode(summaryModeStr)
+	if err != nil {
+		log.Fatalf("Error parsing summary mode: %+v", err)
+	}
 }
 
 // Handle handles the lambda invocation and returns a response for the ALB to pass back to
@@ -226,6 +446,7 @@ func handleRequest(
 		webhookType,
 		bodyBytes,
 		githubAccessToken,
+		clientSettings,
 	)
 	if err != nil {
 		return kaevents.ErrorResponse(err), nil
@@ -238,16 +459,33 @@ func handleRequest(
 		statsClient,
 		cluster.NewKubeClusterClient,
 		kaevents.WebhookHandlerSettings{
-			LogsURL:               logsURL,
-			Env:                   env,
-			Version:               version.Version,
-			StrictCheck:           strictCheck,
-			GreenCIRequired:       greenCIRequired,
-			ReviewRequired:        reviewRequired,
-			Automerge:             automerge,
-			UseLocks:              true,
-			ApplyConsistencyCheck: false,
-			Debug:                 debug,
+			LogsURL:                 logsURL,
+			Env:                     env,
+			Version:                 version.Version,
+			StrictCheck:             strictCheck,
+			GreenCIRequired:         greenCIRequired,
+			ReviewRequired:          reviewRequired,
+			IncrementalDiffs:        incrementalDiffs,
+			DryRunSummaries:         dryRunSummaries,
+			DiffOptional:            diffOptional,
+			ScopeDirectives:         scopeDirectives,
+			ClusterStats:            clusterStats,
+			StatusJSON:              statusJSON,
+			MaxConcurrentApplies:    maxConcurrentApplies,
+			Automerge:               automerge,
+			MergeMessageTemplate:    mergeMessageTemplate,
+			RepoSettings:            repoSettings,
+			UseLocks:                true,
+			ApplyConsistencyCheck:   false,
+			Debug:                   debug,
+			SlackWebhookURL:         slackWebhookURL,
+			SlackEnvs:               splitList(slackEnvsStr),
+			DisabledCommands:        splitList(disabledCommandsStr),
+			Publisher:               publisher,
+			StatusContextPrefix:     statusContextPrefix,
+			SummaryMode:             summaryMode,
+			MaxDiffLinesPerResource: maxDiffLines,
+			GroupDiffsByNamespace:   groupDiffs,
 		},
 	)
 	resp := webhookHandler.HandleWebhook(
@@ -273,6 +511,20 @@ func getLogsURL() string {
 	)
 }
 
+// splitList splits a comma-separated list from an env variable, dropping empty elements.
+func splitList(listStr string) []string {
+	elements := []string{}
+
+	for _, element := range strings.Split(listStr, ",") {
+		element = strings.TrimSpace(element)
+		if element != "" {
+			elements = append(elements, element)
+		}
+	}
+
+	return elements
+}
+
 func main() {
 	lambda.Start(Handle)
 }
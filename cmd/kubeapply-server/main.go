@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
 	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/segmentio/conf"
 	"github.com/segmentio/kubeapply/pkg/cluster"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/events"
+	"github.com/segmentio/kubeapply/pkg/pullreq"
 	kstats "github.com/segmentio/kubeapply/pkg/stats"
+	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/segmentio/kubeapply/pkg/version"
 	"github.com/segmentio/stats/httpstats"
 	"github.com/segmentio/stats/v4"
@@ -42,20 +54,117 @@ type Config struct {
 	GithubToken   string `conf:"github-token"   help:"token for Github API access"`
 	LogsURL       string `conf:"logs-url"       help:"url for logs; used as link for status checks"`
 	WebhookSecret string `conf:"webhook-secret" help:"shared secret set in Github webhooks"`
+	TriggerToken  string `conf:"trigger-token"  help:"bearer token for the /trigger endpoint; the endpoint is disabled if unset"`
+	TmpDir        string `conf:"tmp-dir"        help:"base directory for temporary files, e.g. repo clones and expansions; defaults to the system temp directory"`
 
 	// TODO: Deprecate StrictCheck since it's covered by the parameters below that.
 	StrictCheck     bool `conf:"strict-check"      help:"ensure green status and approval before apply"`
 	GreenCIRequired bool `conf:"green-ci-required" help:"require green CI before applying"`
 	ReviewRequired  bool `conf:"review-required"   help:"require review before applying:"`
+
+	IncrementalDiffs     bool `conf:"incremental-diffs"      help:"only diff subpaths changed since the pull request's last diff"`
+	DryRunSummaries      bool `conf:"dry-run-summaries"      help:"summarize the results of a dry-run apply in diff comments"`
+	DiffOptional         bool `conf:"diff-optional"          help:"allow automerges without a diff before each apply; removes a safety check"`
+	ScopeDirectives      bool `conf:"scope-directives"       help:"read default command clusters and subpaths from pull request labels and body"`
+	ClusterStats         bool `conf:"cluster-stats"          help:"also emit diff and apply stats for each cluster, tagged with the cluster name"`
+	StatusJSON           bool `conf:"status-json"            help:"include a json summary of the workloads in each cluster in status comments"`
+	MaxConcurrentApplies int  `conf:"max-concurrent-applies" help:"maximum number of clusters to apply in parallel"`
+
+	MaxDiffLinesPerResource int  `conf:"max-diff-lines-per-resource" help:"maximum number of raw diff lines shown per resource in diff comments"`
+	GroupDiffsByNamespace   bool `conf:"group-diffs-by-namespace"    help:"group the resources in diff comments by namespace"`
+
+	RepoSettings string `conf:"repo-settings" help:"json object of per-repo setting overrides, keyed by owner/repo"`
+
+	SlackWebhookURL string   `conf:"slack-webhook-url" help:"slack incoming webhook url for apply notifications"`
+	SlackEnvs       []string `conf:"slack-envs"        help:"only send slack notifications for these environments"`
+
+	DisabledCommands []string `conf:"disabled-commands" help:"commands that can't be run via pull request comments"`
+
+	CommentTemplatesDir  string `conf:"comment-templates-dir"  help:"directory with override templates for comments"`
+	MergeMessageTemplate string `conf:"merge-message-template" help:"go template for automerge commit messages"`
+	StatusContextPrefix  string `conf:"status-context-prefix" help:"prefix for github status contexts; defaults to kubeapply"`
+
+	SummaryMode string `conf:"summary-mode" help:"how cluster summaries are generated; either full or basic"`
+
+	CloneDepth     int  `conf:"clone-depth"     help:"number of commits to fetch when cloning the repo; defaults to 1"`
+	FullClone      bool `conf:"full-clone"      help:"clone the full history of the repo"`
+	SparseCheckout bool `conf:"sparse-checkout" help:"only check out the directories with changed files"`
+
+	CloneCacheEntries int           `conf:"clone-cache-entries" help:"number of pull request clones to cache for reuse across commands; caching is disabled if 0"`
+	CloneCacheMaxAge  time.Duration `conf:"clone-cache-max-age" help:"evict cached pull request clones after this long; no limit if 0"`
+
+	ShutdownTimeout time.Duration `conf:"shutdown-timeout" help:"how long to wait for in-flight webhooks when shutting down"`
+
+	MaxConcurrentWebhooks int `conf:"max-concurrent-webhooks" help:"maximum number of webhooks handled at once; others get a 503; unlimited if 0"`
 }
 
 var config = Config{
-	Bind: ":8080",
+	Bind:            ":8080",
+	ShutdownTimeout: 5 * time.Minute,
 }
 
+var repoSettings map[string]events.RepoSettings
+var summaryMode kube.SummaryMode
+
+// ready is set to 1 once the config has been loaded and the stats clients registered, and
+// back to 0 when the server starts shutting down.
+var ready int32
+
+// webhookSlots limits the number of webhooks that are handled at once. It's nil if there's
+// no limit.
+var webhookSlots chan struct{}
+
+// cloneCache stores pull request clones for reuse across commands. It's nil if caching is
+// disabled.
+var cloneCache *pullreq.CloneCache
+
 func main() {
 	conf.Load(&config)
 
+	if config.TmpDir != "" {
+		if err := os.Setenv(util.TempDirEnvVar, config.TmpDir); err != nil {
+			log.Fatalf("Error setting temp directory: %+v", err)
+		}
+	}
+
+	var err error
+	repoSettings, err = events.ParseRepoSettings(config.RepoSettings)
+	if err != nil {
+		log.Fatalf("Error parsing repo settings: %+v", err)
+	}
+
+	summaryMode, err = kube.ParseSummaryMode(config.SummaryMode)
+	if err != nil {
+		log.Fatalf("Error parsing summary mode: %+v", err)
+	}
+
+	if config.CommentTemplatesDir != "" {
+		if err := pullreq.LoadCommentTemplates(config.CommentTemplatesDir); err != nil {
+			log.Fatalf("Error loading comment templates: %+v", err)
+		}
+	}
+
+	if config.MaxConcurrentWebhooks > 0 {
+		webhookSlots = make(chan struct{}, config.MaxConcurrentWebhooks)
+	}
+
+	if config.CloneCacheEntries > 0 {
+		cacheRoot, err := util.TempDir("clone-cache")
+		if err != nil {
+			log.Fatalf("Error creating clone cache directory: %+v", err)
+		}
+		defer os.RemoveAll(cacheRoot)
+
+		cloneCache, err = pullreq.NewCloneCache(
+			cacheRoot,
+			config.CloneCacheEntries,
+			config.CloneCacheMaxAge,
+		)
+		if err != nil {
+			log.Fatalf("Error creating clone cache: %+v", err)
+		}
+	}
+
 	if config.DogStatsdAddr != "" {
 		datadogClient := datadog.NewClient(config.DogStatsdAddr)
 		stats.Register(datadogClient)
@@ -63,18 +172,84 @@ func main() {
 	}
 
 	router := mux.NewRouter()
-	router.HandleFunc("/webhook", webhookHTTPHandler).Methods("POST")
+	router.HandleFunc("/webhook", limitConcurrency(webhookHTTPHandler)).Methods("POST")
+	router.HandleFunc("/trigger", limitConcurrency(triggerHTTPHandler)).Methods("POST")
+	router.HandleFunc("/healthz", healthzHTTPHandler).Methods("GET")
+	router.HandleFunc("/readyz", readyzHTTPHandler).Methods("GET")
 
 	server := &http.Server{
 		Handler: httpstats.NewHandler(router),
 		Addr:    config.Bind,
 	}
 
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		shutdownOnSignal(server)
+	}()
+
+	atomic.StoreInt32(&ready, 1)
 	log.Infof("Starting server on %s", config.Bind)
 
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatalf("Error running server: %+v", err)
 	}
+
+	// Wait for the in-flight webhooks to drain before flushing stats and exiting
+	<-shutdownDone
+	log.Info("Server shut down")
+}
+
+// shutdownOnSignal waits for a SIGTERM or SIGINT, then stops the server from accepting
+// new requests and waits up to config.ShutdownTimeout for the in-flight ones to finish.
+func shutdownOnSignal(server *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+
+	log.Infof(
+		"Received %s, waiting up to %s for in-flight webhooks to finish",
+		sig,
+		config.ShutdownTimeout,
+	)
+	atomic.StoreInt32(&ready, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warnf("Error shutting down server: %+v", err)
+	}
+}
+
+// limitConcurrency wraps the argument handler so that, if config.MaxConcurrentWebhooks is
+// set, requests beyond the limit are rejected with a 503 instead of being queued. Each
+// webhook can involve a clone and several kubectl runs, so queueing them up during a burst
+// (e.g., from repeated force-pushes) could exhaust the server's disk and CPU.
+func limitConcurrency(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		if webhookSlots == nil {
+			handler(writer, req)
+			return
+		}
+
+		select {
+		case webhookSlots <- struct{}{}:
+			defer func() { <-webhookSlots }()
+			handler(writer, req)
+		default:
+			stats.Incr("webhook.busy")
+			respondWithError(
+				writer,
+				req,
+				503,
+				fmt.Errorf(
+					"Server is busy handling %d webhooks, retry later",
+					config.MaxConcurrentWebhooks,
+				),
+			)
+		}
+	}
 }
 
 func webhookHTTPHandler(
@@ -104,6 +279,7 @@ func webhookHTTPHandler(
 		webhookType,
 		bodyBytes,
 		config.GithubToken,
+		pullRequestClientSettings(),
 	)
 	if err != nil {
 		respondWithError(writer, req, 500, err)
@@ -114,20 +290,98 @@ func webhookHTTPHandler(
 	}
 	defer webhookContext.Close()
 
+	handleWebhookContext(writer, req, webhookContext)
+}
+
+// pullRequestClientSettings returns the settings for the pull request clients created by the
+// webhook and trigger handlers.
+func pullRequestClientSettings() pullreq.GHPullRequestClientSettings {
+	return pullreq.GHPullRequestClientSettings{
+		CloneDepth:     config.CloneDepth,
+		FullClone:      config.FullClone,
+		SparseCheckout: config.SparseCheckout,
+		CloneCache:     cloneCache,
+	}
+}
+
+// triggerHTTPHandler runs a kubeapply command in a pull request based on a JSON-formatted
+// events.TriggerRequest, as if it had been posted as a comment. Requests must have a bearer
+// token that matches config.TriggerToken.
+func triggerHTTPHandler(
+	writer http.ResponseWriter,
+	req *http.Request,
+) {
+	if config.TriggerToken == "" {
+		respondWithError(writer, req, 404, errors.New("Trigger endpoint is not enabled"))
+		return
+	}
+
+	authHeader := req.Header.Get("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == authHeader ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(config.TriggerToken)) != 1 {
+		respondWithError(writer, req, 403, errors.New("Invalid trigger token"))
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		respondWithError(writer, req, 500, err)
+		return
+	}
+	defer req.Body.Close()
+
+	webhookContext, err := events.NewTriggerWebhookContext(
+		bodyBytes,
+		config.GithubToken,
+		pullRequestClientSettings(),
+	)
+	if err != nil {
+		respondWithError(writer, req, 400, err)
+		return
+	}
+	defer webhookContext.Close()
+
+	handleWebhookContext(writer, req, webhookContext)
+}
+
+// handleWebhookContext runs the webhook handler on the argument context and writes out its
+// response.
+func handleWebhookContext(
+	writer http.ResponseWriter,
+	req *http.Request,
+	webhookContext *events.WebhookContext,
+) {
 	webhookHandler := events.NewWebhookHandler(
 		kstats.NewSegmentStatsClient(stats.DefaultEngine),
 		cluster.NewKubeClusterClient,
 		events.WebhookHandlerSettings{
-			LogsURL:               config.LogsURL,
-			Env:                   config.Env,
-			Version:               version.Version,
-			UseLocks:              true,
-			ApplyConsistencyCheck: false,
-			Automerge:             config.Automerge,
-			StrictCheck:           config.StrictCheck,
-			GreenCIRequired:       config.GreenCIRequired,
-			ReviewRequired:        config.ReviewRequired,
-			Debug:                 config.Debug,
+			LogsURL:                 config.LogsURL,
+			Env:                     config.Env,
+			Version:                 version.Version,
+			UseLocks:                true,
+			ApplyConsistencyCheck:   false,
+			Automerge:               config.Automerge,
+			MergeMessageTemplate:    config.MergeMessageTemplate,
+			StrictCheck:             config.StrictCheck,
+			GreenCIRequired:         config.GreenCIRequired,
+			ReviewRequired:          config.ReviewRequired,
+			IncrementalDiffs:        config.IncrementalDiffs,
+			DryRunSummaries:         config.DryRunSummaries,
+			DiffOptional:            config.DiffOptional,
+			ScopeDirectives:         config.ScopeDirectives,
+			ClusterStats:            config.ClusterStats,
+			StatusJSON:              config.StatusJSON,
+			MaxConcurrentApplies:    config.MaxConcurrentApplies,
+			RepoSettings:            repoSettings,
+			Debug:                   config.Debug,
+			SlackWebhookURL:         config.SlackWebhookURL,
+			SlackEnvs:               config.SlackEnvs,
+			DisabledCommands:        config.DisabledCommands,
+			StatusContextPrefix:     config.StatusContextPrefix,
+			SummaryMode:             summaryMode,
+			MaxDiffLinesPerResource: config.MaxDiffLinesPerResource,
+			GroupDiffsByNamespace:   config.GroupDiffsByNamespace,
 		},
 	)
 	response := webhookHandler.HandleWebhook(req.Context(), webhookContext)
@@ -141,6 +395,29 @@ func webhookHTTPHandler(
 	writer.Write([]byte(response.Body))
 }
 
+func healthzHTTPHandler(
+	writer http.ResponseWriter,
+	req *http.Request,
+) {
+	respondWithText(writer, req, 200, "OK")
+}
+
+func readyzHTTPHandler(
+	writer http.ResponseWriter,
+	req *http.Request,
+) {
+	if atomic.LoadInt32(&ready) == 0 {
+		respondWithError(writer, req, 503, errors.New("Server is not ready"))
+		return
+	}
+	if config.GithubToken == "" {
+		respondWithError(writer, req, 503, errors.New("Github token is not set"))
+		return
+	}
+
+	respondWithText(writer, req, 200, "OK")
+}
+
 func respondWithText(
 	writer http.ResponseWriter,
 	req *http.Request,
@@ -159,11 +436,12 @@ func respondWithError(
 	err error,
 ) {
 	log.Warnf(
-		"Error for URI %s [%d]: %+v (type:%s)",
+		"Error for URI %s [%d]: %+v (type:%s, kind:%s)",
 		req.RequestURI,
 		code,
 		err,
 		reflect.TypeOf(err).String(),
+		events.ErrorKindOf(err),
 	)
 	respondWithText(writer, req, code, err.Error())
 }
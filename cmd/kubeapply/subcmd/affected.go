@@ -0,0 +1,181 @@
+package subcmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/go-github/v30/github"
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/pullreq"
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var affectedCmd = &cobra.Command{
+	Use:   "affected [repo root]",
+	Short: "affected shows which clusters and subpaths would be diffed for a set of changed files",
+	Long: `affected runs the same coverage logic as the webhooks to show which clusters
+and subpaths are affected by a set of changed files, without contacting Github.
+
+The changed files, relative to the repo root, can be set via --files, computed from the
+git changes since --base, or read one per line from stdin (e.g., from git diff --name-only).`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: affectedRun,
+}
+
+type affectedFlags struct {
+	// Files that have changed, relative to the repo root
+	files []string
+
+	// Git ref to compute changed files from
+	base string
+
+	// Only consider clusters in this env
+	env string
+
+	// Only consider clusters matching these globs, as in the kubeapply diff comment
+	clusters []string
+
+	// Compute multiple subpaths per cluster instead of just the lowest common parent
+	multiSubpaths bool
+}
+
+var affectedFlagValues affectedFlags
+
+func init() {
+	affectedCmd.Flags().StringSliceVar(
+		&affectedFlagValues.files,
+		"files",
+		[]string{},
+		"Changed files, relative to the repo root",
+	)
+	affectedCmd.Flags().StringVar(
+		&affectedFlagValues.base,
+		"base",
+		"",
+		"Git ref to compare against to get the changed files",
+	)
+	affectedCmd.Flags().StringVar(
+		&affectedFlagValues.env,
+		"env",
+		"",
+		"Only consider clusters in this env",
+	)
+	affectedCmd.Flags().StringSliceVar(
+		&affectedFlagValues.clusters,
+		"clusters",
+		[]string{},
+		"Only consider clusters matching these globs",
+	)
+	affectedCmd.Flags().BoolVar(
+		&affectedFlagValues.multiSubpaths,
+		"multi-subpaths",
+		false,
+		"Compute multiple subpaths per cluster",
+	)
+
+	RootCmd.AddCommand(affectedCmd)
+}
+
+func affectedRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	repoRoot := "."
+	if len(args) > 0 {
+		repoRoot = args[0]
+	}
+	repoRoot, err := filepath.Abs(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	if len(affectedFlagValues.files) > 0 && affectedFlagValues.base != "" {
+		return fmt.Errorf("Cannot set both --files and --base")
+	}
+
+	var files []string
+
+	if len(affectedFlagValues.files) > 0 {
+		files = affectedFlagValues.files
+	} else if affectedFlagValues.base != "" {
+		files, err = util.GetChangedFiles(ctx, repoRoot, affectedFlagValues.base)
+		if err != nil {
+			return err
+		}
+	} else {
+		log.Info("Reading changed files from stdin")
+		files, err = readFileList(os.Stdin)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Debugf("Changed files: %+v", files)
+
+	commitFiles := []*github.CommitFile{}
+	for _, file := range files {
+		commitFiles = append(
+			commitFiles,
+			&github.CommitFile{
+				Filename: github.String(filepath.ToSlash(filepath.Clean(file))),
+			},
+		)
+	}
+
+	coveredClusters, err := pullreq.GetCoveredClusters(
+		repoRoot,
+		commitFiles,
+		affectedFlagValues.env,
+		affectedFlagValues.clusters,
+		"",
+		affectedFlagValues.multiSubpaths,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(coveredClusters) == 0 {
+		log.Infof("No clusters are affected by the %d changed files", len(files))
+		return nil
+	}
+
+	return printAffected(os.Stdout, coveredClusters)
+}
+
+func readFileList(reader io.Reader) ([]string, error) {
+	files := []string{}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, scanner.Err()
+}
+
+func printAffected(out io.Writer, clusterConfigs []*config.ClusterConfig) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "CLUSTER\tCONFIG\tSUBPATHS")
+
+	for _, clusterConfig := range clusterConfigs {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\n",
+			clusterConfig.DescriptiveName(),
+			clusterConfig.RelPath(),
+			strings.Join(clusterConfig.Subpaths, ","),
+		)
+	}
+
+	return writer.Flush()
+}
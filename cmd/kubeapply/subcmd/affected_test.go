@@ -0,0 +1,45 @@
+package subcmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFileList(t *testing.T) {
+	files, err := readFileList(
+		strings.NewReader("clusters/file1.yaml\n\n  clusters/subdir/file2.yaml  \n"),
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"clusters/file1.yaml", "clusters/subdir/file2.yaml"},
+		files,
+	)
+}
+
+func TestPrintAffected(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	err := printAffected(
+		out,
+		[]*config.ClusterConfig{
+			{
+				Cluster:  "cluster1",
+				Region:   "us-west-2",
+				Env:      "stage",
+				Subpaths: []string{"apps", "kube-system"},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, 2, len(lines))
+	assert.Equal(t, []string{"CLUSTER", "CONFIG", "SUBPATHS"}, strings.Fields(lines[0]))
+	assert.Contains(t, lines[1], "apps,kube-system")
+}
@@ -4,17 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/user"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/segmentio/kubeapply/pkg/cluster"
 	"github.com/segmentio/kubeapply/pkg/cluster/apply"
 	"github.com/segmentio/kubeapply/pkg/cluster/diff"
 	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/pullreq"
 	"github.com/segmentio/kubeapply/pkg/util"
-	"github.com/segmentio/kubeapply/pkg/version"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -22,24 +28,70 @@ import (
 var applyCmd = &cobra.Command{
 	Use:   "apply [cluster configs]",
 	Short: "apply runs kubectl apply over the resources associated with a cluster config",
-	Args:  cobra.MinimumNArgs(1),
 	RunE:  applyRun,
 }
 
 type applyFlags struct {
+	// Whether to take ownership of resources that already exist in the cluster
+	adopt bool
+
+	// Only apply in clusters whose descriptive names match these globs. If unset, applies in
+	// all clusters.
+	clusters []string
+
+	// Whether to create the namespaces referenced by the applied resources if they don't
+	// already exist
+	createNamespaces bool
+
+	// Whether to stop after validating and diffing, without prompting or applying
+	diffOnly bool
+
+	// Skip resources in these namespaces, in addition to the ones excluded in the cluster
+	// config.
+	excludeNamespaces []string
+
 	// Whether to expand before applying.
 	expand bool
 
+	// Directory with the expanded configs to apply instead of the expanded path in the cluster
+	// config. Can only be used with a single cluster config.
+	expandedDir string
+
+	// Path to an artifact created by "kubeapply package". If set, the expanded configs in the
+	// artifact are applied instead of the ones in the repo.
+	fromArtifact string
+
+	// Whether to proceed, with a warning, if the cluster config's version constraint
+	// isn't satisfied by this kubeapply binary
+	ignoreVersionConstraint bool
+
 	// Whether to keep around temporary, intermediate configs that are used
 	// for actual apply.
 	keepConfigs bool
 
+	// Only consider resources of these kinds. If unset, considers all kinds.
+	kinds []string
+
 	// Path to kubeconfig. If unset, tries to fetch from the environment.
 	kubeConfig string
 
+	// Only consider resources with these names. Globs are allowed. If unset, considers
+	// all names.
+	names []string
+
 	// Whether to just apply without checking anything
 	noCheck bool
 
+	// Whether to check that the current user has the RBAC permissions needed for the apply
+	// before starting it
+	preflightRBAC bool
+
+	// Whether to annotate applied resources with the current user, git SHA, and time
+	record bool
+
+	// Whether to list the matched clusters and prompt for the ones to apply in
+	selectClusters bool
+
 	// Whether to just run "kubectl apply" with the default output options
 	simpleOutput bool
 
@@ -47,6 +99,10 @@ type applyFlags struct {
 	// (typically maps to namespace). Globs are allowed. If unset, considers all configs.
 	subpaths []string
 
+	// How long to wait for the rollouts of applied workloads to complete. If unset, doesn't
+	// wait.
+	waitForRollout time.Duration
+
 	// Whether to accept all prompts automatically; does not apply if
 	// noCheck is enabled
 	yes bool
@@ -55,23 +111,83 @@ type applyFlags struct {
 var applyFlagValues applyFlags
 
 func init() {
+	applyCmd.Flags().BoolVar(
+		&applyFlagValues.adopt,
+		"adopt",
+		false,
+		"Take ownership of existing resources via a server-side apply with conflicts forced",
+	)
+	applyCmd.Flags().StringArrayVar(
+		&applyFlagValues.clusters,
+		"cluster",
+		[]string{},
+		"Apply in clusters whose names (env:region:cluster) match the provided glob(s) only",
+	)
+	applyCmd.Flags().BoolVar(
+		&applyFlagValues.createNamespaces,
+		"create-namespaces",
+		false,
+		"Create the namespaces referenced by the applied resources if they don't exist",
+	)
+	applyCmd.Flags().BoolVar(
+		&applyFlagValues.diffOnly,
+		"diff-only",
+		false,
+		"Validate and show the diff that would be applied, then exit without applying",
+	)
+	applyCmd.Flags().StringArrayVar(
+		&applyFlagValues.excludeNamespaces,
+		"exclude-namespace",
+		[]string{},
+		"Skip resources in the provided namespace(s)",
+	)
 	applyCmd.Flags().BoolVar(
 		&applyFlagValues.expand,
 		"expand",
 		false,
 		"Expand before applying",
 	)
+	applyCmd.Flags().StringVar(
+		&applyFlagValues.expandedDir,
+		"expanded-dir",
+		"",
+		"Apply the expanded configs in this directory (e.g., from expand --output-dir) instead of the expanded path in the cluster config",
+	)
+	applyCmd.Flags().StringVar(
+		&applyFlagValues.fromArtifact,
+		"from-artifact",
+		"",
+		"Apply the expanded configs in the provided artifact from kubeapply package",
+	)
+	applyCmd.Flags().BoolVar(
+		&applyFlagValues.ignoreVersionConstraint,
+		"ignore-version-constraint",
+		false,
+		"Proceed, with a warning, if the version constraint in the cluster config isn't satisfied",
+	)
 	applyCmd.Flags().BoolVar(
 		&applyFlagValues.keepConfigs,
 		"keep-configs",
 		false,
 		"Whether to keep around intermediate configs for easier debugging",
 	)
+	applyCmd.Flags().StringArrayVar(
+		&applyFlagValues.kinds,
+		"kind",
+		[]string{},
+		"Apply resources of the provided kind(s) only",
+	)
 	applyCmd.Flags().StringVar(
 		&applyFlagValues.kubeConfig,
 		"kubeconfig",
 		"",
-		"Path to kubeconfig",
+		"Path to kubeconfig; multiple paths can be separated with colons, as in KUBECONFIG",
+	)
+	applyCmd.Flags().StringArrayVar(
+		&applyFlagValues.names,
+		"name",
+		[]string{},
+		"Apply resources with the provided name(s) only; globs are allowed",
 	)
 	applyCmd.Flags().BoolVar(
 		&applyFlagValues.noCheck,
@@ -79,6 +195,24 @@ func init() {
 		false,
 		"Skip all checks and just apply",
 	)
+	applyCmd.Flags().BoolVar(
+		&applyFlagValues.preflightRBAC,
+		"preflight-rbac",
+		false,
+		"Check that the current user can create and update the applied resource types before applying",
+	)
+	applyCmd.Flags().BoolVar(
+		&applyFlagValues.record,
+		"record",
+		false,
+		"Annotate applied resources with the current user, git SHA, and time",
+	)
+	applyCmd.Flags().BoolVar(
+		&applyFlagValues.selectClusters,
+		"select",
+		false,
+		"List the matched clusters and choose the ones to apply in before proceeding",
+	)
 	applyCmd.Flags().BoolVar(
 		&applyFlagValues.simpleOutput,
 		"simple-output",
@@ -91,6 +225,12 @@ func init() {
 		[]string{},
 		"Apply for expanded configs in the provided subpath(s) only",
 	)
+	applyCmd.Flags().DurationVar(
+		&applyFlagValues.waitForRollout,
+		"wait-for-rollout",
+		0,
+		"Wait up to the provided duration for applied workloads to roll out; fail if they don't",
+	)
 	applyCmd.Flags().BoolVarP(
 		&applyFlagValues.yes,
 		"yes",
@@ -105,37 +245,298 @@ func init() {
 func applyRun(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
+	if applyFlagValues.diffOnly && applyFlagValues.noCheck {
+		return errors.New("Cannot set both --diff-only and --no-check")
+	}
+	if applyFlagValues.diffOnly && applyFlagValues.createNamespaces {
+		return errors.New("Cannot set both --diff-only and --create-namespaces")
+	}
+	if applyFlagValues.waitForRollout < 0 {
+		return errors.New("--wait-for-rollout must not be negative")
+	}
+
+	if applyFlagValues.fromArtifact != "" {
+		if applyFlagValues.expand {
+			return errors.New("Cannot set both --from-artifact and --expand")
+		}
+		if applyFlagValues.expandedDir != "" {
+			return errors.New("Cannot set both --from-artifact and --expanded-dir")
+		}
+		if applyFlagValues.selectClusters {
+			return errors.New("Cannot set both --from-artifact and --select")
+		}
+		if len(args) > 1 {
+			return ErrTooManyArguments
+		}
+		return applyArtifact(ctx, args)
+	} else if len(args) == 0 {
+		return ErrTooFewArguments
+	}
+
+	allPaths := []string{}
+
 	for _, arg := range args {
-		paths, err := filepath.Glob(arg)
+		paths, err := clusterConfigPaths(arg)
 		if err != nil {
 			return err
 		}
+		allPaths = append(allPaths, paths...)
+	}
 
-		for _, path := range paths {
-			if err := applyClusterPath(ctx, path); err != nil {
-				return err
-			}
+	if applyFlagValues.selectClusters {
+		var err error
+		allPaths, err = selectClusterPaths(allPaths)
+		if err != nil {
+			return err
+		}
+	}
+
+	if applyFlagValues.expandedDir != "" && len(allPaths) != 1 {
+		return errors.New("--expanded-dir can only be used with a single cluster config")
+	}
+
+	for _, path := range allPaths {
+		if err := applyClusterPath(ctx, path); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// selectClusterPaths lists the clusters for the argument config paths and prompts the user to
+// choose the ones to apply in. The paths of the chosen clusters are returned.
+func selectClusterPaths(paths []string) ([]string, error) {
+	selectablePaths := []string{}
+	clusterConfigs := []*config.ClusterConfig{}
+
+	for _, path := range paths {
+		clusterConfig, err := config.LoadClusterConfig(path, "")
+		if err != nil {
+			return nil, err
+		}
+
+		selected, err := clusterSelected(clusterConfig, applyFlagValues.clusters)
+		if err != nil {
+			return nil, err
+		} else if !selected {
+			continue
+		}
+
+		selectablePaths = append(selectablePaths, path)
+		clusterConfigs = append(clusterConfigs, clusterConfig)
+	}
+
+	if len(clusterConfigs) == 0 {
+		log.Info("No clusters to select from")
+		return []string{}, nil
+	}
+
+	fmt.Println("Matched clusters:")
+	for c, clusterConfig := range clusterConfigs {
+		fmt.Printf("  [%d] %s\n", c+1, clusterConfig.DescriptiveName())
+	}
+	fmt.Print("Clusters to apply in (indices and/or globs, separated by commas; 'all' for all): ")
+
+	input, err := readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	indices, err := parseClusterSelection(input, clusterConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedPaths := []string{}
+	selectedNames := []string{}
+
+	for _, index := range indices {
+		selectedPaths = append(selectedPaths, selectablePaths[index])
+		selectedNames = append(selectedNames, clusterConfigs[index].DescriptiveName())
+	}
+
+	if len(selectedPaths) == 0 {
+		log.Info("No clusters selected, not continuing")
+	} else {
+		log.Infof("Selected clusters: %s", strings.Join(selectedNames, ", "))
+	}
+
+	return selectedPaths, nil
+}
+
+// parseClusterSelection parses the user's response to the cluster selection prompt into the
+// sorted indices of the selected clusters. The response is a comma or space-separated list
+// of 1-based indices, globs that are matched against the clusters' descriptive names, or
+// "all".
+func parseClusterSelection(
+	input string,
+	clusterConfigs []*config.ClusterConfig,
+) ([]int, error) {
+	selected := map[int]struct{}{}
+
+	tokens := strings.FieldsFunc(
+		input,
+		func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		},
+	)
+
+	for _, token := range tokens {
+		if token == "all" {
+			for c := range clusterConfigs {
+				selected[c] = struct{}{}
+			}
+			continue
+		}
+
+		if index, err := strconv.Atoi(token); err == nil {
+			if index < 1 || index > len(clusterConfigs) {
+				return nil, fmt.Errorf(
+					"Cluster index %d is out of range; must be between 1 and %d",
+					index,
+					len(clusterConfigs),
+				)
+			}
+			selected[index-1] = struct{}{}
+			continue
+		}
+
+		clusterGlobs, err := pullreq.CompileClusterGlobs([]string{token})
+		if err != nil {
+			return nil, err
+		}
+
+		var matched bool
+		for c, clusterConfig := range clusterConfigs {
+			if pullreq.MatchesClusterGlobs(clusterConfig, clusterGlobs) {
+				selected[c] = struct{}{}
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("Glob %s does not match any of the clusters", token)
+		}
+	}
+
+	indices := []int{}
+	for index := range selected {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	return indices, nil
+}
+
+// readLine reads a single line from stdin. This reads a byte at a time, instead of buffering,
+// so that later prompts can still read from stdin.
+func readLine() (string, error) {
+	line := []byte{}
+	buf := make([]byte, 1)
+
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSpace(string(line)), nil
+}
+
 func applyClusterPath(ctx context.Context, path string) error {
 	clusterConfig, err := config.LoadClusterConfig(path, "")
 	if err != nil {
 		return err
 	}
-	if err := clusterConfig.CheckVersion(version.Version); err != nil {
+
+	selected, err := clusterSelected(clusterConfig, applyFlagValues.clusters)
+	if err != nil {
+		return err
+	} else if !selected {
+		return nil
+	}
+
+	err = checkClusterVersion(clusterConfig, applyFlagValues.ignoreVersionConstraint)
+	if err != nil {
 		return err
 	}
 
+	if applyFlagValues.expandedDir != "" {
+		if err := overrideExpandedPath(clusterConfig, applyFlagValues.expandedDir); err != nil {
+			return err
+		}
+	}
+
 	if applyFlagValues.expand {
 		if err := expandCluster(ctx, clusterConfig, false); err != nil {
 			return err
 		}
 	}
 
+	return applyCluster(ctx, clusterConfig)
+}
+
+// applyArtifact applies the expanded configs in the artifact set via --from-artifact. The
+// cluster config in the artifact is used unless a path to one is provided; in either case,
+// the config must be for the same cluster that the artifact was created for.
+func applyArtifact(ctx context.Context, configPaths []string) error {
+	tempDir, err := util.TempDir("artifact")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	log.Infof("Extracting artifact %s", applyFlagValues.fromArtifact)
+	metadata, err := util.ExtractArtifact(ctx, applyFlagValues.fromArtifact, tempDir)
+	if err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(tempDir, util.ArtifactConfigFile)
+	if len(configPaths) > 0 {
+		configPath = configPaths[0]
+	}
+
+	clusterConfig, err := config.LoadClusterConfig(configPath, "")
+	if err != nil {
+		return err
+	}
+	if clusterConfig.DescriptiveName() != metadata.Cluster {
+		return fmt.Errorf(
+			"Artifact %s was created for cluster %s, not %s",
+			applyFlagValues.fromArtifact,
+			metadata.Cluster,
+			clusterConfig.DescriptiveName(),
+		)
+	}
+
+	err = checkClusterVersion(clusterConfig, applyFlagValues.ignoreVersionConstraint)
+	if err != nil {
+		return err
+	}
+
+	clusterConfig.ExpandedPath = filepath.Join(tempDir, util.ArtifactExpandedDir)
+	return applyCluster(ctx, clusterConfig)
+}
+
+// applyCluster applies the expanded configs for the argument cluster.
+func applyCluster(ctx context.Context, clusterConfig *config.ClusterConfig) error {
+	if clusterConfig.DisableApply && !applyFlagValues.diffOnly {
+		return fmt.Errorf(
+			"Applies are disabled for cluster %s via disableApply in its config; use kubeapply diff or --diff-only instead",
+			clusterConfig.DescriptiveName(),
+		)
+	}
+
 	log.Infof("Applying cluster %s", clusterConfig.DescriptiveName())
 
 	ok, err := util.DirExists(clusterConfig.ExpandedPath)
@@ -157,17 +558,44 @@ func applyClusterPath(ctx context.Context, path string) error {
 		}
 	}
 
+	kubeConfigPaths := kubeConfig
+	kubeConfig, cleanup, err := kube.MergeKubeconfigs(kubeConfigPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
 	matches := kube.KubeconfigMatchesCluster(kubeConfig, clusterConfig.Cluster)
 	if !matches {
 		return fmt.Errorf(
 			"Kubeconfig in %s does not appear to reference cluster %s",
-			kubeConfig,
+			kubeConfigPaths,
 			clusterConfig.Cluster,
 		)
 	}
 
 	clusterConfig.KubeConfigPath = kubeConfig
 	clusterConfig.Subpaths = applyFlagValues.subpaths
+	clusterConfig.KindFilters = applyFlagValues.kinds
+	clusterConfig.NameFilters = applyFlagValues.names
+	clusterConfig.CreateNamespaces = applyFlagValues.createNamespaces
+	clusterConfig.RolloutTimeout = applyFlagValues.waitForRollout
+	if applyFlagValues.adopt {
+		clusterConfig.AdoptExisting = true
+	}
+	clusterConfig.ExcludeNamespaces = append(
+		clusterConfig.ExcludeNamespaces,
+		applyFlagValues.excludeNamespaces...,
+	)
+	if applyFlagValues.record {
+		clusterConfig.RecordApply = true
+	}
+
+	if applyFlagValues.preflightRBAC {
+		if err := preflightRBAC(ctx, clusterConfig); err != nil {
+			return err
+		}
+	}
 
 	if !applyFlagValues.noCheck {
 		err := execValidation(ctx, clusterConfig)
@@ -175,7 +603,7 @@ func applyClusterPath(ctx context.Context, path string) error {
 			return err
 		}
 
-		results, rawDiffs, err := execDiff(ctx, clusterConfig, applyFlagValues.simpleOutput)
+		results, rawDiffs, err := execDiff(ctx, clusterConfig, applyFlagValues.simpleOutput, "")
 		if err != nil {
 			log.Errorf("Error running diff: %+v", err)
 			log.Info(
@@ -185,12 +613,15 @@ func applyClusterPath(ctx context.Context, path string) error {
 		}
 
 		if results != nil {
-			diff.PrintFull(results)
+			diff.PrintFull(results, useColors())
 		} else {
 			log.Infof("Raw diff results:\n%s", rawDiffs)
 		}
 
-		if !applyFlagValues.yes {
+		if applyFlagValues.diffOnly {
+			log.Infof("Not applying because --diff-only is true")
+			return nil
+		} else if !applyFlagValues.yes {
 			fmt.Print("Are you sure? (yes/no) ")
 			var response string
 			_, err = fmt.Scanln(&response)
@@ -208,12 +639,22 @@ func applyClusterPath(ctx context.Context, path string) error {
 		log.Warn("Skipping checks because --no-check is true")
 	}
 
+	var appliedBy string
+	var headSHA string
+
+	if clusterConfig.RecordApply {
+		appliedBy, headSHA = applyRecordInfo(ctx, clusterConfig)
+	}
+
 	kubeClient, err := cluster.NewKubeClusterClient(
 		ctx,
 		&cluster.ClusterClientConfig{
+			Actor:                 appliedBy,
+			HeadSHA:               headSHA,
 			CheckApplyConsistency: false,
 			ClusterConfig:         clusterConfig,
 			Debug:                 debug,
+			Quiet:                 quiet,
 			KeepConfigs:           applyFlagValues.keepConfigs,
 			StreamingOutput:       applyFlagValues.simpleOutput,
 			// TODO: Make locking an option
@@ -249,3 +690,71 @@ func applyClusterPath(ctx context.Context, path string) error {
 
 	return nil
 }
+
+// preflightRBAC checks that the user in the cluster config's kubeconfig has the permissions
+// needed to apply all of the selected manifests, returning an error that lists the missing
+// ones if not.
+func preflightRBAC(ctx context.Context, clusterConfig *config.ClusterConfig) error {
+	log.Infof("Checking RBAC permissions in cluster %s", clusterConfig.DescriptiveName())
+
+	manifests, err := kube.GetManifests(clusterConfig.AbsSubpaths())
+	if err != nil {
+		return err
+	}
+	manifests = kube.FilterManifests(
+		manifests,
+		kube.ManifestFilter{
+			Kinds: clusterConfig.KindFilters,
+			Names: clusterConfig.NameFilters,
+
+			ExcludeNamespaces: clusterConfig.ExcludeNamespaces,
+		},
+	)
+
+	denied, err := kube.DeniedPermissions(
+		ctx,
+		clusterConfig.KubeConfigPath,
+		kube.ManifestPermissionChecks(manifests),
+	)
+	if err != nil {
+		return err
+	}
+	if len(denied) > 0 {
+		deniedStrs := []string{}
+		for _, check := range denied {
+			deniedStrs = append(deniedStrs, check.String())
+		}
+
+		return fmt.Errorf(
+			"Missing permissions needed for apply in cluster %s:\n  %s",
+			clusterConfig.DescriptiveName(),
+			strings.Join(deniedStrs, "\n  "),
+		)
+	}
+
+	log.Info("All RBAC permissions needed for apply are present")
+	return nil
+}
+
+// applyRecordInfo returns the current OS user and git SHA for recording applies. Errors are
+// logged and result in the associated value being omitted.
+func applyRecordInfo(
+	ctx context.Context,
+	clusterConfig *config.ClusterConfig,
+) (string, string) {
+	var appliedBy string
+
+	currentUser, err := user.Current()
+	if err != nil {
+		log.Warnf("Could not get current user for recording apply: %+v", err)
+	} else {
+		appliedBy = currentUser.Username
+	}
+
+	headSHA, err := util.GetHeadSHA(ctx, filepath.Dir(clusterConfig.FullPath()))
+	if err != nil {
+		log.Warnf("Could not get git SHA for recording apply: %+v", err)
+	}
+
+	return appliedBy, headSHA
+}
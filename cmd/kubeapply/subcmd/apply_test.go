@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/segmentio/kubeapply/pkg/config"
 	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -57,6 +58,43 @@ func TestApply(t *testing.T) {
 	assert.Equal(t, 1, len(services))
 }
 
+func TestApplyDiffOnly(t *testing.T) {
+	if !util.KindEnabled() {
+		t.Skipf("Skipping because kind is not enabled")
+	}
+
+	ctx := context.Background()
+
+	namespace := fmt.Sprintf("test-apply-diff-only-%d", time.Now().UnixNano()/1000)
+	util.CreateNamespace(ctx, t, namespace, kubeConfigTestPath)
+
+	tempDir, err := ioutil.TempDir("", "apply")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	clusterDir := filepath.Join(tempDir, "cluster")
+	err = util.RecursiveCopy("testdata/clusters/apply-test", clusterDir)
+	require.Nil(t, err)
+
+	replaceNamespace(t, filepath.Join(clusterDir, "expanded"), namespace)
+
+	applyFlagValues.noCheck = false
+	applyFlagValues.diffOnly = true
+	applyFlagValues.kubeConfig = kubeConfigTestPath
+	defer func() {
+		applyFlagValues.diffOnly = false
+	}()
+
+	err = applyClusterPath(
+		ctx,
+		filepath.Join(clusterDir, "cluster.yaml"),
+	)
+	require.Nil(t, err)
+
+	deployments := util.GetResources(ctx, t, "deployments", namespace, kubeConfigTestPath)
+	assert.Equal(t, 0, len(deployments))
+}
+
 func replaceNamespace(t *testing.T, root string, namespace string) {
 	err := filepath.Walk(
 		root,
@@ -85,3 +123,78 @@ func replaceNamespace(t *testing.T, root string, namespace string) {
 	)
 	require.Nil(t, err)
 }
+
+func TestParseClusterSelection(t *testing.T) {
+	type testCase struct {
+		description string
+		input       string
+		expIndices  []int
+		expErr      bool
+	}
+
+	clusterConfigs := []*config.ClusterConfig{
+		{Env: "stage", Region: "us-west-2", Cluster: "cluster1"},
+		{Env: "stage", Region: "us-west-2", Cluster: "cluster2"},
+		{Env: "production", Region: "us-west-2", Cluster: "cluster1"},
+		{Env: "production", Region: "eu-west-1", Cluster: "cluster1"},
+	}
+	for c, clusterConfig := range clusterConfigs {
+		require.NoError(
+			t,
+			clusterConfig.SetDefaults(fmt.Sprintf("/git/repo/clusters/%d.yaml", c), "/git/repo"),
+		)
+	}
+
+	testCases := []testCase{
+		{
+			description: "empty",
+			input:       "",
+			expIndices:  []int{},
+		},
+		{
+			description: "indices",
+			input:       "3, 1",
+			expIndices:  []int{0, 2},
+		},
+		{
+			description: "globs",
+			input:       "stage:*,*:eu-west-1:*",
+			expIndices:  []int{0, 1, 3},
+		},
+		{
+			description: "indices and globs with overlap",
+			input:       "1 stage:us-west-2:cluster*",
+			expIndices:  []int{0, 1},
+		},
+		{
+			description: "all",
+			input:       "all",
+			expIndices:  []int{0, 1, 2, 3},
+		},
+		{
+			description: "index out of range",
+			input:       "5",
+			expErr:      true,
+		},
+		{
+			description: "zero index",
+			input:       "0",
+			expErr:      true,
+		},
+		{
+			description: "glob without matches",
+			input:       "1,development:*",
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		indices, err := parseClusterSelection(testCase.input, clusterConfigs)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			require.NoError(t, err, testCase.description)
+			assert.Equal(t, testCase.expIndices, indices, testCase.description)
+		}
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"time"
 
 	"github.com/briandowns/spinner"
+	"github.com/google/go-github/v30/github"
 	"github.com/segmentio/kubeapply/pkg/cluster"
 	"github.com/segmentio/kubeapply/pkg/cluster/diff"
 	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/pullreq"
 	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/segmentio/kubeapply/pkg/version"
 	log "github.com/sirupsen/logrus"
@@ -32,12 +34,47 @@ var diffCmd = &cobra.Command{
 }
 
 type diffFlags struct {
+	// External command to generate the raw diffs with instead of "diff -u -N". Implies
+	// simpleOutput.
+	differ string
+
+	// Skip resources in these namespaces, in addition to the ones excluded in the cluster
+	// config.
+	excludeNamespaces []string
+
 	// Expand before running diff.
 	expand bool
 
+	// Directory with the expanded configs to diff instead of the expanded path in the cluster
+	// config. Can only be used with a single cluster config.
+	expandedDir string
+
+	// Whether to group the diff output by namespace
+	groupByNamespace bool
+
+	// Whether to exclude helm hooks from the structured diff output
+	ignoreHelmHooks bool
+
+	// Only consider resources of these kinds. If unset, considers all kinds.
+	kinds []string
+
 	// Path to kubeconfig. If unset, tries to fetch from the environment.
 	kubeConfig string
 
+	// Only consider resources with these names. Globs are allowed. If unset, considers
+	// all names.
+	names []string
+
+	// Whether to do a server-side diff even if the cluster doesn't use server-side applies
+	serverSideDiff bool
+
+	// Only diff the clusters and subpaths affected by the git changes since this ref, as in
+	// the webhooks. If unset, diffs everything.
+	sinceRef string
+
+	// Whether to keep managedFields in the structured diff output
+	showManagedFields bool
+
 	// Whether to just run "kubectl diff" with the default output options
 	simpleOutput bool
 
@@ -49,17 +86,77 @@ type diffFlags struct {
 var diffFlagValues diffFlags
 
 func init() {
+	diffCmd.Flags().StringVar(
+		&diffFlagValues.differ,
+		"differ",
+		"",
+		"External command (e.g., difft) to generate raw diffs with; implies --simple-output",
+	)
+	diffCmd.Flags().StringArrayVar(
+		&diffFlagValues.excludeNamespaces,
+		"exclude-namespace",
+		[]string{},
+		"Skip resources in the provided namespace(s)",
+	)
 	diffCmd.Flags().BoolVar(
 		&diffFlagValues.expand,
 		"expand",
 		false,
 		"Expand before running diff",
 	)
+	diffCmd.Flags().StringVar(
+		&diffFlagValues.expandedDir,
+		"expanded-dir",
+		"",
+		"Diff the expanded configs in this directory (e.g., from expand --output-dir) instead of the expanded path in the cluster config",
+	)
+	diffCmd.Flags().BoolVar(
+		&diffFlagValues.groupByNamespace,
+		"group-by-namespace",
+		false,
+		"Group the diff summaries and raw diffs by namespace",
+	)
+	diffCmd.Flags().BoolVar(
+		&diffFlagValues.ignoreHelmHooks,
+		"ignore-helm-hooks",
+		true,
+		"Exclude resources with a helm.sh/hook annotation from diffs in clusters with helm charts",
+	)
+	diffCmd.Flags().StringArrayVar(
+		&diffFlagValues.kinds,
+		"kind",
+		[]string{},
+		"Diff resources of the provided kind(s) only",
+	)
 	diffCmd.Flags().StringVar(
 		&diffFlagValues.kubeConfig,
 		"kubeconfig",
 		"",
-		"Path to kubeconfig",
+		"Path to kubeconfig; multiple paths can be separated with colons, as in KUBECONFIG",
+	)
+	diffCmd.Flags().StringArrayVar(
+		&diffFlagValues.names,
+		"name",
+		[]string{},
+		"Diff resources with the provided name(s) only; globs are allowed",
+	)
+	diffCmd.Flags().BoolVar(
+		&diffFlagValues.serverSideDiff,
+		"server-side-diff",
+		false,
+		"Run diff server-side regardless of apply mode; includes admission webhook effects",
+	)
+	diffCmd.Flags().StringVar(
+		&diffFlagValues.sinceRef,
+		"since-ref",
+		"",
+		"Only diff the clusters and subpaths affected by the git changes since the provided ref",
+	)
+	diffCmd.Flags().BoolVar(
+		&diffFlagValues.showManagedFields,
+		"show-managed-fields",
+		false,
+		"Show managedFields in diffs; useful for debugging server-side apply conflicts",
 	)
 	diffCmd.Flags().BoolVar(
 		&diffFlagValues.simpleOutput,
@@ -80,16 +177,30 @@ func init() {
 func diffRun(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
+	if diffFlagValues.sinceRef != "" && len(diffFlagValues.subpaths) > 0 {
+		return errors.New("Cannot set both --since-ref and --subpath")
+	}
+	if diffFlagValues.sinceRef != "" && diffFlagValues.expandedDir != "" {
+		return errors.New("Cannot set both --since-ref and --expanded-dir")
+	}
+
+	allPaths := []string{}
+
 	for _, arg := range args {
-		paths, err := filepath.Glob(arg)
+		paths, err := clusterConfigPaths(arg)
 		if err != nil {
 			return err
 		}
+		allPaths = append(allPaths, paths...)
+	}
+
+	if diffFlagValues.expandedDir != "" && len(allPaths) != 1 {
+		return errors.New("--expanded-dir can only be used with a single cluster config")
+	}
 
-		for _, path := range paths {
-			if err := diffClusterPath(ctx, path); err != nil {
-				return err
-			}
+	for _, path := range allPaths {
+		if err := diffClusterPath(ctx, path); err != nil {
+			return err
 		}
 	}
 
@@ -104,6 +215,28 @@ func diffClusterPath(ctx context.Context, path string) error {
 	if err := clusterConfig.CheckVersion(version.Version); err != nil {
 		return err
 	}
+	if diffFlagValues.expandedDir != "" {
+		if err := overrideExpandedPath(clusterConfig, diffFlagValues.expandedDir); err != nil {
+			return err
+		}
+	}
+
+	subpaths := diffFlagValues.subpaths
+
+	if diffFlagValues.sinceRef != "" {
+		var covered bool
+		subpaths, covered, err = changedSubpaths(ctx, clusterConfig, diffFlagValues.sinceRef)
+		if err != nil {
+			return err
+		} else if !covered {
+			log.Infof(
+				"Skipping cluster %s because it's not affected by the changes since %s",
+				clusterConfig.DescriptiveName(),
+				diffFlagValues.sinceRef,
+			)
+			return nil
+		}
+	}
 
 	if diffFlagValues.expand {
 		if err := expandCluster(ctx, clusterConfig, false); err != nil {
@@ -132,19 +265,46 @@ func diffClusterPath(ctx context.Context, path string) error {
 		}
 	}
 
+	kubeConfigPaths := kubeConfig
+	kubeConfig, cleanup, err := kube.MergeKubeconfigs(kubeConfigPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
 	matches := kube.KubeconfigMatchesCluster(kubeConfig, clusterConfig.Cluster)
 	if !matches {
 		return fmt.Errorf(
 			"Kubeconfig in %s does not appear to reference cluster %s",
-			kubeConfig,
+			kubeConfigPaths,
 			clusterConfig.Cluster,
 		)
 	}
 
 	clusterConfig.KubeConfigPath = kubeConfig
-	clusterConfig.Subpaths = diffFlagValues.subpaths
+	clusterConfig.Subpaths = subpaths
+	clusterConfig.KindFilters = diffFlagValues.kinds
+	clusterConfig.NameFilters = diffFlagValues.names
+	clusterConfig.ExcludeNamespaces = append(
+		clusterConfig.ExcludeNamespaces,
+		diffFlagValues.excludeNamespaces...,
+	)
+	if diffFlagValues.showManagedFields {
+		clusterConfig.ShowManagedFields = true
+	}
+	if !diffFlagValues.ignoreHelmHooks {
+		clusterConfig.ShowHelmHooks = true
+	}
+	if diffFlagValues.serverSideDiff {
+		clusterConfig.ServerSideDiff = true
+	}
 
-	results, rawDiffs, err := execDiff(ctx, clusterConfig, diffFlagValues.simpleOutput)
+	results, rawDiffs, err := execDiff(
+		ctx,
+		clusterConfig,
+		diffFlagValues.simpleOutput || diffFlagValues.differ != "",
+		diffFlagValues.differ,
+	)
 	if err != nil {
 		log.Errorf("Error running diff: %+v", err)
 		log.Info(
@@ -153,8 +313,10 @@ func diffClusterPath(ctx context.Context, path string) error {
 		return err
 	}
 
-	if results != nil {
-		diff.PrintFull(results)
+	if results != nil && diffFlagValues.groupByNamespace {
+		diff.PrintFullByNamespace(results, useColors())
+	} else if results != nil {
+		diff.PrintFull(results, useColors())
 	} else {
 		log.Infof("Raw diff results:\n%s", rawDiffs)
 	}
@@ -162,10 +324,74 @@ func diffClusterPath(ctx context.Context, path string) error {
 	return nil
 }
 
+// changedSubpaths returns the subpaths of the argument cluster that are affected by the git
+// changes since the argument ref, using the same coverage logic as the webhooks. The second
+// return value is false if the cluster isn't affected at all.
+func changedSubpaths(
+	ctx context.Context,
+	clusterConfig *config.ClusterConfig,
+	sinceRef string,
+) ([]string, bool, error) {
+	repoRoot, err := util.GetRepoRoot(ctx, filepath.Dir(clusterConfig.FullPath()))
+	if err != nil {
+		return nil, false, err
+	}
+
+	files, err := util.GetChangedFiles(ctx, repoRoot, sinceRef)
+	if err != nil {
+		return nil, false, err
+	}
+	log.Debugf("Changed files since %s: %+v", sinceRef, files)
+
+	commitFiles := []*github.CommitFile{}
+	for _, file := range files {
+		commitFiles = append(
+			commitFiles,
+			&github.CommitFile{
+				Filename: github.String(filepath.ToSlash(filepath.Clean(file))),
+			},
+		)
+	}
+
+	coveredClusters, err := pullreq.GetCoveredClusters(
+		repoRoot,
+		commitFiles,
+		"",
+		[]string{},
+		"",
+		false,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	configPath, err := filepath.Abs(clusterConfig.FullPath())
+	if err != nil {
+		return nil, false, err
+	}
+	configPath, err = filepath.EvalSymlinks(configPath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, coveredCluster := range coveredClusters {
+		coveredPath, err := filepath.EvalSymlinks(coveredCluster.FullPath())
+		if err != nil {
+			return nil, false, err
+		}
+		if coveredPath == configPath {
+			return coveredCluster.Subpaths, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
 func execDiff(
 	ctx context.Context,
 	clusterConfig *config.ClusterConfig,
 	simpleOutput bool,
+	differ string,
 ) ([]diff.Result, string, error) {
 	log.Info("Generating diff against versions in Kube API")
 
@@ -183,6 +409,8 @@ func execDiff(
 			CheckApplyConsistency: false,
 			ClusterConfig:         clusterConfig,
 			Debug:                 debug,
+			Differ:                differ,
+			Quiet:                 quiet,
 			SpinnerObj:            spinnerObj,
 			// TODO: Make locking an option
 			UseLocks: false,
@@ -214,7 +442,7 @@ func execDiff(
 		rawResults, err := kubeClient.Diff(
 			ctx,
 			clusterConfig.AbsSubpaths(),
-			clusterConfig.ServerSideApply,
+			clusterConfig.UseServerSideDiff(),
 		)
 		return nil, string(rawResults), err
 	}
@@ -222,8 +450,19 @@ func execDiff(
 	results, err := kubeClient.DiffStructured(
 		ctx,
 		clusterConfig.AbsSubpaths(),
-		clusterConfig.ServerSideApply,
+		clusterConfig.UseServerSideDiff(),
 		"",
 	)
-	return results, "", err
+	if err != nil {
+		return nil, "", err
+	}
+
+	if results.HiddenHelmHooks > 0 {
+		log.Infof(
+			"Hid diffs in %d helm hook resource(s); set showHelmHooks in the cluster config or run kubeapply diff with --ignore-helm-hooks=false to show them",
+			results.HiddenHelmHooks,
+		)
+	}
+
+	return results.Results, "", nil
 }
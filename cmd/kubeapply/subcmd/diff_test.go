@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/segmentio/kubeapply/pkg/config"
 	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -50,3 +53,64 @@ func TestDiff(t *testing.T) {
 	)
 	require.Nil(t, err)
 }
+
+func TestChangedSubpaths(t *testing.T) {
+	ctx := context.Background()
+
+	repoDir, err := ioutil.TempDir("", "repo")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	util.WriteFiles(
+		t,
+		repoDir,
+		map[string]string{
+			"clusters/cluster1/cluster.yaml":                           "cluster: cluster1\nregion: region\nenv: stage\n",
+			"clusters/cluster1/expanded/stage/region/ns1/service.yaml": "kind: Service",
+			"clusters/cluster1/expanded/stage/region/ns2/service.yaml": "kind: Service",
+			"clusters/cluster2/cluster.yaml":                           "cluster: cluster2\nregion: region\nenv: stage\n",
+			"clusters/cluster2/expanded/stage/region/ns1/service.yaml": "kind: Service",
+		},
+	)
+
+	runGit := func(args ...string) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = repoDir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	runGit("init")
+	runGit("add", ".")
+	runGit(
+		"-c", "user.name=test",
+		"-c", "user.email=test@example.com",
+		"commit", "-m", "Initial commit",
+	)
+
+	util.WriteFiles(
+		t,
+		repoDir,
+		map[string]string{
+			"clusters/cluster1/expanded/stage/region/ns2/service.yaml": "kind: Service\nmetadata: {}",
+		},
+	)
+
+	cluster1Config, err := config.LoadClusterConfig(
+		filepath.Join(repoDir, "clusters/cluster1/cluster.yaml"),
+		"",
+	)
+	require.NoError(t, err)
+	subpaths, covered, err := changedSubpaths(ctx, cluster1Config, "HEAD")
+	require.NoError(t, err)
+	assert.True(t, covered)
+	assert.Equal(t, []string{"ns2"}, subpaths)
+
+	cluster2Config, err := config.LoadClusterConfig(
+		filepath.Join(repoDir, "clusters/cluster2/cluster.yaml"),
+		"",
+	)
+	require.NoError(t, err)
+	_, covered, err = changedSubpaths(ctx, cluster2Config, "HEAD")
+	require.NoError(t, err)
+	assert.False(t, covered)
+}
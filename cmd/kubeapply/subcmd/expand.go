@@ -6,10 +6,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
+	"github.com/ghodss/yaml"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
 	"github.com/segmentio/kubeapply/pkg/helm"
+	"github.com/segmentio/kubeapply/pkg/jsonnet"
+	"github.com/segmentio/kubeapply/pkg/pullreq"
 	"github.com/segmentio/kubeapply/pkg/star/expand"
 	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/segmentio/kubeapply/pkg/version"
@@ -22,6 +26,10 @@ const (
 	// helm and downstream steps.
 	noExpandFile = ".noexpand"
 
+	// Files with this name, either in the root of a profile or next to the cluster config,
+	// contain gitignore-style patterns of files to exclude from the expanded outputs.
+	ignoreFile = ".kubeapplyignore"
+
 	// Require a minimum helm version to ensure that expansion works properly
 	helmVersionConstraint = ">= 3.5"
 )
@@ -34,28 +42,116 @@ var expandCmd = &cobra.Command{
 }
 
 type expandFlags struct {
+	// Whether to allow profiles that expand to zero manifests; otherwise, these are treated
+	// as errors since they usually mean that a template or chart failed silently
+	allowEmpty bool
+
+	// Directory to cache expansions in, keyed by a hash of their inputs. If unset, nothing
+	// is cached.
+	cacheDir string
+
 	// Clean old configs in expanded directory before expanding
 	clean bool
 
+	// Only expand clusters whose descriptive names match these globs. If unset, expands all
+	// clusters.
+	clusters []string
+
+	// Maximum size, in megabytes, of the charts and profiles fetched for each cluster. If
+	// zero, there's no limit.
+	fetchMaxSizeMB int64
+
+	// Maximum time to spend fetching each of the charts and profiles for a cluster. If zero,
+	// there's no limit.
+	fetchTimeout time.Duration
+
+	// Whether to lint each helm chart and its values before expanding it
+	helmLint bool
+
 	// Number of helm instances to run in parallel when expanding out charts.
 	helmParallelism int
+
+	// Whether to proceed, with a warning, if the cluster config's version constraint
+	// isn't satisfied by this kubeapply binary
+	ignoreVersionConstraint bool
+
+	// Directory to write the expanded configs to instead of the expanded path in the cluster
+	// config. Can only be used when expanding a single cluster.
+	outputDir string
+
+	// Whether to restrict the functions available in templates to a safe subset
+	sandbox bool
 }
 
 var expandFlagsValues expandFlags
 
 func init() {
+	expandCmd.Flags().BoolVar(
+		&expandFlagsValues.allowEmpty,
+		"allow-empty",
+		false,
+		"Allow profiles that expand to zero manifests instead of failing",
+	)
+	expandCmd.Flags().StringVar(
+		&expandFlagsValues.cacheDir,
+		"cache-dir",
+		"",
+		"Directory to cache expansions in; expansions whose inputs are unchanged are copied from the cache instead of being re-run",
+	)
 	expandCmd.Flags().BoolVar(
 		&expandFlagsValues.clean,
 		"clean",
 		false,
 		"Clean out old configs in expanded directory",
 	)
+	expandCmd.Flags().StringArrayVar(
+		&expandFlagsValues.clusters,
+		"cluster",
+		[]string{},
+		"Expand clusters whose names (env:region:cluster) match the provided glob(s) only",
+	)
+	expandCmd.Flags().Int64Var(
+		&expandFlagsValues.fetchMaxSizeMB,
+		"fetch-max-size-mb",
+		0,
+		"Fail if any of the fetched charts or profiles are larger than this many megabytes; 0 for no limit",
+	)
+	expandCmd.Flags().DurationVar(
+		&expandFlagsValues.fetchTimeout,
+		"fetch-timeout",
+		0,
+		"Fail if fetching any of the charts or profiles takes longer than this; 0 for no limit",
+	)
+	expandCmd.Flags().BoolVar(
+		&expandFlagsValues.helmLint,
+		"helm-lint",
+		false,
+		"Lint helm charts and validate their values against the chart schemas before expanding",
+	)
 	expandCmd.Flags().IntVar(
 		&expandFlagsValues.helmParallelism,
 		"helm-parallelism",
 		5,
 		"Parallelism on helm expansions",
 	)
+	expandCmd.Flags().BoolVar(
+		&expandFlagsValues.ignoreVersionConstraint,
+		"ignore-version-constraint",
+		false,
+		"Proceed, with a warning, if the version constraint in the cluster config isn't satisfied",
+	)
+	expandCmd.Flags().StringVar(
+		&expandFlagsValues.outputDir,
+		"output-dir",
+		"",
+		"Write the expanded configs to this directory instead of the expanded path in the cluster config",
+	)
+	expandCmd.Flags().BoolVar(
+		&expandFlagsValues.sandbox,
+		"sandbox",
+		false,
+		"Disable template functions that read env variables, hit the network, or read files outside of the expanded directory",
+	)
 
 	RootCmd.AddCommand(expandCmd)
 }
@@ -63,14 +159,37 @@ func init() {
 func expandRun(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
+	var expandedCluster string
+
 	for _, arg := range args {
-		paths, err := filepath.Glob(arg)
+		paths, err := clusterConfigPaths(arg)
 		if err != nil {
 			return err
 		}
 
 		for _, path := range paths {
-			if err := expandClusterPath(ctx, path, expandFlagsValues.clean); err != nil {
+			clusterConfig, err := loadExpandClusterConfig(path)
+			if err != nil {
+				return err
+			} else if clusterConfig == nil {
+				continue
+			}
+
+			if expandFlagsValues.outputDir != "" {
+				if expandedCluster != "" {
+					return fmt.Errorf(
+						"Cannot expand both %s and %s into the same --output-dir; select a single cluster with --cluster",
+						expandedCluster,
+						clusterConfig.DescriptiveName(),
+					)
+				}
+				if err := overrideExpandedPath(clusterConfig, expandFlagsValues.outputDir); err != nil {
+					return err
+				}
+				expandedCluster = clusterConfig.DescriptiveName()
+			}
+
+			if err := expandCluster(ctx, clusterConfig, expandFlagsValues.clean); err != nil {
 				return err
 			}
 		}
@@ -79,16 +198,97 @@ func expandRun(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func expandClusterPath(ctx context.Context, path string, clean bool) error {
+// loadExpandClusterConfig loads the cluster config at the argument path and checks its version
+// constraint. It returns nil if the cluster isn't selected via the --cluster flag.
+func loadExpandClusterConfig(path string) (*config.ClusterConfig, error) {
 	clusterConfig, err := config.LoadClusterConfig(path, "")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	selected, err := clusterSelected(clusterConfig, expandFlagsValues.clusters)
+	if err != nil || !selected {
+		return nil, err
 	}
-	if err := clusterConfig.CheckVersion(version.Version); err != nil {
+
+	err = checkClusterVersion(clusterConfig, expandFlagsValues.ignoreVersionConstraint)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusterConfig, nil
+}
+
+// overrideExpandedPath points the argument cluster config at the expanded configs in the
+// argument directory instead of its configured expanded path. The subpaths are resolved
+// relative to the new directory. Only the loaded config is updated; the file on disk isn't
+// changed.
+func overrideExpandedPath(clusterConfig *config.ClusterConfig, dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
 		return err
 	}
 
-	return expandCluster(ctx, clusterConfig, clean)
+	log.Infof(
+		"Using expanded path %s for cluster %s instead of %s",
+		absDir,
+		clusterConfig.DescriptiveName(),
+		clusterConfig.ExpandedPath,
+	)
+	clusterConfig.ExpandedPath = absDir
+	return nil
+}
+
+// clusterConfigPaths returns the cluster config paths for the argument command-line
+// argument. Local paths are expanded as globs, whereas remote URLs are returned as-is.
+func clusterConfigPaths(arg string) ([]string, error) {
+	if config.IsRemotePath(arg) {
+		return []string{arg}, nil
+	}
+	return filepath.Glob(arg)
+}
+
+// clusterSelected returns whether the argument cluster is selected by the argument globs,
+// which are matched against the cluster's descriptive name. All clusters are selected if
+// there are no globs.
+func clusterSelected(clusterConfig *config.ClusterConfig, clusterGlobStrs []string) (bool, error) {
+	if len(clusterGlobStrs) == 0 {
+		return true, nil
+	}
+
+	clusterGlobs, err := pullreq.CompileClusterGlobs(clusterGlobStrs)
+	if err != nil {
+		return false, err
+	}
+
+	if !pullreq.MatchesClusterGlobs(clusterConfig, clusterGlobs) {
+		log.Infof(
+			"Skipping cluster %s because it doesn't match %+v",
+			clusterConfig.DescriptiveName(),
+			clusterGlobStrs,
+		)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// checkClusterVersion checks that this kubeapply binary satisfies the version constraint in
+// the argument cluster config. If ignoreConstraint is true, then a failed check is logged
+// instead of being returned as an error. This is only intended for testing new kubeapply
+// builds locally; the webhooks path always enforces the constraint.
+func checkClusterVersion(clusterConfig *config.ClusterConfig, ignoreConstraint bool) error {
+	err := clusterConfig.CheckVersion(version.Version)
+	if err != nil && ignoreConstraint {
+		log.Warnf(
+			"Ignoring failed version check for cluster %s: %+v",
+			clusterConfig.DescriptiveName(),
+			err,
+		)
+		return nil
+	}
+
+	return err
 }
 
 func expandCluster(
@@ -96,7 +296,73 @@ func expandCluster(
 	clusterConfig *config.ClusterConfig,
 	clean bool,
 ) error {
-	tempDir, err := ioutil.TempDir("", "expand")
+	var cacheKey string
+
+	if expandFlagsValues.cacheDir != "" {
+		var cacheable bool
+		var err error
+
+		cacheKey, cacheable, err = expandCacheKey(clusterConfig)
+		if err != nil {
+			return err
+		}
+
+		if !cacheable {
+			log.Infof(
+				"Not caching expansion for cluster %s because it has remote sources",
+				clusterConfig.DescriptiveName(),
+			)
+			cacheKey = ""
+		} else {
+			if clean {
+				log.Infof("Cleaning old version of expanded configs")
+				os.RemoveAll(clusterConfig.ExpandedPath)
+				clean = false
+			}
+
+			ok, err := restoreCachedExpansion(
+				expandFlagsValues.cacheDir,
+				cacheKey,
+				clusterConfig,
+			)
+			if err != nil || ok {
+				return err
+			}
+
+			// Only cache expansions that start from scratch; otherwise, the leftovers from
+			// previous expansions would end up in the cache too.
+			ok, err = util.DirExists(clusterConfig.ExpandedPath)
+			if err != nil {
+				return err
+			} else if ok {
+				log.Infof(
+					"Not caching expansion for cluster %s because %s isn't empty; run with --clean to populate the cache",
+					clusterConfig.DescriptiveName(),
+					clusterConfig.ExpandedPath,
+				)
+				cacheKey = ""
+			}
+		}
+	}
+
+	if err := expandClusterSources(ctx, clusterConfig, clean); err != nil {
+		return err
+	}
+
+	if cacheKey != "" {
+		return storeCachedExpansion(expandFlagsValues.cacheDir, cacheKey, clusterConfig)
+	}
+
+	return nil
+}
+
+// expandClusterSources runs the full expansion for the argument cluster.
+func expandClusterSources(
+	ctx context.Context,
+	clusterConfig *config.ClusterConfig,
+	clean bool,
+) error {
+	tempDir, err := util.TempDir("expand")
 	if err != nil {
 		return err
 	}
@@ -122,6 +388,7 @@ func expandCluster(
 			filepath.Dir(clusterConfig.FullPath()),
 			clusterConfig.Charts,
 			chartsPath,
+			restoreLimits(),
 		)
 		if err != nil {
 			return err
@@ -142,6 +409,15 @@ func expandCluster(
 
 	if len(clusterConfig.Profiles) > 0 {
 		for _, profile := range clusterConfig.Profiles {
+			if !profile.EnabledForEnv(clusterConfig.Env) {
+				log.Infof(
+					"Skipping profile %s because it's not enabled in env %s",
+					profile.Name,
+					clusterConfig.Env,
+				)
+				continue
+			}
+
 			expandedPath := filepath.Join(clusterConfig.ExpandedPath, profile.Name)
 
 			err = util.RestoreData(
@@ -149,6 +425,7 @@ func expandCluster(
 				filepath.Dir(clusterConfig.FullPath()),
 				profile.URL,
 				expandedPath,
+				restoreLimits(),
 			)
 			if err != nil {
 				return err
@@ -204,6 +481,10 @@ func expandProfile(
 ) error {
 	log.Infof("Expanding profile %s in %s", profile.Name, expandedPath)
 
+	if err := clusterConfig.ValidateParameters(profile); err != nil {
+		return err
+	}
+
 	// TODO: Should probably wrap in another struct that has fields for both cluster config
 	// and profile.
 	clusterConfig.Profile = profile
@@ -212,6 +493,7 @@ func expandProfile(
 		clusterConfig,
 		true,
 		true,
+		expandFlagsValues.sandbox,
 	)
 	if err != nil {
 		return err
@@ -229,10 +511,19 @@ func expandProfile(
 	if chartsPath != "" {
 		log.Infof("Applying helm to charts in %s", expandedPath)
 
+		parallelism := expandFlagsValues.helmParallelism
+		if clusterConfig.HelmParallelism > 0 {
+			parallelism = clusterConfig.HelmParallelism
+		}
+
 		helmClient := helm.HelmClient{
 			RootDir:          filepath.Dir(clusterConfig.FullPath()),
 			GlobalValuesPath: chartGlobalsPath,
-			Parallelism:      expandFlagsValues.helmParallelism,
+			Lint:             expandFlagsValues.helmLint,
+			Parallelism:      parallelism,
+			RestoreLimits:    restoreLimits(),
+
+			GlobalValuesOverride: clusterConfig.HelmGlobalValuesOverride,
 		}
 		err = helmClient.ExpandHelmTemplates(
 			ctx,
@@ -251,12 +542,51 @@ func expandProfile(
 	err = expand.ExpandStar(
 		expandedPath,
 		filepath.Dir(clusterConfig.FullPath()),
+		clusterConfig.StarlarkModulePaths,
+		clusterConfig.StarParams(),
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Evaluating jsonnet files in %s", expandedPath)
+	err = jsonnet.ExpandJsonnet(
+		expandedPath,
 		clusterConfig.StarParams(),
+		expandFlagsValues.sandbox,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Removing ignored files in %s", expandedPath)
+	err = util.RemoveIgnored(
+		expandedPath,
+		filepath.Join(filepath.Dir(clusterConfig.FullPath()), ignoreFile),
+		filepath.Join(expandedPath, ignoreFile),
 	)
 	if err != nil {
 		return err
 	}
 
+	if len(clusterConfig.CommonLabels) > 0 || len(clusterConfig.CommonAnnotations) > 0 {
+		log.Infof("Adding common labels and annotations to resources in %s", expandedPath)
+		err = kube.AddCommonMetadata(
+			expandedPath,
+			clusterConfig.CommonLabels,
+			clusterConfig.CommonAnnotations,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !expandFlagsValues.allowEmpty {
+		if err := checkProfileNotEmpty(expandedPath, profile); err != nil {
+			return err
+		}
+	}
+
 	log.Infof(
 		"Adding header comments to all YAML files in %s",
 		expandedPath,
@@ -269,23 +599,51 @@ func expandProfile(
 	return nil
 }
 
+// checkProfileNotEmpty returns an error if the argument expanded profile doesn't contain any
+// Kubernetes manifests. This almost always means that a template or chart is misconfigured,
+// and applying it would silently do nothing.
+func checkProfileNotEmpty(expandedPath string, profile *config.Profile) error {
+	manifests, err := kube.GetManifests([]string{expandedPath})
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return fmt.Errorf(
+			"Profile %s expanded to zero manifests in %s; run with --allow-empty if this is expected",
+			profile.Name,
+			expandedPath,
+		)
+	}
+
+	log.Debugf("Profile %s expanded to %d manifests", profile.Name, len(manifests))
+	return nil
+}
+
 func writeGlobals(path string, clusterConfig *config.ClusterConfig) error {
 	err := os.MkdirAll(filepath.Dir(path), 0755)
 	if err != nil {
 		return err
 	}
 
-	globalsLines := []string{
-		"---",
-		"global:",
-		fmt.Sprintf("  cluster: %s", clusterConfig.Cluster),
-		fmt.Sprintf("  region: %s", clusterConfig.Region),
-		fmt.Sprintf("  shortRegion: %s", clusterConfig.ShortRegion()),
+	globalsBytes, err := yaml.Marshal(
+		map[string]interface{}{
+			"global": clusterConfig.HelmGlobals(),
+		},
+	)
+	if err != nil {
+		return err
 	}
 
 	return ioutil.WriteFile(
 		path,
-		[]byte(strings.Join(globalsLines, "\n")),
+		append([]byte("---\n"), globalsBytes...),
 		0644,
 	)
 }
+
+func restoreLimits() util.RestoreLimits {
+	return util.RestoreLimits{
+		Timeout: expandFlagsValues.fetchTimeout,
+		MaxSize: expandFlagsValues.fetchMaxSizeMB * 1024 * 1024,
+	}
+}
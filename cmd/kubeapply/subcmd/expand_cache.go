@@ -0,0 +1,187 @@
+package subcmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/segmentio/kubeapply/pkg/version"
+	log "github.com/sirupsen/logrus"
+)
+
+// expandCacheKey returns a hash of the inputs to the expansion of the argument cluster: the
+// loaded cluster config (which includes the parameters), the profile, chart, and starlark module
+// sources, the ignore file next to the config, and the expansion settings. The second return
+// value is false if any of the sources are remote, in which case changes to them can't be
+// detected without fetching them and the expansion can't be cached.
+func expandCacheKey(clusterConfig *config.ClusterConfig) (string, bool, error) {
+	rootDir := filepath.Dir(clusterConfig.FullPath())
+
+	sourcePaths := []string{filepath.Join(rootDir, ignoreFile)}
+
+	if len(clusterConfig.Profiles) > 0 {
+		for _, profile := range clusterConfig.Profiles {
+			if !profile.EnabledForEnv(clusterConfig.Env) {
+				continue
+			}
+			profilePath, ok := util.LocalPath(rootDir, profile.URL)
+			if !ok {
+				return "", false, nil
+			}
+			sourcePaths = append(sourcePaths, profilePath)
+		}
+	} else {
+		sourcePaths = append(sourcePaths, clusterConfig.ProfilePath)
+	}
+
+	if clusterConfig.Charts != "" {
+		chartsPath, ok := util.LocalPath(rootDir, clusterConfig.Charts)
+		if !ok {
+			return "", false, nil
+		}
+		sourcePaths = append(sourcePaths, chartsPath)
+	}
+
+	sourcePaths = append(sourcePaths, clusterConfig.StarlarkModulePaths...)
+
+	configBytes, err := json.Marshal(clusterConfig)
+	if err != nil {
+		return "", false, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(
+		h,
+		"version=%s\nsandbox=%t\nhelmLint=%t\nconfig=%s\n",
+		version.Version,
+		expandFlagsValues.sandbox,
+		expandFlagsValues.helmLint,
+		configBytes,
+	)
+
+	for _, sourcePath := range sourcePaths {
+		if err := hashPath(h, sourcePath, clusterConfig.ExpandedPath); err != nil {
+			return "", false, err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), true, nil
+}
+
+// hashPath writes the names and contents of all of the files under the argument path into the
+// argument hash, skipping anything in skipPath. Missing paths are hashed as such so that
+// creating them changes the hash.
+func hashPath(h hash.Hash, path string, skipPath string) error {
+	fmt.Fprintf(h, "path=%s\n", path)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Fprintf(h, "missing\n")
+		return nil
+	}
+
+	return filepath.Walk(
+		path,
+		func(subPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(path, subPath)
+			if err != nil {
+				return err
+			}
+
+			if subPath == skipPath {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.IsDir() {
+				fmt.Fprintf(h, "dir=%s\n", relPath)
+				return nil
+			}
+
+			fmt.Fprintf(h, "file=%s mode=%s size=%d\n", relPath, info.Mode(), info.Size())
+
+			file, err := os.Open(subPath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			_, err = io.Copy(h, file)
+			return err
+		},
+	)
+}
+
+// restoreCachedExpansion copies the cached expansion with the argument key, if there is one,
+// into the expanded path of the argument cluster. It returns whether the cache had an entry for
+// the key.
+func restoreCachedExpansion(
+	cacheDir string,
+	key string,
+	clusterConfig *config.ClusterConfig,
+) (bool, error) {
+	cachedPath := filepath.Join(cacheDir, key)
+
+	ok, err := util.DirExists(cachedPath)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	log.Infof(
+		"Inputs for cluster %s are unchanged, copying cached expansion from %s",
+		clusterConfig.DescriptiveName(),
+		cachedPath,
+	)
+	return true, util.RecursiveCopy(cachedPath, clusterConfig.ExpandedPath)
+}
+
+// storeCachedExpansion copies the expanded configs for the argument cluster into the cache
+// under the argument key. The copy is made in a temporary directory that's then renamed so
+// that concurrent runs never see partial entries.
+func storeCachedExpansion(
+	cacheDir string,
+	key string,
+	clusterConfig *config.ClusterConfig,
+) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	tempDir, err := ioutil.TempDir(cacheDir, key+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.Chmod(tempDir, 0755); err != nil {
+		return err
+	}
+	if err := util.RecursiveCopy(clusterConfig.ExpandedPath, tempDir); err != nil {
+		return err
+	}
+
+	cachedPath := filepath.Join(cacheDir, key)
+	if err := os.Rename(tempDir, cachedPath); err != nil {
+		if ok, _ := util.DirExists(cachedPath); ok {
+			// Another run stored the same expansion first
+			return nil
+		}
+		return err
+	}
+
+	log.Infof("Cached expansion for cluster %s in %s", clusterConfig.DescriptiveName(), cachedPath)
+	return nil
+}
@@ -0,0 +1,111 @@
+package subcmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandCache(t *testing.T) {
+	ctx := context.Background()
+
+	rootDir, err := ioutil.TempDir("", "expand_cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	configPath := filepath.Join(rootDir, "clusters/cluster1/cluster.yaml")
+	cacheDir := filepath.Join(rootDir, "cache")
+
+	util.WriteFiles(
+		t,
+		rootDir,
+		map[string]string{
+			"clusters/cluster1/cluster.yaml":                  "cluster: cluster1\nregion: region\nenv: stage\nparameters:\n  key: value1\n",
+			"clusters/cluster1/profile/ns1/config.gotpl.yaml": "kind: ConfigMap\nmetadata:\n  name: config1\ndata:\n  key: {{ .Parameters.key }}\n",
+		},
+	)
+
+	loadConfig := func() *config.ClusterConfig {
+		clusterConfig, err := config.LoadClusterConfig(configPath, "")
+		require.NoError(t, err)
+		return clusterConfig
+	}
+
+	prevCacheDir := expandFlagsValues.cacheDir
+	expandFlagsValues.cacheDir = cacheDir
+	defer func() {
+		expandFlagsValues.cacheDir = prevCacheDir
+	}()
+
+	clusterConfig := loadConfig()
+	key1, cacheable, err := expandCacheKey(clusterConfig)
+	require.NoError(t, err)
+	assert.True(t, cacheable)
+
+	require.NoError(t, expandCluster(ctx, clusterConfig, true))
+	ok, err := util.DirExists(filepath.Join(cacheDir, key1))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Add a marker to the cache entry to check that it's used for unchanged inputs
+	util.WriteFiles(
+		t,
+		filepath.Join(cacheDir, key1),
+		map[string]string{
+			"marker.yaml": "kind: ConfigMap\n",
+		},
+	)
+
+	clusterConfig = loadConfig()
+	require.NoError(t, expandCluster(ctx, clusterConfig, true))
+	ok, err = util.FileExists(filepath.Join(clusterConfig.ExpandedPath, "marker.yaml"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Changes to the parameters or the profile invalidate the cache
+	util.WriteFiles(
+		t,
+		rootDir,
+		map[string]string{
+			"clusters/cluster1/cluster.yaml": "cluster: cluster1\nregion: region\nenv: stage\nparameters:\n  key: value2\n",
+		},
+	)
+	key2, _, err := expandCacheKey(loadConfig())
+	require.NoError(t, err)
+	assert.NotEqual(t, key1, key2)
+
+	util.WriteFiles(
+		t,
+		rootDir,
+		map[string]string{
+			"clusters/cluster1/profile/ns2/config.yaml": "kind: ConfigMap\nmetadata:\n  name: config2\n",
+		},
+	)
+	clusterConfig = loadConfig()
+	key3, _, err := expandCacheKey(clusterConfig)
+	require.NoError(t, err)
+	assert.NotEqual(t, key2, key3)
+
+	require.NoError(t, expandCluster(ctx, clusterConfig, true))
+	ok, err = util.FileExists(filepath.Join(clusterConfig.ExpandedPath, "marker.yaml"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	contents, err := ioutil.ReadFile(
+		filepath.Join(clusterConfig.ExpandedPath, "ns1/config.yaml"),
+	)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "key: value2")
+
+	// Expansions with remote sources aren't cached
+	clusterConfig.Charts = "s3://bucket/charts.tar.gz"
+	_, cacheable, err = expandCacheKey(clusterConfig)
+	require.NoError(t, err)
+	assert.False(t, cacheable)
+}
@@ -0,0 +1,187 @@
+package subcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var expandDiffCmd = &cobra.Command{
+	Use:   "expand-diff [cluster config]",
+	Short: "expand-diff shows the difference between the expanded configs at two git refs",
+	Long: `expand-diff expands the argument cluster config at two git refs, each in its own
+temporary worktree, and diffs the results. This shows the changes in the generated manifests,
+e.g. from template or chart updates, independent of the cluster state. The local checkout,
+including any uncommitted changes, isn't used.`,
+	Args: cobra.ExactArgs(1),
+	RunE: expandDiffRun,
+}
+
+type expandDiffFlags struct {
+	// Git ref to expand the old configs at.
+	base string
+
+	// Git ref to expand the new configs at.
+	head string
+}
+
+var expandDiffFlagValues expandDiffFlags
+
+func init() {
+	expandDiffCmd.Flags().StringVar(
+		&expandDiffFlagValues.base,
+		"base",
+		"",
+		"Git ref to expand the old configs at",
+	)
+	expandDiffCmd.MarkFlagRequired("base")
+	expandDiffCmd.Flags().StringVar(
+		&expandDiffFlagValues.head,
+		"head",
+		"HEAD",
+		"Git ref to expand the new configs at",
+	)
+
+	RootCmd.AddCommand(expandDiffCmd)
+}
+
+func expandDiffRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if config.IsRemotePath(args[0]) {
+		return fmt.Errorf("Cannot expand-diff remote cluster config %s", args[0])
+	}
+
+	results, err := expandDiff(
+		ctx,
+		args[0],
+		expandDiffFlagValues.base,
+		expandDiffFlagValues.head,
+	)
+	if err != nil {
+		return err
+	}
+
+	diff.PrintFull(results, useColors())
+	return nil
+}
+
+// expandDiff expands the cluster config at the argument path at the base and head refs of
+// its git repo, and returns the diffs between the two expanded trees.
+func expandDiff(
+	ctx context.Context,
+	configPath string,
+	baseRef string,
+	headRef string,
+) ([]diff.Result, error) {
+	absConfigPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, err
+	}
+	absConfigPath, err = filepath.EvalSymlinks(absConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	repoRoot, err := util.GetRepoRoot(ctx, filepath.Dir(absConfigPath))
+	if err != nil {
+		return nil, err
+	}
+	relConfigPath, err := filepath.Rel(repoRoot, absConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := util.TempDir("expand-diff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	baseExpandedPath, err := expandAtRef(
+		ctx,
+		repoRoot,
+		relConfigPath,
+		baseRef,
+		filepath.Join(tempDir, "base"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	headExpandedPath, err := expandAtRef(
+		ctx,
+		repoRoot,
+		relConfigPath,
+		headRef,
+		filepath.Join(tempDir, "head"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Diffing expanded configs between %s and %s", baseRef, headRef)
+	results, err := diff.DiffKube(baseExpandedPath, headExpandedPath, diff.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return results.Results, nil
+}
+
+// expandAtRef checks out the argument ref in a worktree under tempDir, expands the cluster
+// config at relConfigPath in it, and copies the expanded configs to tempDir/expanded. The
+// path of the copy is returned. If the config doesn't exist at the ref, then the copy is
+// empty so that all of the configs at the other ref show up as additions or removals.
+func expandAtRef(
+	ctx context.Context,
+	repoRoot string,
+	relConfigPath string,
+	ref string,
+	tempDir string,
+) (string, error) {
+	worktreePath := filepath.Join(tempDir, "worktree")
+	expandedCopyPath := filepath.Join(tempDir, "expanded")
+
+	if err := os.MkdirAll(expandedCopyPath, 0755); err != nil {
+		return "", err
+	}
+
+	log.Infof("Checking out %s in %s", ref, worktreePath)
+	if err := util.AddWorktree(ctx, repoRoot, ref, worktreePath); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := util.RemoveWorktree(ctx, repoRoot, worktreePath); err != nil {
+			log.Warnf("Error removing worktree %s: %+v", worktreePath, err)
+		}
+	}()
+
+	configPath := filepath.Join(worktreePath, relConfigPath)
+	ok, err := util.FileExists(configPath)
+	if err != nil {
+		return "", err
+	} else if !ok {
+		log.Infof("Cluster config %s does not exist at %s", relConfigPath, ref)
+		return expandedCopyPath, nil
+	}
+
+	clusterConfig, err := config.LoadClusterConfig(configPath, worktreePath)
+	if err != nil {
+		return "", err
+	}
+	if err := expandCluster(ctx, clusterConfig, true); err != nil {
+		return "", fmt.Errorf("Error expanding at %s: %+v", ref, err)
+	}
+
+	if err := util.RecursiveCopy(clusterConfig.ExpandedPath, expandedCopyPath); err != nil {
+		return "", err
+	}
+	return expandedCopyPath, nil
+}
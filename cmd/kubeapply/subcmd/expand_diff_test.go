@@ -0,0 +1,84 @@
+package subcmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandDiff(t *testing.T) {
+	ctx := context.Background()
+
+	repoDir, err := ioutil.TempDir("", "repo")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	runGit := func(args ...string) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = repoDir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	commit := func(message string) {
+		runGit("add", ".")
+		runGit(
+			"-c", "user.name=test",
+			"-c", "user.email=test@example.com",
+			"commit", "-m", message,
+		)
+	}
+
+	runGit("init")
+	util.WriteFiles(
+		t,
+		repoDir,
+		map[string]string{
+			"clusters/cluster1/cluster.yaml":            "cluster: cluster1\nregion: region\nenv: stage\n",
+			"clusters/cluster1/profile/ns1/config.yaml": "kind: ConfigMap\nmetadata:\n  name: config1\ndata:\n  key: value1\n",
+			"clusters/cluster1/profile/ns2/config.yaml": "kind: ConfigMap\nmetadata:\n  name: config2\n",
+		},
+	)
+	commit("Initial commit")
+
+	util.WriteFiles(
+		t,
+		repoDir,
+		map[string]string{
+			"clusters/cluster1/profile/ns1/config.yaml": "kind: ConfigMap\nmetadata:\n  name: config1\ndata:\n  key: value2\n",
+		},
+	)
+	commit("Update config")
+
+	// Uncommitted changes shouldn't be picked up
+	util.WriteFiles(
+		t,
+		repoDir,
+		map[string]string{
+			"clusters/cluster1/profile/ns2/config.yaml": "kind: ConfigMap\nmetadata:\n  name: updated\n",
+		},
+	)
+
+	results, err := expandDiff(
+		ctx,
+		filepath.Join(repoDir, "clusters/cluster1/cluster.yaml"),
+		"HEAD~1",
+		"HEAD",
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(results))
+	assert.Equal(t, "ns1/config.yaml", results[0].Name)
+	assert.Equal(t, 1, results[0].NumAdded)
+	assert.Equal(t, 1, results[0].NumRemoved)
+	assert.Contains(t, results[0].RawDiff, "+  key: value2")
+
+	ok, err := util.DirExists(filepath.Join(repoDir, "clusters/cluster1/expanded"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
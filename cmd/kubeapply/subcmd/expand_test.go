@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/segmentio/kubeapply/pkg/config"
 	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -42,6 +43,139 @@ func TestExpand(t *testing.T) {
 	}
 }
 
+func TestCheckClusterVersion(t *testing.T) {
+	clusterConfig := &config.ClusterConfig{
+		VersionConstraint: "< 0.0.1",
+	}
+
+	assert.NotNil(t, checkClusterVersion(clusterConfig, false))
+	assert.Nil(t, checkClusterVersion(clusterConfig, true))
+
+	clusterConfig.VersionConstraint = ">= 0.0.1"
+	assert.Nil(t, checkClusterVersion(clusterConfig, false))
+}
+
+func TestClusterSelected(t *testing.T) {
+	clusterConfig := &config.ClusterConfig{
+		Cluster: "cluster1",
+		Region:  "us-west-2",
+		Env:     "production",
+	}
+	require.Nil(t, clusterConfig.SetDefaults("clusters/cluster1.yaml", ""))
+
+	type testCase struct {
+		globStrs    []string
+		expSelected bool
+	}
+
+	testCases := []testCase{
+		{
+			globStrs:    []string{},
+			expSelected: true,
+		},
+		{
+			globStrs:    []string{"production:*"},
+			expSelected: true,
+		},
+		{
+			globStrs:    []string{"stage:*", "*:cluster1"},
+			expSelected: true,
+		},
+		{
+			globStrs:    []string{"stage:*"},
+			expSelected: false,
+		},
+	}
+
+	for index, testCase := range testCases {
+		selected, err := clusterSelected(clusterConfig, testCase.globStrs)
+		require.Nil(t, err, "test case %d", index)
+		assert.Equal(t, testCase.expSelected, selected, "test case %d", index)
+	}
+
+	_, err := clusterSelected(clusterConfig, []string{"["})
+	assert.NotNil(t, err)
+}
+
+func TestExpandOutputDir(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "expand_output")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	util.WriteFiles(
+		t,
+		rootDir,
+		map[string]string{
+			"clusters/cluster1.yaml":           "cluster: cluster1\nregion: region\nenv: stage\n",
+			"clusters/cluster2.yaml":           "cluster: cluster2\nregion: region\nenv: stage\n",
+			"clusters/profile/ns1/config.yaml": "kind: ConfigMap\nmetadata:\n  name: config1\n",
+		},
+	)
+	outputDir := filepath.Join(rootDir, "build")
+
+	prevValues := expandFlagsValues
+	defer func() {
+		expandFlagsValues = prevValues
+	}()
+	expandFlagsValues.outputDir = outputDir
+
+	err = expandRun(nil, []string{filepath.Join(rootDir, "clusters/cluster1.yaml")})
+	require.NoError(t, err)
+
+	contents := getContents(t, outputDir)
+	assert.Contains(t, contents, "ns1/config.yaml")
+	ok, err := util.DirExists(filepath.Join(rootDir, "clusters/expanded"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	clusterConfig, err := config.LoadClusterConfig(
+		filepath.Join(rootDir, "clusters/cluster1.yaml"),
+		"",
+	)
+	require.NoError(t, err)
+	clusterConfig.Subpaths = []string{"ns1"}
+	require.NoError(t, overrideExpandedPath(clusterConfig, outputDir))
+	assert.Equal(t, []string{filepath.Join(outputDir, "ns1")}, clusterConfig.AbsSubpaths())
+
+	err = expandRun(nil, []string{filepath.Join(rootDir, "clusters/*.yaml")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "same --output-dir")
+}
+
+func TestExpandEmptyProfile(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "expand_empty")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	util.WriteFiles(
+		t,
+		rootDir,
+		map[string]string{
+			"clusters/cluster1.yaml": "cluster: cluster1\nregion: region\nenv: stage\n",
+			"clusters/profile/ns1/config.gotpl.yaml": `
+{{- if eq .Env "production" }}
+kind: ConfigMap
+metadata:
+  name: config1
+{{- end }}
+`,
+		},
+	)
+
+	prevValues := expandFlagsValues
+	defer func() {
+		expandFlagsValues = prevValues
+	}()
+
+	err = expandRun(nil, []string{filepath.Join(rootDir, "clusters/cluster1.yaml")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "zero manifests")
+
+	expandFlagsValues.allowEmpty = true
+	err = expandRun(nil, []string{filepath.Join(rootDir, "clusters/cluster1.yaml")})
+	require.NoError(t, err)
+}
+
 func getContents(t *testing.T, root string) map[string]string {
 	contentsMap := map[string]string{}
 
@@ -19,7 +19,18 @@ var kdiffCmd = &cobra.Command{
 	RunE:   kdiffRun,
 }
 
-type kdiffEnv struct{}
+type kdiffEnv struct {
+	// Whether to keep managedFields in the diffs; set by the parent kubeapply process
+	// since kubectl doesn't let us pass through extra arguments.
+	showManagedFields bool
+
+	// Whether to exclude helm hooks from the diffs; also set by the parent kubeapply process.
+	ignoreHelmHooks bool
+
+	// Keys of labels and annotations to redact in the diffs; also set by the parent kubeapply
+	// process.
+	redactKeys []string
+}
 
 var kdiffEnvValues kdiffEnv
 
@@ -32,16 +43,26 @@ func kdiffRun(cmd *cobra.Command, args []string) error {
 		return errors.New("Expected exactly two arguments")
 	}
 
-	results, err := diff.DiffKube(args[0], args[1])
-	if err != nil {
-		return err
+	kdiffEnvValues.showManagedFields = envIsTrue(diff.ShowManagedFieldsEnvVar)
+	kdiffEnvValues.ignoreHelmHooks = envIsTrue(diff.IgnoreHelmHooksEnvVar)
+	if redactKeys := os.Getenv(diff.RedactKeysEnvVar); redactKeys != "" {
+		kdiffEnvValues.redactKeys = strings.Split(redactKeys, ",")
 	}
 
-	wrappedResults := diff.Results{
-		Results: results,
+	results, err := diff.DiffKube(
+		args[0],
+		args[1],
+		diff.Options{
+			ShowManagedFields: kdiffEnvValues.showManagedFields,
+			IgnoreHelmHooks:   kdiffEnvValues.ignoreHelmHooks,
+			RedactKeys:        kdiffEnvValues.redactKeys,
+		},
+	)
+	if err != nil {
+		return err
 	}
 
-	jsonBytes, err := json.MarshalIndent(wrappedResults, "", "  ")
+	jsonBytes, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
 		return err
 	}
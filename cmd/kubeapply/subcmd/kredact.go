@@ -0,0 +1,43 @@
+package subcmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/spf13/cobra"
+)
+
+var kredactCmd = &cobra.Command{
+	Use:    "kredact [old path] [new path]",
+	Short:  "kredact is used for redacting the objects passed to raw kubectl diffs; for internal use only",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE:   kredactRun,
+}
+
+func init() {
+	RootCmd.AddCommand(kredactCmd)
+}
+
+func kredactRun(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return errors.New("Expected exactly two arguments")
+	}
+
+	// Like in kdiff, the keys are set by the parent kubeapply process since kubectl doesn't
+	// let us pass through extra arguments.
+	redactKeys := os.Getenv(diff.RedactKeysEnvVar)
+	if redactKeys == "" {
+		return nil
+	}
+
+	for _, arg := range args {
+		if err := diff.RedactDir(arg, strings.Split(redactKeys, ",")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
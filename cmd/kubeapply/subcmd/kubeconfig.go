@@ -0,0 +1,140 @@
+package subcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
+	"github.com/segmentio/kubeapply/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var kubeconfigCmd = &cobra.Command{
+	Use:   "kubeconfig",
+	Short: "kubeconfig contains commands for managing cluster kubeconfigs",
+}
+
+var kubeconfigGenerateCmd = &cobra.Command{
+	Use:   "generate [cluster configs]",
+	Short: "generate creates kubeconfigs for one or more clusters via the EKS API",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  kubeconfigGenerateRun,
+}
+
+type kubeconfigGenerateFlags struct {
+	// Clusters to generate kubeconfigs for; if unset, generates for all clusters.
+	clusters []string
+
+	// Directory to write the kubeconfigs to.
+	outputDir string
+}
+
+var kubeconfigGenerateFlagValues kubeconfigGenerateFlags
+
+func init() {
+	kubeconfigGenerateCmd.Flags().StringArrayVar(
+		&kubeconfigGenerateFlagValues.clusters,
+		"cluster",
+		[]string{},
+		"Generate kubeconfigs for clusters matching the provided glob(s) only",
+	)
+	kubeconfigGenerateCmd.Flags().StringVar(
+		&kubeconfigGenerateFlagValues.outputDir,
+		"output-dir",
+		"",
+		"Directory to write the kubeconfigs to",
+	)
+	kubeconfigGenerateCmd.MarkFlagRequired("output-dir")
+
+	kubeconfigCmd.AddCommand(kubeconfigGenerateCmd)
+	RootCmd.AddCommand(kubeconfigCmd)
+}
+
+func kubeconfigGenerateRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if err := os.MkdirAll(kubeconfigGenerateFlagValues.outputDir, 0755); err != nil {
+		return err
+	}
+
+	sess := session.Must(session.NewSession())
+
+	for _, arg := range args {
+		paths, err := clusterConfigPaths(arg)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			if err := generateClusterKubeconfig(ctx, sess, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func generateClusterKubeconfig(
+	ctx context.Context,
+	sess *session.Session,
+	path string,
+) error {
+	clusterConfig, err := config.LoadClusterConfig(path, "")
+	if err != nil {
+		return err
+	}
+
+	selected, err := clusterSelected(clusterConfig, kubeconfigGenerateFlagValues.clusters)
+	if err != nil {
+		return err
+	} else if !selected {
+		return nil
+	}
+
+	if clusterConfig.KubeConfigPath != "" {
+		log.Infof(
+			"Skipping cluster %s because it has an explicit kubeconfig path",
+			clusterConfig.DescriptiveName(),
+		)
+		return nil
+	}
+
+	kubeconfigPath := filepath.Join(
+		kubeconfigGenerateFlagValues.outputDir,
+		kubeconfigFileName(clusterConfig),
+	)
+
+	log.Infof(
+		"Generating kubeconfig for cluster %s",
+		clusterConfig.DescriptiveName(),
+	)
+	err = kube.CreateKubeconfigViaAPI(
+		ctx,
+		sess,
+		clusterConfig.Cluster,
+		clusterConfig.Region,
+		kubeconfigPath,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"Error generating kubeconfig for cluster %s: %+v",
+			clusterConfig.DescriptiveName(),
+			err,
+		)
+	}
+
+	fmt.Printf("%s: %s\n", clusterConfig.DescriptiveName(), kubeconfigPath)
+	return nil
+}
+
+// kubeconfigFileName returns the name of the generated kubeconfig file for the argument
+// cluster. Clusters are keyed on name and region since the same cluster name can be used
+// in multiple regions.
+func kubeconfigFileName(clusterConfig *config.ClusterConfig) string {
+	return fmt.Sprintf("%s_%s.yaml", clusterConfig.Cluster, clusterConfig.Region)
+}
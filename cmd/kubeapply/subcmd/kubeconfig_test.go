@@ -0,0 +1,21 @@
+package subcmd
+
+import (
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKubeconfigFileName(t *testing.T) {
+	assert.Equal(
+		t,
+		"test-cluster_us-west-2.yaml",
+		kubeconfigFileName(
+			&config.ClusterConfig{
+				Cluster: "test-cluster",
+				Region:  "us-west-2",
+			},
+		),
+	)
+}
@@ -0,0 +1,116 @@
+package subcmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/segmentio/kubeapply/pkg/star/expand"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [paths]",
+	Short: "lint checks starlark files for syntax and load errors without expanding them",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  lintRun,
+}
+
+type lintFlags struct {
+	// Root used for resolving file:// loads relative to the cluster root. If unset, uses the
+	// directory of each argument path.
+	root string
+
+	// Roots that are searched for module:// loads.
+	moduleRoots []string
+}
+
+var lintFlagValues lintFlags
+
+func init() {
+	lintCmd.Flags().StringVar(
+		&lintFlagValues.root,
+		"root",
+		"",
+		"Root for resolving file:// loads; defaults to the directory of each path",
+	)
+	lintCmd.Flags().StringSliceVar(
+		&lintFlagValues.moduleRoots,
+		"module-root",
+		[]string{},
+		"Directories to search for module:// loads; can be repeated",
+	)
+
+	RootCmd.AddCommand(lintCmd)
+}
+
+func lintRun(cmd *cobra.Command, args []string) error {
+	results := []expand.LintResult{}
+
+	for _, arg := range args {
+		argResults, err := lintPath(arg)
+		if err != nil {
+			return err
+		}
+		results = append(results, argResults...)
+	}
+
+	numErrors := 0
+
+	for _, result := range results {
+		if result.Err != nil {
+			log.Errorf("%s: %+v", result.Path, result.Err)
+			numErrors++
+		} else if result.MissingMain {
+			log.Warnf(
+				"%s: No main(ctx) entrypoint; file will only be used via loads",
+				result.Path,
+			)
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("Found errors in %d of %d starlark files", numErrors, len(results))
+	}
+
+	log.Infof("No errors found in %d starlark files", len(results))
+	return nil
+}
+
+func lintPath(path string) ([]expand.LintResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root := lintFlagValues.root
+	if root == "" {
+		if info.IsDir() {
+			root = path
+		} else {
+			root = filepath.Dir(path)
+		}
+	}
+
+	results := []expand.LintResult{}
+
+	err = filepath.Walk(
+		path,
+		func(subPath string, subInfo os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if subInfo.IsDir() || !strings.HasSuffix(subPath, ".star") {
+				return nil
+			}
+
+			log.Debugf("Linting %s", subPath)
+			results = append(results, expand.LintStarFile(subPath, root, lintFlagValues.moduleRoots))
+			return nil
+		},
+	)
+
+	return results, err
+}
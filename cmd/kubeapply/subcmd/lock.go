@@ -0,0 +1,267 @@
+package subcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/store"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "lock contains commands for inspecting and releasing cluster locks",
+}
+
+var lockListCmd = &cobra.Command{
+	Use:   "list [cluster configs]",
+	Short: "list shows the kubeapply locks in one or more clusters",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  lockListRun,
+}
+
+var lockReleaseCmd = &cobra.Command{
+	Use:   "release [cluster config]",
+	Short: "release force-releases a stuck kubeapply lock in a cluster",
+	Args:  cobra.ExactArgs(1),
+	RunE:  lockReleaseRun,
+}
+
+type lockFlags struct {
+	// Clusters to list the locks of; if unset, lists the locks of all clusters.
+	clusters []string
+
+	// Path to kubeconfig. If unset, tries to fetch from the environment.
+	kubeConfig string
+
+	// Name of the lock to release; defaults to the name of the cluster.
+	name string
+
+	// Whether to skip the confirmation before releasing a lock.
+	yes bool
+}
+
+var lockFlagValues lockFlags
+
+func init() {
+	for _, cmd := range []*cobra.Command{lockListCmd, lockReleaseCmd} {
+		cmd.Flags().StringVar(
+			&lockFlagValues.kubeConfig,
+			"kubeconfig",
+			"",
+			"Path to kubeconfig; multiple paths can be separated with colons, as in KUBECONFIG",
+		)
+	}
+	lockListCmd.Flags().StringArrayVar(
+		&lockFlagValues.clusters,
+		"cluster",
+		[]string{},
+		"List the locks in clusters matching the provided glob(s) only",
+	)
+	lockReleaseCmd.Flags().StringVar(
+		&lockFlagValues.name,
+		"name",
+		"",
+		"Name of the lock to release; defaults to the cluster name",
+	)
+	lockReleaseCmd.Flags().BoolVar(
+		&lockFlagValues.yes,
+		"yes",
+		false,
+		"Release without asking for confirmation",
+	)
+
+	lockCmd.AddCommand(lockListCmd)
+	lockCmd.AddCommand(lockReleaseCmd)
+	RootCmd.AddCommand(lockCmd)
+}
+
+func lockListRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	for _, arg := range args {
+		paths, err := clusterConfigPaths(arg)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			clusterConfig, err := config.LoadClusterConfig(path, "")
+			if err != nil {
+				return err
+			}
+
+			selected, err := clusterSelected(clusterConfig, lockFlagValues.clusters)
+			if err != nil {
+				return err
+			} else if !selected {
+				continue
+			}
+
+			err = withClusterLocker(
+				clusterConfig,
+				func(locker *store.KubeLocker) error {
+					locks, err := locker.List(ctx)
+					if err != nil {
+						return err
+					}
+					fmt.Printf(
+						"Cluster %s:\n%s\n",
+						clusterConfig.DescriptiveName(),
+						lockTable(locks, time.Now()),
+					)
+					return nil
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func lockReleaseRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterConfig, err := config.LoadClusterConfig(args[0], "")
+	if err != nil {
+		return err
+	}
+
+	name := lockFlagValues.name
+	if name == "" {
+		name = clusterConfig.Cluster
+	}
+
+	return withClusterLocker(
+		clusterConfig,
+		func(locker *store.KubeLocker) error {
+			lock, err := locker.Get(ctx, name)
+			if err != nil {
+				return err
+			}
+			if lock == nil {
+				return fmt.Errorf(
+					"No lock found with name %s in cluster %s",
+					name,
+					clusterConfig.DescriptiveName(),
+				)
+			}
+
+			fmt.Println(lockTable([]store.LockInfo{*lock}, time.Now()))
+
+			if !lockFlagValues.yes {
+				fmt.Print(
+					"Releasing a lock that's still in use can lead to concurrent applies. Are you sure? (yes/no) ",
+				)
+				var response string
+				_, err = fmt.Scanln(&response)
+				if err != nil {
+					return err
+				}
+				if strings.TrimSpace(response) != "yes" {
+					log.Infof("Not continuing")
+					return nil
+				}
+			} else {
+				log.Warn("Automatically continuing because --yes is true")
+			}
+
+			if err := locker.ForceRelease(ctx, name); err != nil {
+				return err
+			}
+			log.Infof(
+				"Released lock %s in cluster %s",
+				name,
+				clusterConfig.DescriptiveName(),
+			)
+			return nil
+		},
+	)
+}
+
+// withClusterLocker runs the argument function with a locker for the argument cluster.
+func withClusterLocker(
+	clusterConfig *config.ClusterConfig,
+	run func(locker *store.KubeLocker) error,
+) error {
+	kubeConfig := lockFlagValues.kubeConfig
+
+	if kubeConfig == "" {
+		kubeConfig = os.Getenv("KUBECONFIG")
+		if kubeConfig == "" {
+			return errors.New("Must either set --kubeconfig flag or KUBECONFIG env variable")
+		}
+	}
+
+	kubeConfigPaths := kubeConfig
+	kubeConfig, cleanup, err := kube.MergeKubeconfigs(kubeConfigPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	matches := kube.KubeconfigMatchesCluster(kubeConfig, clusterConfig.Cluster)
+	if !matches {
+		return fmt.Errorf(
+			"Kubeconfig in %s does not appear to reference cluster %s",
+			kubeConfigPaths,
+			clusterConfig.Cluster,
+		)
+	}
+
+	locker, err := store.NewKubeLocker(
+		kubeConfig,
+		"kubeapply-cli",
+		clusterConfig.ManagementNamespace,
+	)
+	if err != nil {
+		return err
+	}
+
+	return run(locker)
+}
+
+// lockTable returns a human-readable table of the argument locks.
+func lockTable(locks []store.LockInfo, now time.Time) string {
+	if len(locks) == 0 {
+		return "No locks found"
+	}
+
+	lines := []string{
+		fmt.Sprintf("%-30s %-40s %-10s %s", "NAME", "HOLDER", "STATE", "LAST RENEWED"),
+	}
+
+	for _, lock := range locks {
+		holder := lock.Holder
+		state := "held"
+
+		if holder == "" {
+			holder = "-"
+			state = "released"
+		} else if !lock.Held(now) {
+			state = "expired"
+		}
+
+		renewed := "-"
+		if !lock.RenewedAt.IsZero() {
+			renewed = fmt.Sprintf("%s ago", now.Sub(lock.RenewedAt).Round(time.Second))
+		}
+
+		lines = append(
+			lines,
+			fmt.Sprintf("%-30s %-40s %-10s %s", lock.Name, holder, state, renewed),
+		)
+	}
+
+	return strings.Join(lines, "\n")
+}
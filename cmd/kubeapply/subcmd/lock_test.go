@@ -0,0 +1,39 @@
+package subcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kubeapply/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockTable(t *testing.T) {
+	now := time.Now()
+
+	table := lockTable(
+		[]store.LockInfo{
+			{
+				Name:          "cluster1",
+				Holder:        "host-123",
+				RenewedAt:     now.Add(-5 * time.Second),
+				LeaseDuration: 20 * time.Second,
+			},
+			{
+				Name:          "cluster2",
+				Holder:        "host-456",
+				RenewedAt:     now.Add(-time.Hour),
+				LeaseDuration: 20 * time.Second,
+			},
+			{
+				Name: "cluster3",
+			},
+		},
+		now,
+	)
+
+	assert.Contains(t, table, "cluster1                       host-123                                 held       5s ago")
+	assert.Contains(t, table, "cluster2                       host-456                                 expired    1h0m0s ago")
+	assert.Contains(t, table, "cluster3                       -                                        released   -")
+	assert.Equal(t, "No locks found", lockTable(nil, now))
+}
@@ -0,0 +1,93 @@
+package subcmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package [cluster config]",
+	Short: "package bundles a cluster config and its expanded configs into an artifact for apply",
+	Args:  cobra.ExactArgs(1),
+	RunE:  packageRun,
+}
+
+type packageFlags struct {
+	// Whether to expand before packaging.
+	expand bool
+
+	// Path to write the artifact to.
+	output string
+}
+
+var packageFlagValues packageFlags
+
+func init() {
+	packageCmd.Flags().BoolVar(
+		&packageFlagValues.expand,
+		"expand",
+		false,
+		"Expand before packaging",
+	)
+	packageCmd.Flags().StringVar(
+		&packageFlagValues.output,
+		"output",
+		"",
+		"Path to write the artifact (a .tar.gz archive) to",
+	)
+	packageCmd.MarkFlagRequired("output")
+
+	RootCmd.AddCommand(packageCmd)
+}
+
+func packageRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if config.IsRemotePath(args[0]) {
+		return fmt.Errorf("Cannot package remote cluster config %s", args[0])
+	}
+
+	clusterConfig, err := config.LoadClusterConfig(args[0], "")
+	if err != nil {
+		return err
+	}
+
+	if packageFlagValues.expand {
+		if err := expandCluster(ctx, clusterConfig, false); err != nil {
+			return err
+		}
+	}
+
+	ok, err := util.DirExists(clusterConfig.ExpandedPath)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf(
+			"Expanded path %s does not exist",
+			clusterConfig.ExpandedPath,
+		)
+	}
+
+	log.Infof(
+		"Packaging cluster %s into %s",
+		clusterConfig.DescriptiveName(),
+		packageFlagValues.output,
+	)
+
+	return util.CreateArtifact(
+		ctx,
+		packageFlagValues.output,
+		clusterConfig.FullPath(),
+		clusterConfig.ExpandedPath,
+		util.ArtifactMetadata{
+			Cluster:   clusterConfig.DescriptiveName(),
+			CreatedAt: time.Now().UTC(),
+		},
+	)
+}
@@ -0,0 +1,110 @@
+package subcmd
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var paramsCmd = &cobra.Command{
+	Use:   "params [cluster configs]",
+	Short: "params prints out the effective parameters for one or more clusters",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  paramsRun,
+}
+
+type paramsFlags struct {
+	// Clusters to print the parameters of; if unset, prints them for all clusters.
+	clusters []string
+}
+
+var paramsFlagValues paramsFlags
+
+func init() {
+	paramsCmd.Flags().StringArrayVar(
+		&paramsFlagValues.clusters,
+		"cluster",
+		[]string{},
+		"Print the parameters of clusters matching the provided glob(s) only",
+	)
+
+	RootCmd.AddCommand(paramsCmd)
+}
+
+// clusterParams contains the effective parameters for a single profile in a cluster.
+type clusterParams struct {
+	Cluster    string                 `json:"cluster"`
+	Profile    string                 `json:"profile"`
+	Parameters map[string]interface{} `json:"parameters"`
+	StarParams map[string]interface{} `json:"starParams"`
+}
+
+func paramsRun(cmd *cobra.Command, args []string) error {
+	for _, arg := range args {
+		paths, err := clusterConfigPaths(arg)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			clusterConfig, err := config.LoadClusterConfig(path, "")
+			if err != nil {
+				return err
+			}
+
+			selected, err := clusterSelected(clusterConfig, paramsFlagValues.clusters)
+			if err != nil {
+				return err
+			} else if !selected {
+				continue
+			}
+
+			paramsBytes, err := formatClusterParams(clusterConfig)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(paramsBytes))
+		}
+	}
+
+	return nil
+}
+
+// formatClusterParams generates a YAML document with the effective parameters of each
+// profile that's expanded in the argument cluster. Nothing is expanded.
+func formatClusterParams(clusterConfig *config.ClusterConfig) ([]byte, error) {
+	profiles := []config.Profile{}
+
+	if len(clusterConfig.Profiles) > 0 {
+		for _, profile := range clusterConfig.Profiles {
+			if profile.EnabledForEnv(clusterConfig.Env) {
+				profiles = append(profiles, profile)
+			}
+		}
+	} else {
+		profiles = append(profiles, config.Profile{Name: "main"})
+	}
+
+	out := []byte{}
+
+	for _, profile := range profiles {
+		paramsBytes, err := yaml.Marshal(
+			clusterParams{
+				Cluster:    clusterConfig.DescriptiveName(),
+				Profile:    profile.Name,
+				Parameters: clusterConfig.EffectiveParameters(&profile),
+				StarParams: clusterConfig.StarParams(),
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, []byte("---\n")...)
+		out = append(out, paramsBytes...)
+	}
+
+	return out, nil
+}
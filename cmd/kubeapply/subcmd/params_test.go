@@ -0,0 +1,62 @@
+package subcmd
+
+import (
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatClusterParams(t *testing.T) {
+	clusterConfig := &config.ClusterConfig{
+		Cluster: "test-cluster",
+		Region:  "test-region",
+		Env:     "stage",
+		Parameters: map[string]interface{}{
+			"replicas": 2,
+			"image":    "app:v1",
+		},
+		Profiles: []config.Profile{
+			{
+				Name: "profile1",
+				URL:  "file://profile1",
+				Parameters: map[string]interface{}{
+					"replicas": 3,
+				},
+			},
+			{
+				Name:        "profile2",
+				URL:         "file://profile2",
+				EnabledEnvs: []string{"production"},
+			},
+		},
+	}
+	require.NoError(
+		t,
+		clusterConfig.SetDefaults("/git/repo/clusters/test.yaml", "/git/repo"),
+	)
+
+	paramsBytes, err := formatClusterParams(clusterConfig)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		`---
+cluster: stage:test-region:test-cluster
+parameters:
+  image: app:v1
+  replicas: 3
+profile: profile1
+starParams:
+  cluster: test-cluster
+  env: stage
+  image: app:v1
+  parameters:
+    image: app:v1
+    replicas: 2
+  region: test-region
+  replicas: 2
+`,
+		string(paramsBytes),
+	)
+}
@@ -29,15 +29,24 @@ type pullRequestFlags struct {
 	// Whether to automerge if applies in all clusters have completed successfully
 	automerge bool
 
+	// Number of commits to fetch when cloning the repo
+	cloneDepth int
+
 	// The body of the comment in the webhook
 	commentBody string
 
+	// Directory with override templates for comments
+	commentTemplatesDir string
+
 	// Environment to evaluate hook in
 	env string
 
 	// Type of event in github webhook
 	eventType string
 
+	// Whether to clone the full history of the repo
+	fullClone bool
+
 	// Key for requests to github API (via github app private key)
 	githubAppKey string
 
@@ -66,6 +75,9 @@ type pullRequestFlags struct {
 
 	// Whether a review is required to apply
 	reviewRequired bool
+
+	// Whether to only check out the directories with changed files
+	sparseCheckout bool
 }
 
 var pullRequestFlagValues pullRequestFlags
@@ -77,12 +89,24 @@ func init() {
 		false,
 		"Automerge value for kubeapply lambda",
 	)
+	pullRequestCmd.Flags().IntVar(
+		&pullRequestFlagValues.cloneDepth,
+		"clone-depth",
+		pullreq.DefaultCloneDepth,
+		"Number of commits to fetch when cloning the repo",
+	)
 	pullRequestCmd.Flags().StringVar(
 		&pullRequestFlagValues.commentBody,
 		"comment-body",
 		"kubeapply help",
 		"Comment in pull request",
 	)
+	pullRequestCmd.Flags().StringVar(
+		&pullRequestFlagValues.commentTemplatesDir,
+		"comment-templates-dir",
+		"",
+		"Directory with override templates for comments",
+	)
 	pullRequestCmd.Flags().StringVar(
 		&pullRequestFlagValues.env,
 		"env",
@@ -95,6 +119,18 @@ func init() {
 		"comment",
 		"Event type",
 	)
+	pullRequestCmd.Flags().BoolVar(
+		&pullRequestFlagValues.fullClone,
+		"full-clone",
+		false,
+		"Clone the full history of the repo",
+	)
+	pullRequestCmd.Flags().BoolVar(
+		&pullRequestFlagValues.sparseCheckout,
+		"sparse-checkout",
+		false,
+		"Only check out the directories with changed files",
+	)
 	pullRequestCmd.Flags().StringVar(
 		&pullRequestFlagValues.githubToken,
 		"github-token",
@@ -180,6 +216,14 @@ func pullRequestPreRun(cmd *cobra.Command, args []string) error {
 func pullRequestRun(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 	var webhookType string
+
+	if pullRequestFlagValues.commentTemplatesDir != "" {
+		err := pullreq.LoadCommentTemplates(pullRequestFlagValues.commentTemplatesDir)
+		if err != nil {
+			return err
+		}
+	}
+
 	var webhookObj interface{}
 
 	var accessToken string
@@ -264,6 +308,11 @@ func pullRequestRun(cmd *cobra.Command, args []string) error {
 		webhookType,
 		webhookBytes,
 		accessToken,
+		pullreq.GHPullRequestClientSettings{
+			CloneDepth:     pullRequestFlagValues.cloneDepth,
+			FullClone:      pullRequestFlagValues.fullClone,
+			SparseCheckout: pullRequestFlagValues.sparseCheckout,
+		},
 	)
 	if err != nil {
 		return err
@@ -288,6 +337,7 @@ func pullRequestRun(cmd *cobra.Command, args []string) error {
 			GreenCIRequired:       pullRequestFlagValues.greenCIRequired,
 			ReviewRequired:        pullRequestFlagValues.reviewRequired,
 			Debug:                 debug,
+			Quiet:                 quiet,
 		},
 	)
 	resp := webhookHandler.HandleWebhook(
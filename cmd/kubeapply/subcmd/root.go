@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/fatih/color"
+	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/segmentio/kubeapply/pkg/version"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -16,7 +18,16 @@ var (
 	ErrTooManyArguments = errors.New("too many arguments")
 	ErrTooFewArguments  = errors.New("too few arguments")
 
-	debug bool
+	debug     bool
+	quiet     bool
+	colorMode string
+	tmpDir    string
+)
+
+const (
+	colorModeAuto   = "auto"
+	colorModeAlways = "always"
+	colorModeNever  = "never"
 )
 
 // RootCmd is the main command for the cobra CLI.
@@ -37,11 +48,33 @@ func init() {
 		false,
 		"Enable debug logging",
 	)
+	RootCmd.PersistentFlags().BoolVar(
+		&quiet,
+		"quiet",
+		false,
+		"Log routine kubectl output at the debug level instead of the info level",
+	)
+	RootCmd.PersistentFlags().StringVar(
+		&colorMode,
+		"color",
+		colorModeAuto,
+		"When to use colors in the output; one of auto, always, or never",
+	)
+	RootCmd.PersistentFlags().StringVar(
+		&tmpDir,
+		"tmp-dir",
+		"",
+		fmt.Sprintf(
+			"Base directory for temporary files; defaults to $%s or the system temp directory",
+			util.TempDirEnvVar,
+		),
+	)
 }
 
 // Execute runs kubeapply.
 func Execute(versionRef string) {
-	RootCmd.Version = fmt.Sprintf("v%s (ref:%s)", version.Version, versionRef)
+	versionInfo = version.GetInfo(versionRef)
+	RootCmd.Version = versionInfo.String()
 
 	if err := RootCmd.Execute(); err != nil {
 		log.Error(err)
@@ -57,7 +90,34 @@ func prerunE(cmd *cobra.Command, args []string) error {
 	if debug {
 		log.SetLevel(log.DebugLevel)
 	}
+
+	if tmpDir != "" {
+		if err := os.Setenv(util.TempDirEnvVar, tmpDir); err != nil {
+			return err
+		}
+	}
+
+	switch colorMode {
+	case colorModeAuto:
+		// The color library disables colors by default if stdout isn't a terminal
+	case colorModeAlways:
+		color.NoColor = false
+	case colorModeNever:
+		color.NoColor = true
+	default:
+		return fmt.Errorf(
+			"Invalid color mode %s; must be one of auto, always, or never",
+			colorMode,
+		)
+	}
+
 	return nil
 }
 
+// useColors returns whether the output should include colors based on the --color flag
+// and, in auto mode, whether stdout is a terminal.
+func useColors() bool {
+	return !color.NoColor
+}
+
 func postrun(cmd *cobra.Command, args []string) {}
@@ -0,0 +1,28 @@
+package subcmd
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorMode(t *testing.T) {
+	origNoColor := color.NoColor
+	origColorMode := colorMode
+	defer func() {
+		color.NoColor = origNoColor
+		colorMode = origColorMode
+	}()
+
+	colorMode = colorModeAlways
+	assert.NoError(t, prerunE(RootCmd, nil))
+	assert.True(t, useColors())
+
+	colorMode = colorModeNever
+	assert.NoError(t, prerunE(RootCmd, nil))
+	assert.False(t, useColors())
+
+	colorMode = "sometimes"
+	assert.Error(t, prerunE(RootCmd, nil))
+}
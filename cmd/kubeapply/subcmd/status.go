@@ -0,0 +1,178 @@
+package subcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/segmentio/kubeapply/pkg/cluster"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [cluster configs]",
+	Short: "status summarizes the current workloads in one or more clusters",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  statusRun,
+}
+
+type statusFlags struct {
+	// Clusters to get the status of; if unset, gets the status of all clusters.
+	clusters []string
+
+	// Path to kubeconfig. If unset, tries to fetch from the environment.
+	kubeConfig string
+
+	// Output format; either "text" or "json".
+	output string
+}
+
+var statusFlagValues statusFlags
+
+// clusterStatus is the status of a single cluster in the JSON output.
+type clusterStatus struct {
+	Cluster string                 `json:"cluster"`
+	Summary kube.StructuredSummary `json:"summary"`
+}
+
+func init() {
+	statusCmd.Flags().StringArrayVar(
+		&statusFlagValues.clusters,
+		"cluster",
+		[]string{},
+		"Get the status of clusters matching the provided glob(s) only",
+	)
+	statusCmd.Flags().StringVar(
+		&statusFlagValues.kubeConfig,
+		"kubeconfig",
+		"",
+		"Path to kubeconfig; multiple paths can be separated with colons, as in KUBECONFIG",
+	)
+	statusCmd.Flags().StringVar(
+		&statusFlagValues.output,
+		"output",
+		"text",
+		"Output format; either text or json",
+	)
+
+	RootCmd.AddCommand(statusCmd)
+}
+
+func statusRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if statusFlagValues.output != "text" && statusFlagValues.output != "json" {
+		return fmt.Errorf("Unrecognized output format: %s", statusFlagValues.output)
+	}
+
+	statuses := []clusterStatus{}
+
+	for _, arg := range args {
+		paths, err := clusterConfigPaths(arg)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			clusterConfig, err := config.LoadClusterConfig(path, "")
+			if err != nil {
+				return err
+			}
+
+			selected, err := clusterSelected(clusterConfig, statusFlagValues.clusters)
+			if err != nil {
+				return err
+			} else if !selected {
+				continue
+			}
+
+			status, err := statusCluster(ctx, clusterConfig)
+			if err != nil {
+				return err
+			}
+			if status != nil {
+				statuses = append(statuses, *status)
+			}
+		}
+	}
+
+	if statusFlagValues.output == "json" {
+		statusesBytes, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(statusesBytes))
+	}
+
+	return nil
+}
+
+// statusCluster gets the status of the argument cluster. In text mode, the summary is printed
+// directly and nil is returned.
+func statusCluster(
+	ctx context.Context,
+	clusterConfig *config.ClusterConfig,
+) (*clusterStatus, error) {
+	kubeConfig := statusFlagValues.kubeConfig
+
+	if kubeConfig == "" {
+		kubeConfig = os.Getenv("KUBECONFIG")
+		if kubeConfig == "" {
+			return nil, errors.New("Must either set --kubeconfig flag or KUBECONFIG env variable")
+		}
+	}
+
+	kubeConfigPaths := kubeConfig
+	kubeConfig, cleanup, err := kube.MergeKubeconfigs(kubeConfigPaths)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	matches := kube.KubeconfigMatchesCluster(kubeConfig, clusterConfig.Cluster)
+	if !matches {
+		return nil, fmt.Errorf(
+			"Kubeconfig in %s does not appear to reference cluster %s",
+			kubeConfigPaths,
+			clusterConfig.Cluster,
+		)
+	}
+	clusterConfig.KubeConfigPath = kubeConfig
+
+	kubeClient, err := cluster.NewKubeClusterClient(
+		ctx,
+		&cluster.ClusterClientConfig{
+			ClusterConfig: clusterConfig,
+			Debug:         debug,
+			Quiet:         quiet,
+			UseLocks:      false,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer kubeClient.Close()
+
+	if statusFlagValues.output == "json" {
+		summary, err := kubeClient.SummaryStructured(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &clusterStatus{
+			Cluster: clusterConfig.DescriptiveName(),
+			Summary: summary,
+		}, nil
+	}
+
+	summary, err := kubeClient.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Cluster %s:\n%s\n", clusterConfig.DescriptiveName(), summary)
+
+	return nil, nil
+}
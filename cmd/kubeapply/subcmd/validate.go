@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"path/filepath"
 
 	"github.com/segmentio/kubeapply/pkg/config"
 	"github.com/segmentio/kubeapply/pkg/util"
@@ -62,7 +61,7 @@ func validateRun(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
 	for _, arg := range args {
-		paths, err := filepath.Glob(arg)
+		paths, err := clusterConfigPaths(arg)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,59 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/segmentio/kubeapply/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "version prints out the kubeapply version",
+	Args:  cobra.MaximumNArgs(0),
+	RunE:  versionRun,
+}
+
+type versionFlags struct {
+	// Whether to print the version details in JSON format
+	json bool
+}
+
+var versionFlagValues versionFlags
+
+// versionInfo contains the details of the current build; it's set in Execute.
+var versionInfo = version.GetInfo("dev")
+
+func init() {
+	versionCmd.Flags().BoolVar(
+		&versionFlagValues.json,
+		"json",
+		false,
+		"Print version details in JSON format",
+	)
+
+	RootCmd.AddCommand(versionCmd)
+}
+
+func versionRun(cmd *cobra.Command, args []string) error {
+	versionStr, err := formatVersion(versionInfo, versionFlagValues.json)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(versionStr)
+	return nil
+}
+
+func formatVersion(info version.Info, jsonFormat bool) (string, error) {
+	if !jsonFormat {
+		return info.String(), nil
+	}
+
+	jsonBytes, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,39 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatVersion(t *testing.T) {
+	info := version.Info{
+		Version:   "1.2.3",
+		Ref:       "v1.2.3-4-gabc1234",
+		GoVersion: "go1.20",
+		BuildDate: "2021-01-02T03:04:05Z",
+	}
+
+	textOutput, err := formatVersion(info, false)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3 (ref:v1.2.3-4-gabc1234)", textOutput)
+
+	jsonOutput, err := formatVersion(info, true)
+	require.NoError(t, err)
+
+	jsonObj := map[string]string{}
+	require.NoError(t, json.Unmarshal([]byte(jsonOutput), &jsonObj))
+	assert.Equal(
+		t,
+		map[string]string{
+			"version":   "1.2.3",
+			"ref":       "v1.2.3-4-gabc1234",
+			"goVersion": "go1.20",
+			"buildDate": "2021-01-02T03:04:05Z",
+		},
+		jsonObj,
+	)
+}
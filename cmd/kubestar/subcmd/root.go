@@ -2,7 +2,6 @@ package subcmd
 
 import (
 	"errors"
-	"fmt"
 	"os"
 
 	"github.com/segmentio/kubeapply/pkg/version"
@@ -41,7 +40,7 @@ func init() {
 
 // Execute runs kubestar.
 func Execute(versionRef string) {
-	RootCmd.Version = fmt.Sprintf("v%s (ref:%s)", version.Version, versionRef)
+	RootCmd.Version = version.GetInfo(versionRef).String()
 
 	if err := RootCmd.Execute(); err != nil {
 		log.Error(err)
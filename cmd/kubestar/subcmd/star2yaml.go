@@ -43,6 +43,7 @@ func init() {
 
 func star2yamlRun(cmd *cobra.Command, args []string) error {
 	var starParams map[string]interface{}
+	var moduleRoots []string
 
 	if star2yamlFlagValues.clusterConfig != "" {
 		clusterConfig, err := config.LoadClusterConfig(
@@ -53,6 +54,7 @@ func star2yamlRun(cmd *cobra.Command, args []string) error {
 			return err
 		}
 		starParams = clusterConfig.StarParams()
+		moduleRoots = clusterConfig.StarlarkModulePaths
 	} else {
 		starParams = map[string]interface{}{}
 	}
@@ -77,7 +79,7 @@ func star2yamlRun(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	result, err := expand.StarToYaml(args[0], cwd, starParams)
+	result, err := expand.StarToYaml(args[0], cwd, moduleRoots, starParams)
 	if err != nil {
 		return err
 	}
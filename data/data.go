@@ -1,10 +1,11 @@
 // Code generated by go-bindata. DO NOT EDIT.
 // sources:
-// pkg/pullreq/templates/apply_comment.gotpl (1.052kB)
-// pkg/pullreq/templates/diff_comment.gotpl (1.228kB)
+// pkg/pullreq/templates/apply_comment.gotpl (1.283kB)
+// pkg/pullreq/templates/diff_comment.gotpl (2.254kB)
 // pkg/pullreq/templates/error_comment.gotpl (172B)
-// pkg/pullreq/templates/help_comment.gotpl (1.025kB)
-// pkg/pullreq/templates/status_comment.gotpl (355B)
+// pkg/pullreq/templates/help_comment.gotpl (1.626kB)
+// pkg/pullreq/templates/locks_comment.gotpl (649B)
+// pkg/pullreq/templates/status_comment.gotpl (603B)
 // scripts/cluster-summary/__init__.py (0)
 // scripts/cluster-summary/cluster_summary.py (4.488kB)
 // scripts/cluster-summary/tabulate.py (57.091kB)
@@ -78,7 +79,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _pkgPullreqTemplatesApply_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xbc\x53\x3b\x6e\x1b\x31\x10\xed\xf7\x14\x03\x28\x85\x2c\xc0\x6b\xd7\x82\x62\x20\xde\xa4\x08\x0c\xc8\x86\xf3\xa9\xf7\x37\x92\x08\x50\x24\xc3\x21\x2d\x08\x2b\x9e\x21\x5d\x8a\x34\x49\x91\x43\xe4\x3c\xb9\x40\x72\x84\x80\x9f\x95\x76\x63\xd5\xd9\x82\xcb\xf9\xf0\xcd\x9b\xe1\xe3\x64\x32\x81\x3f\xdf\x7e\x7c\x81\x3b\x5b\x63\xa5\x14\xdf\x43\x5c\x35\x92\xe5\x06\xba\x0e\xd8\x0a\xf2\x37\xe2\x09\x9c\x9b\x76\x5d\xbf\xbd\xe8\x3a\x40\xd1\x82\x73\x59\xd6\x75\x97\xf0\xa2\xc6\x0d\x13\xed\xed\x1e\xe6\x2f\x21\x7f\xb0\x9c\x3f\xe2\x27\x8b\x64\x0a\xce\x50\x98\xfc\xb6\x0f\x3b\x17\xf2\xd9\x0a\xd6\x66\x70\xea\xda\x07\x7e\x7d\xfd\xfe\xfb\xe7\x67\x78\xbf\x61\x04\xcd\xa6\x12\x6b\x04\x46\x10\x73\xa0\xf4\xc5\xcf\x00\x57\x84\xe0\x5c\x09\xf5\xde\x93\x3d\x21\x3a\x07\x8d\xdc\x6e\x99\xa1\x3c\x54\x1c\xb2\xf5\x2d\x15\xdc\x92\x41\xfd\x4a\x29\xce\x90\x7a\x5e\x3a\x54\x3d\x13\xcc\x26\x7e\x54\xc9\x3f\x8f\x6c\x92\x55\x48\xb1\x62\xeb\xfc\x35\x52\xa3\x99\x32\xec\x09\x97\xd5\x36\x90\x5a\xd4\xfa\xea\x26\x2c\xef\x6c\xad\x2a\xb3\x21\x98\x3e\x3f\x98\x62\x85\xb4\xc2\xf8\xd1\xce\xe1\x79\xce\x83\x46\x63\xf6\x47\x14\xe7\x4e\xd0\x1f\x54\x5b\x19\x6c\xfd\x8d\x49\xab\x1b\x4c\x35\x96\x76\x1b\x23\x14\x30\xb3\x6c\xa1\x6e\x7c\xf7\xbe\xf9\xe9\xda\x8c\x12\xae\x2f\x7c\x8b\x07\xf0\xbc\x49\x55\x0d\xc2\x01\xee\xfc\xd0\xa3\x0b\x0e\x70\xcf\x5b\xf8\x88\x9a\x98\x14\xde\x89\xbb\x93\x95\x1d\xe0\xb2\xff\x20\xee\xc7\xbf\x71\xec\x68\x0d\xe7\xfd\x18\xd4\x46\x03\x75\xe4\x6f\xa9\xd0\x18\x1a\x0b\xd4\x42\x4b\x47\x7a\xce\x41\x74\x05\x96\x47\x2b\xcd\x3d\x59\xf7\xbc\xed\x49\x06\xdf\x6c\x16\x72\x70\x77\xf2\xce\x66\x89\x07\x72\xc2\x54\xb6\x9f\xe7\x7f\x29\x1b\x45\xf9\xef\x96\x07\x4d\x67\x65\x59\x66\x4b\x39\xb8\xd8\x1d\x6a\x04\x1b\xf9\xe5\x21\x3c\x54\xf6\xe2\x2a\xde\xf0\x58\xeb\x3d\xd8\x52\x42\x13\x25\x95\x5e\xb8\x54\xa8\x2b\xc3\xa4\x48\xb8\xad\x14\x38\x7a\x2a\x7f\x03\x00\x00\xff\xff\x7c\xc1\x2b\x9c\x1c\x04\x00\x00")
+var _pkgPullreqTemplatesApply_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xbc\x54\xbd\x8e\xda\x40\x10\xee\xfd\x14\x13\x91\x02\x50\xec\xbb\x1a\x11\xa4\xc3\xa1\x40\x9c\x38\x04\x5c\xa4\x54\xf1\xda\x9e\x83\x55\x8c\xed\xec\xae\x0f\x21\xf0\x33\xa4\x4b\x91\x26\x29\xee\x21\xee\x79\xf2\x02\xc9\x23\x64\x76\xd7\xc6\x90\x43\x4a\x17\x17\xf6\xcc\xce\xdf\xb7\x33\xdf\xb8\xd5\x6a\xc1\xef\xef\x4f\x5f\x61\x52\x84\xc8\xf2\x3c\xd9\x81\x7d\x0b\x94\x45\xa2\x60\xbf\x07\xfe\x00\xde\x28\x7d\x84\xb2\x6c\x93\x56\x89\x1d\x12\x31\x8d\x49\x72\x9c\xfd\xde\x85\xd7\x21\xae\x79\x1a\x0f\x77\xd0\x7b\x0b\xde\xac\x48\x92\x39\x7e\x2e\x50\x2a\x3f\xe1\x98\x2a\x6f\x58\x9b\x29\x40\xfb\x53\xd2\x95\x3a\x89\xba\xd6\x86\x9f\xdf\x7e\xfc\x7a\xfe\x02\xcb\x35\x97\x10\xad\x59\xba\x42\x20\xc9\xfa\x40\xa0\x8b\x5f\x48\xcc\x24\x52\x6c\x00\xe1\x4e\x83\x6d\x32\x96\x25\x44\xd9\x66\xc3\x95\xf4\x4c\xc5\x53\xb4\xfa\x4a\x7e\x52\x48\x85\xe2\x86\x6e\xcb\x51\xd6\xb8\x84\xa9\x7a\xc1\xd8\x7f\xe5\xba\x30\xb9\x1f\x8e\x6e\x66\xb3\xdb\x0f\x1f\x17\xb3\xdb\xf1\x12\x5c\x77\xf0\xc2\x30\xf2\x97\xe3\xbb\xa9\xc6\x52\x67\xf1\xb3\xf4\x81\xaf\xbc\x77\x28\x23\xc1\x73\xc5\x1f\x71\xca\x36\x1a\xb4\x89\x77\x5a\xf4\x40\xe5\xda\xb3\xd7\xfc\x57\x60\xd0\x0f\xc5\xd5\xc0\xbc\x16\x45\x98\x33\xb5\x96\xd0\x7e\x19\x58\xd9\xfc\xac\x48\x95\x9e\x59\xef\x02\xaa\x99\x40\xa5\x76\xc7\x2c\x65\xd9\xa4\xbe\xcf\x63\xa6\x30\xd6\x54\xc8\x0a\x11\x61\x55\x63\x5a\x6c\xac\x45\x9a\x9c\x8e\xd3\xcf\x07\xba\xad\xa6\xab\x8b\x4f\x3c\xcf\xd1\x74\x3a\x08\x02\xa7\x56\x43\x8c\x58\x41\x83\x52\x66\xb4\x16\x00\x6c\x99\x04\x96\x08\x64\xb1\x25\x1d\x27\x47\xa6\x2a\x1f\x33\x3a\x93\xc3\x0c\x2f\xa1\x60\xca\xdf\x26\xd2\x9c\x02\xb8\xee\xe8\x4a\x07\xd0\x7d\x91\x39\x8b\x10\x0e\x30\xd1\x6c\xb1\x47\xf4\xb9\x4b\x62\x78\x8f\x42\xf2\x2c\xd5\x87\xb8\x6d\x34\x8a\x73\xeb\x07\xac\x7c\xfe\x39\xb7\x1d\xb5\x53\xa2\xcc\xcd\x9a\xc8\x13\x5a\x7b\x63\xe9\xd3\x9d\x94\x6d\xc2\xc1\xb4\xbc\x81\x47\x43\xb7\x47\x06\xe5\x51\xab\x09\x61\x35\xc2\x5c\x83\x34\x67\xdd\xae\xf1\xc1\x6d\x73\xda\xed\x56\x38\xea\xce\x50\xd9\x7a\x5e\xff\xa5\xac\xdd\xa6\xbf\xc5\xc4\x2c\xa3\x19\xdb\x34\xab\x76\x58\x1e\x87\x5b\xd3\x67\x7e\x24\x14\xd5\x69\xd8\x25\x30\xca\xd2\x88\x27\x18\xbf\xa1\xff\x81\x0d\x8e\x3b\x0d\x07\xaa\x05\xee\x5f\x59\xbe\x9d\xaf\x74\x5d\x5a\x97\xad\xf8\x65\x7f\x64\x59\x8e\x82\x29\x82\x2f\x61\x8b\x02\x21\xce\x52\x3c\xfb\x23\xfc\x01\x00\x00\xff\xff\x03\x00\x77\x7f\xe5\xc8\x03\x05\x00\x00")
 
 func pkgPullreqTemplatesApply_commentGotplBytes() ([]byte, error) {
 	return bindataRead(
@@ -93,12 +94,12 @@ func pkgPullreqTemplatesApply_commentGotpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "pkg/pullreq/templates/apply_comment.gotpl", size: 1052, mode: os.FileMode(0644), modTime: time.Unix(1702514262, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x39, 0x98, 0xb8, 0x81, 0x9e, 0xbb, 0x97, 0xdd, 0x58, 0x91, 0x82, 0x55, 0x99, 0xef, 0x6c, 0xc3, 0x49, 0xe7, 0xe0, 0x9d, 0x4, 0x30, 0x35, 0x16, 0xb9, 0xa3, 0x44, 0x34, 0x84, 0x2e, 0x0, 0x1b}}
+	info := bindataFileInfo{name: "pkg/pullreq/templates/apply_comment.gotpl", size: 1283, mode: os.FileMode(0644), modTime: time.Unix(1792151253, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa7, 0x1b, 0x45, 0x34, 0xd8, 0xbf, 0x86, 0x94, 0xe8, 0x35, 0x68, 0xca, 0x82, 0x78, 0x2c, 0x6f, 0x76, 0x95, 0x75, 0xbe, 0xbf, 0xe1, 0xc3, 0x25, 0xd2, 0xe0, 0x52, 0x15, 0x25, 0x9a, 0x72, 0xb1}}
 	return a, nil
 }
 
-var _pkgPullreqTemplatesDiff_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x54\x3d\x6e\xdb\x3e\x14\xdf\x79\x8a\xf7\x87\xff\x83\x0d\x54\x72\x86\x76\x31\x54\x01\x8d\x93\xa1\x48\x60\x18\x4e\x3a\x74\xaa\xbe\x9e\x6c\x22\x34\xa9\x92\x8f\x71\x0d\x41\x37\x28\xda\x25\xe8\xd0\x25\x1d\x3a\xe6\x00\x3d\x4f\x2f\xd0\x1c\xa1\x20\x25\xc7\x2a\xdc\x02\x5e\x04\x89\x8f\xfc\x7d\xbc\xdf\xa3\x06\x83\x01\x3c\xde\xdf\x3d\xc0\x85\xcd\x30\xad\x2a\xb1\x85\x82\x97\x25\x68\x34\x56\x10\xd4\x35\xf0\x12\xc2\x73\x79\x0b\x4d\x33\xac\xeb\xdd\xeb\xa8\xae\x01\x65\x01\x4d\xc3\x58\x5d\x07\xf0\x7f\x86\x2b\x2e\x8b\xd3\x2d\x4c\x5e\x42\x38\xb7\x42\x2c\xf0\xbd\x45\x43\x53\xc1\x51\x52\x78\xba\x2b\x37\x8d\xdf\xcf\x4b\x58\x52\xef\xd4\x89\x2b\xfc\xfc\xfa\xed\xd7\x8f\xcf\x70\xbd\xe2\x06\xf2\x55\x2a\x97\x08\xdc\x40\xbb\x07\x12\x47\xfe\x17\xe0\xd4\x20\x34\x4d\x02\xd9\xd6\x89\xdd\x23\x36\x0d\xe4\x6a\xbd\xe6\x64\x42\xcf\xd8\x57\xeb\x2c\x4d\x85\x35\x84\xfa\x8c\x97\xa5\xd9\xa9\xd2\x9e\xf3\xa0\xc4\x06\xae\x4b\xdd\xea\xa4\x55\xd2\x7d\x4d\x95\x2c\xf9\x32\x3c\x43\x93\x6b\x5e\x11\xbf\xc5\x59\xba\xf6\x82\xa2\x4c\x8f\x63\xff\xb8\xb2\x59\x95\xd2\xca\xc0\xf0\xf0\x60\x57\x9b\x2a\x2b\xc9\xb5\x75\x02\x87\x7b\xe6\x1a\x89\xb6\x4f\x28\xad\x09\xe7\x61\xb8\x24\x18\x0a\x94\x10\x2e\x7c\x5a\x66\x04\x27\x23\x57\xf7\x7a\x17\x68\x94\xd5\x39\x1a\xd8\x70\x5a\xf9\x54\x5b\x09\xfd\x13\x8e\x92\xf5\xbd\x77\xeb\x0e\x25\x2a\x90\x52\x2e\x4c\xcc\x22\x63\xd7\xeb\x54\x6f\xe3\x28\x8b\xa3\x5c\x15\x18\x3b\x95\x9d\xd3\x68\xec\x57\x5a\x77\x33\xbb\x9e\xfa\xe4\x8a\x4b\x2e\xd1\xc1\x80\xf0\x2f\x6d\x9e\xc5\x28\x1a\x67\x71\x34\xde\xe1\xb1\xa8\x8a\x19\x4b\x92\xc4\xa9\x63\xad\x75\x5e\x55\x58\x2c\xd2\x8d\x6b\x3f\x3c\x7f\x71\xe2\x47\x23\x49\x12\xc6\xa2\x71\x15\xb3\x68\xbc\x97\xf5\x5f\x10\xc0\xc5\x9b\xd3\xf3\x57\xf3\xf9\xe5\xdb\x77\x57\xf3\xcb\xd7\xd7\x10\x04\x31\xdb\x0f\xa7\x4f\x5e\xf8\x11\xf1\x18\x33\xd5\x35\x62\x83\x1a\xa1\x54\x56\x16\xa1\x2f\xf4\x47\xc4\xb7\x6f\x86\x1f\x08\x0c\x61\x65\x18\x0b\xe0\xf1\xfe\xfb\x17\xb8\x56\xd0\xde\x10\x5a\xa1\xc1\x0e\x88\x4b\xf7\x09\x79\x1b\xd9\x33\xa8\x94\xa1\x09\x03\x00\x08\x20\xb9\x79\xba\x54\xed\xf3\xa8\xc9\xf1\x74\x1f\x3f\x39\x3a\x83\xe8\xd1\x0d\xa5\x64\x0d\xa8\x12\x52\x21\x20\xb7\x5a\xa3\x24\xd8\x28\x7d\x23\x54\x5a\x1c\x2d\xa2\x83\x39\x5e\xc5\xdd\x83\x53\xa1\x31\x58\xa2\x44\x9d\x12\xf6\xad\xff\x93\xc6\xff\x40\x8e\x23\x39\xb8\x9a\xbb\xb0\x66\x6a\xe7\x06\x72\x7f\xbc\x9b\xa0\x2e\xb9\x02\x09\x73\xc2\xe2\x8f\xbb\xfd\x3b\x00\x00\xff\xff\x10\xf5\xb8\x39\xcc\x04\x00\x00")
+var _pkgPullreqTemplatesDiff_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x56\xc1\x6e\xdb\x46\x10\xbd\xf3\x2b\xa6\x90\x81\x48\x40\x48\xf9\x90\x5e\x1c\xd5\x40\x2c\x1b\xb5\x61\x47\x11\x6c\xe7\xd0\x53\xb4\x22\x47\xe6\xc2\xd4\x2e\xb3\xbb\xb4\x22\x18\xbe\xe5\x18\xa4\x97\xa2\x87\x5e\xd2\x43\x81\x02\x45\x3f\xa0\xf9\x9d\xfc\x40\xf3\x09\x9d\x9d\x25\x25\xca\x72\x50\xeb\x40\xec\x72\x76\x66\xdf\xcc\xbc\x79\x54\xa7\xd3\x81\xaf\x9f\x7e\xf9\x1b\x4e\xab\x29\x8a\xb2\x2c\x96\x90\xc9\xd9\x0c\x0c\xda\xaa\x70\x70\x7b\x0b\x72\x06\xc9\x91\xba\x81\xbb\xbb\x2e\xed\xea\x65\x8f\x96\xa8\x32\x5a\x45\xd1\xed\x6d\x0c\x3b\x53\xcc\xa5\xca\x0e\x96\xb0\xf7\x03\x24\xe3\xaa\x28\xce\xf1\x6d\x85\xd6\x0d\x0b\x89\xca\x25\x07\x8d\x99\x1c\xfc\x79\x0a\x7a\xe5\x5a\x5e\xbb\xde\xf0\xe5\xb7\xdf\xff\xfd\xe7\x67\xb8\xcc\xa5\x85\x34\x17\xea\x0a\x81\x56\xe1\x0c\x4c\xfc\xe5\x0f\x04\x16\x16\xc9\x77\x02\xd3\xa5\x07\xbb\x8e\x78\x77\x07\xa9\x9e\xcf\xa5\xb3\x09\xdf\xd8\x46\xeb\x53\x1a\x16\x95\x75\x68\x0e\x29\x59\xdb\xa0\x32\x7c\xe7\x96\x69\xf0\x5d\x1c\xc3\xe9\xeb\x83\xa3\x17\xe3\xf1\xd9\x4f\x6f\x2e\xc6\x67\x27\x97\x10\xc7\xfb\x5b\x86\xa3\xe1\xe5\xc9\xab\x91\xc7\xd1\xc4\x18\x6a\x35\x93\x57\xc9\x21\xda\xd4\xc8\xd2\xc9\x1b\x1c\x89\xb9\x07\xcc\xfe\x51\x87\x7e\x50\x1f\xdd\x0b\x29\xfe\x9f\xe3\x64\x30\x35\xfd\x7d\x7e\x5c\x54\xd3\x52\xb8\xdc\x42\x77\xdb\xb1\xb6\x0d\x75\xa5\x9c\xef\xd7\xde\x03\xa8\xc6\x06\x9d\x5b\xae\xa2\xac\x5b\x93\x9c\xa8\xd4\xe0\x9c\xea\x2b\x8a\x0b\xa9\x52\xe4\xca\x7d\x79\xff\xd9\xb7\xe7\x95\x22\x8e\xb8\x1c\xc1\x36\x8e\xa1\x57\x19\x58\x3e\xea\x4d\x85\xb0\x2e\xf0\x48\xb8\x90\xd6\x03\x11\x27\xb0\x40\x83\x7c\x0c\xb3\x80\x2f\x20\x6a\x9f\x5d\x83\x4b\xe0\x05\xd1\x53\xa2\x05\xeb\x64\x51\x50\x77\x6f\xd0\x80\xa0\x95\x9e\x6d\xe2\x11\x53\x32\x3d\x07\x8b\x01\x0c\x0a\x43\x6e\x26\xe0\xf1\x9c\xa0\xc8\x16\xa4\x22\x23\xd1\xab\x24\x4a\x11\xdb\x99\x53\x30\xd3\x86\x5d\x34\x3d\xcc\x26\x71\x9a\xd2\x1c\x9a\xe5\x79\xa5\xce\x79\x3c\x56\x35\x5b\x48\x97\x37\x26\x2e\x39\x5b\xa2\xaf\x9f\xfe\xfc\x0b\xe8\x6d\x6c\x2a\x05\x3c\x5c\x75\x1b\x0c\x0a\x87\x3e\x2c\x38\x0d\x29\xef\x9e\xb2\xe5\x75\x99\xb5\x2c\x15\xef\x6a\x8b\x6a\xca\x4c\xb6\xaa\xd9\xdc\x47\xb8\x66\xb9\x07\xdb\xa5\x19\xeb\x16\xa8\x20\xa9\xf1\xf6\x60\xb7\xe7\xed\xcc\x3b\x7a\xa7\x2b\x93\x52\x41\x19\x7e\xc6\x74\xf7\x54\x6a\x7b\x78\xea\x44\xd1\x29\xcd\x94\x6d\xb7\xc8\xbf\x58\x27\xda\x9a\x9d\xe0\xf6\xa3\xd1\x55\x69\x61\x27\xe1\xc5\xc1\xd2\x73\xd7\x96\x22\x10\xa9\xae\xe4\xc3\x46\x86\xd6\x69\x74\xa7\x6d\x5a\xad\xeb\x41\x69\xdb\xfc\x0b\x2c\x58\x09\x6a\x8a\xc7\x36\xd5\x25\x15\xcb\x34\x49\xae\x04\x6b\x2b\x45\x3f\x1e\x9b\x75\xdc\x4c\x26\x68\x40\x86\x4e\xc8\xc2\xd2\xd0\xdb\x6a\x3e\x17\x66\x49\x33\xb8\x3f\x48\x75\x86\xfb\x0d\x1a\x3a\x37\xe8\xf3\x9b\x30\x91\xa3\x6a\x3e\x0c\x6d\x3a\x93\x0a\x7d\x18\x28\x78\x51\x37\xaf\x37\xe8\x53\x88\x7e\x13\x2f\x1a\x94\x24\x09\x93\xc9\xc4\x77\xc2\xa3\xe8\xd2\xbc\xca\x32\xf8\xee\x24\x2f\xc5\x3b\xaf\x48\xbc\x1d\xa3\x69\xba\xd7\xe3\x43\x94\xea\xb9\x58\x78\x3b\x3c\xfb\x7e\x97\xd5\x94\x02\x45\xd1\xa0\x4f\x31\x07\xfd\x35\xf8\x6f\x49\xd9\x5a\xcf\xef\x33\x2a\x94\x95\xc3\x8d\x74\xcd\x12\x1e\xdc\x19\xf5\x3f\x4b\xd8\xb0\x3d\x27\xc4\xbc\xe4\x58\x66\x19\xaa\x63\x2c\xe6\xc7\x5a\x5f\xdb\x20\xf2\x8d\x8c\xf8\x02\xdd\x3f\x40\x05\xca\x69\x03\x39\xed\x56\x9d\xeb\x12\x6b\x5b\x04\x15\x74\x73\xce\x7e\xcf\xfd\xa8\x12\x38\xff\x46\xb0\x34\x64\x30\xad\x1c\x28\xed\xc0\xe6\x7a\xa1\x6a\x41\xca\x39\x36\x9d\x52\x4f\x1c\x94\x34\xd7\x92\x08\x42\xaa\x98\x06\xa6\x90\x9c\xd0\x90\x6d\x7e\x24\x98\x83\x23\x7c\x47\x81\x1c\x96\x36\x8a\x62\xfa\x48\xfe\xf1\x2b\x5c\xea\x30\xc6\xf5\xcd\x01\x11\x2b\x09\x36\xe1\x9e\x42\xa9\xad\xdb\x8b\x80\x7e\x31\x4c\xae\x57\x9f\xd5\xf0\x7c\x94\xc4\xf3\x75\x1f\x3e\xfa\xeb\x1a\x11\xf3\x20\x2b\xeb\xa5\xce\x2b\x5e\x5a\x19\xe3\x53\x58\x68\x73\x5d\x68\x91\x3d\x1a\x44\x1d\xe6\xf1\x28\xe8\x9f\x01\xa1\x30\x18\x5f\xa1\x42\x43\x85\x6a\xa7\xfe\xcd\x6b\x58\x6a\x1f\x77\xc9\xd6\xc7\xb9\x21\x1c\x91\xad\xe9\x50\xca\xee\xf5\xd8\xd4\xec\x23\x4a\x63\x4a\x52\xb9\xd1\xb8\xff\x00\x00\x00\xff\xff\x03\x00\x45\x2c\xa7\xb8\xce\x08\x00\x00")
 
 func pkgPullreqTemplatesDiff_commentGotplBytes() ([]byte, error) {
 	return bindataRead(
@@ -113,8 +114,8 @@ func pkgPullreqTemplatesDiff_commentGotpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "pkg/pullreq/templates/diff_comment.gotpl", size: 1228, mode: os.FileMode(0644), modTime: time.Unix(1702514262, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xee, 0x25, 0x58, 0x45, 0x7c, 0xdc, 0xb1, 0x76, 0x21, 0x29, 0x8e, 0x84, 0x5d, 0xb3, 0x29, 0xcf, 0x2e, 0x6e, 0xac, 0x9c, 0xd7, 0x94, 0xb5, 0xab, 0x64, 0xe6, 0x5b, 0xc3, 0x31, 0x9e, 0x7b, 0xcc}}
+	info := bindataFileInfo{name: "pkg/pullreq/templates/diff_comment.gotpl", size: 2254, mode: os.FileMode(0644), modTime: time.Unix(1792154408, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xea, 0xde, 0xba, 0x5c, 0xd6, 0x2a, 0x47, 0x06, 0x87, 0x86, 0xdf, 0x7a, 0x55, 0xa1, 0x28, 0xae, 0xd2, 0xbf, 0xde, 0x4c, 0xd7, 0xe4, 0xa6, 0x55, 0xd4, 0x65, 0x4a, 0xf0, 0xb0, 0xc1, 0x96, 0x7b}}
 	return a, nil
 }
 
@@ -138,7 +139,7 @@ func pkgPullreqTemplatesError_commentGotpl() (*asset, error) {
 	return a, nil
 }
 
-var _pkgPullreqTemplatesHelp_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x93\x41\x6e\xdb\x3a\x10\x86\xf7\x3c\xc5\x8f\x64\xf1\x12\xe0\xc5\xde\x7b\x9b\xf7\xd0\x45\x8b\xa0\x68\xba\x29\x82\x00\xa6\xc5\x91\x44\x84\xe6\xa8\x9c\x61\x52\x43\xd6\x09\xba\xea\x09\x7a\xc5\x1e\xa1\xa0\x24\xa7\x0a\xd0\x00\x45\xbd\x30\x08\x8a\xf3\x7f\xfc\x87\xff\x9c\x9f\x9f\xe3\xc7\xf7\x6f\x5f\xf1\x36\xef\xc8\x76\x5d\x38\xa0\xa5\xd0\xa1\xef\xe1\x6b\xac\xfe\x8f\x8f\x18\x86\x8b\xbe\x3f\x2d\x2f\xfb\x1e\x14\x1d\x86\xc1\x98\x8f\xad\x17\x24\xea\x18\x5e\x50\x71\xac\x7d\x93\x13\x39\x28\x23\x0b\xe1\xee\xe1\x24\x79\x7f\xd1\xaa\x76\xb2\x59\xaf\x1b\xaf\x6d\xde\xad\x2a\xde\xaf\x85\x9a\x3d\x45\xf5\xbc\x7e\x3e\x77\xb9\x32\xe6\x13\x67\x54\x36\x22\xe5\x88\xed\xf3\x97\x2d\x2a\xde\xef\x6d\x74\x82\xdd\x01\x1d\x8b\xfa\xd8\x8c\x7b\x14\x55\x0a\x51\xcb\x65\xba\x1c\x02\x12\x7d\xce\x24\xba\x31\xe6\x6a\xa1\x30\xda\xda\x6e\xf0\x86\x22\x25\xab\x34\x15\xec\x49\xc4\x36\x04\xdb\x58\x1f\xb1\xb3\x42\x0e\x1c\xa1\x2d\x21\x58\x25\x51\x54\xad\x8d\x0d\xc9\x4b\x2d\xe7\xeb\x1a\x77\xdc\xa9\xe7\x68\x03\xaa\x90\x45\x29\x5d\xc8\xe5\xfd\x92\x50\x4e\x09\x6a\x4e\x20\xaf\x2d\x25\xd8\x10\xc0\x69\x54\x17\x0a\x54\x29\xb9\x45\xed\x4b\xc4\xf4\xff\x1a\xe3\x43\xe9\xce\xdc\x99\xbf\x04\x88\x5a\xcd\xf2\x2a\xe1\xb6\xe5\xa7\x49\x68\x3a\xc7\x35\x9e\x38\x3d\x04\xb6\x4e\xe0\xe3\x1f\x12\xcd\x0d\x8f\xad\xb6\x8a\x33\xfa\xd2\xd9\xe8\x7c\x6c\xce\xe6\xb0\x08\x38\x2b\xa4\xe5\x1c\x1c\x76\x04\xc7\x91\x10\xb8\xb2\xa1\xf8\x8f\xae\xc4\x2a\xb2\xa2\xb5\xd1\x05\x72\xe5\xe5\x17\x89\x58\x19\x33\x87\xf4\x7a\xe2\x5d\xcf\xa2\xc3\x50\x42\x94\xe6\x97\x43\x95\x53\xa2\xa8\x45\xb2\xae\xa9\x2a\x69\x69\x09\x35\x87\xc0\x4f\x63\x88\xa6\x6a\x19\x89\x92\x77\x9d\xd5\x56\x36\xc6\x1c\x31\xeb\xe2\x88\xdb\x79\x1b\x47\x73\xc4\xd5\xf4\xc3\x62\x65\xfa\xfe\x0a\x69\xc4\xfd\xe6\x36\x47\x6c\xcb\x00\xfd\x47\x52\x25\xdf\xa9\x7f\xa4\x1b\xbb\x27\x0c\xc3\x16\xc7\x32\x68\xab\xf7\x89\x54\x0f\x27\xc8\x3b\x2f\x8a\x61\x98\x55\x4f\xc3\x36\xae\x83\x94\x32\x73\x7d\xb2\xf4\x2f\x0e\x0b\xa7\x8e\x49\xe2\x3f\x3a\xfb\x84\x8d\x87\x5f\xe6\x7c\x9c\x02\x5f\xc6\x75\xb5\x14\xfe\x19\x00\x00\xff\xff\xa9\xf7\x96\xb3\x01\x04\x00\x00")
+var _pkgPullreqTemplatesHelp_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xbd\x55\xcb\x6e\xd3\x40\x14\xdd\xfb\x2b\xae\x5a\x24\x5a\xa9\x76\xf6\xd9\xa0\x28\x20\x16\xa0\x0a\x51\x36\xa8\xaa\xe4\x89\x7d\x6d\x8f\x32\x9e\x31\xf3\x68\x88\x9a\x7c\x01\x2b\xbe\x80\x5f\xe4\x13\x38\x63\x3b\xa9\x0b\xad\xa8\x58\x90\x45\x72\xed\xb9\x73\xce\xb9\xcf\x9c\x9e\x9e\xd2\xcf\x1f\xdf\xbf\xd1\xbb\xb0\x62\xd1\x75\x6a\x4b\x0d\xab\x8e\xee\xee\x48\x56\x94\xbd\xd1\xb7\xb4\xdf\x9f\xe1\x69\x34\xcf\x61\xb2\x2e\x61\x25\xc9\xa7\x46\x3a\xb2\xdc\x19\xc2\x6f\x61\x74\x25\xeb\x60\xb9\x24\x6f\x28\x38\xa6\xeb\xf5\x01\xf2\xe6\xac\xf1\xbe\x73\xf3\xd9\xac\x96\xbe\x09\xab\xac\x30\xed\xcc\x71\xdd\xb2\xf6\xd2\xcc\x8e\x7e\xe7\x59\x92\x7c\x36\x81\x0a\xa1\xc9\x06\x4d\xf9\xf1\x24\x07\x7e\xdb\x0a\x5d\x3a\x5a\x6d\xa9\x33\xce\x4b\x5d\xf7\xef\x00\xe1\x22\xa3\x8f\x62\xba\xa0\x14\x14\x7d\x09\xec\xfc\x3c\x49\xd2\x09\x42\x1f\x56\x3e\xa7\xb7\xac\xd9\x0a\xcf\xc3\x85\x96\x9d\x13\x35\x93\xa8\x85\xd4\xb4\x12\x0e\xf2\x8d\xc6\x19\x93\x82\x93\xf3\x54\x34\x42\xd7\xec\x1e\x62\x95\xb2\xaa\xe8\xda\x74\x50\xaf\x85\xa2\x42\x05\xe7\xd9\x9e\xb9\xf3\x9b\x29\x43\xf4\x72\x54\x19\x4b\x8c\xb0\xd9\x92\x80\x3a\x3c\x45\x74\xc7\x8a\x0b\x0f\xb6\xfb\xbb\x0f\x29\x86\xef\xa7\x38\x3e\xc6\xec\x8c\x99\x79\x3e\x01\xe1\x93\xd2\xa2\x2c\x29\x4f\x53\xcb\x2e\xb4\x9c\xc7\xdc\xb9\xb5\xec\xfa\x4b\xa3\x2f\x12\xda\x08\x4f\x1b\xb6\xc8\x8c\xb2\x2c\xca\x41\x8f\x04\x1c\xde\x4f\xb3\x83\x0a\x48\x7f\x41\x9c\xd5\x19\x89\xca\x47\x0d\xe4\x65\xcb\x26\xf8\x87\x74\x50\x59\x0c\x6c\x43\x64\x48\xf7\x33\xd8\x36\x88\x4b\xf6\xf5\x90\x96\x5a\xa9\x65\x1b\xda\x23\x00\xee\xde\x0a\xf5\x5b\x69\x9c\x17\x3e\xb8\x27\x13\x77\xd5\x98\xcd\x90\x9f\xc1\xcf\x54\xb4\x31\x76\xad\x8c\x40\x6b\x81\xe9\x1f\x2a\xa5\x4c\xb1\xfe\x0b\xe1\xa6\x91\x45\x13\xb9\x9e\x05\x4f\xf7\xd8\x71\xb0\x82\xb5\x68\xf2\xb1\xeb\x62\xdb\xe3\x66\x7f\x1a\x6d\xa9\x93\xe4\xd2\xf4\xed\x8c\x1c\x9e\xf0\xd7\x0e\x43\x82\x83\x93\x71\x20\x11\x61\xf0\xe4\x1a\x13\x54\x49\x2b\xf4\xa4\xd1\x1c\x15\x43\x01\xb2\x8c\x41\x06\x83\x36\x9e\xd0\xe5\xa5\x82\x0a\x4c\xd7\x64\xea\x30\x8f\xe3\x22\x58\x0e\xea\x96\x23\x28\xc6\x1f\x83\x6a\xc7\xe9\x98\x68\x14\x55\x85\x70\x5c\x1f\x5a\x65\x94\x32\x9b\x7e\x50\x0f\x95\x8e\x8c\x2e\xac\x3a\xe1\x1b\x37\x3f\x82\x2f\x0a\x8f\x98\x52\x80\xee\x68\x24\xa2\x1d\x5d\x8d\x7e\x30\x97\x58\x08\x79\x5c\x42\x83\xe7\x7e\x9f\x0f\x19\x78\x45\x3b\x5c\x49\x87\x0f\x3d\x6a\x81\x23\x25\xdb\xab\x7c\x24\x88\xdd\x00\xfb\x9a\x5d\x61\x25\x0a\x78\xcb\x97\xa2\xe5\x9e\x60\x17\x77\x60\xf6\xc1\xb2\xf7\xdb\x83\x94\xf7\x12\x3d\xbf\xdf\x0f\x67\x2f\xb2\x45\xd4\xb0\x28\x0a\x2c\x11\xca\xfa\xf7\x3d\xdb\xb8\x1f\x7b\x53\x61\x11\x3e\x1d\x58\xf2\x1f\x25\xff\x21\x6d\xdc\xe2\x47\x99\x78\x58\x1e\xea\x78\x41\xdb\x49\x79\x4b\xc3\x4e\xbf\xf4\x63\x71\x51\xc4\xed\x7d\x45\xfb\xe9\x1c\xff\x07\xb2\x29\xf0\x2f\x83\x0e\x70\xdd\x5a\x06\x00\x00")
 
 func pkgPullreqTemplatesHelp_commentGotplBytes() ([]byte, error) {
 	return bindataRead(
@@ -153,12 +154,32 @@ func pkgPullreqTemplatesHelp_commentGotpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "pkg/pullreq/templates/help_comment.gotpl", size: 1025, mode: os.FileMode(0644), modTime: time.Unix(1702514262, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x2d, 0xd5, 0xd5, 0x14, 0xf7, 0xf3, 0x98, 0xc4, 0x7e, 0x8e, 0x37, 0x4c, 0x3a, 0x9a, 0x21, 0x20, 0xe2, 0xe, 0x6a, 0x1b, 0x92, 0x5e, 0x3a, 0x45, 0x4e, 0x7, 0x60, 0xe8, 0x65, 0x94, 0x37, 0xab}}
+	info := bindataFileInfo{name: "pkg/pullreq/templates/help_comment.gotpl", size: 1626, mode: os.FileMode(0644), modTime: time.Unix(1792157372, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xbc, 0x8e, 0xb0, 0x6d, 0x63, 0xe7, 0xc5, 0x54, 0x18, 0x27, 0x11, 0x78, 0xdf, 0xf, 0xb6, 0x5e, 0x22, 0xf3, 0xf9, 0x3b, 0xeb, 0x21, 0xf1, 0x35, 0xfa, 0x88, 0xcd, 0x5e, 0xcc, 0x6b, 0xd, 0x1e}}
 	return a, nil
 }
 
-var _pkgPullreqTemplatesStatus_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\x90\xc1\x6a\xc3\x30\x0c\x86\xef\x7a\x0a\x41\x2e\xdb\x61\xc9\x7d\x98\x5c\xb2\xc1\x60\xd0\x4b\x5f\xc0\x5e\xa3\x74\x66\x8e\x6d\x2c\xbb\xa5\x18\xbf\xc1\xd8\x2b\xec\x15\xf7\x08\xc3\x69\x3b\xc2\xe8\xcd\xe6\x93\x3e\xfd\x52\xd3\x34\xf8\xf3\xfd\xf9\x85\xaf\xe9\x8d\x94\xf7\xe6\x84\x3b\x93\x38\x52\x40\x8e\x2a\x26\xc6\x40\x9c\x4c\xc4\x9c\x51\x4f\xd8\x3e\xdb\x03\x96\x72\x97\xf3\xf5\x79\x9f\x33\x92\x1d\xb1\x14\x80\x9c\x1f\x96\xa2\xe1\x6c\xd8\x2e\x02\xe2\xca\x2a\x0a\xca\xee\xe9\x26\x85\xa6\xe6\xb8\x80\x47\x94\x55\x7f\xf9\x0d\xce\x4e\x7a\xdf\x3e\x11\xef\x82\xf6\x51\x1f\x68\xa3\x66\xc2\x52\x24\x80\x18\x29\x2a\x6d\xb8\x07\xc1\x69\x9e\x55\x38\xf5\x43\x0a\x81\x6c\xc4\xa3\x0b\x1f\xc6\xa9\x91\x45\x77\x45\x20\x7c\x0f\x20\xa5\x84\xaa\x7f\x21\x65\xe2\xfb\xf6\xcc\x6a\x86\x0a\x40\x74\xbe\x07\xd1\xfd\x79\x97\xdc\xeb\xf5\xc8\x70\x1d\x0e\x1b\xf7\xef\x4e\xc4\x78\xa4\x40\x38\xb9\x64\xc7\x76\xdd\xf7\x1b\x00\x00\xff\xff\x68\xca\x74\xd3\x63\x01\x00\x00")
+var _pkgPullreqTemplatesLocks_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x75\x52\x4b\x4e\xc3\x30\x10\xdd\xe7\x14\x23\x75\x01\x48\x6d\x2e\x11\x90\x8a\x40\x6c\x2a\xf6\x71\x9d\x71\x62\xc5\xb5\x23\xdb\x69\x15\xb5\xbd\x04\x62\xcf\x15\x39\x02\xe3\x4f\x4a\x11\x90\xcd\xcc\x3c\xbd\x99\x79\x6f\x9c\xc5\x62\x01\x9f\x1f\xef\x6f\xf0\x34\x6e\x91\x0d\x83\x9a\x40\x19\xde\x3b\x38\x1e\x41\x0a\x28\x1f\xf4\x1e\xce\xe7\x5b\xaa\x72\x7a\x47\x29\xea\x86\xb2\x22\x53\x2a\x35\x3a\x8f\xf6\x39\xb6\x11\x7c\x82\x8c\xc0\x09\x36\x9e\x79\xa4\xb8\x36\xaa\x89\x40\x60\x61\x03\x4e\x6a\x4e\x38\x71\x57\xe9\x83\x9c\xcd\xf1\x3b\x99\x4b\x5a\xb7\x02\xcb\x74\x8b\x7f\xad\xac\x83\xc2\x0c\x57\x46\x0b\xd9\x96\xf7\xe8\xb8\x95\x83\x97\x7b\x7c\x61\x3b\x24\x5e\x4d\x43\xb3\xe8\x35\xaa\xe0\x21\xc9\x09\x96\x94\x0b\x8c\x57\xad\x2e\x48\x34\xf9\xab\x23\x2e\x0a\x6d\x65\xf6\x94\xa0\x7f\xd8\x33\x19\x9b\x4d\x74\x1c\x91\x99\x1b\x1d\xe5\x5b\x16\xc9\x0b\xb3\x08\x5d\x68\x3d\x74\x52\x21\xf4\x97\x47\x91\x0e\x1a\x29\x84\xd4\x2d\x18\x0b\x11\x0b\xb9\xd4\xc0\x80\x27\xdb\x25\x3c\x0a\xaa\x82\x01\x70\x88\x3b\x07\xce\x8f\xbc\x5f\x16\xbc\x43\x82\x7c\x77\x3d\x4f\x99\xd6\xc1\x16\x85\xa1\x85\x16\x15\x32\x17\xc7\x79\x38\x48\xdf\x41\xdd\xff\xf8\x1b\x32\x03\xeb\xb2\x48\x9a\xd3\xb1\x8a\x6a\xb4\x16\xb5\x57\xd3\x12\x26\x33\x5a\xe0\x5d\x7c\x9e\xc6\xa0\xd3\x37\x1e\x98\x10\xc8\x29\xe8\x69\x96\xe8\x82\x60\xdf\x91\x19\x8b\x83\x29\xaf\x0f\xf0\x05\x02\xf7\x19\x35\x89\x02\x00\x00")
+
+func pkgPullreqTemplatesLocks_commentGotplBytes() ([]byte, error) {
+	return bindataRead(
+		_pkgPullreqTemplatesLocks_commentGotpl,
+		"pkg/pullreq/templates/locks_comment.gotpl",
+	)
+}
+
+func pkgPullreqTemplatesLocks_commentGotpl() (*asset, error) {
+	bytes, err := pkgPullreqTemplatesLocks_commentGotplBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "pkg/pullreq/templates/locks_comment.gotpl", size: 649, mode: os.FileMode(0644), modTime: time.Unix(1792156750, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa3, 0x1, 0xcf, 0x2f, 0xc0, 0x97, 0x5, 0x1e, 0xd, 0x23, 0x23, 0x34, 0xc5, 0x1a, 0x62, 0x4e, 0x8a, 0x5a, 0x70, 0x7e, 0xb6, 0xa2, 0x4f, 0x82, 0x87, 0x65, 0x0, 0xc8, 0x71, 0xf0, 0x20, 0xe8}}
+	return a, nil
+}
+
+var _pkgPullreqTemplatesStatus_commentGotpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x52\xcb\x4e\xc3\x30\x10\xbc\xfb\x2b\x16\xe5\xd2\x1e\x92\xdc\x51\x54\x09\x42\x24\x4a\xab\xb4\x52\xca\x81\x13\x31\xc9\xa6\x04\x1c\x27\xf2\xa3\x55\x15\xf5\x0f\x10\xbf\xc0\x2f\xf2\x09\xd8\xe9\x43\x81\x16\xa9\x3e\x8d\x35\x3b\xb3\xe3\x5d\x3b\x8e\x03\xdf\x5f\x1f\x9f\x30\xd1\x2f\x48\x9b\x86\x6d\x20\x63\x5a\x2a\x14\x20\x15\x55\x5a\x82\x40\xa9\x99\x82\xb6\x85\xb2\x00\x2f\xe2\x2b\xd8\x6e\x07\xe6\xb6\x87\x43\x03\x91\xe7\x06\x11\xd2\xb6\x6e\x57\x14\xee\x1c\x92\xce\x00\xa5\xe5\x2c\x25\x28\x5f\xe2\x59\x36\xb8\x72\x5d\x98\x3c\xde\x46\x37\xf3\xf9\xf4\xe9\x39\x99\x4f\xc7\x0b\x70\xdd\xd1\x09\x11\x85\x8b\xf1\x2c\xb6\x61\x0e\x36\x61\xcd\x8b\x72\xe9\xdd\xa1\xcc\x44\xd9\xa8\x72\x85\x31\xad\xd0\x98\x76\x7a\xe2\x98\x03\xfb\xd2\x6b\x48\x2f\x11\xa6\x84\x04\x39\x2a\x5a\x32\x69\x02\x48\x5d\x55\x54\x6c\x46\xa1\x16\x02\xb9\x82\x75\x2d\xde\x59\x4d\x73\x19\xf8\x07\x8a\x04\x8d\xe9\x94\xa6\x29\xb1\xf6\xf7\x48\x99\x7a\x4d\x76\x9c\x7d\x9c\x25\x48\xe0\x9b\x9a\xc0\x3f\xfa\x1e\x67\x95\x28\xa1\x33\xa5\x05\xe6\x3d\xc9\x45\x01\x60\xf0\x90\xcc\xe2\xe1\x99\x1c\x6f\xb2\xe6\x5d\x96\x13\x73\xab\xf8\x37\x93\x8d\xd4\x5f\xe5\x2f\xcc\xa4\x9d\x0d\x89\xeb\x3f\xff\xc3\x2c\x70\x8d\x02\xa1\xa8\x35\xcf\xbd\xbe\xee\x07\x00\x00\xff\xff\x03\x00\xee\x63\x1f\xbc\x5b\x02\x00\x00")
 
 func pkgPullreqTemplatesStatus_commentGotplBytes() ([]byte, error) {
 	return bindataRead(
@@ -173,8 +194,8 @@ func pkgPullreqTemplatesStatus_commentGotpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "pkg/pullreq/templates/status_comment.gotpl", size: 355, mode: os.FileMode(0644), modTime: time.Unix(1702514262, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x2c, 0xf0, 0xcb, 0x4, 0x35, 0xaa, 0xf4, 0xf7, 0x74, 0x5d, 0xee, 0xae, 0x74, 0xe2, 0xec, 0xd7, 0x59, 0x9, 0x86, 0xca, 0x60, 0x36, 0x29, 0x25, 0xfb, 0x74, 0x50, 0x8d, 0xbd, 0xe8, 0x9c, 0x70}}
+	info := bindataFileInfo{name: "pkg/pullreq/templates/status_comment.gotpl", size: 603, mode: os.FileMode(0644), modTime: time.Unix(1792152958, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x3e, 0x21, 0xac, 0x5f, 0xca, 0xb0, 0xf5, 0xd0, 0x29, 0xad, 0x62, 0x1b, 0xb0, 0xc4, 0x1f, 0x53, 0x4a, 0x83, 0x31, 0x0a, 0x05, 0x43, 0x49, 0x68, 0xcf, 0x2c, 0x48, 0xdb, 0x6c, 0x5b, 0xc1, 0x14}}
 	return a, nil
 }
 
@@ -393,6 +414,7 @@ var _bindata = map[string]func() (*asset, error){
 	"pkg/pullreq/templates/diff_comment.gotpl":   pkgPullreqTemplatesDiff_commentGotpl,
 	"pkg/pullreq/templates/error_comment.gotpl":  pkgPullreqTemplatesError_commentGotpl,
 	"pkg/pullreq/templates/help_comment.gotpl":   pkgPullreqTemplatesHelp_commentGotpl,
+	"pkg/pullreq/templates/locks_comment.gotpl":  pkgPullreqTemplatesLocks_commentGotpl,
 	"pkg/pullreq/templates/status_comment.gotpl": pkgPullreqTemplatesStatus_commentGotpl,
 	"scripts/cluster-summary/__init__.py":        scriptsClusterSummary__init__Py,
 	"scripts/cluster-summary/cluster_summary.py": scriptsClusterSummaryCluster_summaryPy,
@@ -455,6 +477,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 				"diff_comment.gotpl":   {pkgPullreqTemplatesDiff_commentGotpl, map[string]*bintree{}},
 				"error_comment.gotpl":  {pkgPullreqTemplatesError_commentGotpl, map[string]*bintree{}},
 				"help_comment.gotpl":   {pkgPullreqTemplatesHelp_commentGotpl, map[string]*bintree{}},
+				"locks_comment.gotpl":  {pkgPullreqTemplatesLocks_commentGotpl, map[string]*bintree{}},
 				"status_comment.gotpl": {pkgPullreqTemplatesStatus_commentGotpl, map[string]*bintree{}},
 			}},
 		}},
@@ -7,7 +7,9 @@ import (
 	"github.com/briandowns/spinner"
 	"github.com/segmentio/kubeapply/pkg/cluster/apply"
 	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/store"
 )
 
 const (
@@ -23,6 +25,15 @@ type ClusterClient interface {
 	// as opposed to raw, outputs
 	ApplyStructured(ctx context.Context, paths []string, serverSide bool) ([]apply.Result, error)
 
+	// DryRunStructured does a dry-run apply of all of the configs at the given path and returns
+	// structured results. Since nothing is changed, the old and new versions in each result are
+	// the same; resources that would be created have empty versions.
+	DryRunStructured(
+		ctx context.Context,
+		paths []string,
+		serverSide bool,
+	) ([]apply.Result, error)
+
 	// Diff gets the diffs between the configs at the given path and the actual state of resources
 	// in the cluster.
 	Diff(ctx context.Context, paths []string, serverSide bool) ([]byte, error)
@@ -37,17 +48,37 @@ type ClusterClient interface {
 		paths []string,
 		serverSide bool,
 		diffCommand string,
-	) ([]diff.Result, error)
+	) (diff.Results, error)
 
 	// Summary returns a summary of all workloads in the cluster.
 	Summary(ctx context.Context) (string, error)
 
+	// SummaryStructured returns a machine-readable summary of the workloads in the cluster.
+	SummaryStructured(ctx context.Context) (kube.StructuredSummary, error)
+
 	// GetStoreValue gets the value of the given key.
 	GetStoreValue(ctx context.Context, key string) (string, error)
 
 	// SetStoreValue sets the given key/value pair in the cluster.
 	SetStoreValue(ctx context.Context, key string, value string) error
 
+	// LastDiffSHA returns the git SHA of the last recorded diff for the client's pull request
+	// in the cluster or an empty string if no diff has been recorded.
+	LastDiffSHA(ctx context.Context) (string, error)
+
+	// AppliedAtHead returns whether the client's subpaths were successfully applied at the
+	// head SHA of its pull request.
+	AppliedAtHead(ctx context.Context) (bool, error)
+
+	// LastApplyTime returns when the last successful apply in the cluster finished or the zero
+	// time if none has been recorded. Applies are only recorded in clusters with a
+	// MinApplyInterval in their configs.
+	LastApplyTime(ctx context.Context) (time.Time, error)
+
+	// Lock returns the state of the cluster's kubeapply lock or nil if the lock has never been
+	// acquired.
+	Lock(ctx context.Context) (*store.LockInfo, error)
+
 	// Config returns the config for this cluster.
 	Config() *config.ClusterConfig
 
@@ -60,6 +91,11 @@ type ClusterClient interface {
 
 // ClusterClientConfig stores the configuration necessary to create a ClusterClient.
 type ClusterClientConfig struct {
+	// Actor is the user responsible for the applies and diffs made by the client. It's
+	// recorded in the last diff event for apply consistency checks and, if RecordApply is set
+	// in the cluster config, in the annotations of applied resources.
+	Actor string
+
 	// CheckApplyConsistency indicates whether we should check whether an apply is done with
 	// the same SHA as the last diff in the cluster.
 	CheckApplyConsistency bool
@@ -74,8 +110,26 @@ type ClusterClientConfig struct {
 	// yaml manifests. These are useful for debugging when there are apply errors.
 	KeepConfigs bool
 
-	// HeadSHA is the SHA of the current branch. Used for consistency checking, can be omitted
-	// if that option is false.
+	// Quiet indicates whether routine kubectl output should be logged at the debug level
+	// instead of the info level. Errors are still logged at the warn level.
+	Quiet bool
+
+	// Differ is an external command (e.g., "difft" or "dyff between") that's run in place of
+	// "diff -u -N" on the live and merged object directories in raw diffs. Structured diffs
+	// always use kubeapply's own differ.
+	Differ string
+
+	// RecordDiffs indicates whether successful diffs should be recorded for PullRequestNum in
+	// the cluster. The last recorded diff is used as the base for incremental diffs.
+	RecordDiffs bool
+
+	// PullRequestNum is the number of the pull request that the client is used for, if any.
+	// Diffs recorded via RecordDiffs are scoped to it. If set, successful applies are also
+	// recorded for it so that they can be skipped when resuming.
+	PullRequestNum int
+
+	// HeadSHA is the SHA of the current branch. Used for consistency checking and for recording
+	// diffs and applies, can be omitted if none of those options is set.
 	HeadSHA string
 
 	// UseColors indicates whether output should include colors. Currently only applies to diff
@@ -93,6 +147,11 @@ type ClusterClientConfig struct {
 	// StreamingOutput indicates whether results should be streamed out to stdout and stderr.
 	// Currently only applies to apply operations.
 	StreamingOutput bool
+
+	// SummaryMode determines how cluster summaries are generated.
+	//
+	// Optional, defaults to kube.SummaryModeFull.
+	SummaryMode kube.SummaryMode
 }
 
 // ClusterClientGenerator generates a ClusterClient from a config.
@@ -2,7 +2,9 @@ package diff
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -18,19 +20,77 @@ import (
 
 const (
 	maxLineLen = 256
+
+	// ShowManagedFieldsEnvVar is the environment variable used to pass the ShowManagedFields
+	// option through to kubeapply kdiff, which is invoked by kubectl.
+	ShowManagedFieldsEnvVar = "KUBEAPPLY_SHOW_MANAGED_FIELDS"
+
+	// IgnoreHelmHooksEnvVar is the environment variable used to pass the IgnoreHelmHooks
+	// option through to kubeapply kdiff.
+	IgnoreHelmHooksEnvVar = "KUBEAPPLY_IGNORE_HELM_HOOKS"
+
+	// RedactKeysEnvVar is the environment variable used to pass the RedactKeys option
+	// through to kubeapply kdiff, as a comma-separated list.
+	RedactKeysEnvVar = "KUBEAPPLY_REDACT_KEYS"
+
+	// RecordAnnotationPrefix is the prefix of the annotations set when recording applies.
+	// These change on every apply, so they're stripped out of diffs.
+	RecordAnnotationPrefix = "kubeapply.segment.io/applied-"
+
+	helmHookAnnotation = "helm.sh/hook"
+
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
 )
 
+// Options stores the options that adjust the behavior of DiffKube.
+type Options struct {
+	// ShowManagedFields indicates whether the managedFields in each object's metadata
+	// should be kept in the diffs. By default, these are stripped since they change
+	// constantly.
+	ShowManagedFields bool
+
+	// IgnoreHelmHooks indicates whether resources with a helm.sh/hook annotation should be
+	// excluded from the diffs. These aren't persistent cluster state, so they otherwise show
+	// up as diffs every time.
+	IgnoreHelmHooks bool
+
+	// RedactKeys are the keys of labels and annotations whose values should be replaced with
+	// RedactedValue in the diffs, e.g. because they contain internal URLs or tokens.
+	RedactKeys []string
+}
+
+// EnvVars returns the environment variables that should be set for kubeapply kdiff
+// to pick up these options.
+func (o Options) EnvVars() []string {
+	envVars := []string{}
+
+	if o.ShowManagedFields {
+		envVars = append(envVars, fmt.Sprintf("%s=true", ShowManagedFieldsEnvVar))
+	}
+	if o.IgnoreHelmHooks {
+		envVars = append(envVars, fmt.Sprintf("%s=true", IgnoreHelmHooksEnvVar))
+	}
+	if len(o.RedactKeys) > 0 {
+		envVars = append(
+			envVars,
+			fmt.Sprintf("%s=%s", RedactKeysEnvVar, strings.Join(o.RedactKeys, ",")),
+		)
+	}
+
+	return envVars
+}
+
 // DiffKube processes the results of a kubectl diff call in place of the default 'diff'
 // command.
-func DiffKube(oldRoot string, newRoot string) ([]Result, error) {
+func DiffKube(oldRoot string, newRoot string, options Options) (Results, error) {
 	oldNames, err := walkPaths(oldRoot)
 	if err != nil {
-		return nil, err
+		return Results{}, err
 	}
 
 	newNames, err := walkPaths(newRoot)
 	if err != nil {
-		return nil, err
+		return Results{}, err
 	}
 
 	allNames := map[string]struct{}{}
@@ -50,47 +110,55 @@ func DiffKube(oldRoot string, newRoot string) ([]Result, error) {
 		return allNamesSlice[a] < allNamesSlice[b]
 	})
 
-	results := []Result{}
+	results := Results{
+		Results: []Result{},
+	}
 
 	for _, name := range allNamesSlice {
 		_, oldOk := oldNames[name]
 		_, newOk := newNames[name]
 
 		var diffResult *Result
+		var hiddenHook bool
 
 		if oldOk && newOk {
-			diffResult, err = evalDiffs(
+			diffResult, hiddenHook, err = evalDiffs(
 				name,
 				oldRoot,
 				name,
 				newRoot,
 				name,
+				options,
 			)
 		} else if oldOk {
-			diffResult, err = evalDiffs(
+			diffResult, hiddenHook, err = evalDiffs(
 				name,
 				oldRoot,
 				name,
 				newRoot,
 				"",
+				options,
 			)
 		} else {
-			diffResult, err = evalDiffs(
+			diffResult, hiddenHook, err = evalDiffs(
 				name,
 				oldRoot,
 				"",
 				newRoot,
 				name,
+				options,
 			)
 		}
 
 		if err != nil {
-			return nil, err
+			return Results{}, err
 		}
 
-		if diffResult != nil && diffResult.RawDiff != "" {
-			results = append(
-				results,
+		if hiddenHook {
+			results.HiddenHelmHooks++
+		} else if diffResult != nil && diffResult.RawDiff != "" {
+			results.Results = append(
+				results.Results,
 				*diffResult,
 			)
 		}
@@ -132,19 +200,22 @@ func evalDiffs(
 	oldName string,
 	newRoot string,
 	newName string,
-) (*Result, error) {
+	options Options,
+) (*Result, bool, error) {
 	var oldLines []string
 	var newLines []string
 	var oldHash string
 	var newHash string
+	var oldPath string
+	var newPath string
 	var obj *apply.TypedKubeObj
 	var err error
 
 	if oldName != "" {
-		oldPath := filepath.Join(oldRoot, oldName)
-		oldLines, oldHash, err = getFileLines(oldPath)
+		oldPath = filepath.Join(oldRoot, oldName)
+		oldLines, oldHash, err = getFileLines(oldPath, options)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		obj, err = getFileObj(oldPath)
 		if err != nil {
@@ -153,10 +224,10 @@ func evalDiffs(
 	}
 
 	if newName != "" {
-		newPath := filepath.Join(newRoot, newName)
-		newLines, newHash, err = getFileLines(newPath)
+		newPath = filepath.Join(newRoot, newName)
+		newLines, newHash, err = getFileLines(newPath, options)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		// If we already got the object, don't bother trying to get it again since
@@ -171,7 +242,12 @@ func evalDiffs(
 	}
 
 	if oldHash == newHash {
-		return nil, nil
+		return nil, false, nil
+	}
+
+	if options.IgnoreHelmHooks && (isHelmHook(oldPath) || isHelmHook(newPath)) {
+		log.Debugf("Ignoring diffs in helm hook %s", name)
+		return nil, true, nil
 	}
 
 	diff := difflib.UnifiedDiff{
@@ -184,7 +260,7 @@ func evalDiffs(
 
 	diffStr, err := difflib.GetUnifiedDiffString(diff)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	numAdded, numRemoved := diffCounts(diffStr)
@@ -195,22 +271,32 @@ func evalDiffs(
 		RawDiff:    diffStr,
 		NumAdded:   numAdded,
 		NumRemoved: numRemoved,
-	}, nil
+	}, false, nil
 }
 
-func getFileLines(path string) ([]string, string, error) {
-	file, err := os.Open(path)
+func getFileLines(path string, options Options) ([]string, string, error) {
+	contents, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, "", err
 	}
-	defer file.Close()
+
+	contents, err = stripRecordAnnotations(contents)
+	if err != nil {
+		log.Warnf("Error stripping record annotations from %s: %+v", path, err)
+	}
+
+	contents, err = redactMetadata(contents, options.RedactKeys)
+	if err != nil {
+		// Don't risk showing the values that should be redacted
+		return nil, "", fmt.Errorf("Error redacting metadata in %s: %+v", path, err)
+	}
 
 	lines := []string{}
 
 	// Hash the file contents so we can avoid diffing files with the same content.
 	h := sha1.New()
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, 1024*1024)
 
@@ -221,8 +307,9 @@ func getFileLines(path string) ([]string, string, error) {
 		line := scanner.Text()
 
 		// Skip over managedFields chunk in metadata since it's constantly
-		// changing and causing spurious diffs.
-		if strings.HasPrefix(line, "  managedFields:") {
+		// changing and causing spurious diffs. This can be turned off via showManagedFields
+		// for debugging field ownership issues.
+		if !options.ShowManagedFields && strings.HasPrefix(line, "  managedFields:") {
 			insideManagedFields = true
 			keep = false
 		} else if insideManagedFields {
@@ -251,6 +338,70 @@ func getFileLines(path string) ([]string, string, error) {
 	return lines, fmt.Sprintf("%x", h.Sum(nil)), scanner.Err()
 }
 
+// stripRecordAnnotations removes the annotations set when recording applies from the
+// metadata of the argument object and from its kubectl last-applied configuration, if any.
+// The contents are returned as-is if they don't reference any of these annotations.
+func stripRecordAnnotations(contents []byte) ([]byte, error) {
+	if !bytes.Contains(contents, []byte(RecordAnnotationPrefix)) {
+		return contents, nil
+	}
+
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(contents, &obj); err != nil {
+		return contents, err
+	}
+
+	annotations := objAnnotations(obj)
+	if annotations == nil {
+		return contents, nil
+	}
+	stripAnnotations(obj, annotations)
+
+	if lastApplied, ok := annotations[lastAppliedAnnotation].(string); ok {
+		lastAppliedObj := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(lastApplied), &lastAppliedObj); err != nil {
+			return contents, err
+		}
+
+		if lastAppliedAnnotations := objAnnotations(lastAppliedObj); lastAppliedAnnotations != nil {
+			stripAnnotations(lastAppliedObj, lastAppliedAnnotations)
+
+			lastAppliedBytes, err := json.Marshal(lastAppliedObj)
+			if err != nil {
+				return contents, err
+			}
+			if strings.HasSuffix(lastApplied, "\n") {
+				lastAppliedBytes = append(lastAppliedBytes, '\n')
+			}
+			annotations[lastAppliedAnnotation] = string(lastAppliedBytes)
+		}
+	}
+
+	return yaml.Marshal(obj)
+}
+
+func objAnnotations(obj map[string]interface{}) map[string]interface{} {
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	return annotations
+}
+
+// stripAnnotations removes the record annotations from the argument annotations map, which
+// is also removed from the object metadata if it ends up empty.
+func stripAnnotations(obj map[string]interface{}, annotations map[string]interface{}) {
+	for key := range annotations {
+		if strings.HasPrefix(key, RecordAnnotationPrefix) {
+			delete(annotations, key)
+		}
+	}
+	if len(annotations) == 0 {
+		delete(obj["metadata"].(map[string]interface{}), "annotations")
+	}
+}
+
 func getFileObj(path string) (*apply.TypedKubeObj, error) {
 	obj := apply.TypedKubeObj{}
 
@@ -266,6 +417,36 @@ func getFileObj(path string) (*apply.TypedKubeObj, error) {
 	return &obj, nil
 }
 
+// hookMetadata is used for parsing just the annotations out of a manifest.
+type hookMetadata struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// isHelmHook returns whether the manifest at the argument path has a helm.sh/hook annotation.
+// Unparseable or missing manifests are treated as non-hooks.
+func isHelmHook(path string) bool {
+	if path == "" {
+		return false
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Warnf("Error reading path %s: %+v", path, err)
+		return false
+	}
+
+	obj := hookMetadata{}
+	if err := yaml.Unmarshal(contents, &obj); err != nil {
+		log.Warnf("Error parsing path %s: %+v", path, err)
+		return false
+	}
+
+	_, ok := obj.Metadata.Annotations[helmHookAnnotation]
+	return ok
+}
+
 func diffCounts(diffStr string) (int, int) {
 	numAdded := 0
 	numRemoved := 0
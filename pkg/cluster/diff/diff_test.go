@@ -9,8 +9,9 @@ import (
 )
 
 func TestDiffKube(t *testing.T) {
-	results, err := DiffKube("testdata/old", "testdata/new")
+	wrappedResults, err := DiffKube("testdata/old", "testdata/new", Options{})
 	require.NoError(t, err)
+	results := wrappedResults.Results
 	require.Equal(t, 3, len(results))
 
 	names := []string{}
@@ -75,3 +76,131 @@ func TestDiffKube(t *testing.T) {
 		results[0].Object,
 	)
 }
+
+func TestDiffKubeShowManagedFields(t *testing.T) {
+	wrappedResults, err := DiffKube(
+		"testdata/old",
+		"testdata/new",
+		Options{
+			ShowManagedFields: true,
+		},
+	)
+	require.NoError(t, err)
+	results := wrappedResults.Results
+	require.Equal(t, 4, len(results))
+
+	assert.Equal(t, "file4.yaml", results[3].Name)
+	assert.Equal(t, 4, results[3].NumAdded)
+	assert.Equal(t, 4, results[3].NumRemoved)
+}
+
+func TestDiffKubeIgnoreHelmHooks(t *testing.T) {
+	results, err := DiffKube("testdata/hooks/old", "testdata/hooks/new", Options{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(results.Results))
+	assert.Equal(t, "job.yaml", results.Results[0].Name)
+	assert.Equal(t, "service.yaml", results.Results[1].Name)
+	assert.Equal(t, 0, results.HiddenHelmHooks)
+
+	results, err = DiffKube(
+		"testdata/hooks/old",
+		"testdata/hooks/new",
+		Options{
+			IgnoreHelmHooks: true,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(results.Results))
+	assert.Equal(t, "service.yaml", results.Results[0].Name)
+	assert.Equal(t, 1, results.HiddenHelmHooks)
+}
+
+func TestResultSummaries(t *testing.T) {
+	wrappedResults, err := DiffKube("testdata/old", "testdata/new", Options{})
+	require.NoError(t, err)
+	results := wrappedResults.Results
+	require.Equal(t, 3, len(results))
+
+	assert.Equal(
+		t,
+		ResultSummary{
+			Name:       "file1.yaml",
+			Kind:       "Deployment",
+			Namespace:  "apps",
+			NumAdded:   2,
+			NumRemoved: 2,
+		},
+		results[0].Summary(),
+	)
+
+	summariesJSON, err := SummariesJSON(results[0:1])
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"file1.yaml":{"name":"file1.yaml","kind":"Deployment","namespace":"apps","numAdded":2,"numRemoved":2}}`,
+		summariesJSON,
+	)
+}
+
+func TestClipLines(t *testing.T) {
+	result := Result{
+		Name:       "test",
+		RawDiff:    "line1\nline2\nline3\nline4\n",
+		NumAdded:   3,
+		NumRemoved: 1,
+	}
+
+	assert.Equal(t, result.RawDiff, result.ClipLines(0).RawDiff)
+	assert.Equal(t, result.RawDiff, result.ClipLines(4).RawDiff)
+
+	clipped := result.ClipLines(2)
+	assert.Equal(t, "line1\nline2\n... (2 lines omitted, see logs)", clipped.RawDiff)
+	assert.Equal(t, 3, clipped.NumAdded)
+	assert.Equal(t, 1, clipped.NumRemoved)
+	assert.Equal(t, "line1\nline2\nline3\nline4\n", result.RawDiff)
+}
+
+func TestDiffKubeRecordAnnotations(t *testing.T) {
+	results, err := DiffKube("testdata/records/old", "testdata/records/new", Options{})
+	require.NoError(t, err)
+
+	// The configmap only differs in the record annotations, so it's omitted entirely
+	require.Equal(t, 1, len(results.Results))
+	assert.Equal(t, "deployment.yaml", results.Results[0].Name)
+	assert.Equal(t, 1, results.Results[0].NumAdded)
+	assert.Equal(t, 1, results.Results[0].NumRemoved)
+	assert.NotContains(t, results.Results[0].RawDiff, RecordAnnotationPrefix)
+	assert.Contains(t, results.Results[0].RawDiff, "+  replicas: 2")
+}
+
+func TestStripRecordAnnotations(t *testing.T) {
+	contents, err := stripRecordAnnotations(
+		[]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    kubeapply.segment.io/applied-at: "2021-01-02T03:04:05Z"
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"kind":"ConfigMap","metadata":{"annotations":{"kubeapply.segment.io/applied-at":"2021-01-02T03:04:05Z"},"name":"test-config"}}
+  name: test-config
+`),
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		`apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"kind":"ConfigMap","metadata":{"name":"test-config"}}
+  name: test-config
+`,
+		string(contents),
+	)
+
+	unchanged := []byte("kind: ConfigMap\nmetadata:\n  name: test-config\n")
+	contents, err = stripRecordAnnotations(unchanged)
+	require.NoError(t, err)
+	assert.Equal(t, unchanged, contents)
+}
@@ -0,0 +1,114 @@
+package diff
+
+import (
+	"strings"
+)
+
+// DefaultIgnoredFields are the fields that are set by the API server and change without
+// any corresponding change in the configs.
+var DefaultIgnoredFields = []string{
+	"creationTimestamp",
+	"generation",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// FilterOptions determines which diff results are significant enough to keep.
+type FilterOptions struct {
+	// MinChangedLines is the minimum number of significant added or removed lines that a
+	// result must have to be kept. Values less than 1 are treated as 1, i.e. results without
+	// any significant changes are always dropped.
+	MinChangedLines int
+
+	// IgnoredFields are YAML keys whose changes aren't significant, e.g. DefaultIgnoredFields.
+	IgnoredFields []string
+
+	// IgnoreWhitespace indicates whether changes that only affect whitespace are ignored.
+	IgnoreWhitespace bool
+}
+
+// SignificantChanges returns the number of added and removed lines in this result that
+// aren't ignored by the argument options.
+func (r Result) SignificantChanges(options FilterOptions) int {
+	ignoredFields := map[string]struct{}{}
+	for _, field := range options.IgnoredFields {
+		ignoredFields[field] = struct{}{}
+	}
+
+	// Keep track of removed lines so that they can be paired with whitespace-only additions
+	removed := map[string]int{}
+	numRemoved := 0
+	added := []string{}
+
+	for _, line := range strings.Split(r.RawDiff, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		content := line[1:]
+		if _, ok := ignoredFields[lineKey(content)]; ok {
+			continue
+		}
+		if options.IgnoreWhitespace {
+			content = strings.Join(strings.Fields(content), "")
+			if content == "" {
+				continue
+			}
+		}
+
+		if line[0] == '-' {
+			removed[content]++
+			numRemoved++
+		} else {
+			added = append(added, content)
+		}
+	}
+
+	numChanged := numRemoved + len(added)
+
+	if options.IgnoreWhitespace {
+		for _, content := range added {
+			if removed[content] > 0 {
+				removed[content]--
+				numChanged -= 2
+			}
+		}
+	}
+
+	return numChanged
+}
+
+// FilterResults returns the subset of the argument results that have at least
+// options.MinChangedLines significant changes.
+func FilterResults(results []Result, options FilterOptions) []Result {
+	minChangedLines := options.MinChangedLines
+	if minChangedLines < 1 {
+		minChangedLines = 1
+	}
+
+	filtered := []Result{}
+
+	for _, result := range results {
+		if result.SignificantChanges(options) >= minChangedLines {
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered
+}
+
+// lineKey returns the YAML key for the argument line, if any.
+func lineKey(line string) string {
+	trimmed := strings.TrimSpace(line)
+	trimmed = strings.TrimPrefix(trimmed, "- ")
+
+	index := strings.Index(trimmed, ":")
+	if index < 0 {
+		return ""
+	}
+	return strings.Trim(trimmed[:index], `"'`)
+}
@@ -0,0 +1,88 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterResults(t *testing.T) {
+	timestampDiff := Result{
+		Name: "timestamp",
+		RawDiff: `--- Server:deployment.yaml
++++ Local:deployment.yaml
+@@ -3,7 +3,7 @@
+ metadata:
+-  creationTimestamp: "2021-01-01T00:00:00Z"
++  creationTimestamp: null
+-  uid: abc123
+   name: test
+`,
+	}
+	whitespaceDiff := Result{
+		Name: "whitespace",
+		RawDiff: `--- Server:config.yaml
++++ Local:config.yaml
+@@ -1,3 +1,3 @@
+ data:
+-  key:   value
++  key: value
++
+`,
+	}
+	replicasDiff := Result{
+		Name: "replicas",
+		RawDiff: `--- Server:deployment.yaml
++++ Local:deployment.yaml
+@@ -8,7 +8,7 @@
+ spec:
+-  replicas: 1
++  replicas: 3
+`,
+	}
+	results := []Result{timestampDiff, whitespaceDiff, replicasDiff}
+
+	type testCase struct {
+		description string
+		options     FilterOptions
+		expNames    []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "no filtering",
+			options:     FilterOptions{},
+			expNames:    []string{"timestamp", "whitespace", "replicas"},
+		},
+		{
+			description: "ignored fields",
+			options: FilterOptions{
+				IgnoredFields: DefaultIgnoredFields,
+			},
+			expNames: []string{"whitespace", "replicas"},
+		},
+		{
+			description: "ignored fields and whitespace",
+			options: FilterOptions{
+				IgnoredFields:    DefaultIgnoredFields,
+				IgnoreWhitespace: true,
+			},
+			expNames: []string{"replicas"},
+		},
+		{
+			description: "min changed lines",
+			options: FilterOptions{
+				MinChangedLines: 3,
+			},
+			expNames: []string{"timestamp", "whitespace"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		names := []string{}
+		for _, result := range FilterResults(results, testCase.options) {
+			names = append(names, result.Name)
+		}
+		assert.Equal(t, testCase.expNames, names, testCase.description)
+	}
+}
@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// RedactedValue is the value that redacted labels and annotations are replaced with.
+const RedactedValue = "[REDACTED]"
+
+// RedactDir redacts the labels and annotations with the argument keys in each of the object
+// files in the argument directory, in place. It's used on the directories passed to external
+// differs, e.g. "diff -u -N" for raw diffs, since they don't redact anything themselves.
+func RedactDir(root string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return filepath.Walk(
+		root,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			redacted, err := redactMetadata(contents, keys)
+			if err != nil {
+				return fmt.Errorf("Error redacting metadata in %s: %+v", path, err)
+			}
+			if bytes.Equal(redacted, contents) {
+				return nil
+			}
+
+			return ioutil.WriteFile(path, redacted, info.Mode())
+		},
+	)
+}
+
+// redactMetadata replaces the values of the labels and annotations with the argument keys
+// in the argument object, including in any nested metadata like pod templates, and in its
+// kubectl last-applied configuration. The contents are returned as-is if they don't reference
+// any of the keys.
+func redactMetadata(contents []byte, keys []string) ([]byte, error) {
+	keysMap := map[string]struct{}{}
+	for _, key := range keys {
+		if bytes.Contains(contents, []byte(key)) {
+			keysMap[key] = struct{}{}
+		}
+	}
+	if len(keysMap) == 0 {
+		return contents, nil
+	}
+
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(contents, &obj); err != nil {
+		return contents, err
+	}
+
+	if annotations := objAnnotations(obj); annotations != nil {
+		if lastApplied, ok := annotations[lastAppliedAnnotation].(string); ok {
+			lastAppliedObj := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(lastApplied), &lastAppliedObj); err != nil {
+				return contents, err
+			}
+			redactValue(lastAppliedObj, keysMap)
+
+			lastAppliedBytes, err := json.Marshal(lastAppliedObj)
+			if err != nil {
+				return contents, err
+			}
+			if strings.HasSuffix(lastApplied, "\n") {
+				lastAppliedBytes = append(lastAppliedBytes, '\n')
+			}
+			annotations[lastAppliedAnnotation] = string(lastAppliedBytes)
+		}
+	}
+
+	redactValue(obj, keysMap)
+	return yaml.Marshal(obj)
+}
+
+// redactValue walks the argument value and redacts the matching labels and annotations in
+// every metadata map that it finds.
+func redactValue(value interface{}, keys map[string]struct{}) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if metadata, ok := typedValue["metadata"].(map[string]interface{}); ok {
+			for _, field := range []string{"labels", "annotations"} {
+				values, _ := metadata[field].(map[string]interface{})
+				for key := range values {
+					if _, ok := keys[key]; ok {
+						values[key] = RedactedValue
+					}
+				}
+			}
+		}
+		for _, subValue := range typedValue {
+			redactValue(subValue, keys)
+		}
+	case []interface{}:
+		for _, subValue := range typedValue {
+			redactValue(subValue, keys)
+		}
+	}
+}
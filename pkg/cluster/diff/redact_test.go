@@ -0,0 +1,111 @@
+package diff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactMetadata(t *testing.T) {
+	contents, err := redactMetadata(
+		[]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    example.com/token: secret-token
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"kind":"Deployment","metadata":{"annotations":{"example.com/token":"secret-token"},"name":"test"}}
+  labels:
+    app: test
+    example.com/internal-url: http://internal
+  name: test
+spec:
+  template:
+    metadata:
+      annotations:
+        example.com/token: secret-token
+`),
+		[]string{"example.com/token", "example.com/internal-url", "example.com/other"},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    example.com/token: '[REDACTED]'
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"kind":"Deployment","metadata":{"annotations":{"example.com/token":"[REDACTED]"},"name":"test"}}
+  labels:
+    app: test
+    example.com/internal-url: '[REDACTED]'
+  name: test
+spec:
+  template:
+    metadata:
+      annotations:
+        example.com/token: '[REDACTED]'
+`,
+		string(contents),
+	)
+
+	unchanged := []byte("kind: ConfigMap\nmetadata:\n  name: test-config\n")
+	contents, err = redactMetadata(unchanged, []string{"example.com/token"})
+	require.NoError(t, err)
+	assert.Equal(t, unchanged, contents)
+}
+
+func TestRedactDir(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "redact")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"v1.ConfigMap.test.config1": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    example.com/token: secret-token
+  name: config1
+`,
+			"v1.ConfigMap.test.config2": `# Not redacted
+kind: ConfigMap
+metadata:
+  name: config2
+`,
+		},
+	)
+
+	require.NoError(t, RedactDir(tempDir, []string{"example.com/token"}))
+
+	contents, err := ioutil.ReadFile(filepath.Join(tempDir, "v1.ConfigMap.test.config1"))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		`apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    example.com/token: '[REDACTED]'
+  name: config1
+`,
+		string(contents),
+	)
+
+	contents, err = ioutil.ReadFile(filepath.Join(tempDir, "v1.ConfigMap.test.config2"))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"# Not redacted\nkind: ConfigMap\nmetadata:\n  name: config2\n",
+		string(contents),
+	)
+}
@@ -1,7 +1,9 @@
 package diff
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/segmentio/kubeapply/pkg/cluster/apply"
@@ -12,6 +14,10 @@ import (
 // everything can be put in a single struct when exported by kubeapply kdiff.
 type Results struct {
 	Results []Result `json:"results"`
+
+	// HiddenHelmHooks is the number of helm hook resources with diffs that were excluded
+	// because the IgnoreHelmHooks option was set.
+	HiddenHelmHooks int `json:"hiddenHelmHooks"`
 }
 
 // Result contains the results of diffing a single object.
@@ -23,8 +29,19 @@ type Result struct {
 	NumRemoved int                 `json:"numRemoved"`
 }
 
-// PrintFull prints out a table and the raw diffs for a results slice.
-func PrintFull(results []Result) {
+// ResultSummary contains structured metadata about the diff of a single object, without
+// the raw diff itself.
+type ResultSummary struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	NumAdded   int    `json:"numAdded"`
+	NumRemoved int    `json:"numRemoved"`
+}
+
+// PrintFull prints out a table and the raw diffs for a results slice. If useColors is true,
+// then added and removed lines are colored in the raw diffs.
+func PrintFull(results []Result, useColors bool) {
 	if len(results) == 0 {
 		log.Infof("No diffs found")
 		return
@@ -33,7 +50,29 @@ func PrintFull(results []Result) {
 	log.Infof("Diffs summary:\n%s", ResultsTable(results))
 	log.Info("Raw diffs:")
 	for _, result := range results {
-		result.PrintRaw(true)
+		result.PrintRaw(useColors)
+	}
+}
+
+// PrintFullByNamespace is like PrintFull, but prints a separate summary table and set of
+// raw diffs for each namespace. See GroupByNamespace for how the results are grouped.
+func PrintFullByNamespace(results []Result, useColors bool) {
+	if len(results) == 0 {
+		log.Infof("No diffs found")
+		return
+	}
+
+	for _, group := range GroupByNamespace(results) {
+		log.Infof(
+			"Diffs summary for %s (%d):\n%s",
+			group.Label(),
+			len(group.Results),
+			ResultsTable(group.Results),
+		)
+		log.Infof("Raw diffs for %s:", group.Label())
+		for _, result := range group.Results {
+			result.PrintRaw(useColors)
+		}
 	}
 }
 
@@ -90,6 +129,24 @@ func (r *Result) ClippedRawDiff(maxLen int) string {
 	return r.RawDiff
 }
 
+// ClipLines returns a copy of this result with the raw diff clipped to the argument number of
+// lines. The added and removed counts are left as-is. If maxLines is zero or negative, then
+// the raw diff isn't clipped.
+func (r *Result) ClipLines(maxLines int) *Result {
+	clipped := *r
+
+	lines := strings.Split(strings.TrimRight(r.RawDiff, "\n"), "\n")
+	if maxLines > 0 && len(lines) > maxLines {
+		clipped.RawDiff = fmt.Sprintf(
+			"%s\n... (%d lines omitted, see logs)",
+			strings.Join(lines[0:maxLines], "\n"),
+			len(lines)-maxLines,
+		)
+	}
+
+	return &clipped
+}
+
 // NumChangedLines returns the rough number of lines changed (taken as the max of the num
 // added and num removed).
 func (r *Result) NumChangedLines() int {
@@ -99,6 +156,80 @@ func (r *Result) NumChangedLines() int {
 	return r.NumRemoved
 }
 
+// Summary returns a structured summary of this result.
+func (r *Result) Summary() ResultSummary {
+	summary := ResultSummary{
+		Name:       r.Name,
+		NumAdded:   r.NumAdded,
+		NumRemoved: r.NumRemoved,
+	}
+	if r.Object != nil {
+		summary.Kind = r.Object.Kind
+		summary.Namespace = r.Object.Namespace
+	}
+	return summary
+}
+
+// NamespaceGroup is a group of diff results for resources in the same namespace.
+type NamespaceGroup struct {
+	// Namespace is the namespace of the resources. It's empty for cluster-scoped resources.
+	Namespace string
+	Results   []Result
+}
+
+// Label returns a human-readable description of the group.
+func (g NamespaceGroup) Label() string {
+	if g.Namespace == "" {
+		return "cluster-scoped resources"
+	}
+	return fmt.Sprintf("namespace %s", g.Namespace)
+}
+
+// GroupByNamespace groups the argument results by the namespaces of their objects. The group
+// for cluster-scoped resources, if any, comes first, followed by the other groups in namespace
+// order. Results keep their relative order within each group.
+func GroupByNamespace(results []Result) []NamespaceGroup {
+	groups := []NamespaceGroup{}
+	groupIndices := map[string]int{}
+
+	for _, result := range results {
+		var namespace string
+		if result.Object != nil {
+			namespace = result.Object.Namespace
+		}
+
+		index, ok := groupIndices[namespace]
+		if !ok {
+			index = len(groups)
+			groupIndices[namespace] = index
+			groups = append(groups, NamespaceGroup{Namespace: namespace})
+		}
+		groups[index].Results = append(groups[index].Results, result)
+	}
+
+	sort.SliceStable(groups, func(a, b int) bool {
+		return groups[a].Namespace < groups[b].Namespace
+	})
+
+	return groups
+}
+
+// SummariesJSON returns the JSON-encoded summaries of all of the argument results, keyed
+// by result name.
+func SummariesJSON(results []Result) (string, error) {
+	summaries := map[string]ResultSummary{}
+
+	for _, result := range results {
+		summaries[result.Name] = result.Summary()
+	}
+
+	summariesBytes, err := json.Marshal(summaries)
+	if err != nil {
+		return "", err
+	}
+	return string(summariesBytes), nil
+}
+
 func printRed(line string) {
 	// Use escape codes directly instead of color library to force colors even if we're
 	// not in a terminal
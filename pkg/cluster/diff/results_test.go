@@ -0,0 +1,52 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/cluster/apply"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupByNamespace(t *testing.T) {
+	result := func(name string, namespace string) Result {
+		return Result{
+			Name: name,
+			Object: &apply.TypedKubeObj{
+				KubeMetadata: apply.KubeMetadata{
+					Name:      name,
+					Namespace: namespace,
+				},
+			},
+		}
+	}
+
+	groups := GroupByNamespace(
+		[]Result{
+			result("deployment1", "ns2"),
+			result("service1", "ns1"),
+			result("role1", ""),
+			result("deployment2", "ns2"),
+			{Name: "unparsed"},
+		},
+	)
+
+	groupNames := [][]string{}
+	for _, group := range groups {
+		names := []string{group.Label()}
+		for _, result := range group.Results {
+			names = append(names, result.Name)
+		}
+		groupNames = append(groupNames, names)
+	}
+
+	assert.Equal(
+		t,
+		[][]string{
+			{"cluster-scoped resources", "role1", "unparsed"},
+			{"namespace ns1", "service1"},
+			{"namespace ns2", "deployment1", "deployment2"},
+		},
+		groupNames,
+	)
+	assert.Equal(t, []NamespaceGroup{}, GroupByNamespace(nil))
+}
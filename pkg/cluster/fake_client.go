@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/segmentio/kubeapply/pkg/cluster/apply"
 	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/store"
 )
 
 var _ ClusterClient = (*FakeClusterClient)(nil)
@@ -18,6 +21,11 @@ type FakeClusterClient struct {
 	subpathOverride string
 	store           map[string]string
 	kubectlErr      error
+	lastDiffSHA     string
+	headSHA         string
+	lastApplySHA    string
+	lastApplyTime   time.Time
+	lock            *store.LockInfo
 }
 
 // NewFakeClusterClient returns a FakeClusterClient that works without errors.
@@ -44,6 +52,73 @@ func NewFakeClusterClientError(
 	}, nil
 }
 
+// NewFakeClusterClientLastDiff returns a ClusterClientGenerator for FakeClusterClients that
+// have a recorded diff at the argument SHA.
+func NewFakeClusterClientLastDiff(lastDiffSHA string) ClusterClientGenerator {
+	return func(
+		ctx context.Context,
+		config *ClusterClientConfig,
+	) (ClusterClient, error) {
+		return &FakeClusterClient{
+			clusterConfig: config.ClusterConfig,
+			store:         map[string]string{},
+			lastDiffSHA:   lastDiffSHA,
+		}, nil
+	}
+}
+
+// NewFakeClusterClientLastApply returns a ClusterClientGenerator for FakeClusterClients that
+// have a recorded apply at the argument SHA.
+func NewFakeClusterClientLastApply(lastApplySHA string) ClusterClientGenerator {
+	return func(
+		ctx context.Context,
+		config *ClusterClientConfig,
+	) (ClusterClient, error) {
+		return &FakeClusterClient{
+			clusterConfig: config.ClusterConfig,
+			store:         map[string]string{},
+			headSHA:       config.HeadSHA,
+			lastApplySHA:  lastApplySHA,
+		}, nil
+	}
+}
+
+// NewFakeClusterClientLastApplyTime returns a ClusterClientGenerator for FakeClusterClients
+// whose last applies finished at the argument time.
+func NewFakeClusterClientLastApplyTime(lastApplyTime time.Time) ClusterClientGenerator {
+	return func(
+		ctx context.Context,
+		config *ClusterClientConfig,
+	) (ClusterClient, error) {
+		return &FakeClusterClient{
+			clusterConfig: config.ClusterConfig,
+			store:         map[string]string{},
+			lastApplyTime: lastApplyTime,
+		}, nil
+	}
+}
+
+// NewFakeClusterClientLocked returns a ClusterClientGenerator for FakeClusterClients whose
+// locks are held by the argument holder since the argument time.
+func NewFakeClusterClientLocked(holder string, acquiredAt time.Time) ClusterClientGenerator {
+	return func(
+		ctx context.Context,
+		config *ClusterClientConfig,
+	) (ClusterClient, error) {
+		return &FakeClusterClient{
+			clusterConfig: config.ClusterConfig,
+			store:         map[string]string{},
+			lock: &store.LockInfo{
+				Name:          config.ClusterConfig.Cluster,
+				Holder:        holder,
+				AcquiredAt:    acquiredAt,
+				RenewedAt:     acquiredAt,
+				LeaseDuration: time.Hour,
+			},
+		}, nil
+	}
+}
+
 // Apply runs a fake apply using the configs in the argument path.
 func (cc *FakeClusterClient) Apply(
 	ctx context.Context,
@@ -82,6 +157,31 @@ func (cc *FakeClusterClient) ApplyStructured(
 	}, cc.kubectlErr
 }
 
+// DryRunStructured runs a fake structured dry-run apply using the configs in the argument
+// path.
+func (cc *FakeClusterClient) DryRunStructured(
+	ctx context.Context,
+	paths []string,
+	serverSide bool,
+) ([]apply.Result, error) {
+	return []apply.Result{
+		{
+			Kind:       "Deployment",
+			Name:       "created-deployment",
+			Namespace:  "test-namespace",
+			OldVersion: "",
+			NewVersion: "",
+		},
+		{
+			Kind:       "Deployment",
+			Name:       "existing-deployment",
+			Namespace:  "test-namespace",
+			OldVersion: "1234",
+			NewVersion: "1234",
+		},
+	}, cc.kubectlErr
+}
+
 // Diff runs a fake diff using the configs in the argument path.
 func (cc *FakeClusterClient) Diff(
 	ctx context.Context,
@@ -104,15 +204,17 @@ func (cc *FakeClusterClient) DiffStructured(
 	paths []string,
 	serverSide bool,
 	diffCommand string,
-) ([]diff.Result, error) {
-	return []diff.Result{
-			{
-				Name: "result",
-				RawDiff: fmt.Sprintf(
-					"diff result for %s with paths %+v",
-					cc.clusterConfig.Cluster,
-					paths,
-				),
+) (diff.Results, error) {
+	return diff.Results{
+			Results: []diff.Result{
+				{
+					Name: "result",
+					RawDiff: fmt.Sprintf(
+						"diff result for %s with paths %+v",
+						cc.clusterConfig.Cluster,
+						paths,
+					),
+				},
 			},
 		},
 		cc.kubectlErr
@@ -123,6 +225,23 @@ func (cc *FakeClusterClient) Summary(ctx context.Context) (string, error) {
 	return fmt.Sprintf("summary %s", cc.clusterConfig.Cluster), cc.kubectlErr
 }
 
+// SummaryStructured creates a fake structured summary of the current cluster state.
+func (cc *FakeClusterClient) SummaryStructured(
+	ctx context.Context,
+) (kube.StructuredSummary, error) {
+	return kube.StructuredSummary{
+		Namespaces: []kube.NamespaceSummary{
+			{
+				Namespace: cc.clusterConfig.Cluster,
+				TotalPods: 1,
+				PodPhases: map[string]int{"Running": 1},
+			},
+		},
+		Deployments: []kube.DeploymentSummary{},
+		Nodes:       kube.NodesSummary{Total: 1, Ready: 1},
+	}, cc.kubectlErr
+}
+
 // GetStoreValue gets the value of the argument key.
 func (cc *FakeClusterClient) GetStoreValue(ctx context.Context, key string) (string, error) {
 	return cc.store[key], nil
@@ -134,6 +253,26 @@ func (cc *FakeClusterClient) SetStoreValue(ctx context.Context, key string, valu
 	return nil
 }
 
+// LastDiffSHA returns the fake SHA of the last diff in the cluster.
+func (cc *FakeClusterClient) LastDiffSHA(ctx context.Context) (string, error) {
+	return cc.lastDiffSHA, nil
+}
+
+// AppliedAtHead returns whether the fake recorded apply is at the head SHA.
+func (cc *FakeClusterClient) AppliedAtHead(ctx context.Context) (bool, error) {
+	return cc.lastApplySHA != "" && cc.lastApplySHA == cc.headSHA, nil
+}
+
+// LastApplyTime returns the fake time of the last apply in the cluster.
+func (cc *FakeClusterClient) LastApplyTime(ctx context.Context) (time.Time, error) {
+	return cc.lastApplyTime, nil
+}
+
+// Lock returns the fake state of the cluster's lock.
+func (cc *FakeClusterClient) Lock(ctx context.Context) (*store.LockInfo, error) {
+	return cc.lock, nil
+}
+
 // Config returns this client's cluster config.
 func (cc *FakeClusterClient) Config() *config.ClusterConfig {
 	return cc.clusterConfig
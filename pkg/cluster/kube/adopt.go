@@ -0,0 +1,134 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExistingResources returns the names, in kubectl "kind/name" format, of the resources in
+// the argument paths that already exist in the cluster.
+func (k *OrderedClient) ExistingResources(
+	ctx context.Context,
+	paths []string,
+) ([]string, error) {
+	tempDir, err := k.writeFilteredManifests(paths)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	args := []string{
+		"--kubeconfig",
+		k.kubeConfigPath,
+		"get",
+		"-R",
+		"-f",
+		tempDir,
+		"--ignore-not-found",
+		"-o",
+		"name",
+	}
+
+	out, err := k.kubectlOutput(ctx, args, k.extraEnv, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error getting existing resources: %s",
+			strings.TrimSpace(string(out)),
+		)
+	}
+
+	return parseResourceNames(string(out)), nil
+}
+
+// Adopt applies the manifests in the argument paths, taking ownership of any resources that
+// already exist in the cluster instead of failing on them.
+//
+// This is done via a server-side apply with the client's field manager and conflicts forced.
+// As a result, any fields set in the manifests that were previously owned by other managers
+// (e.g., kubectl client-side applies or Helm) are transferred to kubeapply. Later applies of
+// these fields by the other managers will then conflict unless they also force conflicts.
+//
+// If output is true, then the output is returned in the argument format instead of being
+// logged.
+func (k *OrderedClient) Adopt(
+	ctx context.Context,
+	paths []string,
+	output bool,
+	format string,
+	dryRun bool,
+) ([]byte, error) {
+	existing, err := k.ExistingResources(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		log.Infof("Adopting %d existing resources: %+v", len(existing), existing)
+	}
+
+	tempDir, err := util.TempDir("manifests")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifests, err := applyManifests(
+		paths,
+		k.filter,
+		k.applyRecord,
+		k.ownershipLabels,
+		k.applyOrderPath,
+		k.protectedResources,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteManifests(tempDir, manifests); err != nil {
+		return nil, err
+	}
+
+	return k.runApply(ctx, k.applyArgs(tempDir, format, dryRun, k.serverSide, true), manifests, output, dryRun)
+}
+
+// writeFilteredManifests writes the manifests in the argument paths that match the client's
+// filter to a new temp directory, in apply order.
+func (k *OrderedClient) writeFilteredManifests(paths []string) (string, error) {
+	manifests, err := GetManifests(paths)
+	if err != nil {
+		return "", err
+	}
+	manifests = FilterManifests(manifests, k.filter)
+	if len(manifests) == 0 {
+		return "", errors.New("No manifests match the provided kind and name filters")
+	}
+	SortManifests(manifests)
+
+	tempDir, err := util.TempDir("manifests")
+	if err != nil {
+		return "", err
+	}
+	if err := WriteManifests(tempDir, manifests); err != nil {
+		os.RemoveAll(tempDir)
+		return "", err
+	}
+
+	return tempDir, nil
+}
+
+func parseResourceNames(output string) []string {
+	names := []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			names = append(names, line)
+		}
+	}
+
+	return names
+}
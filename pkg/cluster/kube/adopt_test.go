@@ -0,0 +1,97 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResourceNames(t *testing.T) {
+	assert.Equal(t, []string{}, parseResourceNames(""))
+	assert.Equal(
+		t,
+		[]string{
+			"deployment.apps/test-deployment",
+			"configmap/test-configmap",
+		},
+		parseResourceNames("deployment.apps/test-deployment\n\nconfigmap/test-configmap\n"),
+	)
+}
+
+func TestApplyArgs(t *testing.T) {
+	type testCase struct {
+		description string
+		client      *OrderedClient
+		format      string
+		dryRun      bool
+		adopt       bool
+		expArgs     []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "client-side apply",
+			client:      &OrderedClient{kubeConfigPath: "kubeconfig.yaml"},
+			expArgs: []string{
+				"apply", "--kubeconfig", "kubeconfig.yaml", "-R", "-f", "test-dir",
+			},
+		},
+		{
+			description: "server-side apply",
+			client: &OrderedClient{
+				kubeConfigPath: "kubeconfig.yaml",
+				serverSide:     true,
+				fieldManager:   "test-manager",
+			},
+			format: "json",
+			expArgs: []string{
+				"apply", "--kubeconfig", "kubeconfig.yaml", "-R", "-f", "test-dir",
+				"--server-side", "true", "--field-manager=test-manager",
+				"-o", "json",
+			},
+		},
+		{
+			description: "adopt",
+			client: &OrderedClient{
+				kubeConfigPath: "kubeconfig.yaml",
+				fieldManager:   "test-manager",
+			},
+			adopt: true,
+			expArgs: []string{
+				"apply", "--kubeconfig", "kubeconfig.yaml", "-R", "-f", "test-dir",
+				"--server-side", "--force-conflicts", "--field-manager=test-manager",
+			},
+		},
+		{
+			description: "adopt dry-run with server-side client",
+			client: &OrderedClient{
+				kubeConfigPath: "kubeconfig.yaml",
+				serverSide:     true,
+				fieldManager:   "test-manager",
+			},
+			format: "json",
+			dryRun: true,
+			adopt:  true,
+			expArgs: []string{
+				"apply", "--kubeconfig", "kubeconfig.yaml", "-R", "-f", "test-dir",
+				"--server-side", "--force-conflicts", "--field-manager=test-manager",
+				"-o", "json", "--dry-run",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(
+			t,
+			testCase.expArgs,
+			testCase.client.applyArgs(
+				"test-dir",
+				testCase.format,
+				testCase.dryRun,
+				testCase.client.serverSide,
+				testCase.adopt,
+			),
+			testCase.description,
+		)
+	}
+}
@@ -0,0 +1,176 @@
+package kube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+)
+
+// ApplyOrderFile is the name of the optional file in the root of a cluster's expanded configs
+// that lists the resources that need to be applied in a specific order.
+const ApplyOrderFile = "apply-order.yaml"
+
+// ApplyOrder is a list of resource IDs, in the order that the associated resources should be
+// applied. Each ID is either [kind]/[namespace]/[name] for namespaced resources or [kind]/[name]
+// for cluster-scoped ones; any component can be a glob.
+type ApplyOrder []string
+
+// LoadApplyOrder loads the apply order in the argument file. If the path is empty or the file
+// doesn't exist, then it returns a nil ApplyOrder.
+func LoadApplyOrder(orderPath string) (ApplyOrder, error) {
+	if orderPath == "" {
+		return nil, nil
+	}
+
+	contents, err := ioutil.ReadFile(orderPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	order := ApplyOrder{}
+	if err := yaml.Unmarshal(contents, &order); err != nil {
+		return nil, fmt.Errorf("Error parsing apply order in %s: %+v", orderPath, err)
+	}
+	if err := order.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid apply order in %s: %+v", orderPath, err)
+	}
+
+	return order, nil
+}
+
+// Validate checks that each of the IDs in this ApplyOrder is well-formed.
+func (o ApplyOrder) Validate() error {
+	for _, id := range o {
+		components := strings.Split(id, "/")
+		if len(components) < 2 || len(components) > 3 {
+			return fmt.Errorf(
+				"ID %s must be in the format [kind]/[namespace]/[name] or [kind]/[name]",
+				id,
+			)
+		}
+		if _, err := path.Match(id, ""); err != nil {
+			return fmt.Errorf("ID %s is not a valid glob: %+v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// Sort sorts the argument manifests, which should already be sorted via SortManifests, so that
+// the ones that match an ID in this ApplyOrder come first in each apply wave, in the order of
+// the IDs that they match. The manifests that don't match any ID keep their kind-based order.
+func (o ApplyOrder) Sort(manifests []Manifest) {
+	if len(o) == 0 {
+		return
+	}
+
+	keys := make([]int, len(manifests))
+	for m, manifest := range manifests {
+		keys[m] = o.index(manifest)
+	}
+
+	sort.Stable(
+		manifestsByOrder{
+			manifests: manifests,
+			keys:      keys,
+		},
+	)
+}
+
+// index returns the index of the first ID in this ApplyOrder that matches the argument
+// manifest or, if there aren't any matches, the length of the ApplyOrder.
+func (o ApplyOrder) index(manifest Manifest) int {
+	id := ManifestID(manifest)
+
+	for i, pattern := range o {
+		matches, err := path.Match(pattern, id)
+		if err != nil {
+			log.Warnf("Invalid apply order ID %s: %+v", pattern, err)
+			continue
+		}
+		if matches {
+			return i
+		}
+	}
+
+	return len(o)
+}
+
+// ManifestID returns the ID of the argument manifest in the format used by ApplyOrder.
+func ManifestID(manifest Manifest) string {
+	var namespace, name string
+
+	if manifest.Head.Metadata != nil {
+		namespace = manifest.Head.Metadata.Namespace
+		name = manifest.Head.Metadata.Name
+	}
+
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s", manifest.Head.Kind, name)
+	}
+	return fmt.Sprintf("%s/%s/%s", manifest.Head.Kind, namespace, name)
+}
+
+// hasApplyOrderFile returns whether there's an apply order file anywhere in the argument
+// paths. Like GetManifests, this walks each path recursively so that subpaths containing a
+// nested apply order file are detected.
+func hasApplyOrderFile(paths []string) bool {
+	found := false
+
+	for _, configPath := range paths {
+		err := filepath.Walk(
+			configPath,
+			func(subPath string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() && filepath.Base(subPath) == ApplyOrderFile {
+					found = true
+					return filepath.SkipAll
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			log.Warnf("Error looking for apply order file in %s: %+v", configPath, err)
+		}
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// manifestsByOrder sorts manifests by wave and then by their apply order keys.
+type manifestsByOrder struct {
+	manifests []Manifest
+	keys      []int
+}
+
+func (m manifestsByOrder) Len() int {
+	return len(m.manifests)
+}
+
+func (m manifestsByOrder) Less(i, j int) bool {
+	wave1 := ManifestWave(m.manifests[i])
+	wave2 := ManifestWave(m.manifests[j])
+	if wave1 != wave2 {
+		return wave1 < wave2
+	}
+	return m.keys[i] < m.keys[j]
+}
+
+func (m manifestsByOrder) Swap(i, j int) {
+	m.manifests[i], m.manifests[j] = m.manifests[j], m.manifests[i]
+	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+}
@@ -0,0 +1,227 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOrder(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "data")
+	require.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	util.WriteFiles(
+		t,
+		outDir,
+		map[string]string{
+			ApplyOrderFile: `
+- Secret/jobs/db-password
+- Job/jobs/migrate-*
+- ClusterRole/job-runner
+`,
+			"manifests.yaml": `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: jobs
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate-db
+  namespace: jobs
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: cleanup
+  namespace: jobs
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db-password
+  namespace: jobs
+  annotations:
+    kubeapply.segment.io/wave: "1"
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: job-runner
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db-config
+  namespace: jobs
+`,
+		},
+	)
+
+	applyOrder, err := LoadApplyOrder(filepath.Join(outDir, ApplyOrderFile))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		ApplyOrder{
+			"Secret/jobs/db-password",
+			"Job/jobs/migrate-*",
+			"ClusterRole/job-runner",
+		},
+		applyOrder,
+	)
+
+	// The apply order file itself isn't a manifest
+	manifests, err := GetManifests([]string{outDir})
+	require.NoError(t, err)
+	assert.Equal(t, 6, len(manifests))
+	assert.True(t, hasApplyOrderFile([]string{outDir}))
+
+	SortManifests(manifests)
+	applyOrder.Sort(manifests)
+
+	ids := []string{}
+	for _, manifest := range manifests {
+		ids = append(ids, ManifestID(manifest))
+	}
+
+	assert.Equal(
+		t,
+		[]string{
+			"Job/jobs/migrate-db",
+			"ClusterRole/job-runner",
+			"Namespace/jobs",
+			"ConfigMap/jobs/db-config",
+			"Job/jobs/cleanup",
+			"Secret/jobs/db-password",
+		},
+		ids,
+	)
+}
+
+func TestLoadApplyOrder(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "data")
+	require.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	util.WriteFiles(
+		t,
+		outDir,
+		map[string]string{
+			"bad-format.yaml": "- Deployment\n",
+			"bad-glob.yaml":   "- Deployment/apps/[app\n",
+			"not-a-list.yaml": "key: value\n",
+		},
+	)
+
+	type testCase struct {
+		path        string
+		expectedErr bool
+	}
+
+	testCases := []testCase{
+		{
+			path: "",
+		},
+		{
+			path: filepath.Join(outDir, "missing.yaml"),
+		},
+		{
+			path:        filepath.Join(outDir, "bad-format.yaml"),
+			expectedErr: true,
+		},
+		{
+			path:        filepath.Join(outDir, "bad-glob.yaml"),
+			expectedErr: true,
+		},
+		{
+			path:        filepath.Join(outDir, "not-a-list.yaml"),
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		applyOrder, err := LoadApplyOrder(testCase.path)
+		if testCase.expectedErr {
+			assert.Error(t, err, testCase.path)
+		} else {
+			assert.NoError(t, err, testCase.path)
+			assert.Nil(t, applyOrder, testCase.path)
+		}
+	}
+}
+
+func TestHasApplyOrderFile(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "apply_order")
+	require.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	util.WriteFiles(
+		t,
+		outDir,
+		map[string]string{
+			"root/" + ApplyOrderFile:                   "- Namespace/jobs\n",
+			"root/manifests.yaml":                      "kind: Namespace\n",
+			"nested/subdir1/subdir2/" + ApplyOrderFile: "- Namespace/jobs\n",
+			"nested/subdir1/manifests.yaml":            "kind: Namespace\n",
+			"none/subdir1/manifests.yaml":              "kind: Namespace\n",
+		},
+	)
+
+	type testCase struct {
+		description string
+		paths       []string
+		expected    bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "file in root",
+			paths:       []string{filepath.Join(outDir, "root")},
+			expected:    true,
+		},
+		{
+			description: "nested file",
+			paths:       []string{filepath.Join(outDir, "nested")},
+			expected:    true,
+		},
+		{
+			description: "nested file in second path",
+			paths: []string{
+				filepath.Join(outDir, "none"),
+				filepath.Join(outDir, "nested"),
+			},
+			expected: true,
+		},
+		{
+			description: "no file",
+			paths:       []string{filepath.Join(outDir, "none")},
+			expected:    false,
+		},
+		{
+			description: "manifest file path",
+			paths:       []string{filepath.Join(outDir, "root", "manifests.yaml")},
+			expected:    false,
+		},
+		{
+			description: "missing path",
+			paths:       []string{filepath.Join(outDir, "missing")},
+			expected:    false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(
+			t,
+			testCase.expected,
+			hasApplyOrderFile(testCase.paths),
+			testCase.description,
+		)
+	}
+}
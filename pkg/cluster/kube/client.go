@@ -0,0 +1,73 @@
+package kube
+
+import (
+	"context"
+	"time"
+
+	"github.com/briandowns/spinner"
+)
+
+const (
+	// BackendKubectl is the backend that runs diffs and applies by shelling out to kubectl.
+	BackendKubectl = "kubectl"
+
+	// BackendClientGo is the backend that runs diffs and applies via the kube API directly.
+	BackendClientGo = "client-go"
+)
+
+// Client is the interface implemented by the backends that interact with a cluster.
+type Client interface {
+	// Apply applies the manifests in the argument paths. If output is true, then the
+	// output is returned in the argument format instead of being logged.
+	Apply(
+		ctx context.Context,
+		applyPaths []string,
+		output bool,
+		format string,
+		dryRun bool,
+	) ([]byte, error)
+
+	// Adopt applies the manifests in the argument paths like Apply, but takes ownership of
+	// any resources that already exist in the cluster instead of conflicting with their
+	// current field managers.
+	Adopt(
+		ctx context.Context,
+		paths []string,
+		output bool,
+		format string,
+		dryRun bool,
+	) ([]byte, error)
+
+	// Diff diffs the manifests in the argument paths against the cluster. If structured is
+	// true, then the result is a JSON-encoded diff.Results; otherwise, it's a raw diff.
+	Diff(
+		ctx context.Context,
+		configPaths []string,
+		serverSide bool,
+		structured bool,
+		diffCommand string,
+		spinner *spinner.Spinner,
+	) ([]byte, error)
+
+	// Summary returns a pretty summary of the current cluster state.
+	Summary(ctx context.Context, mode SummaryMode) (string, error)
+
+	// SummaryStructured returns a machine-readable summary of the current cluster state.
+	SummaryStructured(ctx context.Context) (StructuredSummary, error)
+
+	// GetNamespaceUID returns the kubernetes identifier for a given namespace.
+	GetNamespaceUID(ctx context.Context, namespace string) (string, error)
+
+	// NamespaceExists returns whether the argument namespace exists in the cluster.
+	NamespaceExists(ctx context.Context, namespace string) (bool, error)
+
+	// CreateNamespace creates the argument namespace if it doesn't already exist.
+	CreateNamespace(ctx context.Context, namespace string) error
+
+	// WaitForRollout waits for the rollouts of the workloads in the argument paths to
+	// complete, returning an error if they don't before the timeout elapses.
+	WaitForRollout(ctx context.Context, paths []string, timeout time.Duration) error
+}
+
+var _ Client = (*OrderedClient)(nil)
+var _ Client = (*DynamicClient)(nil)
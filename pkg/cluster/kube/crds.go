@@ -0,0 +1,122 @@
+package kube
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+)
+
+// crdRegistrationWait is how long to wait for CRDs to be registered before retrying an
+// apply that failed because of custom resources with unknown kinds.
+var crdRegistrationWait = 5 * time.Second
+
+// noMatchesRegexp matches the kubectl errors for resources with kinds that aren't registered
+// in the cluster, e.g. `no matches for kind "Foo" in version "example.com/v1"`.
+var noMatchesRegexp = regexp.MustCompile(`no matches for kind "([^"]+)" in version "([^"]+)"`)
+
+// missingKind is a resource kind that kubectl couldn't find in the cluster.
+type missingKind struct {
+	Kind    string
+	Version string
+}
+
+// group returns the API group of the kind, or an empty string for the core group.
+func (m missingKind) group() string {
+	if components := strings.SplitN(m.Version, "/", 2); len(components) == 2 {
+		return components[0]
+	}
+	return ""
+}
+
+// crdManifest is the subset of a CRD manifest that's needed to get the kind it defines.
+type crdManifest struct {
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+	} `json:"spec"`
+}
+
+// missingKinds returns the unique kinds in the argument kubectl output that failed because
+// they aren't registered in the cluster.
+func missingKinds(output string) []missingKind {
+	kinds := []missingKind{}
+	seen := map[missingKind]struct{}{}
+
+	for _, match := range noMatchesRegexp.FindAllStringSubmatch(output, -1) {
+		kind := missingKind{Kind: match[1], Version: match[2]}
+		if _, ok := seen[kind]; !ok {
+			kinds = append(kinds, kind)
+			seen[kind] = struct{}{}
+		}
+	}
+
+	return kinds
+}
+
+// crdKinds returns the kinds defined by the CRDs in the argument manifests, keyed by
+// "[group]/[kind]".
+func crdKinds(manifests []Manifest) map[string]struct{} {
+	kinds := map[string]struct{}{}
+
+	for _, manifest := range manifests {
+		if manifest.Head.Kind != "CustomResourceDefinition" {
+			continue
+		}
+
+		crd := crdManifest{}
+		if err := yaml.Unmarshal([]byte(manifest.Contents), &crd); err != nil {
+			log.Warnf("Could not parse CRD in %s: %+v", manifest.Path, err)
+			continue
+		}
+		kinds[fmt.Sprintf("%s/%s", crd.Spec.Group, crd.Spec.Names.Kind)] = struct{}{}
+	}
+
+	return kinds
+}
+
+// allDefinedByCRDs returns whether all of the argument missing kinds are defined by the
+// argument CRD kinds.
+func allDefinedByCRDs(kinds []missingKind, crds map[string]struct{}) bool {
+	for _, kind := range kinds {
+		if _, ok := crds[fmt.Sprintf("%s/%s", kind.group(), kind.Kind)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// missingKindsError wraps the argument apply error with an explanation of why each of the
+// argument kinds couldn't be found.
+func missingKindsError(err error, kinds []missingKind, crds map[string]struct{}) error {
+	explanations := []string{}
+
+	for _, kind := range kinds {
+		if allDefinedByCRDs([]missingKind{kind}, crds) {
+			explanations = append(
+				explanations,
+				fmt.Sprintf(
+					"kind %s in version %s is defined by a CRD in the applied manifests, but the CRD wasn't registered in the cluster when the custom resources were applied; re-running the apply once the CRD is registered should fix this",
+					kind.Kind,
+					kind.Version,
+				),
+			)
+		} else {
+			explanations = append(
+				explanations,
+				fmt.Sprintf(
+					"kind %s in version %s isn't registered in the cluster and its CRD isn't in the applied manifests; install the CRD before applying resources of this kind",
+					kind.Kind,
+					kind.Version,
+				),
+			)
+		}
+	}
+
+	return fmt.Errorf("%+v: %s", err, strings.Join(explanations, "; "))
+}
@@ -0,0 +1,83 @@
+package kube
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingKinds(t *testing.T) {
+	output := `deployment.apps/test unchanged
+error: unable to recognize "manifests/foo.yaml": no matches for kind "Foo" in version "example.com/v1"
+error: unable to recognize "manifests/foo2.yaml": no matches for kind "Foo" in version "example.com/v1"
+error: unable to recognize "manifests/bar.yaml": no matches for kind "Bar" in version "other.com/v1beta1"`
+
+	assert.Equal(
+		t,
+		[]missingKind{
+			{Kind: "Foo", Version: "example.com/v1"},
+			{Kind: "Bar", Version: "other.com/v1beta1"},
+		},
+		missingKinds(output),
+	)
+	assert.Equal(t, []missingKind{}, missingKinds("error: connection refused"))
+}
+
+func TestCRDKinds(t *testing.T) {
+	manifests := []Manifest{
+		{
+			Path: "crd.yaml",
+			Head: SimpleHeader{
+				Version: "apiextensions.k8s.io/v1",
+				Kind:    "CustomResourceDefinition",
+			},
+			Contents: `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+    plural: foos`,
+		},
+		{
+			Path: "deployment.yaml",
+			Head: SimpleHeader{
+				Version: "apps/v1",
+				Kind:    "Deployment",
+			},
+			Contents: "apiVersion: apps/v1\nkind: Deployment",
+		},
+	}
+
+	crds := crdKinds(manifests)
+	assert.Equal(t, map[string]struct{}{"example.com/Foo": {}}, crds)
+
+	assert.True(
+		t,
+		allDefinedByCRDs([]missingKind{{Kind: "Foo", Version: "example.com/v1"}}, crds),
+	)
+	assert.False(
+		t,
+		allDefinedByCRDs(
+			[]missingKind{
+				{Kind: "Foo", Version: "example.com/v1"},
+				{Kind: "Bar", Version: "other.com/v1"},
+			},
+			crds,
+		),
+	)
+
+	err := missingKindsError(
+		errors.New("exit status 1"),
+		[]missingKind{
+			{Kind: "Foo", Version: "example.com/v1"},
+			{Kind: "Bar", Version: "other.com/v1"},
+		},
+		crds,
+	)
+	assert.Contains(t, err.Error(), "kind Foo in version example.com/v1 is defined by a CRD")
+	assert.Contains(t, err.Error(), "kind Bar in version other.com/v1 isn't registered")
+}
@@ -0,0 +1,529 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/ghodss/yaml"
+	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var namespacesResource = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "namespaces",
+}
+
+// DynamicClient is a Client that does server-side applies and diffs via the kube API instead
+// of shelling out to kubectl. A single authenticated client is shared by all requests, which
+// avoids the overhead of starting kubectl and re-authenticating for each operation.
+//
+// Unlike OrderedClient, this client doesn't wait between apply waves. Summaries are still
+// generated via kubectl.
+type DynamicClient struct {
+	client       dynamic.Interface
+	mapper       meta.RESTMapper
+	namespace    string
+	keepConfigs  bool
+	fieldManager string
+
+	diffOptions diff.Options
+	filter      ManifestFilter
+	applyRecord *ApplyRecord
+
+	ownershipLabels    map[string]string
+	applyOrderPath     string
+	protectedResources []ResourceSelector
+
+	kubectlClient *OrderedClient
+}
+
+// NewDynamicClient returns a new DynamicClient instance for the cluster in the argument
+// kubeconfig. The argument OrderedClient is used for the operations that still require kubectl.
+func NewDynamicClient(
+	kubeConfigPath string,
+	keepConfigs bool,
+	fieldManager string,
+	diffOptions diff.Options,
+	filter ManifestFilter,
+	applyRecord *ApplyRecord,
+	ownershipLabels map[string]string,
+	applyOrderPath string,
+	protectedResources []ResourceSelector,
+	kubectlClient *OrderedClient,
+) (*DynamicClient, error) {
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeConfigPath},
+		&clientcmd.ConfigOverrides{},
+	)
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+
+	return &DynamicClient{
+		client: dynamicClient,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(
+			memory.NewMemCacheClient(discoveryClient),
+		),
+		namespace:       namespace,
+		keepConfigs:     keepConfigs,
+		fieldManager:    fieldManager,
+		diffOptions:     diffOptions,
+		filter:          filter,
+		applyRecord:     applyRecord,
+		ownershipLabels: ownershipLabels,
+		applyOrderPath:  applyOrderPath,
+		kubectlClient:   kubectlClient,
+
+		protectedResources: protectedResources,
+	}, nil
+}
+
+// Apply does a server-side apply of the manifests in the argument paths, in the same order as
+// OrderedClient. If output is true, then the results are returned instead of being logged; the
+// only supported formats are "json", which returns a list of the applied objects in the same
+// format as kubectl, and "", which returns one line per object.
+func (d *DynamicClient) Apply(
+	ctx context.Context,
+	applyPaths []string,
+	output bool,
+	format string,
+	dryRun bool,
+) ([]byte, error) {
+	if format != "" && format != "json" {
+		return nil, fmt.Errorf("Unsupported output format: %s", format)
+	}
+
+	manifests, err := applyManifests(
+		applyPaths,
+		d.filter,
+		d.applyRecord,
+		d.ownershipLabels,
+		d.applyOrderPath,
+		d.protectedResources,
+	)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := manifestsToObjects(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []interface{}{}
+	lines := []string{}
+
+	for _, obj := range objs {
+		resource, mapping, err := d.resourceFor(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		applied, err := d.applyObject(ctx, resource, obj, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("Error applying %s: %+v", objectName(mapping, obj), err)
+		}
+
+		line := fmt.Sprintf("%s serverside-applied", objectName(mapping, obj))
+		if dryRun {
+			line += " (server dry run)"
+		}
+
+		if output {
+			items = append(items, applied.Object)
+			lines = append(lines, line)
+		} else {
+			log.Infof("[client-go] %s", line)
+		}
+	}
+
+	if !output {
+		return nil, nil
+	} else if format == "json" {
+		return json.Marshal(
+			map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "List",
+				"items":      items,
+			},
+		)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// Diff diffs the manifests in the argument paths against the cluster by comparing the live
+// objects with the results of server-side dry-run applies. Diffs are always done server-side,
+// so the serverSide argument is ignored. If diffCommand is set, then it's run on the live and
+// merged object directories in the same way as kubectl's external differ; otherwise, the
+// diffs are generated in-process.
+func (d *DynamicClient) Diff(
+	ctx context.Context,
+	configPaths []string,
+	serverSide bool,
+	structured bool,
+	diffCommand string,
+	spinner *spinner.Spinner,
+) ([]byte, error) {
+	tempDir, err := util.TempDir("diff")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if d.keepConfigs {
+			log.Infof("Keeping temporary configs in %s", tempDir)
+		} else {
+			os.RemoveAll(tempDir)
+		}
+	}()
+
+	manifests, err := GetManifests(configPaths)
+	if err != nil {
+		return nil, err
+	}
+	manifests = FilterManifests(manifests, d.filter)
+	if len(manifests) == 0 && !d.filter.IsEmpty() {
+		return nil, errors.New("No manifests match the provided kind, name, and namespace filters")
+	}
+	if err := labelManifests(manifests, d.ownershipLabels); err != nil {
+		return nil, err
+	}
+	objs, err := manifestsToObjects(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	liveDir := filepath.Join(tempDir, "LIVE")
+	mergedDir := filepath.Join(tempDir, "MERGED")
+	for _, dir := range []string{liveDir, mergedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if spinner != nil {
+		spinner.Start()
+		defer spinner.Stop()
+	}
+
+	for _, obj := range objs {
+		resource, mapping, err := d.resourceFor(obj)
+		if err != nil {
+			return nil, err
+		}
+		name := objectName(mapping, obj)
+		fileName := diffFileName(mapping, obj)
+
+		live, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("Error getting %s: %+v", name, err)
+		} else if err == nil {
+			if err := writeObject(filepath.Join(liveDir, fileName), live); err != nil {
+				return nil, err
+			}
+		}
+
+		merged, err := d.applyObject(ctx, resource, obj, true)
+		if err != nil {
+			return nil, fmt.Errorf("Error running dry-run apply for %s: %+v", name, err)
+		}
+		if err := writeObject(filepath.Join(mergedDir, fileName), merged); err != nil {
+			return nil, err
+		}
+	}
+
+	return diffDirs(ctx, liveDir, mergedDir, structured, diffCommand, d.diffOptions)
+}
+
+// Summary returns a pretty summary of the current cluster state via kubectl.
+func (d *DynamicClient) Summary(ctx context.Context, mode SummaryMode) (string, error) {
+	return d.kubectlClient.Summary(ctx, mode)
+}
+
+// SummaryStructured returns a machine-readable summary of the current cluster state. Like
+// Summary, it's generated via kubectl.
+func (d *DynamicClient) SummaryStructured(ctx context.Context) (StructuredSummary, error) {
+	return d.kubectlClient.SummaryStructured(ctx)
+}
+
+// CreateNamespace creates the argument namespace via kubectl if it doesn't already exist.
+func (d *DynamicClient) CreateNamespace(ctx context.Context, namespace string) error {
+	return d.kubectlClient.CreateNamespace(ctx, namespace)
+}
+
+// Adopt applies the manifests in the argument paths via kubectl, taking ownership of any
+// resources that already exist.
+func (d *DynamicClient) Adopt(
+	ctx context.Context,
+	paths []string,
+	output bool,
+	format string,
+	dryRun bool,
+) ([]byte, error) {
+	return d.kubectlClient.Adopt(ctx, paths, output, format, dryRun)
+}
+
+// WaitForRollout waits for the rollouts of the workloads in the argument paths via kubectl.
+func (d *DynamicClient) WaitForRollout(
+	ctx context.Context,
+	paths []string,
+	timeout time.Duration,
+) error {
+	return d.kubectlClient.WaitForRollout(ctx, paths, timeout)
+}
+
+// NamespaceExists returns whether the argument namespace exists in the cluster.
+func (d *DynamicClient) NamespaceExists(ctx context.Context, namespace string) (bool, error) {
+	_, err := d.client.Resource(namespacesResource).Get(ctx, namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetNamespaceUID returns the kubernetes identifier for a given namespace in this cluster.
+func (d *DynamicClient) GetNamespaceUID(ctx context.Context, namespace string) (string, error) {
+	if namespace == "" {
+		return "", errors.New("expected a valid kubernetes namespace")
+	}
+
+	obj, err := d.client.Resource(namespacesResource).Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(obj.GetUID()), nil
+}
+
+// resourceFor returns the resource client and REST mapping for the argument object. If the
+// object is namespaced but doesn't have a namespace, then it's set to the default namespace
+// from the kubeconfig.
+func (d *DynamicClient) resourceFor(
+	obj *unstructured.Unstructured,
+) (dynamic.ResourceInterface, *meta.RESTMapping, error) {
+	gvk := obj.GroupVersionKind()
+
+	mapping, err := d.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// The kind might be from a CRD that was created after the mappings were cached
+		if resettable, ok := d.mapper.(interface{ Reset() }); ok {
+			resettable.Reset()
+			mapping, err = d.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting resource for kind %s: %+v", gvk.String(), err)
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return d.client.Resource(mapping.Resource), mapping, nil
+	}
+
+	if obj.GetNamespace() == "" {
+		namespace := d.namespace
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		obj.SetNamespace(namespace)
+	}
+	return d.client.Resource(mapping.Resource).Namespace(obj.GetNamespace()), mapping, nil
+}
+
+func (d *DynamicClient) applyObject(
+	ctx context.Context,
+	resource dynamic.ResourceInterface,
+	obj *unstructured.Unstructured,
+	dryRun bool,
+) (*unstructured.Unstructured, error) {
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	force := false
+	options := metav1.PatchOptions{
+		FieldManager: d.fieldManager,
+		Force:        &force,
+	}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+
+	return resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, options)
+}
+
+// manifestsToObjects converts the argument manifests to unstructured objects. Lists are
+// flattened into their items.
+func manifestsToObjects(manifests []Manifest) ([]*unstructured.Unstructured, error) {
+	objs := []*unstructured.Unstructured{}
+
+	for _, manifest := range manifests {
+		jsonBytes, err := yaml.YAMLToJSON([]byte(manifest.Contents))
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing manifest in %s: %+v", manifest.Path, err)
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(jsonBytes); err != nil {
+			return nil, fmt.Errorf("Error parsing manifest in %s: %+v", manifest.Path, err)
+		}
+
+		if !obj.IsList() {
+			objs = append(objs, obj)
+			continue
+		}
+
+		err = obj.EachListItem(
+			func(item runtime.Object) error {
+				itemObj, ok := item.(*unstructured.Unstructured)
+				if !ok {
+					return fmt.Errorf("Unexpected list item type in %s", manifest.Path)
+				}
+				objs = append(objs, itemObj)
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return objs, nil
+}
+
+// objectName returns a kubectl-style name for the argument object, e.g.
+// deployments.apps/my-app.
+func objectName(mapping *meta.RESTMapping, obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", mapping.Resource.GroupResource().String(), obj.GetName())
+}
+
+// diffFileName returns the name of the file that the argument object is written to for diffs.
+// This uses the same format as kubectl diff so that the results are the same with either
+// backend.
+func diffFileName(mapping *meta.RESTMapping, obj *unstructured.Unstructured) string {
+	gvk := mapping.GroupVersionKind
+
+	var group string
+	if gvk.Group != "" {
+		group = gvk.Group + "."
+	}
+
+	return fmt.Sprintf(
+		"%s%s.%s.%s.%s",
+		group,
+		gvk.Version,
+		gvk.Kind,
+		obj.GetNamespace(),
+		obj.GetName(),
+	)
+}
+
+func writeObject(path string, obj *unstructured.Unstructured) error {
+	contents, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, contents, 0644)
+}
+
+// diffDirs diffs the objects in the argument LIVE and MERGED directories, which are laid out
+// like the ones that kubectl passes to external diff commands. If diffCommand is set, then
+// it's used for the diff; otherwise, the diff is done in-process via diff.DiffKube. Raw diffs
+// from diffCommand are run on redacted copies of the objects since the command doesn't know
+// about the redact keys.
+func diffDirs(
+	ctx context.Context,
+	liveDir string,
+	mergedDir string,
+	structured bool,
+	diffCommand string,
+	diffOptions diff.Options,
+) ([]byte, error) {
+	if diffCommand != "" {
+		if !structured {
+			for _, dir := range []string{liveDir, mergedDir} {
+				if err := diff.RedactDir(dir, diffOptions.RedactKeys); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return runDiffCommand(ctx, diffCommand, structured, liveDir, mergedDir)
+	}
+
+	results, err := diff.DiffKube(liveDir, mergedDir, diffOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if structured {
+		return json.Marshal(results)
+	}
+
+	rawDiffs := []string{}
+	for _, result := range results.Results {
+		rawDiffs = append(rawDiffs, result.RawDiff)
+	}
+	return []byte(strings.Join(rawDiffs, "")), nil
+}
+
+// runDiffCommand runs the argument external diff command on the live and merged object
+// directories. For raw diffs, a non-zero exit code of 1 is treated as success since it just
+// indicates that there are differences.
+func runDiffCommand(
+	ctx context.Context,
+	diffCommand string,
+	structured bool,
+	liveDir string,
+	mergedDir string,
+) ([]byte, error) {
+	script := fmt.Sprintf("%s %s %s", diffCommand, liveDir, mergedDir)
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", script)
+	out, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if !structured && errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return out, nil
+	}
+	return out, err
+}
@@ -0,0 +1,170 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestManifestsToObjects(t *testing.T) {
+	manifests := []Manifest{
+		{
+			Path: "deployment.yaml",
+			Contents: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test-deployment
+  namespace: test-namespace`,
+		},
+		{
+			Path: "list.yaml",
+			Contents: `apiVersion: v1
+kind: ConfigMapList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: test-config1
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: test-config2`,
+		},
+	}
+
+	objs, err := manifestsToObjects(manifests)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, obj := range objs {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"Deployment/test-deployment",
+			"ConfigMap/test-config1",
+			"ConfigMap/test-config2",
+		},
+		names,
+	)
+
+	_, err = manifestsToObjects(
+		[]Manifest{
+			{
+				Path:     "bad.yaml",
+				Contents: "kind: [",
+			},
+		},
+	)
+	assert.Error(t, err)
+}
+
+func TestDynamicClientResourceFor(t *testing.T) {
+	deploymentGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	namespaceGVK := schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(deploymentGVK, meta.RESTScopeNamespace)
+	mapper.Add(namespaceGVK, meta.RESTScopeRoot)
+
+	client := &DynamicClient{
+		client:    dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		mapper:    mapper,
+		namespace: "test-namespace",
+	}
+
+	type testCase struct {
+		description  string
+		gvk          schema.GroupVersionKind
+		namespace    string
+		expNamespace string
+		expName      string
+		expFileName  string
+		expErr       bool
+	}
+
+	testCases := []testCase{
+		{
+			description:  "namespaced with default",
+			gvk:          deploymentGVK,
+			expNamespace: "test-namespace",
+			expName:      "deployments.apps/test-name",
+			expFileName:  "apps.v1.Deployment.test-namespace.test-name",
+		},
+		{
+			description:  "namespaced with explicit namespace",
+			gvk:          deploymentGVK,
+			namespace:    "other-namespace",
+			expNamespace: "other-namespace",
+			expName:      "deployments.apps/test-name",
+			expFileName:  "apps.v1.Deployment.other-namespace.test-name",
+		},
+		{
+			description:  "cluster-scoped",
+			gvk:          namespaceGVK,
+			expNamespace: "",
+			expName:      "namespaces/test-name",
+			expFileName:  "v1.Namespace..test-name",
+		},
+		{
+			description: "unknown kind",
+			gvk:         schema.GroupVersionKind{Group: "test", Version: "v1", Kind: "Unknown"},
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(testCase.gvk)
+		obj.SetName("test-name")
+		obj.SetNamespace(testCase.namespace)
+
+		_, mapping, err := client.resourceFor(obj)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+			continue
+		}
+		require.NoError(t, err, testCase.description)
+		assert.Equal(t, testCase.expNamespace, obj.GetNamespace(), testCase.description)
+		assert.Equal(t, testCase.expName, objectName(mapping, obj), testCase.description)
+		assert.Equal(t, testCase.expFileName, diffFileName(mapping, obj), testCase.description)
+	}
+}
+
+func TestDynamicClientGetNamespaceUID(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	client := &DynamicClient{
+		client: dynamicfake.NewSimpleDynamicClient(
+			scheme,
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-namespace",
+					UID:  types.UID("test-uid"),
+				},
+			},
+		),
+	}
+
+	uid, err := client.GetNamespaceUID(context.Background(), "test-namespace")
+	require.NoError(t, err)
+	assert.Equal(t, "test-uid", uid)
+
+	_, err = client.GetNamespaceUID(context.Background(), "missing-namespace")
+	assert.Error(t, err)
+
+	_, err = client.GetNamespaceUID(context.Background(), "")
+	assert.Error(t, err)
+}
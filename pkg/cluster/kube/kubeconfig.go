@@ -3,14 +3,22 @@ package kube
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 	"text/template"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/ghodss/yaml"
+	"github.com/segmentio/kubeapply/pkg/util"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
 )
 
 const (
@@ -47,6 +55,8 @@ var (
 	kubeconfigTemplate = template.Must(
 		template.New("kubeconfig").Parse(kubeconfigTemplateStr),
 	)
+
+	defaultKubeconfigCache = newKubeconfigCache(describeEKSCluster)
 )
 
 // KubeconfigTemplateData stores the data necessary to generate a kubeconfig.
@@ -92,6 +102,38 @@ func CreateKubeconfigViaAPI(
 	region string,
 	path string,
 ) error {
+	cluster, err := describeEKSCluster(ctx, sess, clusterName)
+	if err != nil {
+		return err
+	}
+
+	return CreateKubeconfigFromClusterData(
+		clusterName,
+		aws.StringValue(cluster.Endpoint),
+		aws.StringValue(cluster.CertificateAuthority.Data),
+		region,
+		path,
+	)
+}
+
+// CachedKubeconfigViaAPI returns the path to a kubeconfig for the argument cluster that's
+// generated by hitting the EKS API, along with a function that releases it. Kubeconfigs are
+// shared by all of the callers that hold them, so the API is only hit once per cluster and
+// region; each kubeconfig is removed after all of its holders have released it.
+func CachedKubeconfigViaAPI(
+	ctx context.Context,
+	sess *session.Session,
+	clusterName string,
+	region string,
+) (string, func(), error) {
+	return defaultKubeconfigCache.get(ctx, sess, clusterName, region)
+}
+
+func describeEKSCluster(
+	ctx context.Context,
+	sess *session.Session,
+	clusterName string,
+) (*eks.Cluster, error) {
 	eksClient := eks.New(sess)
 	resp, err := eksClient.DescribeClusterWithContext(
 		ctx,
@@ -100,17 +142,138 @@ func CreateKubeconfigViaAPI(
 		},
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	cluster := resp.Cluster
+	return resp.Cluster, nil
+}
 
-	return CreateKubeconfigFromClusterData(
+type describeClusterFunc func(
+	ctx context.Context,
+	sess *session.Session,
+	clusterName string,
+) (*eks.Cluster, error)
+
+// kubeconfigCache caches the kubeconfigs generated from the EKS API by cluster and region.
+type kubeconfigCache struct {
+	sync.Mutex
+
+	describeCluster describeClusterFunc
+	dir             string
+	entries         map[string]*kubeconfigCacheEntry
+}
+
+type kubeconfigCacheEntry struct {
+	path string
+	refs int
+}
+
+func newKubeconfigCache(describeCluster describeClusterFunc) *kubeconfigCache {
+	return &kubeconfigCache{
+		describeCluster: describeCluster,
+		entries:         map[string]*kubeconfigCacheEntry{},
+	}
+}
+
+func (c *kubeconfigCache) get(
+	ctx context.Context,
+	sess *session.Session,
+	clusterName string,
+	region string,
+) (string, func(), error) {
+	c.Lock()
+	defer c.Unlock()
+
+	key := fmt.Sprintf("%s__%s", clusterName, region)
+
+	entry, ok := c.entries[key]
+	if ok {
+		// Regenerate the kubeconfig if something else has cleaned it up
+		if _, err := os.Stat(entry.path); err == nil {
+			log.Debugf("Using cached kubeconfig for cluster %s in %s", clusterName, entry.path)
+			entry.refs++
+			return entry.path, c.releaseFunc(key, entry), nil
+		}
+	}
+
+	if c.dir == "" {
+		dir, err := util.TempDir("kubeconfigs")
+		if err != nil {
+			return "", nil, err
+		}
+		c.dir = dir
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", nil, err
+	}
+
+	cluster, err := c.describeCluster(ctx, sess, clusterName)
+	if err != nil {
+		c.removeDirIfUnused()
+		return "", nil, err
+	}
+
+	path := filepath.Join(
+		c.dir,
+		fmt.Sprintf("kubeconfig_%s_%s.yaml", clusterName, region),
+	)
+	err = CreateKubeconfigFromClusterData(
 		clusterName,
 		aws.StringValue(cluster.Endpoint),
 		aws.StringValue(cluster.CertificateAuthority.Data),
 		region,
 		path,
 	)
+	if err != nil {
+		c.removeDirIfUnused()
+		return "", nil, err
+	}
+
+	// Holders of a kubeconfig that was cleaned up keep their references to the regenerated
+	// one at the same path.
+	if !ok {
+		entry = &kubeconfigCacheEntry{path: path}
+		c.entries[key] = entry
+	}
+	entry.refs++
+
+	return path, c.releaseFunc(key, entry), nil
+}
+
+// releaseFunc returns a function that drops a reference to the argument entry. The
+// kubeconfig is removed after its last reference is dropped, and the cache directory is
+// removed after all of its kubeconfigs are.
+func (c *kubeconfigCache) releaseFunc(key string, entry *kubeconfigCacheEntry) func() {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			c.Lock()
+			defer c.Unlock()
+
+			entry.refs--
+			if entry.refs > 0 {
+				return
+			}
+
+			log.Debugf("Removing cached kubeconfig %s", entry.path)
+			if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+				log.Warnf("Error removing cached kubeconfig %s: %+v", entry.path, err)
+			}
+			delete(c.entries, key)
+			c.removeDirIfUnused()
+		})
+	}
+}
+
+func (c *kubeconfigCache) removeDirIfUnused() {
+	if len(c.entries) > 0 || c.dir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(c.dir); err != nil {
+		log.Warnf("Error removing kubeconfig cache directory %s: %+v", c.dir, err)
+	}
+	c.dir = ""
 }
 
 // KubeconfigMatchesCluster determines (roughly) whether a kubeconfig matches the provided
@@ -124,3 +287,64 @@ func KubeconfigMatchesCluster(path string, clusterName string) bool {
 
 	return bytes.Contains(contents, []byte(clusterName))
 }
+
+// MergeKubeconfigs merges the kubeconfigs in the argument path list, which uses the same
+// format as the KUBECONFIG env variable (i.e., paths separated by colons on unix), into a
+// single temporary file using the kubectl merge rules. It returns the path to the merged file
+// along with a function that cleans it up. If the list only has a single path, then that path
+// is returned as-is.
+func MergeKubeconfigs(pathList string) (string, func(), error) {
+	paths := []string{}
+	for _, path := range filepath.SplitList(pathList) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return "", nil, fmt.Errorf("No kubeconfig paths in %s", pathList)
+	} else if len(paths) == 1 {
+		return paths[0], func() {}, nil
+	}
+
+	loadingRules := &clientcmd.ClientConfigLoadingRules{
+		Precedence: paths,
+	}
+	mergedConfig, err := loadingRules.Load()
+	if err != nil {
+		return "", nil, fmt.Errorf("Error merging kubeconfigs %+v: %+v", paths, err)
+	}
+
+	// Convert to the versioned config and marshal it ourselves instead of using
+	// clientcmd.WriteToFile; the json-iterator version used by the latter's serializer
+	// panics in newer Go versions.
+	v1Config := &clientcmdv1.Config{}
+	if err := clientcmdlatest.Scheme.Convert(mergedConfig, v1Config, nil); err != nil {
+		return "", nil, err
+	}
+	v1Config.APIVersion = "v1"
+	v1Config.Kind = "Config"
+
+	contents, err := yaml.Marshal(v1Config)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tempFile, err := util.TempFile("kubeconfig")
+	if err != nil {
+		return "", nil, err
+	}
+	tempFile.Close()
+
+	cleanup := func() {
+		os.Remove(tempFile.Name())
+	}
+
+	if err := ioutil.WriteFile(tempFile.Name(), contents, 0600); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	log.Debugf("Merged kubeconfigs %+v into %s", paths, tempFile.Name())
+
+	return tempFile.Name(), cleanup, nil
+}
@@ -0,0 +1,156 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	testBaseKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://test-cluster.example.com
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    user: test-user
+  name: test-cluster
+current-context: test-cluster
+`
+
+	testCredsKubeconfig = `
+apiVersion: v1
+kind: Config
+users:
+- name: test-user
+  user:
+    token: test-token
+`
+)
+
+func TestMergeKubeconfigs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "kubeconfigs")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"base.yaml":  testBaseKubeconfig,
+			"creds.yaml": testCredsKubeconfig,
+		},
+	)
+	basePath := filepath.Join(tempDir, "base.yaml")
+	credsPath := filepath.Join(tempDir, "creds.yaml")
+
+	path, cleanup, err := MergeKubeconfigs(basePath)
+	require.NoError(t, err)
+	cleanup()
+	assert.Equal(t, basePath, path)
+
+	path, cleanup, err = MergeKubeconfigs(
+		strings.Join([]string{basePath, credsPath}, string(os.PathListSeparator)),
+	)
+	require.NoError(t, err)
+
+	mergedConfig, err := clientcmd.LoadFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "test-cluster", mergedConfig.CurrentContext)
+	require.Contains(t, mergedConfig.AuthInfos, "test-user")
+	assert.Equal(t, "test-token", mergedConfig.AuthInfos["test-user"].Token)
+	assert.True(t, KubeconfigMatchesCluster(path, "test-cluster"))
+
+	cleanup()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	_, _, err = MergeKubeconfigs("")
+	assert.Error(t, err)
+}
+
+func TestKubeconfigCache(t *testing.T) {
+	ctx := context.Background()
+	describeCalls := map[string]int{}
+
+	cache := newKubeconfigCache(
+		func(
+			ctx context.Context,
+			sess *session.Session,
+			clusterName string,
+		) (*eks.Cluster, error) {
+			describeCalls[clusterName]++
+			return &eks.Cluster{
+				Endpoint: aws.String(fmt.Sprintf("https://%s.example.com", clusterName)),
+				CertificateAuthority: &eks.Certificate{
+					Data: aws.String("test-ca-data"),
+				},
+			}, nil
+		},
+	)
+	path1, release1, err := cache.get(ctx, nil, "test-cluster1", "us-west-2")
+	require.NoError(t, err)
+	defer os.RemoveAll(cache.dir)
+	path1Again, release1Again, err := cache.get(ctx, nil, "test-cluster1", "us-west-2")
+	require.NoError(t, err)
+	path2, release2, err := cache.get(ctx, nil, "test-cluster2", "us-west-2")
+	require.NoError(t, err)
+
+	assert.Equal(t, path1, path1Again)
+	assert.NotEqual(t, path1, path2)
+	assert.Equal(t, map[string]int{"test-cluster1": 1, "test-cluster2": 1}, describeCalls)
+	assert.True(t, KubeconfigMatchesCluster(path1, "test-cluster1"))
+	assert.True(t, KubeconfigMatchesCluster(path2, "test-cluster2"))
+
+	// Kubeconfigs that have been removed are regenerated
+	require.NoError(t, os.Remove(path1))
+	path1Again, release1Regenerated, err := cache.get(ctx, nil, "test-cluster1", "us-west-2")
+	require.NoError(t, err)
+	assert.Equal(t, path1, path1Again)
+	assert.Equal(t, 2, describeCalls["test-cluster1"])
+	assert.True(t, KubeconfigMatchesCluster(path1, "test-cluster1"))
+
+	// Kubeconfigs are only removed after all of their holders release them
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	dir := cache.dir
+	release1()
+	release1()
+	release1Again()
+	assert.True(t, exists(path1))
+	release1Regenerated()
+	assert.False(t, exists(path1))
+	assert.True(t, exists(path2))
+	assert.True(t, exists(dir))
+
+	// The cache directory is removed along with the last kubeconfig
+	release2()
+	assert.False(t, exists(path2))
+	assert.False(t, exists(dir))
+
+	// Released kubeconfigs are regenerated in a new directory
+	path1Again, release1, err = cache.get(ctx, nil, "test-cluster1", "us-west-2")
+	require.NoError(t, err)
+	assert.Equal(t, 3, describeCalls["test-cluster1"])
+	assert.True(t, KubeconfigMatchesCluster(path1Again, "test-cluster1"))
+	release1()
+	assert.False(t, exists(path1Again))
+}
@@ -3,8 +3,10 @@ package kube
 import (
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/ghodss/yaml"
@@ -50,6 +52,10 @@ var KindOrder []string = []string{
 	"APIService",
 }
 
+// WaveAnnotation is the annotation used to assign a resource to an apply wave. Waves are
+// applied in increasing order; resources without the annotation are in wave 0.
+const WaveAnnotation = "kubeapply.segment.io/wave"
+
 // KindithoutMetadata lists out Kubernetes manifest types that don't have metadata.
 var KindithoutMetadata []string = []string{
 	"ConfigMapList",
@@ -88,7 +94,9 @@ func GetManifests(paths []string) ([]Manifest, error) {
 					return err
 				}
 
-				if info.IsDir() || !strings.HasSuffix(subPath, ".yaml") {
+				if info.IsDir() ||
+					!strings.HasSuffix(subPath, ".yaml") ||
+					filepath.Base(subPath) == ApplyOrderFile {
 					return nil
 				}
 
@@ -143,6 +151,92 @@ func GetManifests(paths []string) ([]Manifest, error) {
 	return results, nil
 }
 
+// ManifestFilter is used to restrict operations to a subset of manifests.
+type ManifestFilter struct {
+	// Kinds is a list of kinds to match (case-insensitive). If empty, all kinds match.
+	Kinds []string
+
+	// Names is a list of names to match. Globs are allowed. If empty, all names match.
+	Names []string
+
+	// ExcludeNamespaces is a list of namespaces whose resources should not match. Resources
+	// without an explicit namespace, including cluster-scoped ones, are never excluded.
+	ExcludeNamespaces []string
+}
+
+// IsEmpty returns whether this filter has no conditions set (and thus matches everything).
+func (f ManifestFilter) IsEmpty() bool {
+	return len(f.Kinds) == 0 && len(f.Names) == 0 && len(f.ExcludeNamespaces) == 0
+}
+
+// Matches returns whether the argument manifest matches this filter.
+func (f ManifestFilter) Matches(manifest Manifest) bool {
+	if len(f.Kinds) > 0 {
+		var kindMatches bool
+
+		for _, kind := range f.Kinds {
+			if strings.EqualFold(kind, manifest.Head.Kind) {
+				kindMatches = true
+				break
+			}
+		}
+
+		if !kindMatches {
+			return false
+		}
+	}
+
+	if len(f.Names) > 0 {
+		if manifest.Head.Metadata == nil {
+			return false
+		}
+
+		var nameMatches bool
+
+		for _, name := range f.Names {
+			matches, err := path.Match(name, manifest.Head.Metadata.Name)
+			if err != nil {
+				log.Warnf("Invalid name filter %s: %+v", name, err)
+				continue
+			}
+			if matches {
+				nameMatches = true
+				break
+			}
+		}
+
+		if !nameMatches {
+			return false
+		}
+	}
+
+	if len(f.ExcludeNamespaces) > 0 &&
+		manifest.Head.Metadata != nil &&
+		manifest.Head.Metadata.Namespace != "" &&
+		contains(f.ExcludeNamespaces, manifest.Head.Metadata.Namespace) {
+		return false
+	}
+
+	return true
+}
+
+// FilterManifests returns the subset of the argument manifests that match the argument filter.
+func FilterManifests(manifests []Manifest, filter ManifestFilter) []Manifest {
+	if filter.IsEmpty() {
+		return manifests
+	}
+
+	filtered := []Manifest{}
+
+	for _, manifest := range manifests {
+		if filter.Matches(manifest) {
+			filtered = append(filtered, manifest)
+		}
+	}
+
+	return filtered
+}
+
 func contains(list []string, str string) bool {
 	for _, v := range list {
 		if str == v {
@@ -164,8 +258,8 @@ func isEmpty(contents string) bool {
 	return true
 }
 
-// SortManifests sorts the provided manifest slice using the KindOrder above.
-// Ties within the same type are broken by (namespace, name).
+// SortManifests sorts the provided manifest slice by apply wave (see WaveAnnotation) and then
+// using the KindOrder above. Ties within the same type are broken by (namespace, name).
 func SortManifests(manifests []Manifest) {
 	orderMap := map[string]int{}
 
@@ -179,6 +273,12 @@ func SortManifests(manifests []Manifest) {
 			manifest1 := manifests[i]
 			manifest2 := manifests[j]
 
+			wave1 := ManifestWave(manifest1)
+			wave2 := ManifestWave(manifest2)
+			if wave1 != wave2 {
+				return wave1 < wave2
+			}
+
 			var kindOrder1, kindOrder2 int
 			var namespace1, namespace2 string
 			var name1, name2 string
@@ -225,3 +325,42 @@ func SortManifests(manifests []Manifest) {
 		},
 	)
 }
+
+// ManifestWave returns the apply wave of the argument manifest based on its WaveAnnotation.
+// Manifests without a valid annotation are in wave 0.
+func ManifestWave(manifest Manifest) int {
+	if manifest.Head.Metadata == nil {
+		return 0
+	}
+	waveStr, ok := manifest.Head.Metadata.Annotations[WaveAnnotation]
+	if !ok {
+		return 0
+	}
+
+	wave, err := strconv.Atoi(strings.TrimSpace(waveStr))
+	if err != nil {
+		log.Warnf(
+			"Ignoring invalid wave annotation %s in %s: %+v",
+			waveStr,
+			manifest.Path,
+			err,
+		)
+		return 0
+	}
+	return wave
+}
+
+// GroupManifestsByWave splits the argument manifests, which should already be sorted via
+// SortManifests, into batches that share the same apply wave.
+func GroupManifestsByWave(manifests []Manifest) [][]Manifest {
+	waves := [][]Manifest{}
+
+	for m, manifest := range manifests {
+		if m == 0 || ManifestWave(manifest) != ManifestWave(manifests[m-1]) {
+			waves = append(waves, []Manifest{})
+		}
+		waves[len(waves)-1] = append(waves[len(waves)-1], manifest)
+	}
+
+	return waves
+}
@@ -111,3 +111,176 @@ func TestGetManifests(t *testing.T) {
 
 	assert.Equal(t, strings.TrimSpace(testManifest2), manifests[0].Contents)
 }
+
+func TestFilterManifests(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "data")
+	if err != nil {
+		assert.FailNow(t, "Cannot create tempDir: %+v", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	util.WriteFiles(
+		t,
+		outDir,
+		map[string]string{
+			"manifest1.yaml":     testManifest1,
+			"dir/manifest2.yaml": testManifest2,
+		},
+	)
+
+	manifests, err := GetManifests([]string{outDir})
+	assert.Nil(t, err)
+	SortManifests(manifests)
+
+	type testCase struct {
+		filter   ManifestFilter
+		expNames []string
+	}
+
+	testCases := []testCase{
+		{
+			filter: ManifestFilter{},
+			expNames: []string{
+				"fluent-bit-config",
+				"fluentbit",
+				"pod-log-reader",
+				"pod-log-crb",
+			},
+		},
+		{
+			filter: ManifestFilter{
+				Kinds: []string{"clusterrole", "ConfigMap"},
+			},
+			expNames: []string{
+				"fluent-bit-config",
+				"pod-log-reader",
+			},
+		},
+		{
+			filter: ManifestFilter{
+				Names: []string{"pod-log-*"},
+			},
+			expNames: []string{
+				"pod-log-reader",
+				"pod-log-crb",
+			},
+		},
+		{
+			filter: ManifestFilter{
+				Kinds: []string{"ClusterRoleBinding"},
+				Names: []string{"pod-log-*"},
+			},
+			expNames: []string{
+				"pod-log-crb",
+			},
+		},
+		{
+			filter: ManifestFilter{
+				Kinds: []string{"Deployment"},
+			},
+			expNames: []string{},
+		},
+		{
+			filter: ManifestFilter{
+				ExcludeNamespaces: []string{"monitoring"},
+			},
+			expNames: []string{
+				"pod-log-reader",
+				"pod-log-crb",
+			},
+		},
+		{
+			filter: ManifestFilter{
+				Kinds:             []string{"ConfigMap", "ClusterRole"},
+				ExcludeNamespaces: []string{"other"},
+			},
+			expNames: []string{
+				"fluent-bit-config",
+				"pod-log-reader",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		names := []string{}
+
+		for _, manifest := range FilterManifests(manifests, testCase.filter) {
+			names = append(names, manifest.Head.Metadata.Name)
+		}
+		assert.Equal(t, testCase.expNames, names)
+	}
+}
+
+func TestSortManifestsWaves(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "data")
+	if err != nil {
+		assert.FailNow(t, "Cannot create tempDir: %+v", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	util.WriteFiles(
+		t,
+		outDir,
+		map[string]string{
+			"manifests.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: late-config
+  namespace: apps
+  annotations:
+    kubeapply.segment.io/wave: "2"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: database
+  namespace: apps
+  annotations:
+    kubeapply.segment.io/wave: "-1"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: apps
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: apps
+  annotations:
+    kubeapply.segment.io/wave: "not-a-number"
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: apps
+`,
+		},
+	)
+
+	manifests, err := GetManifests([]string{outDir})
+	assert.Nil(t, err)
+	SortManifests(manifests)
+
+	waveNames := [][]string{}
+	for _, wave := range GroupManifestsByWave(manifests) {
+		names := []string{}
+		for _, manifest := range wave {
+			names = append(names, manifest.Head.Metadata.Name)
+		}
+		waveNames = append(waveNames, names)
+	}
+
+	assert.Equal(
+		t,
+		[][]string{
+			{"database"},
+			{"apps", "app-config", "app"},
+			{"late-config"},
+		},
+		waveNames,
+	)
+}
@@ -0,0 +1,90 @@
+package kube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AddCommonMetadata adds the argument labels and annotations to the metadata of every resource
+// in the YAML files under the argument root. Values already set on a resource take precedence
+// over the common ones. The resources that change are parsed and re-emitted, which drops their
+// comments; files without any changes are left as-is. Files that can't be parsed cause an error.
+func AddCommonMetadata(
+	root string,
+	labels map[string]string,
+	annotations map[string]string,
+) error {
+	if len(labels) == 0 && len(annotations) == 0 {
+		return nil
+	}
+
+	return filepath.Walk(
+		root,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() || !strings.HasSuffix(path, ".yaml") {
+				return nil
+			}
+
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			updatedContents, updated, err := addCommonMetadata(
+				string(contents),
+				labels,
+				annotations,
+			)
+			if err != nil {
+				return fmt.Errorf("Could not add common metadata to %s: %+v", path, err)
+			} else if !updated {
+				return nil
+			}
+
+			return ioutil.WriteFile(path, []byte(updatedContents), info.Mode().Perm())
+		},
+	)
+}
+
+// addCommonMetadata adds the argument labels and annotations to each of the resources in the
+// argument YAML contents. It returns whether any resources were updated; if none were, then
+// the contents should be left as-is.
+func addCommonMetadata(
+	contents string,
+	labels map[string]string,
+	annotations map[string]string,
+) (string, bool, error) {
+	manifestStrs := sep.Split(strings.TrimSpace(contents), -1)
+	results := []string{}
+	updated := false
+
+	for _, manifestStr := range manifestStrs {
+		manifestStr = strings.TrimSpace(manifestStr)
+		if isEmpty(manifestStr) {
+			continue
+		}
+
+		result, manifestUpdated, err := updateMetadata(
+			manifestStr+"\n",
+			map[string]map[string]string{
+				"labels":      labels,
+				"annotations": annotations,
+			},
+			false,
+		)
+		if err != nil {
+			return "", false, err
+		}
+		results = append(results, result)
+		updated = updated || manifestUpdated
+	}
+
+	return strings.Join(results, "---\n"), updated, nil
+}
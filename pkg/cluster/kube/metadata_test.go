@@ -0,0 +1,157 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddCommonMetadata(t *testing.T) {
+	type testCase struct {
+		description string
+		contents    string
+		expUpdated  bool
+		expContents string
+	}
+
+	testCases := []testCase{
+		{
+			description: "multiple resources",
+			contents: `# A comment
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config1
+  labels:
+    team: other-team
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: service1
+  annotations:
+    key: value
+`,
+			expUpdated: true,
+			expContents: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    cost-center: "123"
+  labels:
+    managed-by: kubeapply
+    team: other-team
+  name: config1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  annotations:
+    cost-center: "123"
+    key: value
+  labels:
+    managed-by: kubeapply
+    team: team1
+  name: service1
+`,
+		},
+		{
+			description: "no resources",
+			contents:    "# Just a comment\n",
+			expUpdated:  false,
+		},
+		{
+			description: "list without metadata",
+			contents:    "apiVersion: v1\nkind: ConfigMapList\nitems: []\n",
+			expUpdated:  false,
+		},
+		{
+			description: "non-resource list",
+			contents:    "- Secret/jobs/db-password\n- Job/jobs/migrate-*\n",
+			expUpdated:  false,
+		},
+		{
+			description: "unchanged resources keep their comments",
+			contents: `# Source: chart1/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config1
+  labels:
+    managed-by: other
+    team: other-team
+  annotations:
+    cost-center: "456"
+---
+# Source: chart1/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: service1
+`,
+			expUpdated: true,
+			expContents: `# Source: chart1/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config1
+  labels:
+    managed-by: other
+    team: other-team
+  annotations:
+    cost-center: "456"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  annotations:
+    cost-center: "123"
+  labels:
+    managed-by: kubeapply
+    team: team1
+  name: service1
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		contents, updated, err := addCommonMetadata(
+			testCase.contents,
+			map[string]string{
+				"managed-by": "kubeapply",
+				"team":       "team1",
+			},
+			map[string]string{
+				"cost-center": "123",
+			},
+		)
+		require.NoError(t, err, testCase.description)
+		assert.Equal(t, testCase.expUpdated, updated, testCase.description)
+		if testCase.expUpdated {
+			assert.Equal(t, testCase.expContents, contents, testCase.description)
+		}
+	}
+}
+
+func TestAddCommonMetadataBadYAML(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "metadata")
+	require.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	util.WriteFiles(
+		t,
+		outDir,
+		map[string]string{
+			"good.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns1\n",
+			"bad.yaml":  "apiVersion: v1\nkind: Namespace\nmetadata: [\n",
+		},
+	)
+
+	err = AddCommonMetadata(outDir, map[string]string{"team": "team1"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad.yaml")
+}
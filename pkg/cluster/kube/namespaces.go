@@ -0,0 +1,200 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Number of attempts and base backoff for namespace creation in the case of conflicts
+	namespaceCreateAttempts = 5
+	namespaceCreateBackoff  = time.Second
+)
+
+// CreateNamespace creates the argument namespace if it doesn't already exist. This is safe to
+// run concurrently from multiple processes; AlreadyExists errors are treated as success and
+// conflicts are retried.
+func (k *OrderedClient) CreateNamespace(ctx context.Context, namespace string) error {
+	if namespace == "" {
+		return errors.New("expected a valid kubernetes namespace")
+	}
+
+	args := []string{
+		"--kubeconfig",
+		k.kubeConfigPath,
+		"create",
+		"namespace",
+		namespace,
+	}
+
+	return createNamespaceWithRetries(
+		ctx,
+		namespace,
+		namespaceCreateBackoff,
+		func() (string, error) {
+			out, err := k.kubectlOutput(ctx, args, k.extraEnv, nil)
+			return string(out), err
+		},
+	)
+}
+
+// NamespaceExists returns whether the argument namespace exists in the cluster.
+func (k *OrderedClient) NamespaceExists(ctx context.Context, namespace string) (bool, error) {
+	args := []string{
+		"--kubeconfig",
+		k.kubeConfigPath,
+		"get",
+		"namespace",
+		namespace,
+		"-o",
+		"name",
+	}
+
+	out, err := k.kubectlOutput(ctx, args, k.extraEnv, nil)
+	if err == nil {
+		return true, nil
+	} else if isNotFoundError(string(out)) {
+		return false, nil
+	}
+	return false, fmt.Errorf("Error getting namespace %s: %s", namespace, out)
+}
+
+// DiffNewManifests diffs the argument manifests as if none of them exist in the cluster yet,
+// e.g. because their namespaces haven't been created. Nothing is sent to the cluster, and the
+// result is in the same format as Client.Diff.
+func DiffNewManifests(
+	ctx context.Context,
+	manifests []Manifest,
+	structured bool,
+	diffCommand string,
+	diffOptions diff.Options,
+) ([]byte, error) {
+	tempDir, err := util.TempDir("diff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	liveDir := filepath.Join(tempDir, "LIVE")
+	mergedDir := filepath.Join(tempDir, "MERGED")
+	for _, dir := range []string{liveDir, mergedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, manifest := range manifests {
+		err := ioutil.WriteFile(
+			filepath.Join(mergedDir, manifestDiffFileName(manifest)),
+			[]byte(manifest.Contents),
+			0644,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return diffDirs(ctx, liveDir, mergedDir, structured, diffCommand, diffOptions)
+}
+
+// ManifestNamespaces returns the sorted, unique namespaces of the argument manifests.
+// Cluster-scoped manifests and ones without an explicit namespace are skipped.
+func ManifestNamespaces(manifests []Manifest) []string {
+	namespacesMap := map[string]struct{}{}
+
+	for _, manifest := range manifests {
+		if manifest.Head.Metadata != nil && manifest.Head.Metadata.Namespace != "" {
+			namespacesMap[manifest.Head.Metadata.Namespace] = struct{}{}
+		}
+	}
+
+	namespaces := []string{}
+	for namespace := range namespacesMap {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// createNamespaceWithRetries runs the argument create function, which returns the output of
+// a "kubectl create namespace" call, until it succeeds, the namespace is found to already
+// exist, or it fails with an error other than a conflict.
+func createNamespaceWithRetries(
+	ctx context.Context,
+	namespace string,
+	backoff time.Duration,
+	create func() (string, error),
+) error {
+	var err error
+
+	for attempt := 1; attempt <= namespaceCreateAttempts; attempt++ {
+		var out string
+
+		out, err = create()
+		if err == nil {
+			log.Infof("Created namespace %s", namespace)
+			return nil
+		}
+
+		if isAlreadyExistsError(out) {
+			log.Infof("Namespace %s already exists", namespace)
+			return nil
+		} else if !isConflictError(out) {
+			return fmt.Errorf("Error creating namespace %s: %s", namespace, out)
+		}
+
+		err = fmt.Errorf("Conflict creating namespace %s: %s", namespace, out)
+		log.Warnf("%+v (attempt %d/%d)", err, attempt, namespaceCreateAttempts)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * backoff):
+		}
+	}
+
+	return err
+}
+
+func isAlreadyExistsError(output string) bool {
+	return strings.Contains(output, "(AlreadyExists)")
+}
+
+func isNotFoundError(output string) bool {
+	return strings.Contains(output, "(NotFound)")
+}
+
+func isConflictError(output string) bool {
+	return strings.Contains(output, "(Conflict)")
+}
+
+// manifestDiffFileName returns the name of the file that the argument manifest is written to
+// for diffs, in the same format as diffFileName.
+func manifestDiffFileName(manifest Manifest) string {
+	var name, namespace string
+
+	if manifest.Head.Metadata != nil {
+		name = manifest.Head.Metadata.Name
+		namespace = manifest.Head.Metadata.Namespace
+	}
+
+	return fmt.Sprintf(
+		"%s.%s.%s.%s",
+		strings.Replace(manifest.Head.Version, "/", ".", 1),
+		manifest.Head.Kind,
+		namespace,
+		name,
+	)
+}
@@ -0,0 +1,120 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateNamespaceWithRetries(t *testing.T) {
+	type testCase struct {
+		description string
+		outputs     []string
+		expCalls    int
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "created",
+			outputs:     []string{""},
+			expCalls:    1,
+		},
+		{
+			description: "already exists",
+			outputs: []string{
+				`Error from server (AlreadyExists): namespaces "test" already exists`,
+			},
+			expCalls: 1,
+		},
+		{
+			description: "conflict then success",
+			outputs: []string{
+				"Error from server (Conflict): Operation cannot be fulfilled",
+				"Error from server (Conflict): Operation cannot be fulfilled",
+				"",
+			},
+			expCalls: 3,
+		},
+		{
+			description: "conflict then already exists",
+			outputs: []string{
+				"Error from server (Conflict): Operation cannot be fulfilled",
+				`Error from server (AlreadyExists): namespaces "test" already exists`,
+			},
+			expCalls: 2,
+		},
+		{
+			description: "conflicts on all attempts",
+			outputs: []string{
+				"Error from server (Conflict): Operation cannot be fulfilled",
+				"Error from server (Conflict): Operation cannot be fulfilled",
+				"Error from server (Conflict): Operation cannot be fulfilled",
+				"Error from server (Conflict): Operation cannot be fulfilled",
+				"Error from server (Conflict): Operation cannot be fulfilled",
+			},
+			expCalls: namespaceCreateAttempts,
+			expErr:   true,
+		},
+		{
+			description: "other error",
+			outputs: []string{
+				"Error from server (Forbidden): namespaces is forbidden",
+			},
+			expCalls: 1,
+			expErr:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		calls := 0
+
+		err := createNamespaceWithRetries(
+			context.Background(),
+			"test",
+			time.Millisecond,
+			func() (string, error) {
+				output := testCase.outputs[calls]
+				calls++
+
+				if output == "" {
+					return "", nil
+				}
+				return output, errors.New("exit status 1")
+			},
+		)
+		assert.Equal(t, testCase.expCalls, calls, testCase.description)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			assert.NoError(t, err, testCase.description)
+		}
+	}
+}
+
+func TestManifestNamespaces(t *testing.T) {
+	manifests := []Manifest{}
+
+	for _, contents := range []string{
+		"kind: ClusterRole\nmetadata:\n  name: test",
+		"kind: Deployment\nmetadata:\n  name: test\n  namespace: namespace2",
+		"kind: Service\nmetadata:\n  name: test\n  namespace: namespace1",
+		"kind: ConfigMap\nmetadata:\n  name: test\n  namespace: namespace2",
+		"kind: ConfigMapList\nitems: []",
+	} {
+		head := SimpleHeader{}
+		require.NoError(t, yaml.Unmarshal([]byte(contents), &head))
+		manifests = append(manifests, Manifest{Head: head, Contents: contents})
+	}
+
+	assert.Equal(
+		t,
+		[]string{"namespace1", "namespace2"},
+		ManifestNamespaces(manifests),
+	)
+}
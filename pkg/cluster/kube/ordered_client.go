@@ -1,6 +1,7 @@
 package kube
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -11,9 +12,11 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/segmentio/kubeapply/data"
+	"github.com/segmentio/kubeapply/pkg/cluster/diff"
 	"github.com/segmentio/kubeapply/pkg/util"
 	log "github.com/sirupsen/logrus"
 )
@@ -21,13 +24,20 @@ import (
 const (
 	rawDiffScript = `#!/bin/bash
 
-diff -u -N $1 $2
+%s
 
 # Ensure that we only exit with non-zero status is there was a real error
 if [[ $? -gt 1 ]]; then
     exit 1
 fi`
 
+	// defaultRawDiffCommand is the differ used for raw diffs if a custom one isn't set.
+	defaultRawDiffCommand = "diff -u -N"
+
+	// rawDiffRedactCommand redacts the objects in place before they're passed to the raw
+	// differ, which doesn't know about the redact keys.
+	rawDiffRedactCommand = "kubeapply kredact $1 $2 || exit 1"
+
 	structuredDiffScript = `#!/bin/bash
 
 # This is used as the custom differ for kubectl diff. We need a wrapper script instead
@@ -35,6 +45,10 @@ fi`
 # any subcommands or arguments).
 
 kubeapply kdiff $1 $2`
+
+	// DefaultFieldManager is the field manager used for server-side applies and diffs if
+	// one isn't set explicitly.
+	DefaultFieldManager = "kubeapply"
 )
 
 // TODO: Switch to a YAML library that supports doing this splitting for us.
@@ -43,11 +57,24 @@ var sep = regexp.MustCompile("(?:^|\\s*\n)---\\s*")
 // OrderedClient is a kubectl-wrapped client that tries to be clever about the order
 // in which resources are created or destroyed.
 type OrderedClient struct {
-	kubeConfigPath string
-	keepConfigs    bool
-	extraEnv       []string
-	debug          bool
-	serverSide     bool
+	kubeConfigPath  string
+	keepConfigs     bool
+	extraEnv        []string
+	debug           bool
+	quiet           bool
+	serverSide      bool
+	serverSideKinds []string
+	fieldManager    string
+	kubectlAttempts int
+	waveTimeout     time.Duration
+
+	diffOptions diff.Options
+	filter      ManifestFilter
+	applyRecord *ApplyRecord
+
+	ownershipLabels    map[string]string
+	applyOrderPath     string
+	protectedResources []ResourceSelector
 }
 
 // NewOrderedClient returns a new OrderedClient instance.
@@ -56,19 +83,52 @@ func NewOrderedClient(
 	keepConfigs bool,
 	extraEnv []string,
 	debug bool,
+	quiet bool,
 	serverSide bool,
+	serverSideKinds []string,
+	fieldManager string,
+	kubectlAttempts int,
+	waveTimeout time.Duration,
+	diffOptions diff.Options,
+	filter ManifestFilter,
+	applyRecord *ApplyRecord,
+	ownershipLabels map[string]string,
+	applyOrderPath string,
+	protectedResources []ResourceSelector,
 ) *OrderedClient {
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+	if kubectlAttempts <= 0 {
+		kubectlAttempts = DefaultKubectlAttempts
+	}
+
 	return &OrderedClient{
-		kubeConfigPath: kubeConfigPath,
-		keepConfigs:    keepConfigs,
-		extraEnv:       extraEnv,
-		debug:          debug,
-		serverSide:     serverSide,
+		kubeConfigPath:  kubeConfigPath,
+		keepConfigs:     keepConfigs,
+		extraEnv:        extraEnv,
+		debug:           debug,
+		quiet:           quiet,
+		serverSide:      serverSide,
+		serverSideKinds: serverSideKinds,
+		fieldManager:    fieldManager,
+		kubectlAttempts: kubectlAttempts,
+		waveTimeout:     waveTimeout,
+		diffOptions:     diffOptions,
+		filter:          filter,
+		applyRecord:     applyRecord,
+		ownershipLabels: ownershipLabels,
+		applyOrderPath:  applyOrderPath,
+
+		protectedResources: protectedResources,
 	}
 }
 
 // Apply runs kubectl apply on the manifests in the argument path. The apply is done
-// in the optimal order based on resource type.
+// in the optimal order based on resource type, with the resources in the client's apply
+// order file (see ApplyOrder), if any, applied first. If the manifests are split into multiple
+// waves via WaveAnnotation, then each wave is applied separately and, if a wave timeout is
+// set, the workloads in each wave must finish rolling out before the next one is applied.
 func (k *OrderedClient) Apply(
 	ctx context.Context,
 	applyPaths []string,
@@ -76,7 +136,7 @@ func (k *OrderedClient) Apply(
 	format string,
 	dryRun bool,
 ) ([]byte, error) {
-	tempDir, err := ioutil.TempDir("", "manifests")
+	tempDir, err := util.TempDir("manifests")
 	if err != nil {
 		return nil, err
 	}
@@ -88,51 +148,231 @@ func (k *OrderedClient) Apply(
 		}
 	}()
 
-	manifests, err := GetManifests(applyPaths)
+	manifests, err := applyManifests(
+		applyPaths,
+		k.filter,
+		k.applyRecord,
+		k.ownershipLabels,
+		k.applyOrderPath,
+		k.protectedResources,
+	)
 	if err != nil {
 		return nil, err
 	}
-	SortManifests(manifests)
 
-	for m, manifest := range manifests {
-		// kubectl applies resources in their lexicographic ordering, so this naming scheme
-		// should force it to apply the manifests in the order we want.
+	waves := GroupManifestsByWave(manifests)
+	if len(waves) == 1 {
+		outputs, err := k.applyWave(ctx, tempDir, manifests, manifests, output, format, dryRun)
+		if err == nil && len(outputs) > 1 && output && format == "json" {
+			return mergeJSONOutputs(outputs)
+		}
+		return bytes.Join(outputs, nil), err
+	}
 
-		var name string
-		var namespace string
+	allOutput := []byte{}
+	waveOutputs := [][]byte{}
+
+	for w, wave := range waves {
+		waveNum := ManifestWave(wave[0])
+		log.Infof(
+			"Applying wave %d (%d/%d) with %d manifests",
+			waveNum,
+			w+1,
+			len(waves),
+			len(wave),
+		)
 
-		if manifest.Head.Metadata != nil {
-			name = manifest.Head.Metadata.Name
-			namespace = manifest.Head.Metadata.Namespace
+		waveDir := filepath.Join(tempDir, fmt.Sprintf("wave_%03d", w))
+		outputs, err := k.applyWave(ctx, waveDir, wave, manifests, output, format, dryRun)
+		allOutput = append(allOutput, bytes.Join(outputs, nil)...)
+		waveOutputs = append(waveOutputs, outputs...)
+		if err != nil {
+			return allOutput, fmt.Errorf("Error applying wave %d: %+v", waveNum, err)
 		}
 
-		tempPath := filepath.Join(
-			tempDir,
-			fmt.Sprintf(
-				"%06d_%s_%s_%s.yaml",
-				m,
-				name,
-				namespace,
-				manifest.Head.Kind,
-			),
-		)
+		if k.waveTimeout > 0 && !dryRun && w < len(waves)-1 {
+			err = k.waitForManifestRollouts(ctx, RolloutManifests(wave), k.waveTimeout)
+			if err != nil {
+				return allOutput, fmt.Errorf("Error waiting for wave %d: %+v", waveNum, err)
+			}
+		}
+	}
+
+	if !output {
+		return nil, nil
+	} else if format == "json" {
+		// Each wave produces its own JSON object or list, so these need to be merged
+		// into a single list for the output to be parseable.
+		return mergeJSONOutputs(waveOutputs)
+	}
+	return allOutput, nil
+}
+
+// applyWave writes the argument wave of manifests into the argument directory and applies
+// them, returning the output of each kubectl run. If some kinds are always applied
+// server-side, then the wave is split into batches via serverSideBatches and each batch is
+// applied in its own subdirectory.
+func (k *OrderedClient) applyWave(
+	ctx context.Context,
+	dir string,
+	wave []Manifest,
+	manifests []Manifest,
+	output bool,
+	format string,
+	dryRun bool,
+) ([][]byte, error) {
+	batches := serverSideBatches(wave, k.serverSide, k.serverSideKinds)
+	outputs := [][]byte{}
+
+	for b, batch := range batches {
+		batchDir := dir
+		if len(batches) > 1 {
+			log.Infof(
+				"Applying batch %d/%d with %d manifests (server-side=%v)",
+				b+1,
+				len(batches),
+				len(batch.manifests),
+				batch.serverSide,
+			)
+			batchDir = filepath.Join(dir, fmt.Sprintf("batch_%03d", b))
+		}
+		if err := os.MkdirAll(batchDir, 0755); err != nil {
+			return outputs, err
+		}
+		if err := WriteManifests(batchDir, batch.manifests); err != nil {
+			return outputs, err
+		}
 
-		err = ioutil.WriteFile(tempPath, []byte(manifest.Contents), 0644)
+		batchOutput, err := k.runApply(
+			ctx,
+			k.applyArgs(batchDir, format, dryRun, batch.serverSide, false),
+			manifests,
+			output,
+			dryRun,
+		)
+		outputs = append(outputs, batchOutput)
 		if err != nil {
-			return nil, err
+			return outputs, err
 		}
 	}
 
+	return outputs, nil
+}
+
+// applyBatch is a group of consecutive manifests that are all applied either server-side or
+// client-side.
+type applyBatch struct {
+	manifests  []Manifest
+	serverSide bool
+}
+
+// serverSideBatches splits the argument sorted manifests into consecutive batches based on
+// whether each one should be applied server-side. Since the batches are applied one after
+// the other, the sort order is preserved across them. If serverSide is true or there are no
+// server-side kinds, then everything is in a single batch.
+func serverSideBatches(
+	manifests []Manifest,
+	serverSide bool,
+	serverSideKinds []string,
+) []applyBatch {
+	if serverSide || len(serverSideKinds) == 0 {
+		return []applyBatch{{manifests: manifests, serverSide: serverSide}}
+	}
+
+	kindsMap := map[string]struct{}{}
+	for _, kind := range serverSideKinds {
+		kindsMap[strings.ToLower(kind)] = struct{}{}
+	}
+
+	batches := []applyBatch{}
+
+	for _, manifest := range manifests {
+		_, manifestServerSide := kindsMap[strings.ToLower(manifest.Head.Kind)]
+
+		if len(batches) == 0 || batches[len(batches)-1].serverSide != manifestServerSide {
+			batches = append(batches, applyBatch{serverSide: manifestServerSide})
+		}
+		batches[len(batches)-1].manifests = append(
+			batches[len(batches)-1].manifests,
+			manifest,
+		)
+	}
+
+	return batches
+}
+
+// mergeJSONOutputs merges the results of multiple "kubectl apply ... -o json" runs into a
+// single List. Each output can either be a single object or a List and may be prefixed by
+// kubectl warnings.
+func mergeJSONOutputs(outputs [][]byte) ([]byte, error) {
+	items := []interface{}{}
+
+	for _, output := range outputs {
+		// Skip over any warnings before the start of the JSON
+		startIndex := bytes.Index(output, []byte("{"))
+		if startIndex == -1 {
+			continue
+		}
+
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(output[startIndex:], &obj); err != nil {
+			return nil, fmt.Errorf(
+				"Could not unmarshal kubectl JSON response (err=%+v): %s",
+				err,
+				string(output),
+			)
+		}
+
+		if obj["kind"] == "List" {
+			listItems, _ := obj["items"].([]interface{})
+			items = append(items, listItems...)
+		} else {
+			items = append(items, obj)
+		}
+	}
+
+	return json.Marshal(
+		map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      items,
+		},
+	)
+}
+
+// applyArgs returns the kubectl arguments for applying the manifests in the argument
+// directory. If serverSide is true, then the apply is done server-side. If adopt is true, then
+// the apply is done server-side with conflicts forced so that the client's field manager
+// takes ownership of any existing resources.
+func (k *OrderedClient) applyArgs(
+	dir string,
+	format string,
+	dryRun bool,
+	serverSide bool,
+	adopt bool,
+) []string {
 	args := []string{
 		"apply",
 		"--kubeconfig",
 		k.kubeConfigPath,
 		"-R",
 		"-f",
-		tempDir,
+		dir,
 	}
-	if k.serverSide {
-		args = append(args, "--server-side", "true")
+	if adopt {
+		args = append(
+			args,
+			"--server-side",
+			"--force-conflicts",
+			fmt.Sprintf("--field-manager=%s", k.fieldManager),
+		)
+	} else if serverSide {
+		args = append(
+			args,
+			"--server-side",
+			"true",
+			fmt.Sprintf("--field-manager=%s", k.fieldManager),
+		)
 	}
 	if k.debug {
 		args = append(args, "-v", "8")
@@ -143,31 +383,96 @@ func (k *OrderedClient) Apply(
 	if dryRun {
 		args = append(args, "--dry-run")
 	}
+	return args
+}
+
+// runApply runs kubectl with the argument apply args, returning the output if output
+// is true and logging it otherwise.
+//
+// If the apply fails because some custom resources have kinds that aren't registered in the
+// cluster and the CRDs for all of them are in the argument manifests, then the CRDs were
+// likely applied too recently to be registered, so the apply is retried once after a short
+// wait. If it still fails, the returned error explains the CRD ordering problem.
+func (k *OrderedClient) runApply(
+	ctx context.Context,
+	args []string,
+	manifests []Manifest,
+	output bool,
+	dryRun bool,
+) ([]byte, error) {
+	out, errOutput, err := k.runApplyOnce(ctx, args, output)
+	if err == nil {
+		return out, nil
+	}
+
+	kinds := missingKinds(errOutput)
+	if len(kinds) == 0 {
+		return out, err
+	}
+
+	crds := crdKinds(manifests)
+
+	if !dryRun && allDefinedByCRDs(kinds, crds) {
+		log.Warnf(
+			"Apply failed because the CRDs for some custom resources weren't registered yet, retrying in %s",
+			crdRegistrationWait,
+		)
+		select {
+		case <-ctx.Done():
+			return out, ctx.Err()
+		case <-time.After(crdRegistrationWait):
+		}
+
+		out, errOutput, err = k.runApplyOnce(ctx, args, output)
+		if err == nil {
+			return out, nil
+		}
 
+		kinds = missingKinds(errOutput)
+		if len(kinds) == 0 {
+			return out, err
+		}
+	}
+
+	return out, missingKindsError(err, kinds, crds)
+}
+
+// runApplyOnce runs kubectl with the argument apply args. In addition to the output, if
+// output is true, it returns the error output so that failures can be classified.
+func (k *OrderedClient) runApplyOnce(
+	ctx context.Context,
+	args []string,
+	output bool,
+) ([]byte, string, error) {
 	if output {
-		return runKubectlOutput(
+		out, err := k.kubectlOutput(
 			ctx,
 			args,
 			k.extraEnv,
 			nil,
 		)
+		return out, string(out), err
 	}
-	return nil, runKubectl(
+
+	errOutput, err := k.kubectl(
 		ctx,
 		args,
 		k.extraEnv,
 	)
+	return nil, errOutput, err
 }
 
-// Diff runs kubectl diff for the configs at the argument path.
+// Diff runs kubectl diff for the configs at the argument path. If serverSide is true, then
+// the diff is done server-side even if this client isn't doing server-side applies.
 func (k *OrderedClient) Diff(
 	ctx context.Context,
 	configPaths []string,
+	serverSide bool,
 	structured bool,
 	diffCommand string,
 	spinner *spinner.Spinner,
 ) ([]byte, error) {
-	tempDir, err := ioutil.TempDir("", "diff")
+	tempDir, err := util.TempDir("diff")
 	if err != nil {
 		return nil, err
 	}
@@ -186,12 +491,48 @@ func (k *OrderedClient) Diff(
 		"-R",
 	}
 
-	for _, configPath := range configPaths {
-		args = append(args, "-f", configPath)
+	if k.filter.IsEmpty() && len(k.ownershipLabels) == 0 && !hasApplyOrderFile(configPaths) {
+		for _, configPath := range configPaths {
+			args = append(args, "-f", configPath)
+		}
+	} else {
+		// Write out just the manifests that match the filter, with the ownership labels that
+		// are added in applies, and diff those instead. This also keeps kubectl from trying
+		// to parse the apply order file as a manifest.
+		manifests, err := GetManifests(configPaths)
+		if err != nil {
+			return nil, err
+		}
+		manifests = FilterManifests(manifests, k.filter)
+		if len(manifests) == 0 {
+			return nil, errors.New("No manifests match the provided kind, name, and namespace filters")
+		}
+		if err := labelManifests(manifests, k.ownershipLabels); err != nil {
+			return nil, err
+		}
+
+		manifestsDir := filepath.Join(tempDir, "manifests")
+		if err := os.MkdirAll(manifestsDir, 0755); err != nil {
+			return nil, err
+		}
+		if err := WriteManifests(manifestsDir, manifests); err != nil {
+			return nil, err
+		}
+		args = append(args, "-f", manifestsDir)
 	}
 
-	if k.serverSide {
-		args = append(args, "--server-side", "true")
+	if k.serverSide || serverSide {
+		args = append(
+			args,
+			"--server-side",
+			fmt.Sprintf("--field-manager=%s", k.fieldManager),
+		)
+
+		if !k.serverSide {
+			// Resources that were applied client-side are owned by a different field
+			// manager, so force the conflicts to preview what a server-side apply would do.
+			args = append(args, "--force-conflicts")
+		}
 	}
 	if k.debug {
 		args = append(args, "-v", "8")
@@ -212,16 +553,10 @@ func (k *OrderedClient) Diff(
 			)
 		}
 	} else {
-		if diffCommand == "" {
-			diffScriptBody = rawDiffScript
-		} else {
-			diffScriptBody = strings.Replace(
-				rawDiffScript,
-				"diff",
-				diffCommand,
-				-1,
-			)
-		}
+		diffScriptBody = rawDiffScriptBody(
+			diffCommand,
+			len(k.diffOptions.RedactKeys) > 0,
+		)
 	}
 
 	kubectlDiffCmd := filepath.Join(tempDir, "diff.sh")
@@ -238,8 +573,9 @@ func (k *OrderedClient) Diff(
 		envVars,
 		fmt.Sprintf("KUBECTL_EXTERNAL_DIFF=%s", kubectlDiffCmd),
 	)
+	envVars = append(envVars, k.diffOptions.EnvVars()...)
 
-	return runKubectlOutput(
+	return k.kubectlOutput(
 		ctx,
 		args,
 		envVars,
@@ -247,11 +583,24 @@ func (k *OrderedClient) Diff(
 	)
 }
 
-// Summary returns a pretty summary of the current cluster state.
+// Summary returns a pretty summary of the current cluster state. In SummaryModeFull, the
+// summary is generated by a python script if python is available; otherwise, the basic
+// summary is returned.
 func (k *OrderedClient) Summary(
 	ctx context.Context,
+	mode SummaryMode,
 ) (string, error) {
-	tempDir, err := ioutil.TempDir("", "cluster-summary")
+	if mode == SummaryModeBasic {
+		return k.basicSummary(ctx)
+	}
+
+	pythonPath, err := exec.LookPath("python")
+	if err != nil {
+		log.Warnf("Could not find python (%+v), falling back to basic summary", err)
+		return k.basicSummary(ctx)
+	}
+
+	tempDir, err := util.TempDir("cluster-summary")
 	if err != nil {
 		return "", err
 	}
@@ -269,7 +618,7 @@ func (k *OrderedClient) Summary(
 	}
 
 	cmd := exec.Command(
-		"python",
+		pythonPath,
 		filepath.Join(tempDir, "scripts/cluster-summary/cluster_summary.py"),
 		"--no-color",
 		"--kubeconfig",
@@ -296,7 +645,7 @@ func (k *OrderedClient) GetNamespaceUID(ctx context.Context, namespace string) (
 		"json",
 	}
 
-	out, err := runKubectlOutput(ctx, args, nil, nil)
+	out, err := k.kubectlOutput(ctx, args, nil, nil)
 	if err != nil {
 		return "", err
 	}
@@ -313,21 +662,147 @@ func (k *OrderedClient) GetNamespaceUID(ctx context.Context, namespace string) (
 	return j.Metadata.UID, nil
 }
 
-func runKubectl(ctx context.Context, args []string, extraEnv []string) error {
+// applyManifests gets the manifests in the argument paths that match the argument filter,
+// sorts them in apply order, adds the argument ownership labels to them, and, if applyRecord
+// is set, annotates them with the record. If there's an apply order file at applyOrderPath,
+// then the resources listed in it are moved ahead of the others in their waves. If any of the
+// manifests match the argument protected resources, then an error is returned instead.
+func applyManifests(
+	applyPaths []string,
+	filter ManifestFilter,
+	applyRecord *ApplyRecord,
+	ownershipLabels map[string]string,
+	applyOrderPath string,
+	protectedResources []ResourceSelector,
+) ([]Manifest, error) {
+	applyOrder, err := LoadApplyOrder(applyOrderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := GetManifests(applyPaths)
+	if err != nil {
+		return nil, err
+	}
+	manifests = FilterManifests(manifests, filter)
+	if len(manifests) == 0 {
+		return nil, errors.New("No manifests match the provided kind, name, and namespace filters")
+	}
+	if err := CheckProtectedResources(manifests, protectedResources); err != nil {
+		return nil, err
+	}
+	SortManifests(manifests)
+	applyOrder.Sort(manifests)
+
+	if err := labelManifests(manifests, ownershipLabels); err != nil {
+		return nil, err
+	}
+
+	if applyRecord != nil {
+		annotations := applyRecord.Annotations()
+
+		for m := range manifests {
+			if err := AnnotateManifest(&manifests[m], annotations); err != nil {
+				return nil, fmt.Errorf(
+					"Error annotating manifest in %s: %+v",
+					manifests[m].Path,
+					err,
+				)
+			}
+		}
+	}
+
+	return manifests, nil
+}
+
+// labelManifests adds the argument labels to each of the argument manifests.
+func labelManifests(manifests []Manifest, labels map[string]string) error {
+	for m := range manifests {
+		if err := LabelManifest(&manifests[m], labels); err != nil {
+			return fmt.Errorf(
+				"Error labeling manifest in %s: %+v",
+				manifests[m].Path,
+				err,
+			)
+		}
+	}
+
+	return nil
+}
+
+// WriteManifests writes each of the argument manifests into its own file in the
+// argument directory.
+func WriteManifests(dir string, manifests []Manifest) error {
+	for m, manifest := range manifests {
+		// kubectl applies resources in their lexicographic ordering, so this naming scheme
+		// should force it to apply the manifests in the order we want.
+
+		var name string
+		var namespace string
+
+		if manifest.Head.Metadata != nil {
+			name = manifest.Head.Metadata.Name
+			namespace = manifest.Head.Metadata.Namespace
+		}
+
+		tempPath := filepath.Join(
+			dir,
+			fmt.Sprintf(
+				"%06d_%s_%s_%s.yaml",
+				m,
+				name,
+				namespace,
+				manifest.Head.Kind,
+			),
+		)
+
+		err := ioutil.WriteFile(tempPath, []byte(manifest.Contents), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runKubectl runs kubectl with its output streamed to the logs. The stderr output is also
+// returned so that errors can be classified by the caller. If quiet is set, the stdout output
+// is logged at the debug level and the stderr output at the warn level.
+func runKubectl(
+	ctx context.Context,
+	args []string,
+	extraEnv []string,
+	quiet bool,
+) (string, error) {
 	kubectlPath, err := exec.LookPath("kubectl")
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return util.RunCmdWithPrinters(
+	stdoutPrinter, stderrPrinter := kubectlPrinters(quiet)
+	stderrLines := []string{}
+
+	err = util.RunCmdWithPrinters(
 		ctx,
 		kubectlPath,
 		args,
 		extraEnv,
 		nil,
-		util.LogrusInfoPrinter("[kubectl]"),
-		util.LogrusInfoPrinter("[kubectl]"),
+		stdoutPrinter,
+		func(line string) {
+			stderrLines = append(stderrLines, line)
+			stderrPrinter(line)
+		},
 	)
+	return strings.Join(stderrLines, "\n"), err
+}
+
+// kubectlPrinters returns the printers for the stdout and stderr output of kubectl.
+func kubectlPrinters(quiet bool) (util.Printer, util.Printer) {
+	if quiet {
+		return util.LogrusDebugPrinter("[kubectl]"), util.LogrusWarnPrinter("[kubectl]")
+	}
+	return util.LogrusInfoPrinter("[kubectl]"), util.LogrusInfoPrinter("[kubectl]")
 }
 
 func runKubectlOutput(
@@ -356,3 +831,19 @@ func runKubectlOutput(
 
 	return cmd.CombinedOutput()
 }
+
+// rawDiffScriptBody returns the body of the external differ script used for raw diffs. The
+// argument command, if set, is run in place of "diff -u -N" on the live and merged object
+// directories, like a differ set in KUBECTL_EXTERNAL_DIFF. If redact is true, then the
+// objects are redacted via kubeapply kredact first.
+func rawDiffScriptBody(diffCommand string, redact bool) string {
+	if diffCommand == "" {
+		diffCommand = defaultRawDiffCommand
+	}
+	diffCommand = fmt.Sprintf("%s $1 $2", diffCommand)
+
+	if redact {
+		diffCommand = fmt.Sprintf("%s\n%s", rawDiffRedactCommand, diffCommand)
+	}
+	return fmt.Sprintf(rawDiffScript, diffCommand)
+}
@@ -0,0 +1,240 @@
+package kube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/cluster/apply"
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeJSONOutputs(t *testing.T) {
+	listOutput, err := ioutil.ReadFile("../apply/testdata/objs_old.json")
+	require.NoError(t, err)
+	objOutput, err := ioutil.ReadFile("../apply/testdata/obj_old.json")
+	require.NoError(t, err)
+
+	// Simulate three waves, one of which has a warning prefix
+	merged, err := mergeJSONOutputs(
+		[][]byte{
+			listOutput,
+			append([]byte("Warning: this is a kubectl warning\n"), objOutput...),
+			listOutput,
+		},
+	)
+	require.NoError(t, err)
+
+	objs, err := apply.KubeJSONToObjects(merged)
+	require.NoError(t, err)
+
+	kinds := []string{}
+	for _, obj := range objs {
+		kinds = append(kinds, obj.Kind)
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"Deployment",
+			"ServiceAccount",
+			"Service",
+			"ServiceAccount",
+			"Deployment",
+			"ServiceAccount",
+			"Service",
+		},
+		kinds,
+	)
+
+	_, err = mergeJSONOutputs([][]byte{[]byte("{not json")})
+	require.Error(t, err)
+}
+
+func TestKubectlPrinters(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	stdoutPrinter, stderrPrinter := kubectlPrinters(false)
+	stdoutPrinter("configured")
+	stderrPrinter("warning")
+
+	stdoutPrinter, stderrPrinter = kubectlPrinters(true)
+	stdoutPrinter("configured")
+	stderrPrinter("error")
+
+	levels := []log.Level{}
+	for _, entry := range hook.AllEntries() {
+		levels = append(levels, entry.Level)
+	}
+
+	// The debug entry is dropped at the default log level
+	assert.Equal(
+		t,
+		[]log.Level{log.InfoLevel, log.InfoLevel, log.WarnLevel},
+		levels,
+	)
+	assert.Equal(t, "[kubectl] error", hook.LastEntry().Message)
+}
+
+func TestServerSideBatches(t *testing.T) {
+	manifests := []Manifest{
+		{Path: "namespace.yaml", Head: SimpleHeader{Kind: "Namespace"}},
+		{Path: "crd1.yaml", Head: SimpleHeader{Kind: "CustomResourceDefinition"}},
+		{Path: "crd2.yaml", Head: SimpleHeader{Kind: "CustomResourceDefinition"}},
+		{Path: "deployment.yaml", Head: SimpleHeader{Kind: "Deployment"}},
+		{Path: "foo.yaml", Head: SimpleHeader{Kind: "Foo"}},
+	}
+
+	type testCase struct {
+		description     string
+		serverSide      bool
+		serverSideKinds []string
+		expBatches      [][]string
+		expServerSide   []bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "no server-side kinds",
+			expBatches: [][]string{
+				{"namespace.yaml", "crd1.yaml", "crd2.yaml", "deployment.yaml", "foo.yaml"},
+			},
+			expServerSide: []bool{false},
+		},
+		{
+			description:     "all server-side",
+			serverSide:      true,
+			serverSideKinds: []string{"Foo"},
+			expBatches: [][]string{
+				{"namespace.yaml", "crd1.yaml", "crd2.yaml", "deployment.yaml", "foo.yaml"},
+			},
+			expServerSide: []bool{true},
+		},
+		{
+			description:     "server-side kinds",
+			serverSideKinds: []string{"customresourcedefinition", "Foo"},
+			expBatches: [][]string{
+				{"namespace.yaml"},
+				{"crd1.yaml", "crd2.yaml"},
+				{"deployment.yaml"},
+				{"foo.yaml"},
+			},
+			expServerSide: []bool{false, true, false, true},
+		},
+	}
+
+	for _, testCase := range testCases {
+		batches := serverSideBatches(
+			manifests,
+			testCase.serverSide,
+			testCase.serverSideKinds,
+		)
+
+		paths := [][]string{}
+		serverSide := []bool{}
+
+		for _, batch := range batches {
+			batchPaths := []string{}
+			for _, manifest := range batch.manifests {
+				batchPaths = append(batchPaths, manifest.Path)
+			}
+			paths = append(paths, batchPaths)
+			serverSide = append(serverSide, batch.serverSide)
+		}
+
+		assert.Equal(t, testCase.expBatches, paths, testCase.description)
+		assert.Equal(t, testCase.expServerSide, serverSide, testCase.description)
+	}
+}
+
+func TestRawDiffScriptBody(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "diff")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	liveDir := filepath.Join(tempDir, "live")
+	mergedDir := filepath.Join(tempDir, "merged")
+	util.WriteFiles(t, liveDir, map[string]string{"obj.yaml": "key: value1\n"})
+	util.WriteFiles(t, mergedDir, map[string]string{"obj.yaml": "key: value2\n"})
+
+	// Stand in for kubeapply kredact so that we can check that it's run before the differ
+	binDir := filepath.Join(tempDir, "bin")
+	util.WriteFiles(
+		t,
+		binDir,
+		map[string]string{"kubeapply": "#!/bin/bash\necho \"redacting $2 $3\"\n"},
+	)
+	require.NoError(t, os.Chmod(filepath.Join(binDir, "kubeapply"), 0755))
+
+	type testCase struct {
+		description string
+		diffCommand string
+		redact      bool
+		expOutput   string
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "default differ",
+			expOutput:   "+key: value2",
+		},
+		{
+			description: "custom differ",
+			diffCommand: "echo custom",
+			expOutput:   fmt.Sprintf("custom %s %s", liveDir, mergedDir),
+		},
+		{
+			description: "custom differ with differences",
+			diffCommand: "echo custom; false",
+			expOutput:   "custom",
+		},
+		{
+			description: "redacted",
+			diffCommand: "echo custom",
+			redact:      true,
+			expOutput: fmt.Sprintf(
+				"redacting %s %s\ncustom %s %s",
+				liveDir,
+				mergedDir,
+				liveDir,
+				mergedDir,
+			),
+		},
+		{
+			description: "custom differ error",
+			diffCommand: "exit 2;",
+			expErr:      true,
+		},
+	}
+
+	for index, testCase := range testCases {
+		scriptPath := filepath.Join(tempDir, fmt.Sprintf("diff%d.sh", index))
+		err := ioutil.WriteFile(
+			scriptPath,
+			[]byte(rawDiffScriptBody(testCase.diffCommand, testCase.redact)),
+			0755,
+		)
+		require.NoError(t, err)
+
+		cmd := exec.Command(scriptPath, liveDir, mergedDir)
+		cmd.Env = append(
+			os.Environ(),
+			fmt.Sprintf("PATH=%s:%s", binDir, os.Getenv("PATH")),
+		)
+		out, err := cmd.CombinedOutput()
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			assert.NoError(t, err, testCase.description)
+			assert.Contains(t, string(out), testCase.expOutput, testCase.description)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package kube
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ResourceSelector matches resources by API version, kind, namespace, and name. Each field is
+// a glob, and empty fields match all resources. Kinds are matched case-insensitively.
+type ResourceSelector struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// Matches returns whether the argument manifest matches this selector.
+func (s ResourceSelector) Matches(manifest Manifest) bool {
+	var namespace, name string
+
+	if manifest.Head.Metadata != nil {
+		namespace = manifest.Head.Metadata.Namespace
+		name = manifest.Head.Metadata.Name
+	}
+
+	return globMatches(s.APIVersion, manifest.Head.Version) &&
+		globMatches(strings.ToLower(s.Kind), strings.ToLower(manifest.Head.Kind)) &&
+		globMatches(s.Namespace, namespace) &&
+		globMatches(s.Name, name)
+}
+
+// String returns a human-readable representation of this selector.
+func (s ResourceSelector) String() string {
+	components := []string{}
+
+	for _, component := range []struct {
+		key   string
+		value string
+	}{
+		{key: "apiVersion", value: s.APIVersion},
+		{key: "kind", value: s.Kind},
+		{key: "namespace", value: s.Namespace},
+		{key: "name", value: s.Name},
+	} {
+		if component.value != "" {
+			components = append(
+				components,
+				fmt.Sprintf("%s=%s", component.key, component.value),
+			)
+		}
+	}
+
+	return strings.Join(components, ",")
+}
+
+// CheckProtectedResources returns an error naming each of the argument manifests that matches
+// one of the argument protected resource selectors, if any.
+func CheckProtectedResources(manifests []Manifest, protected []ResourceSelector) error {
+	if len(protected) == 0 {
+		return nil
+	}
+
+	matches := []string{}
+
+	for _, manifest := range manifests {
+		for _, selector := range protected {
+			if selector.Matches(manifest) {
+				matches = append(
+					matches,
+					fmt.Sprintf(
+						"%s in %s (protected by %s)",
+						ManifestID(manifest),
+						manifest.Path,
+						selector,
+					),
+				)
+				break
+			}
+		}
+	}
+
+	if len(matches) > 0 {
+		return fmt.Errorf(
+			"Refusing to apply protected resources: %s",
+			strings.Join(matches, "; "),
+		)
+	}
+	return nil
+}
+
+func globMatches(pattern string, value string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	matches, err := path.Match(pattern, value)
+	if err != nil {
+		log.Warnf("Invalid protected resource glob %s: %+v", pattern, err)
+		return false
+	}
+	return matches
+}
@@ -0,0 +1,119 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckProtectedResources(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "data")
+	require.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	util.WriteFiles(
+		t,
+		outDir,
+		map[string]string{
+			"manifests.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: aws-auth
+  namespace: kube-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: apps
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: aws-node
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: cluster-admin
+`,
+		},
+	)
+
+	manifests, err := GetManifests([]string{outDir})
+	require.NoError(t, err)
+
+	type testCase struct {
+		description string
+		protected   []ResourceSelector
+		expectedErr []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "no protected resources",
+		},
+		{
+			description: "no matches",
+			protected: []ResourceSelector{
+				{
+					Kind:      "Secret",
+					Namespace: "kube-system",
+				},
+				{
+					APIVersion: "apps/*",
+					Name:       "aws-auth",
+				},
+			},
+		},
+		{
+			description: "namespace and name globs",
+			protected: []ResourceSelector{
+				{
+					Kind:      "configmap",
+					Namespace: "kube-*",
+					Name:      "aws-*",
+				},
+			},
+			expectedErr: []string{
+				"ConfigMap/kube-system/aws-auth",
+			},
+		},
+		{
+			description: "api version and cluster-scoped",
+			protected: []ResourceSelector{
+				{
+					APIVersion: "apps/*",
+				},
+				{
+					Kind: "ClusterRole",
+					Name: "cluster-admin",
+				},
+			},
+			expectedErr: []string{
+				"DaemonSet/kube-system/aws-node",
+				"ClusterRole/cluster-admin",
+				"protected by kind=ClusterRole,name=cluster-admin",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := CheckProtectedResources(manifests, testCase.protected)
+		if len(testCase.expectedErr) == 0 {
+			assert.NoError(t, err, testCase.description)
+		} else {
+			require.Error(t, err, testCase.description)
+			for _, expected := range testCase.expectedErr {
+				assert.Contains(t, err.Error(), expected, testCase.description)
+			}
+			assert.NotContains(t, err.Error(), "app-config", testCase.description)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// applyVerbs are the verbs that kubectl needs for each resource type in an apply.
+var applyVerbs = []string{"get", "create", "patch"}
+
+// PermissionCheck is a single operation that needs to be allowed for an apply to succeed.
+type PermissionCheck struct {
+	// Verb is the API verb, e.g. "create".
+	Verb string
+
+	// Resource is the resource type in kubectl format, e.g. "deployment.apps".
+	Resource string
+
+	// Namespace is the namespace of the resources. It's empty for cluster-scoped resources
+	// and for namespaced ones that use the default namespace in the kubeconfig.
+	Namespace string
+}
+
+func (p PermissionCheck) String() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s %s", p.Verb, p.Resource)
+	}
+	return fmt.Sprintf("%s %s in namespace %s", p.Verb, p.Resource, p.Namespace)
+}
+
+// ManifestPermissionChecks returns the sorted, unique permission checks needed to apply the
+// argument manifests.
+func ManifestPermissionChecks(manifests []Manifest) []PermissionCheck {
+	checksMap := map[PermissionCheck]struct{}{}
+
+	for _, manifest := range manifests {
+		if manifest.Head.Kind == "" {
+			continue
+		}
+
+		resource := strings.ToLower(manifest.Head.Kind)
+		if components := strings.SplitN(manifest.Head.Version, "/", 2); len(components) == 2 {
+			resource = fmt.Sprintf("%s.%s", resource, components[0])
+		}
+
+		var namespace string
+		if manifest.Head.Metadata != nil {
+			namespace = manifest.Head.Metadata.Namespace
+		}
+
+		for _, verb := range applyVerbs {
+			checksMap[PermissionCheck{
+				Verb:      verb,
+				Resource:  resource,
+				Namespace: namespace,
+			}] = struct{}{}
+		}
+	}
+
+	checks := []PermissionCheck{}
+	for check := range checksMap {
+		checks = append(checks, check)
+	}
+	sort.Slice(checks, func(a, b int) bool {
+		if checks[a].Namespace != checks[b].Namespace {
+			return checks[a].Namespace < checks[b].Namespace
+		}
+		if checks[a].Resource != checks[b].Resource {
+			return checks[a].Resource < checks[b].Resource
+		}
+		return checks[a].Verb < checks[b].Verb
+	})
+
+	return checks
+}
+
+// DeniedPermissions runs each of the argument checks via "kubectl auth can-i" and returns the
+// ones that aren't allowed.
+func DeniedPermissions(
+	ctx context.Context,
+	kubeConfigPath string,
+	checks []PermissionCheck,
+) ([]PermissionCheck, error) {
+	denied := []PermissionCheck{}
+
+	for _, check := range checks {
+		args := []string{
+			"--kubeconfig",
+			kubeConfigPath,
+			"auth",
+			"can-i",
+			check.Verb,
+			check.Resource,
+		}
+		if check.Namespace != "" {
+			args = append(args, "--namespace", check.Namespace)
+		}
+
+		out, err := runKubectlOutput(ctx, args, nil, nil)
+		allowed, err := parseCanIOutput(string(out), err)
+		if err != nil {
+			return nil, fmt.Errorf("Error checking permission to %s: %+v", check, err)
+		}
+		if !allowed {
+			denied = append(denied, check)
+		}
+	}
+
+	return denied, nil
+}
+
+// parseCanIOutput parses the output of a "kubectl auth can-i" call. The command exits with an
+// error for denied permissions, so errors are only returned if the answer can't be found.
+func parseCanIOutput(output string, err error) (bool, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	answer := strings.TrimSpace(lines[len(lines)-1])
+
+	switch {
+	case answer == "yes" && err == nil:
+		return true, nil
+	case strings.HasPrefix(answer, "no"):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("%+v: %s", err, strings.TrimSpace(output))
+	default:
+		return false, fmt.Errorf("Unexpected output: %s", strings.TrimSpace(output))
+	}
+}
@@ -0,0 +1,92 @@
+package kube
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestPermissionChecks(t *testing.T) {
+	manifests := []Manifest{}
+
+	for _, contents := range []string{
+		"apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: test",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: test1\n  namespace: ns1",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: test2\n  namespace: ns1",
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: test\n  namespace: ns1",
+	} {
+		head := SimpleHeader{}
+		require.NoError(t, yaml.Unmarshal([]byte(contents), &head))
+		manifests = append(manifests, Manifest{Head: head})
+	}
+
+	assert.Equal(
+		t,
+		[]PermissionCheck{
+			{Verb: "create", Resource: "clusterrole.rbac.authorization.k8s.io"},
+			{Verb: "get", Resource: "clusterrole.rbac.authorization.k8s.io"},
+			{Verb: "patch", Resource: "clusterrole.rbac.authorization.k8s.io"},
+			{Verb: "create", Resource: "deployment.apps", Namespace: "ns1"},
+			{Verb: "get", Resource: "deployment.apps", Namespace: "ns1"},
+			{Verb: "patch", Resource: "deployment.apps", Namespace: "ns1"},
+			{Verb: "create", Resource: "service", Namespace: "ns1"},
+			{Verb: "get", Resource: "service", Namespace: "ns1"},
+			{Verb: "patch", Resource: "service", Namespace: "ns1"},
+		},
+		ManifestPermissionChecks(manifests),
+	)
+}
+
+func TestParseCanIOutput(t *testing.T) {
+	type testCase struct {
+		description string
+		output      string
+		err         error
+		expAllowed  bool
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "allowed",
+			output:      "yes\n",
+			expAllowed:  true,
+		},
+		{
+			description: "allowed with warning",
+			output:      "Warning: resource 'foos' is not namespace scoped\nyes\n",
+			expAllowed:  true,
+		},
+		{
+			description: "denied",
+			output:      "no\n",
+			err:         errors.New("exit status 1"),
+			expAllowed:  false,
+		},
+		{
+			description: "denied with reason",
+			output:      "no - RBAC: clusterrole.rbac.authorization.k8s.io \"test\" not found\n",
+			err:         errors.New("exit status 1"),
+			expAllowed:  false,
+		},
+		{
+			description: "error",
+			output:      "error: You must be logged in to the server (Unauthorized)\n",
+			err:         errors.New("exit status 1"),
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		allowed, err := parseCanIOutput(testCase.output, testCase.err)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			require.NoError(t, err, testCase.description)
+			assert.Equal(t, testCase.expAllowed, allowed, testCase.description)
+		}
+	}
+}
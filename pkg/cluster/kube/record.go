@@ -0,0 +1,163 @@
+package kube
+
+import (
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+)
+
+const (
+	// AnnotationPrefix is the prefix shared by all of the annotations below. These are
+	// stripped out of diffs.
+	AnnotationPrefix = diff.RecordAnnotationPrefix
+
+	// AppliedByAnnotation is the annotation used to record who made the last kubeapply apply
+	// of a resource.
+	AppliedByAnnotation = AnnotationPrefix + "by"
+
+	// AppliedSHAAnnotation is the annotation used to record the git SHA of the configs in the
+	// last kubeapply apply of a resource.
+	AppliedSHAAnnotation = AnnotationPrefix + "sha"
+
+	// AppliedAtAnnotation is the annotation used to record the time of the last kubeapply
+	// apply of a resource.
+	AppliedAtAnnotation = AnnotationPrefix + "at"
+)
+
+// ApplyRecord contains the details that are stamped onto resources when recording applies.
+type ApplyRecord struct {
+	// AppliedBy is the user (or pull request commenter) responsible for the apply.
+	AppliedBy string
+
+	// SHA is the git SHA of the configs being applied.
+	SHA string
+
+	// AppliedAt is the time of the apply. This is fixed when the record is created so that
+	// the dry-run and real applies in a single kubeapply run stamp the same value.
+	AppliedAt time.Time
+}
+
+// Annotations returns the annotations that should be set for this record. Empty values are
+// omitted.
+func (r ApplyRecord) Annotations() map[string]string {
+	annotations := map[string]string{}
+
+	if !r.AppliedAt.IsZero() {
+		annotations[AppliedAtAnnotation] = r.AppliedAt.UTC().Format(time.RFC3339)
+	}
+	if r.AppliedBy != "" {
+		annotations[AppliedByAnnotation] = r.AppliedBy
+	}
+	if r.SHA != "" {
+		annotations[AppliedSHAAnnotation] = r.SHA
+	}
+
+	return annotations
+}
+
+// AnnotateManifest adds the argument annotations to the metadata of a manifest. Manifests
+// without metadata (e.g., lists) are left as-is.
+func AnnotateManifest(manifest *Manifest, annotations map[string]string) error {
+	if manifest.Head.Metadata == nil || len(annotations) == 0 {
+		return nil
+	}
+
+	if err := setManifestMetadata(manifest, "annotations", annotations); err != nil {
+		return err
+	}
+
+	if manifest.Head.Metadata.Annotations == nil {
+		manifest.Head.Metadata.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		manifest.Head.Metadata.Annotations[key] = value
+	}
+
+	return nil
+}
+
+// LabelManifest adds the argument labels to the metadata of a manifest. Manifests without
+// metadata (e.g., lists) are left as-is.
+func LabelManifest(manifest *Manifest, labels map[string]string) error {
+	if manifest.Head.Metadata == nil || len(labels) == 0 {
+		return nil
+	}
+
+	return setManifestMetadata(manifest, "labels", labels)
+}
+
+// setManifestMetadata sets the argument values in the argument metadata field (e.g.,
+// "annotations") of a manifest's contents.
+func setManifestMetadata(manifest *Manifest, field string, values map[string]string) error {
+	contents, _, err := updateMetadata(
+		manifest.Contents,
+		map[string]map[string]string{field: values},
+		true,
+	)
+	if err != nil {
+		return err
+	}
+	manifest.Contents = contents
+
+	return nil
+}
+
+// updateMetadata adds the argument values to the metadata fields (e.g., "labels") of the
+// argument YAML document. If overwrite is false, then values that are already set on the
+// document are kept. It returns the updated document and whether anything changed.
+//
+// Changed documents are re-emitted from their parsed form, so comments are dropped and keys
+// are sorted. Documents that don't change, including ones without metadata, are returned
+// as-is.
+func updateMetadata(
+	contents string,
+	values map[string]map[string]string,
+	overwrite bool,
+) (string, bool, error) {
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(contents), &parsed); err != nil {
+		return "", false, err
+	}
+
+	obj, ok := parsed.(map[string]interface{})
+	if !ok {
+		return contents, false, nil
+	}
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		return contents, false, nil
+	}
+
+	updated := false
+
+	for field, fieldValues := range values {
+		if len(fieldValues) == 0 {
+			continue
+		}
+
+		objValues, ok := metadata[field].(map[string]interface{})
+		if !ok {
+			objValues = map[string]interface{}{}
+		}
+
+		for key, value := range fieldValues {
+			if currValue, ok := objValues[key]; ok && (!overwrite || currValue == value) {
+				continue
+			}
+			objValues[key] = value
+			updated = true
+		}
+		metadata[field] = objValues
+	}
+
+	if !updated {
+		return contents, false, nil
+	}
+
+	updatedContents, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", false, err
+	}
+	return string(updatedContents), true, nil
+}
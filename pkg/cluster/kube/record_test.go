@@ -0,0 +1,123 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotateManifest(t *testing.T) {
+	contents := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: fluentbit
+  namespace: monitoring
+  annotations:
+    eks.amazonaws.com/role-arn: test-role`
+
+	head := SimpleHeader{}
+	require.NoError(t, yaml.Unmarshal([]byte(contents), &head))
+
+	manifest := Manifest{
+		Path:     "test.yaml",
+		Head:     head,
+		Contents: contents,
+	}
+
+	record := ApplyRecord{
+		AppliedBy: "test-user",
+		SHA:       "test-sha",
+		AppliedAt: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	annotations := record.Annotations()
+	assert.Equal(
+		t,
+		map[string]string{
+			AppliedByAnnotation:  "test-user",
+			AppliedSHAAnnotation: "test-sha",
+			AppliedAtAnnotation:  "2021-01-02T03:04:05Z",
+		},
+		annotations,
+	)
+
+	require.NoError(t, AnnotateManifest(&manifest, annotations))
+
+	updatedHead := SimpleHeader{}
+	require.NoError(t, yaml.Unmarshal([]byte(manifest.Contents), &updatedHead))
+	assert.Equal(t, "fluentbit", updatedHead.Metadata.Name)
+	assert.Equal(
+		t,
+		map[string]string{
+			"eks.amazonaws.com/role-arn": "test-role",
+			AppliedByAnnotation:          "test-user",
+			AppliedSHAAnnotation:         "test-sha",
+			AppliedAtAnnotation:          "2021-01-02T03:04:05Z",
+		},
+		updatedHead.Metadata.Annotations,
+	)
+	assert.Equal(t, updatedHead.Metadata.Annotations, manifest.Head.Metadata.Annotations)
+
+	listManifest := Manifest{
+		Path:     "list.yaml",
+		Head:     SimpleHeader{Kind: "ConfigMapList"},
+		Contents: "kind: ConfigMapList\nitems: []",
+	}
+	require.NoError(t, AnnotateManifest(&listManifest, annotations))
+	assert.Equal(t, "kind: ConfigMapList\nitems: []", listManifest.Contents)
+}
+
+func TestLabelManifest(t *testing.T) {
+	contents := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-config
+  labels:
+    app: test`
+
+	head := SimpleHeader{}
+	require.NoError(t, yaml.Unmarshal([]byte(contents), &head))
+
+	manifest := Manifest{
+		Path:     "test.yaml",
+		Head:     head,
+		Contents: contents,
+	}
+	require.NoError(
+		t,
+		LabelManifest(
+			&manifest,
+			map[string]string{"app.kubernetes.io/managed-by": "kubeapply-test"},
+		),
+	)
+
+	obj := struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}{}
+	require.NoError(t, yaml.Unmarshal([]byte(manifest.Contents), &obj))
+	assert.Equal(t, "test-config", obj.Metadata.Name)
+	assert.Equal(
+		t,
+		map[string]string{
+			"app":                          "test",
+			"app.kubernetes.io/managed-by": "kubeapply-test",
+		},
+		obj.Metadata.Labels,
+	)
+
+	listManifest := Manifest{
+		Path:     "list.yaml",
+		Head:     SimpleHeader{Kind: "ConfigMapList"},
+		Contents: "kind: ConfigMapList\nitems: []",
+	}
+	require.NoError(
+		t,
+		LabelManifest(&listManifest, map[string]string{"app.kubernetes.io/managed-by": "kubeapply"}),
+	)
+	assert.Equal(t, "kind: ConfigMapList\nitems: []", listManifest.Contents)
+}
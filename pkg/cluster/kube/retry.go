@@ -0,0 +1,136 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultKubectlAttempts is the number of times that a kubectl command is tried when it
+	// fails with a transient error, if a value isn't set explicitly.
+	DefaultKubectlAttempts = 3
+
+	// Base and max backoff between kubectl attempts
+	kubectlBaseBackoff = 2 * time.Second
+	kubectlMaxBackoff  = 30 * time.Second
+)
+
+// transientKubectlErrors are substrings of kubectl output that indicate a problem talking
+// to the API server as opposed to a problem with the request itself.
+var transientKubectlErrors = []string{
+	"connection refused",
+	"connection reset by peer",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"net/http: request canceled",
+	"unexpected EOF",
+	"(TooManyRequests)",
+	"(ServiceUnavailable)",
+	"the server is currently unable to handle the request",
+	"etcdserver: request timed out",
+	"etcdserver: leader changed",
+}
+
+// isTransientKubectlError returns whether the argument kubectl output indicates that the
+// command failed for a transient reason and can be safely retried.
+func isTransientKubectlError(output string) bool {
+	for _, transientErr := range transientKubectlErrors {
+		if strings.Contains(output, transientErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// kubectlBackoff returns the exponential backoff to use after the argument attempt.
+func kubectlBackoff(baseBackoff time.Duration, attempt int) time.Duration {
+	backoff := baseBackoff
+	for i := 1; i < attempt && backoff < kubectlMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > kubectlMaxBackoff {
+		backoff = kubectlMaxBackoff
+	}
+	return backoff
+}
+
+// runWithRetries calls the argument function, which should run kubectl and return its
+// output, up to the given number of attempts. Only failures with transient errors are
+// retried.
+func runWithRetries(
+	ctx context.Context,
+	attempts int,
+	baseBackoff time.Duration,
+	run func() (string, error),
+) error {
+	for attempt := 1; ; attempt++ {
+		output, err := run()
+		if err == nil || attempt >= attempts || !isTransientKubectlError(output) {
+			return err
+		}
+
+		backoff := kubectlBackoff(baseBackoff, attempt)
+		log.Warnf(
+			"Transient error running kubectl (attempt %d/%d), retrying in %s: %s",
+			attempt,
+			attempts,
+			backoff,
+			strings.TrimSpace(output),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// kubectl runs kubectl with streaming output, retrying transient errors. The stderr output
+// is from the last attempt.
+func (k *OrderedClient) kubectl(
+	ctx context.Context,
+	args []string,
+	extraEnv []string,
+) (string, error) {
+	var stderr string
+
+	err := runWithRetries(
+		ctx,
+		k.kubectlAttempts,
+		kubectlBaseBackoff,
+		func() (string, error) {
+			var err error
+			stderr, err = runKubectl(ctx, args, extraEnv, k.quiet)
+			return stderr, err
+		},
+	)
+	return stderr, err
+}
+
+// kubectlOutput runs kubectl and returns its combined output, retrying transient errors.
+// The output is from the last attempt.
+func (k *OrderedClient) kubectlOutput(
+	ctx context.Context,
+	args []string,
+	extraEnv []string,
+	spinner *spinner.Spinner,
+) ([]byte, error) {
+	var out []byte
+
+	err := runWithRetries(
+		ctx,
+		k.kubectlAttempts,
+		kubectlBaseBackoff,
+		func() (string, error) {
+			var err error
+			out, err = runKubectlOutput(ctx, args, extraEnv, spinner)
+			return string(out), err
+		},
+	)
+	return out, err
+}
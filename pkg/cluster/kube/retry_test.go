@@ -0,0 +1,126 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientKubectlError(t *testing.T) {
+	type testCase struct {
+		output       string
+		expTransient bool
+	}
+
+	testCases := []testCase{
+		{
+			output: "The connection to the server 1.2.3.4:443 was refused - did you " +
+				"specify the right host or port?: dial tcp 1.2.3.4:443: connect: connection refused",
+			expTransient: true,
+		},
+		{
+			output:       "Error from server (TooManyRequests): the server has received too many requests",
+			expTransient: true,
+		},
+		{
+			output:       "Unable to connect to the server: net/http: TLS handshake timeout",
+			expTransient: true,
+		},
+		{
+			output:       "Error from server (ServiceUnavailable): the server is currently unable to handle the request",
+			expTransient: true,
+		},
+		{
+			output:       `The Deployment "test" is invalid: spec.replicas: Invalid value: -1`,
+			expTransient: false,
+		},
+		{
+			output:       "error: error validating \"deployment.yaml\": unknown field \"replica\"",
+			expTransient: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(
+			t,
+			testCase.expTransient,
+			isTransientKubectlError(testCase.output),
+			testCase.output,
+		)
+	}
+}
+
+func TestKubectlBackoff(t *testing.T) {
+	assert.Equal(t, 2*time.Second, kubectlBackoff(2*time.Second, 1))
+	assert.Equal(t, 4*time.Second, kubectlBackoff(2*time.Second, 2))
+	assert.Equal(t, 8*time.Second, kubectlBackoff(2*time.Second, 3))
+	assert.Equal(t, kubectlMaxBackoff, kubectlBackoff(2*time.Second, 10))
+}
+
+func TestRunWithRetries(t *testing.T) {
+	type testCase struct {
+		description string
+		attempts    int
+		outputs     []string
+		expCalls    int
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "success",
+			attempts:    3,
+			outputs:     []string{""},
+			expCalls:    1,
+		},
+		{
+			description: "transient then success",
+			attempts:    3,
+			outputs:     []string{"connection refused", "connection refused", ""},
+			expCalls:    3,
+		},
+		{
+			description: "transient exhausted",
+			attempts:    2,
+			outputs:     []string{"connection refused", "(TooManyRequests)", ""},
+			expCalls:    2,
+			expErr:      true,
+		},
+		{
+			description: "non-transient",
+			attempts:    3,
+			outputs:     []string{"Invalid value", ""},
+			expCalls:    1,
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		calls := 0
+
+		err := runWithRetries(
+			context.Background(),
+			testCase.attempts,
+			time.Millisecond,
+			func() (string, error) {
+				output := testCase.outputs[calls]
+				calls++
+
+				if output == "" {
+					return "", nil
+				}
+				return output, errors.New("kubectl failed")
+			},
+		)
+
+		assert.Equal(t, testCase.expCalls, calls, testCase.description)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			assert.NoError(t, err, testCase.description)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RolloutKinds are the kinds of workloads that can be waited on via kubectl rollout status.
+var RolloutKinds = []string{
+	"DaemonSet",
+	"Deployment",
+	"StatefulSet",
+}
+
+// RolloutManifests returns the subset of the argument manifests that correspond to workloads
+// whose rollouts can be waited on.
+func RolloutManifests(manifests []Manifest) []Manifest {
+	rolloutManifests := []Manifest{}
+
+	for _, manifest := range manifests {
+		if manifest.Head.Metadata == nil || manifest.Head.Metadata.Name == "" {
+			continue
+		}
+		if contains(RolloutKinds, manifest.Head.Kind) {
+			rolloutManifests = append(rolloutManifests, manifest)
+		}
+	}
+
+	return rolloutManifests
+}
+
+// WaitForRollout waits for the rollouts of all of the workloads (deployments, statefulsets,
+// and daemonsets) in the argument paths to complete. An error is returned if any of
+// the rollouts doesn't complete before the timeout elapses.
+func (k *OrderedClient) WaitForRollout(
+	ctx context.Context,
+	paths []string,
+	timeout time.Duration,
+) error {
+	if timeout <= 0 {
+		return errors.New("Rollout timeout must be positive")
+	}
+
+	manifests, err := GetManifests(paths)
+	if err != nil {
+		return err
+	}
+	return k.waitForManifestRollouts(
+		ctx,
+		RolloutManifests(FilterManifests(manifests, k.filter)),
+		timeout,
+	)
+}
+
+func (k *OrderedClient) waitForManifestRollouts(
+	ctx context.Context,
+	manifests []Manifest,
+	timeout time.Duration,
+) error {
+	deadline := time.Now().Add(timeout)
+
+	for _, manifest := range manifests {
+		resource := fmt.Sprintf(
+			"%s/%s",
+			strings.ToLower(manifest.Head.Kind),
+			manifest.Head.Metadata.Name,
+		)
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("Timed out waiting for rollout of %s", resource)
+		}
+
+		args := []string{
+			"--kubeconfig",
+			k.kubeConfigPath,
+			"rollout",
+			"status",
+			resource,
+			fmt.Sprintf("--timeout=%s", remaining.Round(time.Second)),
+		}
+		if manifest.Head.Metadata.Namespace != "" {
+			args = append(args, "-n", manifest.Head.Metadata.Namespace)
+		}
+
+		log.Infof("Waiting for rollout of %s", resource)
+		out, err := k.kubectlOutput(ctx, args, k.extraEnv, nil)
+		if err != nil {
+			return fmt.Errorf(
+				"Error waiting for rollout of %s: %s",
+				resource,
+				strings.TrimSpace(string(out)),
+			)
+		}
+	}
+
+	return nil
+}
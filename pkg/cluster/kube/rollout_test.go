@@ -0,0 +1,53 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutManifests(t *testing.T) {
+	contents := []string{
+		`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test-deployment
+  namespace: test-namespace`,
+		`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap
+  namespace: test-namespace`,
+		`apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: test-statefulset
+  namespace: test-namespace`,
+		`apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: test-daemonset
+  namespace: kube-system`,
+		`kind: DeploymentList
+items: []`,
+	}
+
+	manifests := []Manifest{}
+	for _, content := range contents {
+		head := SimpleHeader{}
+		require.NoError(t, yaml.Unmarshal([]byte(content), &head))
+		manifests = append(manifests, Manifest{Head: head, Contents: content})
+	}
+
+	names := []string{}
+	for _, manifest := range RolloutManifests(manifests) {
+		names = append(names, manifest.Head.Metadata.Name)
+	}
+	assert.Equal(
+		t,
+		[]string{"test-deployment", "test-statefulset", "test-daemonset"},
+		names,
+	)
+}
@@ -0,0 +1,326 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// SummaryMode determines how cluster summaries are generated.
+type SummaryMode string
+
+const (
+	// SummaryModeFull generates summaries via a python script that shows the state of all
+	// pods, jobs, deployments, statefulsets, daemonsets, and nodes. If python isn't
+	// available, then the basic mode is used instead.
+	SummaryModeFull SummaryMode = "full"
+
+	// SummaryModeBasic generates summaries with per-namespace pod counts via kubectl
+	// alone. This is useful for running kubeapply in images that don't include python.
+	SummaryModeBasic SummaryMode = "basic"
+)
+
+// ParseSummaryMode converts the argument string into a SummaryMode. An empty string
+// is interpreted as SummaryModeFull.
+func ParseSummaryMode(modeStr string) (SummaryMode, error) {
+	switch SummaryMode(modeStr) {
+	case "", SummaryModeFull:
+		return SummaryModeFull, nil
+	case SummaryModeBasic:
+		return SummaryModeBasic, nil
+	default:
+		return "", fmt.Errorf(
+			"Unrecognized summary mode %s; expected %s or %s",
+			modeStr,
+			SummaryModeFull,
+			SummaryModeBasic,
+		)
+	}
+}
+
+var podPhases = []string{"Running", "Pending", "Succeeded", "Failed", "Unknown"}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type nodeList struct {
+	Items []struct {
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// basicSummary returns a summary of the pod counts in each namespace plus the number of
+// ready nodes. Unlike the full summary, it only requires kubectl.
+func (k *OrderedClient) basicSummary(ctx context.Context) (string, error) {
+	pods := podList{}
+	if err := k.getJSON(ctx, []string{"pods", "--all-namespaces"}, &pods); err != nil {
+		return "", err
+	}
+
+	nodes := nodeList{}
+	if err := k.getJSON(ctx, []string{"nodes"}, &nodes); err != nil {
+		return "", err
+	}
+
+	return formatBasicSummary(pods, nodes), nil
+}
+
+func formatBasicSummary(pods podList, nodes nodeList) string {
+	totals := map[string]int{}
+	phaseCounts := map[string]map[string]int{}
+
+	for _, pod := range pods.Items {
+		namespace := pod.Metadata.Namespace
+		if _, ok := phaseCounts[namespace]; !ok {
+			phaseCounts[namespace] = map[string]int{}
+		}
+		totals[namespace]++
+		phaseCounts[namespace][pod.Status.Phase]++
+	}
+
+	namespaces := []string{}
+	for namespace := range phaseCounts {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "PODS")
+	fmt.Fprintln(buf)
+
+	writer := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(
+		writer,
+		"NAMESPACE\tTOTAL\t%s\n",
+		strings.ToUpper(strings.Join(podPhases, "\t")),
+	)
+	for _, namespace := range namespaces {
+		counts := []string{}
+		for _, phase := range podPhases {
+			counts = append(counts, fmt.Sprintf("%d", phaseCounts[namespace][phase]))
+		}
+		fmt.Fprintf(
+			writer,
+			"%s\t%d\t%s\n",
+			namespace,
+			totals[namespace],
+			strings.Join(counts, "\t"),
+		)
+	}
+	writer.Flush()
+
+	readyNodes := 0
+	for _, node := range nodes.Items {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				readyNodes++
+			}
+		}
+	}
+
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "NODES")
+	fmt.Fprintln(buf)
+	fmt.Fprintf(buf, "%d/%d ready\n", readyNodes, len(nodes.Items))
+
+	return buf.String()
+}
+
+// StructuredSummary is a machine-readable summary of the current cluster state.
+type StructuredSummary struct {
+	Namespaces  []NamespaceSummary  `json:"namespaces"`
+	Deployments []DeploymentSummary `json:"deployments"`
+	Nodes       NodesSummary        `json:"nodes"`
+}
+
+// NamespaceSummary contains the pod counts in a single namespace.
+type NamespaceSummary struct {
+	Namespace string `json:"namespace"`
+	TotalPods int    `json:"totalPods"`
+
+	// PodPhases are the number of pods in each phase, e.g. "Running".
+	PodPhases map[string]int `json:"podPhases"`
+}
+
+// DeploymentSummary contains the replica counts of a single deployment.
+type DeploymentSummary struct {
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	Replicas        int    `json:"replicas"`
+	ReadyReplicas   int    `json:"readyReplicas"`
+	UpdatedReplicas int    `json:"updatedReplicas"`
+
+	// Ready is whether all of the desired replicas are updated and ready.
+	Ready bool `json:"ready"`
+}
+
+// NodesSummary contains the node counts in the cluster.
+type NodesSummary struct {
+	Total int `json:"total"`
+	Ready int `json:"ready"`
+}
+
+type deploymentList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			ReadyReplicas   int `json:"readyReplicas"`
+			UpdatedReplicas int `json:"updatedReplicas"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// SummaryStructured returns a structured summary of the pods in each namespace, the
+// deployments, and the nodes in the cluster. Like the basic summary, it only requires
+// kubectl.
+func (k *OrderedClient) SummaryStructured(ctx context.Context) (StructuredSummary, error) {
+	pods := podList{}
+	if err := k.getJSON(ctx, []string{"pods", "--all-namespaces"}, &pods); err != nil {
+		return StructuredSummary{}, err
+	}
+
+	deployments := deploymentList{}
+	if err := k.getJSON(ctx, []string{"deployments", "--all-namespaces"}, &deployments); err != nil {
+		return StructuredSummary{}, err
+	}
+
+	nodes := nodeList{}
+	if err := k.getJSON(ctx, []string{"nodes"}, &nodes); err != nil {
+		return StructuredSummary{}, err
+	}
+
+	return structuredSummary(pods, deployments, nodes), nil
+}
+
+// getJSON runs "kubectl get" with the argument args and unmarshals the JSON output into obj.
+func (k *OrderedClient) getJSON(ctx context.Context, args []string, obj interface{}) error {
+	fullArgs := append([]string{"--kubeconfig", k.kubeConfigPath, "get"}, args...)
+	fullArgs = append(fullArgs, "-o", "json")
+
+	out, err := k.kubectlOutput(ctx, fullArgs, k.extraEnv, nil)
+	if err != nil {
+		return fmt.Errorf("%+v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return unmarshalKubectlJSON(out, obj)
+}
+
+// unmarshalKubectlJSON unmarshals the JSON in the kubectl output into obj. The output
+// includes stderr, so any warnings before the start of the JSON are skipped.
+func unmarshalKubectlJSON(output []byte, obj interface{}) error {
+	startIndex := bytes.Index(output, []byte("{"))
+	if startIndex == -1 {
+		return fmt.Errorf(
+			"Could not find JSON in kubectl response: %s",
+			strings.TrimSpace(string(output)),
+		)
+	}
+
+	if err := json.Unmarshal(output[startIndex:], obj); err != nil {
+		return fmt.Errorf(
+			"Could not unmarshal kubectl JSON response (err=%+v): %s",
+			err,
+			strings.TrimSpace(string(output)),
+		)
+	}
+	return nil
+}
+
+func structuredSummary(
+	pods podList,
+	deployments deploymentList,
+	nodes nodeList,
+) StructuredSummary {
+	summary := StructuredSummary{
+		Namespaces:  []NamespaceSummary{},
+		Deployments: []DeploymentSummary{},
+	}
+
+	namespaceIndices := map[string]int{}
+
+	for _, pod := range pods.Items {
+		namespace := pod.Metadata.Namespace
+		index, ok := namespaceIndices[namespace]
+		if !ok {
+			index = len(summary.Namespaces)
+			namespaceIndices[namespace] = index
+			summary.Namespaces = append(
+				summary.Namespaces,
+				NamespaceSummary{
+					Namespace: namespace,
+					PodPhases: map[string]int{},
+				},
+			)
+		}
+		phase := pod.Status.Phase
+		if phase == "" {
+			phase = "Unknown"
+		}
+
+		summary.Namespaces[index].TotalPods++
+		summary.Namespaces[index].PodPhases[phase]++
+	}
+	sort.Slice(summary.Namespaces, func(a, b int) bool {
+		return summary.Namespaces[a].Namespace < summary.Namespaces[b].Namespace
+	})
+
+	for _, deployment := range deployments.Items {
+		// Replicas defaults to 1 if it's not set in the spec
+		replicas := 1
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+
+		summary.Deployments = append(
+			summary.Deployments,
+			DeploymentSummary{
+				Namespace:       deployment.Metadata.Namespace,
+				Name:            deployment.Metadata.Name,
+				Replicas:        replicas,
+				ReadyReplicas:   deployment.Status.ReadyReplicas,
+				UpdatedReplicas: deployment.Status.UpdatedReplicas,
+				Ready: deployment.Status.ReadyReplicas >= replicas &&
+					deployment.Status.UpdatedReplicas >= replicas,
+			},
+		)
+	}
+	sort.Slice(summary.Deployments, func(a, b int) bool {
+		if summary.Deployments[a].Namespace != summary.Deployments[b].Namespace {
+			return summary.Deployments[a].Namespace < summary.Deployments[b].Namespace
+		}
+		return summary.Deployments[a].Name < summary.Deployments[b].Name
+	})
+
+	summary.Nodes.Total = len(nodes.Items)
+	for _, node := range nodes.Items {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				summary.Nodes.Ready++
+			}
+		}
+	}
+
+	return summary
+}
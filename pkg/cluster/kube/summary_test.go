@@ -0,0 +1,181 @@
+package kube
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSummaryMode(t *testing.T) {
+	mode, err := ParseSummaryMode("")
+	require.NoError(t, err)
+	assert.Equal(t, SummaryModeFull, mode)
+
+	mode, err = ParseSummaryMode("basic")
+	require.NoError(t, err)
+	assert.Equal(t, SummaryModeBasic, mode)
+
+	_, err = ParseSummaryMode("fancy")
+	assert.Error(t, err)
+}
+
+func TestFormatBasicSummary(t *testing.T) {
+	podsJSON := `{
+  "items": [
+    {"metadata": {"namespace": "kube-system"}, "status": {"phase": "Running"}},
+    {"metadata": {"namespace": "apps"}, "status": {"phase": "Running"}},
+    {"metadata": {"namespace": "apps"}, "status": {"phase": "Pending"}},
+    {"metadata": {"namespace": "apps"}, "status": {"phase": "Failed"}},
+    {"metadata": {"namespace": "apps"}, "status": {}}
+  ]
+}`
+	nodesJSON := `{
+  "items": [
+    {"status": {"conditions": [{"type": "Ready", "status": "True"}]}},
+    {"status": {"conditions": [{"type": "Ready", "status": "False"}]}}
+  ]
+}`
+
+	pods := podList{}
+	require.NoError(t, json.Unmarshal([]byte(podsJSON), &pods))
+	nodes := nodeList{}
+	require.NoError(t, json.Unmarshal([]byte(nodesJSON), &nodes))
+
+	assert.Equal(
+		t,
+		`PODS
+
+NAMESPACE    TOTAL  RUNNING  PENDING  SUCCEEDED  FAILED  UNKNOWN
+apps         4      1        1        0          1       0
+kube-system  1      1        0        0          0       0
+
+NODES
+
+1/2 ready
+`,
+		formatBasicSummary(pods, nodes),
+	)
+}
+
+func TestStructuredSummary(t *testing.T) {
+	podsJSON := `{
+  "items": [
+    {"metadata": {"namespace": "kube-system"}, "status": {"phase": "Running"}},
+    {"metadata": {"namespace": "apps"}, "status": {"phase": "Running"}},
+    {"metadata": {"namespace": "apps"}, "status": {"phase": "Pending"}},
+    {"metadata": {"namespace": "apps"}, "status": {}}
+  ]
+}`
+	deploymentsJSON := `{
+  "items": [
+    {
+      "metadata": {"namespace": "apps", "name": "web"},
+      "spec": {"replicas": 2},
+      "status": {"readyReplicas": 1, "updatedReplicas": 2}
+    },
+    {
+      "metadata": {"namespace": "apps", "name": "api"},
+      "spec": {},
+      "status": {"readyReplicas": 1, "updatedReplicas": 1}
+    }
+  ]
+}`
+	nodesJSON := `{
+  "items": [
+    {"status": {"conditions": [{"type": "Ready", "status": "True"}]}},
+    {"status": {"conditions": [{"type": "Ready", "status": "False"}]}}
+  ]
+}`
+
+	pods := podList{}
+	require.NoError(t, json.Unmarshal([]byte(podsJSON), &pods))
+	deployments := deploymentList{}
+	require.NoError(t, json.Unmarshal([]byte(deploymentsJSON), &deployments))
+	nodes := nodeList{}
+	require.NoError(t, json.Unmarshal([]byte(nodesJSON), &nodes))
+
+	assert.Equal(
+		t,
+		StructuredSummary{
+			Namespaces: []NamespaceSummary{
+				{
+					Namespace: "apps",
+					TotalPods: 3,
+					PodPhases: map[string]int{"Running": 1, "Pending": 1, "Unknown": 1},
+				},
+				{
+					Namespace: "kube-system",
+					TotalPods: 1,
+					PodPhases: map[string]int{"Running": 1},
+				},
+			},
+			Deployments: []DeploymentSummary{
+				{
+					Namespace:       "apps",
+					Name:            "api",
+					Replicas:        1,
+					ReadyReplicas:   1,
+					UpdatedReplicas: 1,
+					Ready:           true,
+				},
+				{
+					Namespace:       "apps",
+					Name:            "web",
+					Replicas:        2,
+					ReadyReplicas:   1,
+					UpdatedReplicas: 2,
+					Ready:           false,
+				},
+			},
+			Nodes: NodesSummary{Total: 2, Ready: 1},
+		},
+		structuredSummary(pods, deployments, nodes),
+	)
+}
+
+func TestUnmarshalKubectlJSON(t *testing.T) {
+	type testCase struct {
+		description string
+		output      string
+		expNodes    int
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "plain JSON",
+			output:      `{"items": [{}, {}]}`,
+			expNodes:    2,
+		},
+		{
+			description: "JSON with warnings",
+			output: "Warning: v1 ComponentStatus is deprecated in v1.19+\n" +
+				"W0101 00:00:00.000000 exec plugin message\n" +
+				`{"items": [{}]}`,
+			expNodes: 1,
+		},
+		{
+			description: "no JSON",
+			output:      "error: the server doesn't have a resource type \"nodes\"",
+			expErr:      true,
+		},
+		{
+			description: "bad JSON",
+			output:      `{"items": [`,
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		nodes := nodeList{}
+		err := unmarshalKubectlJSON([]byte(testCase.output), &nodes)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			require.NoError(t, err, testCase.description)
+			assert.Equal(t, testCase.expNodes, len(nodes.Items), testCase.description)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
@@ -17,29 +16,40 @@ import (
 	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
 	"github.com/segmentio/kubeapply/pkg/store"
+	"github.com/segmentio/kubeapply/pkg/util"
 	log "github.com/sirupsen/logrus"
 )
 
 var _ ClusterClient = (*KubeClusterClient)(nil)
 
 // KubeClusterClient is an implementation of a ClusterClient that hits an actual Kubernetes API.
-// It's backed by a kube.OrderedClient which, in turn, wraps kubectl.
+// It's backed by a kube.Client, which is either a kube.OrderedClient that wraps kubectl or a
+// kube.DynamicClient that uses the kube API directly.
 type KubeClusterClient struct {
 	clusterConfig *config.ClusterConfig
 
 	headSHA               string
 	clusterKey            string
+	pullRequestKey        string
+	applyKey              string
+	lastApplyKey          string
 	lockID                string
+	actor                 string
 	useLocks              bool
 	checkApplyConsistency bool
+	recordDiffs           bool
+	differ                string
 	spinnerObj            *spinner.Spinner
 	streamingOutput       bool
-
-	tempDir        string
-	kubeConfigPath string
-	kubeClient     *kube.OrderedClient
-	kubeLocker     store.Locker
-	kubeStore      store.Store
+	summaryMode           kube.SummaryMode
+
+	kubeConfigPath    string
+	kubeConfigRelease func()
+	kubeClient        kube.Client
+	manifestFilter    kube.ManifestFilter
+	diffOptions       diff.Options
+	kubeLocker        *store.KubeLocker
+	kubeStore         store.Store
 }
 
 // kubeapplyDiffEvent is used for storing the last successful diff in the kubeStore.
@@ -51,6 +61,36 @@ type kubeapplyDiffEvent struct {
 	UpdatedBy string    `json:"updatedBy"`
 }
 
+// kubeapplyApplyEvent is used for storing the last successful apply of a pull request in the
+// kubeStore. This value is checked when resuming applies to skip clusters that were already
+// applied.
+type kubeapplyApplyEvent struct {
+	SHA      string   `json:"sha"`
+	Subpaths []string `json:"subpaths"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy"`
+}
+
+// checkDiffEvent verifies that the diff event stored in storeValue was generated
+// at headSHA. The returned error includes who ran the last diff and when.
+func checkDiffEvent(storeValue string, headSHA string) error {
+	diffEvent := kubeapplyDiffEvent{}
+	if err := json.Unmarshal([]byte(storeValue), &diffEvent); err != nil {
+		return err
+	}
+
+	if diffEvent.SHA != headSHA {
+		return fmt.Errorf(
+			"Last diff was applied at a different SHA (%s) by %s at %s.\nPlease run kubeapply diff again.",
+			diffEvent.SHA,
+			diffEvent.UpdatedBy,
+			diffEvent.UpdatedAt.UTC().Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
 // NewKubeClusterClient creates a new ClusterClient instance for a real
 // Kubernetes cluster.
 func NewKubeClusterClient(
@@ -64,55 +104,127 @@ func NewKubeClusterClient(
 		config.ClusterConfig.Env,
 	)
 
+	lastApplyKey := fmt.Sprintf("%s__lastApply", clusterKey)
+
+	// Diffs used for incremental diffs are scoped to the pull request so that diffs in
+	// other pull requests don't change the base.
+	var pullRequestKey string
+	var applyKey string
+	if config.PullRequestNum > 0 {
+		pullRequestKey = fmt.Sprintf("%s__pr%d", clusterKey, config.PullRequestNum)
+		applyKey = fmt.Sprintf("%s__apply", pullRequestKey)
+	}
+
 	var err error
-	var tempDir string
 	var kubeConfigPath string
+	kubeConfigRelease := func() {}
 
 	if config.ClusterConfig.KubeConfigPath != "" {
 		kubeConfigPath = config.ClusterConfig.KubeConfigPath
 	} else {
-		// Generate a kubeconfig via the EKS API.
-		tempDir, err = ioutil.TempDir("", "kubeconfigs")
-		if err != nil {
-			return nil, err
-		}
-
-		kubeConfigPath = filepath.Join(
-			tempDir,
-			fmt.Sprintf(
-				"kubeconfig_%s_%s.yaml",
-				config.ClusterConfig.Cluster,
-				config.ClusterConfig.Region,
-			),
-		)
-
+		// Generate a kubeconfig via the EKS API. These are cached so that clients for the
+		// same cluster don't each hit the API.
 		sess := session.Must(session.NewSession())
-		err = kube.CreateKubeconfigViaAPI(
+		kubeConfigPath, kubeConfigRelease, err = kube.CachedKubeconfigViaAPI(
 			ctx,
 			sess,
 			config.ClusterConfig.Cluster,
 			config.ClusterConfig.Region,
-			kubeConfigPath,
 		)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	kubeClient := kube.NewOrderedClient(
+	var applyRecord *kube.ApplyRecord
+	if config.ClusterConfig.RecordApply {
+		applyRecord = &kube.ApplyRecord{
+			AppliedBy: config.Actor,
+			SHA:       config.HeadSHA,
+			AppliedAt: time.Now(),
+		}
+	}
+
+	diffOptions := diff.Options{
+		ShowManagedFields: config.ClusterConfig.ShowManagedFields,
+		IgnoreHelmHooks:   config.ClusterConfig.IgnoreHelmHooks(),
+		RedactKeys:        config.ClusterConfig.RedactKeys,
+	}
+	filter := kube.ManifestFilter{
+		Kinds: config.ClusterConfig.KindFilters,
+		Names: config.ClusterConfig.NameFilters,
+
+		ExcludeNamespaces: config.ClusterConfig.ExcludeNamespaces,
+	}
+
+	applyOrderPath := filepath.Join(config.ClusterConfig.ExpandedPath, kube.ApplyOrderFile)
+
+	protectedResources := []kube.ResourceSelector{}
+	for _, protectedResource := range config.ClusterConfig.ProtectedResources {
+		protectedResources = append(
+			protectedResources,
+			kube.ResourceSelector{
+				APIVersion: protectedResource.APIVersion,
+				Kind:       protectedResource.Kind,
+				Namespace:  protectedResource.Namespace,
+				Name:       protectedResource.Name,
+			},
+		)
+	}
+
+	orderedClient := kube.NewOrderedClient(
 		kubeConfigPath,
 		config.KeepConfigs,
 		nil,
 		config.Debug,
+		config.Quiet,
 		config.ClusterConfig.ServerSideApply,
+		config.ClusterConfig.ServerSideApplyKinds,
+		config.ClusterConfig.FieldManager,
+		config.ClusterConfig.KubectlAttempts,
+		config.ClusterConfig.WaveTimeoutDuration(),
+		diffOptions,
+		filter,
+		applyRecord,
+		config.ClusterConfig.OwnershipLabels,
+		applyOrderPath,
+		protectedResources,
 	)
 
+	var kubeClient kube.Client
+
+	switch config.ClusterConfig.KubeBackend {
+	case "", kube.BackendKubectl:
+		kubeClient = orderedClient
+	case kube.BackendClientGo:
+		kubeClient, err = kube.NewDynamicClient(
+			kubeConfigPath,
+			config.KeepConfigs,
+			config.ClusterConfig.FieldManager,
+			diffOptions,
+			filter,
+			applyRecord,
+			config.ClusterConfig.OwnershipLabels,
+			applyOrderPath,
+			protectedResources,
+			orderedClient,
+		)
+		if err != nil {
+			kubeConfigRelease()
+			return nil, err
+		}
+	default:
+		kubeConfigRelease()
+		return nil, fmt.Errorf("Unrecognized kube backend: %s", config.ClusterConfig.KubeBackend)
+	}
+
 	kubeStore, err := store.NewKubeStore(
 		kubeConfigPath,
 		"kubeapply-store",
-		"kube-system",
+		config.ClusterConfig.ManagementNamespace,
 	)
 	if err != nil {
+		kubeConfigRelease()
 		return nil, err
 	}
 
@@ -128,9 +240,10 @@ func NewKubeClusterClient(
 	kubeLocker, err := store.NewKubeLocker(
 		kubeConfigPath,
 		lockID,
-		"kube-system",
+		config.ClusterConfig.ManagementNamespace,
 	)
 	if err != nil {
+		kubeConfigRelease()
 		return nil, err
 	}
 
@@ -139,13 +252,22 @@ func NewKubeClusterClient(
 		headSHA:               config.HeadSHA,
 		useLocks:              config.UseLocks,
 		checkApplyConsistency: config.CheckApplyConsistency,
+		recordDiffs:           config.RecordDiffs,
+		differ:                config.Differ,
 		spinnerObj:            config.SpinnerObj,
 		streamingOutput:       config.StreamingOutput,
+		summaryMode:           config.SummaryMode,
 		clusterKey:            clusterKey,
+		pullRequestKey:        pullRequestKey,
+		applyKey:              applyKey,
+		lastApplyKey:          lastApplyKey,
 		lockID:                lockID,
-		tempDir:               tempDir,
+		actor:                 config.Actor,
 		kubeConfigPath:        kubeConfigPath,
+		kubeConfigRelease:     kubeConfigRelease,
 		kubeClient:            kubeClient,
+		manifestFilter:        filter,
+		diffOptions:           diffOptions,
 		kubeStore:             kubeStore,
 		kubeLocker:            kubeLocker,
 	}, nil
@@ -203,6 +325,36 @@ func (cc *KubeClusterClient) ApplyStructured(
 	return sortedApplyResults(results), nil
 }
 
+// DryRunStructured does a structured kubectl dry-run apply for the resources at the argument
+// paths. Unlike ApplyStructured, it doesn't acquire the cluster lock or check apply consistency
+// since nothing is changed in the cluster.
+func (cc *KubeClusterClient) DryRunStructured(
+	ctx context.Context,
+	paths []string,
+	serverSide bool,
+) ([]apply.Result, error) {
+	contents, err := cc.kubeClient.Apply(ctx, paths, true, "json", true)
+	if err != nil {
+		return nil,
+			fmt.Errorf(
+				"Error running apply dry-run: %+v; output: %s",
+				err,
+				string(contents),
+			)
+	}
+
+	objs, err := apply.KubeJSONToObjects(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := apply.ObjsToResults(objs, objs)
+	if err != nil {
+		return nil, err
+	}
+	return sortedApplyResults(results), nil
+}
+
 // Diff runs a kubectl diff between the configs at the argument path and the associated
 // resources in the cluster. It returns raw output that can be immediately printed to the
 // console.
@@ -211,7 +363,7 @@ func (cc *KubeClusterClient) Diff(
 	paths []string,
 	serverSide bool,
 ) ([]byte, error) {
-	rawResults, err := cc.execDiff(ctx, paths, false, "")
+	rawResults, err := cc.execDiff(ctx, paths, serverSide, false, cc.differ)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"Error running diff: %+v (output: %s)",
@@ -230,16 +382,26 @@ func (cc *KubeClusterClient) DiffStructured(
 	paths []string,
 	serverSide bool,
 	diffCommand string,
-) ([]diff.Result, error) {
-	rawResults, err := cc.execDiff(ctx, paths, true, diffCommand)
+) (diff.Results, error) {
+	rawResults, err := cc.execDiff(ctx, paths, serverSide, true, diffCommand)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return diff.Results{}, fmt.Errorf(
 			"Error running diff: %+v (output: %s)",
 			err,
 			string(rawResults),
 		)
 	}
 
+	results, err := parseDiffResults(rawResults)
+	if err != nil {
+		return diff.Results{}, err
+	}
+	results.Results = sortedDiffResults(results.Results)
+	return results, nil
+}
+
+// parseDiffResults parses the output of a structured diff.
+func parseDiffResults(rawResults []byte) (diff.Results, error) {
 	// Strip everything before the initial "{"; kubectl can insert arbitrary warnings, etc.
 	// that can cause the result to not be valid JSON.
 	jsonStart := bytes.Index(rawResults, []byte("{"))
@@ -249,14 +411,19 @@ func (cc *KubeClusterClient) DiffStructured(
 
 	results := diff.Results{}
 	if err := json.Unmarshal(rawResults, &results); err != nil {
-		return nil, err
+		return diff.Results{}, err
 	}
-	return sortedDiffResults(results.Results), nil
+	return results, nil
 }
 
 // Summary returns a summary of the current cluster state.
 func (cc *KubeClusterClient) Summary(ctx context.Context) (string, error) {
-	return cc.kubeClient.Summary(ctx)
+	return cc.kubeClient.Summary(ctx, cc.summaryMode)
+}
+
+// SummaryStructured returns a machine-readable summary of the current cluster state.
+func (cc *KubeClusterClient) SummaryStructured(ctx context.Context) (kube.StructuredSummary, error) {
+	return cc.kubeClient.SummaryStructured(ctx)
 }
 
 // GetStoreValue gets the value of the argument key.
@@ -269,6 +436,82 @@ func (cc *KubeClusterClient) SetStoreValue(ctx context.Context, key string, valu
 	return cc.kubeStore.Set(ctx, key, value)
 }
 
+// LastDiffSHA returns the git SHA of the last recorded diff for this client's pull request
+// in the cluster or an empty string if no diff has been recorded.
+func (cc *KubeClusterClient) LastDiffSHA(ctx context.Context) (string, error) {
+	if cc.pullRequestKey == "" {
+		return "", nil
+	}
+
+	storeValue, err := cc.GetStoreValue(ctx, cc.pullRequestKey)
+	if err != nil || storeValue == "" {
+		return "", err
+	}
+
+	diffEvent := kubeapplyDiffEvent{}
+	if err := json.Unmarshal([]byte(storeValue), &diffEvent); err != nil {
+		return "", err
+	}
+	return diffEvent.SHA, nil
+}
+
+// AppliedAtHead returns whether this client's subpaths were successfully applied at the head
+// SHA of its pull request.
+func (cc *KubeClusterClient) AppliedAtHead(ctx context.Context) (bool, error) {
+	if cc.applyKey == "" {
+		return false, nil
+	}
+
+	storeValue, err := cc.GetStoreValue(ctx, cc.applyKey)
+	if err != nil {
+		return false, err
+	}
+	return applyEventMatches(storeValue, cc.headSHA, cc.clusterConfig.Subpaths)
+}
+
+// LastApplyTime returns when the last successful apply in the cluster finished.
+func (cc *KubeClusterClient) LastApplyTime(ctx context.Context) (time.Time, error) {
+	storeValue, err := cc.GetStoreValue(ctx, cc.lastApplyKey)
+	if err != nil || storeValue == "" {
+		return time.Time{}, err
+	}
+
+	applyEvent := kubeapplyApplyEvent{}
+	if err := json.Unmarshal([]byte(storeValue), &applyEvent); err != nil {
+		return time.Time{}, err
+	}
+	return applyEvent.UpdatedAt, nil
+}
+
+// applyEventMatches returns whether the apply event stored in storeValue was for the argument
+// SHA and subpaths.
+func applyEventMatches(storeValue string, headSHA string, subpaths []string) (bool, error) {
+	if storeValue == "" || headSHA == "" {
+		return false, nil
+	}
+
+	applyEvent := kubeapplyApplyEvent{}
+	if err := json.Unmarshal([]byte(storeValue), &applyEvent); err != nil {
+		return false, err
+	}
+	if applyEvent.SHA != headSHA || len(applyEvent.Subpaths) != len(subpaths) {
+		return false, nil
+	}
+
+	for s, subpath := range subpaths {
+		if applyEvent.Subpaths[s] != subpath {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Lock returns the state of the cluster's kubeapply lock.
+func (cc *KubeClusterClient) Lock(ctx context.Context) (*store.LockInfo, error) {
+	return cc.kubeLocker.Get(ctx, cc.clusterConfig.Cluster)
+}
+
 // Config returns this client's cluster config.
 func (cc *KubeClusterClient) Config() *config.ClusterConfig {
 	return cc.clusterConfig
@@ -282,14 +525,25 @@ func (cc *KubeClusterClient) GetNamespaceUID(
 	return cc.kubeClient.GetNamespaceUID(ctx, namespace)
 }
 
-// Close closes the client and cleans up all of the associated resources.
+// Close closes the client and cleans up all of the associated resources. Kubeconfigs
+// generated via the EKS API are shared with other clients, so these are only removed
+// after every client using them has been closed.
 func (cc *KubeClusterClient) Close() error {
-	if cc.tempDir != "" {
-		return os.RemoveAll(cc.tempDir)
+	if cc.kubeConfigRelease != nil {
+		cc.kubeConfigRelease()
 	}
 	return nil
 }
 
+// kubeApplyFunc is the signature of the kube client's Apply and Adopt methods.
+type kubeApplyFunc func(
+	ctx context.Context,
+	paths []string,
+	output bool,
+	format string,
+	dryRun bool,
+) ([]byte, error)
+
 func (cc *KubeClusterClient) execApply(
 	ctx context.Context,
 	paths []string,
@@ -324,33 +578,325 @@ func (cc *KubeClusterClient) execApply(
 		if err != nil {
 			return nil, err
 		}
-		diffEvent := kubeapplyDiffEvent{}
-		if err := json.Unmarshal([]byte(storeValue), &diffEvent); err != nil {
+		if err := checkDiffEvent(storeValue, cc.headSHA); err != nil {
 			return nil, err
 		}
-
-		if diffEvent.SHA != cc.headSHA {
-			return nil, fmt.Errorf(
-				"Last diff was applied at a different SHA (%s).\nPlease run kubeapply diff again.",
-				diffEvent.SHA,
-			)
-		}
 	} else {
 		log.Debug("Skipping over apply consistency check")
 	}
 
-	return cc.kubeClient.Apply(
+	applyFunc := cc.kubeClient.Apply
+	if cc.clusterConfig.AdoptExisting {
+		applyFunc = cc.kubeClient.Adopt
+	}
+
+	var output []byte
+	var err error
+
+	if dryRun {
+		output, err = cc.dryRunApply(ctx, paths, format, applyFunc)
+	} else {
+		if err := cc.createNamespaces(ctx, paths); err != nil {
+			return nil, err
+		}
+		output, err = applyFunc(
+			ctx,
+			paths,
+			!cc.streamingOutput,
+			format,
+			false,
+		)
+	}
+	if err != nil || dryRun {
+		return output, err
+	}
+
+	if cc.clusterConfig.RolloutTimeout > 0 {
+		err := cc.kubeClient.WaitForRollout(
+			ctx,
+			paths,
+			cc.clusterConfig.RolloutTimeout,
+		)
+		if err != nil {
+			return output, err
+		}
+	}
+
+	// The apply itself succeeded, so don't fail if it can't be recorded; at worst, the
+	// cluster is re-applied when resuming.
+	if err := cc.recordApply(ctx); err != nil {
+		log.Warnf("Error recording apply for %s: %+v", cc.clusterConfig.Cluster, err)
+	}
+
+	return output, nil
+}
+
+// recordApply records a successful apply of this client's pull request so that it can be
+// skipped when resuming applies. If the cluster has a MinApplyInterval, the apply is also
+// recorded as the last one in the cluster.
+func (cc *KubeClusterClient) recordApply(ctx context.Context) error {
+	keys := []string{}
+	if cc.applyKey != "" {
+		keys = append(keys, cc.applyKey)
+	}
+	if cc.clusterConfig.MinApplyInterval != "" {
+		keys = append(keys, cc.lastApplyKey)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	updatedBy := cc.actor
+	if updatedBy == "" {
+		updatedBy = cc.lockID
+	}
+
+	applyEvent := kubeapplyApplyEvent{
+		SHA:       cc.headSHA,
+		Subpaths:  cc.clusterConfig.Subpaths,
+		UpdatedAt: time.Now(),
+		UpdatedBy: updatedBy,
+	}
+	applyEventBytes, err := json.Marshal(applyEvent)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		log.Infof("Setting store key value: %s, %s", key, string(applyEventBytes))
+		if err := cc.kubeStore.Set(ctx, key, string(applyEventBytes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createNamespaces creates the namespaces referenced by the manifests in the argument paths
+// if CreateNamespaces is set in the cluster config.
+func (cc *KubeClusterClient) createNamespaces(ctx context.Context, paths []string) error {
+	if !cc.clusterConfig.CreateNamespaces {
+		return nil
+	}
+
+	manifests, err := kube.GetManifests(paths)
+	if err != nil {
+		return err
+	}
+
+	manifests = kube.FilterManifests(manifests, cc.manifestFilter)
+
+	for _, namespace := range kube.ManifestNamespaces(manifests) {
+		if err := cc.kubeClient.CreateNamespace(ctx, namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dryRunApply runs a dry-run of the argument apply function for the argument paths. If
+// CreateNamespaces is set, then some namespaces might not exist until the real apply creates
+// them, and kubectl can't dry-run the resources in these. These resources are left out of the
+// dry-run and added to its JSON output as new objects instead; the namespaces aren't created.
+func (cc *KubeClusterClient) dryRunApply(
+	ctx context.Context,
+	paths []string,
+	format string,
+	applyFunc kubeApplyFunc,
+) ([]byte, error) {
+	if !cc.clusterConfig.CreateNamespaces || format != "json" {
+		return applyFunc(ctx, paths, !cc.streamingOutput, format, true)
+	}
+
+	existingManifests, newManifests, err := cc.splitMissingNamespaces(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(newManifests) == 0 {
+		return applyFunc(ctx, paths, !cc.streamingOutput, format, true)
+	}
+
+	objs := []apply.TypedKubeObj{}
+
+	if len(existingManifests) > 0 {
+		tempDir, err := util.TempDir("dry-run")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tempDir)
+
+		if err := kube.WriteManifests(tempDir, existingManifests); err != nil {
+			return nil, err
+		}
+		output, err := applyFunc(ctx, []string{tempDir}, !cc.streamingOutput, format, true)
+		if err != nil {
+			return output, err
+		}
+		existingObjs, err := apply.KubeJSONToObjects(output)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, existingObjs...)
+	}
+
+	log.Infof(
+		"Adding %d resources in namespaces that don't exist yet to the dry-run as new",
+		len(newManifests),
+	)
+	for _, manifest := range newManifests {
+		objs = append(
+			objs,
+			apply.TypedKubeObj{
+				APIVersion: manifest.Head.Version,
+				Kind:       manifest.Head.Kind,
+				KubeMetadata: apply.KubeMetadata{
+					Name:      manifest.Head.Metadata.Name,
+					Namespace: manifest.Head.Metadata.Namespace,
+				},
+			},
+		)
+	}
+
+	return json.Marshal(
+		apply.TypedKubeObj{
+			APIVersion: "v1",
+			Kind:       "List",
+			Items:      objs,
+		},
+	)
+}
+
+// splitMissingNamespaces splits the manifests in the argument paths into the ones in
+// namespaces that exist and the ones in namespaces that don't exist yet. The latter are
+// labelled with the ownership labels that are added in applies.
+func (cc *KubeClusterClient) splitMissingNamespaces(
+	ctx context.Context,
+	paths []string,
+) ([]kube.Manifest, []kube.Manifest, error) {
+	manifests, err := kube.GetManifests(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifests = kube.FilterManifests(manifests, cc.manifestFilter)
+
+	missingNamespaces := map[string]struct{}{}
+	for _, namespace := range kube.ManifestNamespaces(manifests) {
+		exists, err := cc.kubeClient.NamespaceExists(ctx, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !exists {
+			missingNamespaces[namespace] = struct{}{}
+		}
+	}
+
+	existingManifests := []kube.Manifest{}
+	newManifests := []kube.Manifest{}
+
+	for _, manifest := range manifests {
+		if manifest.Head.Metadata != nil {
+			if _, ok := missingNamespaces[manifest.Head.Metadata.Namespace]; ok {
+				err := kube.LabelManifest(&manifest, cc.clusterConfig.OwnershipLabels)
+				if err != nil {
+					return nil, nil, err
+				}
+				newManifests = append(newManifests, manifest)
+				continue
+			}
+		}
+		existingManifests = append(existingManifests, manifest)
+	}
+
+	return existingManifests, newManifests, nil
+}
+
+// diff runs the kube client's diff for the argument paths. If CreateNamespaces is set, then
+// some namespaces might not exist until the apply creates them, and the kube clients can't
+// diff the resources in these against the cluster. These resources are diffed as if they're
+// all new instead; the namespaces aren't created.
+func (cc *KubeClusterClient) diff(
+	ctx context.Context,
+	paths []string,
+	serverSide bool,
+	structured bool,
+	diffCommand string,
+) ([]byte, error) {
+	if !cc.clusterConfig.CreateNamespaces {
+		return cc.kubeClient.Diff(ctx, paths, serverSide, structured, diffCommand, cc.spinnerObj)
+	}
+
+	existingManifests, newManifests, err := cc.splitMissingNamespaces(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(newManifests) == 0 {
+		return cc.kubeClient.Diff(ctx, paths, serverSide, structured, diffCommand, cc.spinnerObj)
+	}
+
+	log.Infof(
+		"Diffing %d resources in namespaces that don't exist yet as new",
+		len(newManifests),
+	)
+	newResult, err := kube.DiffNewManifests(
+		ctx,
+		newManifests,
+		structured,
+		diffCommand,
+		cc.diffOptions,
+	)
+	if err != nil || len(existingManifests) == 0 {
+		return newResult, err
+	}
+
+	tempDir, err := util.TempDir("diff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := kube.WriteManifests(tempDir, existingManifests); err != nil {
+		return nil, err
+	}
+	existingResult, err := cc.kubeClient.Diff(
 		ctx,
-		paths,
-		!cc.streamingOutput,
-		format,
-		dryRun,
+		[]string{tempDir},
+		serverSide,
+		structured,
+		diffCommand,
+		cc.spinnerObj,
 	)
+	if err != nil {
+		return existingResult, err
+	}
+
+	return mergeDiffOutputs(existingResult, newResult, structured)
+}
+
+// mergeDiffOutputs merges the outputs of two kube client diffs into a single one.
+func mergeDiffOutputs(output1 []byte, output2 []byte, structured bool) ([]byte, error) {
+	if !structured {
+		return append(output1, output2...), nil
+	}
+
+	merged := diff.Results{
+		Results: []diff.Result{},
+	}
+
+	for _, output := range [][]byte{output1, output2} {
+		results, err := parseDiffResults(output)
+		if err != nil {
+			return nil, err
+		}
+		merged.Results = append(merged.Results, results.Results...)
+		merged.HiddenHelmHooks += results.HiddenHelmHooks
+	}
+
+	return json.Marshal(merged)
 }
 
 func (cc *KubeClusterClient) execDiff(
 	ctx context.Context,
 	paths []string,
+	serverSide bool,
 	structured bool,
 	diffCommand string,
 ) ([]byte, error) {
@@ -376,21 +922,23 @@ func (cc *KubeClusterClient) execDiff(
 		log.Debug("Skipping over locking")
 	}
 
-	diffResult, err := cc.kubeClient.Diff(
-		ctx,
-		paths,
-		structured,
-		diffCommand,
-		cc.spinnerObj,
-	)
-	if err != nil || !cc.checkApplyConsistency {
+	diffResult, err := cc.diff(ctx, paths, serverSide, structured, diffCommand)
+	recordPullRequest := cc.recordDiffs && cc.pullRequestKey != ""
+	if err != nil || !(cc.checkApplyConsistency || recordPullRequest) {
 		return diffResult, err
 	}
 
+	// Prefer the user who triggered the diff so that consistency errors can point at them;
+	// fall back to the lock ID if the user isn't known.
+	updatedBy := cc.actor
+	if updatedBy == "" {
+		updatedBy = cc.lockID
+	}
+
 	diffEvent := kubeapplyDiffEvent{
 		SHA:       cc.headSHA,
 		UpdatedAt: time.Now(),
-		UpdatedBy: cc.lockID,
+		UpdatedBy: updatedBy,
 	}
 	diffEventBytes, err := json.Marshal(diffEvent)
 	if err != nil {
@@ -398,6 +946,19 @@ func (cc *KubeClusterClient) execDiff(
 	}
 	diffEventStr := string(diffEventBytes)
 
-	log.Infof("Setting store key value: %s, %s", cc.clusterKey, diffEventStr)
-	return diffResult, cc.kubeStore.Set(ctx, cc.clusterKey, diffEventStr)
+	keys := []string{}
+	if cc.checkApplyConsistency {
+		keys = append(keys, cc.clusterKey)
+	}
+	if recordPullRequest {
+		keys = append(keys, cc.pullRequestKey)
+	}
+
+	for _, key := range keys {
+		log.Infof("Setting store key value: %s, %s", key, diffEventStr)
+		if err := cc.kubeStore.Set(ctx, key, diffEventStr); err != nil {
+			return diffResult, err
+		}
+	}
+	return diffResult, nil
 }
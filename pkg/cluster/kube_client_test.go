@@ -0,0 +1,506 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/segmentio/kubeapply/pkg/cluster/apply"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
+	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/store"
+	"github.com/segmentio/kubeapply/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDiffEvent(t *testing.T) {
+	type testCase struct {
+		description string
+		storeValue  string
+		headSHA     string
+		expErr      string
+	}
+
+	testCases := []testCase{
+		{
+			description: "matching SHA",
+			storeValue:  `{"sha":"abc123","updatedAt":"2021-03-04T05:06:07Z","updatedBy":"octocat"}`,
+			headSHA:     "abc123",
+		},
+		{
+			description: "different SHA",
+			storeValue:  `{"sha":"abc123","updatedAt":"2021-03-04T05:06:07-08:00","updatedBy":"octocat"}`,
+			headSHA:     "def456",
+			expErr:      "Last diff was applied at a different SHA (abc123) by octocat at 2021-03-04T13:06:07Z.\nPlease run kubeapply diff again.",
+		},
+		{
+			description: "bad JSON",
+			storeValue:  `not json`,
+			headSHA:     "abc123",
+			expErr:      "invalid character",
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := checkDiffEvent(testCase.storeValue, testCase.headSHA)
+		if testCase.expErr == "" {
+			require.NoError(t, err, testCase.description)
+		} else {
+			require.Error(t, err, testCase.description)
+			assert.Contains(t, err.Error(), testCase.expErr, testCase.description)
+		}
+	}
+}
+
+type fakeKubeClient struct {
+	applyErr        error
+	adopted         bool
+	rolloutErr      error
+	rolloutTimeouts []time.Duration
+
+	// jsonOutput sets whether JSON applies return objects for the applied manifests.
+	jsonOutput bool
+
+	namespaces        map[string]struct{}
+	createdNamespaces []string
+	appliedPaths      [][]string
+	diffedPaths       [][]string
+}
+
+var _ kube.Client = (*fakeKubeClient)(nil)
+
+func (f *fakeKubeClient) Apply(
+	ctx context.Context,
+	applyPaths []string,
+	output bool,
+	format string,
+	dryRun bool,
+) ([]byte, error) {
+	f.appliedPaths = append(f.appliedPaths, applyPaths)
+	if f.jsonOutput && format == "json" && f.applyErr == nil {
+		return fakeApplyJSON(applyPaths, dryRun)
+	}
+	return []byte("applied"), f.applyErr
+}
+
+// fakeApplyJSON returns the JSON output of applying the manifests in the argument paths. The
+// resource version of each object is bumped by the apply unless it's a dry-run.
+func fakeApplyJSON(applyPaths []string, dryRun bool) ([]byte, error) {
+	manifests, err := kube.GetManifests(applyPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceVersion := "2"
+	if dryRun {
+		resourceVersion = "1"
+	}
+
+	objs := []apply.TypedKubeObj{}
+	for _, manifest := range manifests {
+		objs = append(
+			objs,
+			apply.TypedKubeObj{
+				Kind: manifest.Head.Kind,
+				KubeMetadata: apply.KubeMetadata{
+					Name:            manifest.Head.Metadata.Name,
+					Namespace:       manifest.Head.Metadata.Namespace,
+					ResourceVersion: resourceVersion,
+				},
+			},
+		)
+	}
+
+	return json.Marshal(apply.TypedKubeObj{Kind: "List", Items: objs})
+}
+
+func (f *fakeKubeClient) Adopt(
+	ctx context.Context,
+	paths []string,
+	output bool,
+	format string,
+	dryRun bool,
+) ([]byte, error) {
+	f.adopted = true
+	return []byte("adopted"), f.applyErr
+}
+
+func (f *fakeKubeClient) Diff(
+	ctx context.Context,
+	configPaths []string,
+	serverSide bool,
+	structured bool,
+	diffCommand string,
+	spinner *spinner.Spinner,
+) ([]byte, error) {
+	f.diffedPaths = append(f.diffedPaths, configPaths)
+	if structured {
+		return []byte(`{"results":[]}`), nil
+	}
+	return nil, nil
+}
+
+func (f *fakeKubeClient) Summary(ctx context.Context, mode kube.SummaryMode) (string, error) {
+	return "", nil
+}
+
+func (f *fakeKubeClient) SummaryStructured(ctx context.Context) (kube.StructuredSummary, error) {
+	return kube.StructuredSummary{}, nil
+}
+
+func (f *fakeKubeClient) GetNamespaceUID(ctx context.Context, namespace string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeKubeClient) NamespaceExists(ctx context.Context, namespace string) (bool, error) {
+	_, ok := f.namespaces[namespace]
+	return ok, nil
+}
+
+func (f *fakeKubeClient) CreateNamespace(ctx context.Context, namespace string) error {
+	f.createdNamespaces = append(f.createdNamespaces, namespace)
+	return nil
+}
+
+func (f *fakeKubeClient) WaitForRollout(
+	ctx context.Context,
+	paths []string,
+	timeout time.Duration,
+) error {
+	f.rolloutTimeouts = append(f.rolloutTimeouts, timeout)
+	return f.rolloutErr
+}
+
+func TestExecApplyWaitForRollout(t *testing.T) {
+	type testCase struct {
+		description    string
+		rolloutTimeout time.Duration
+		dryRun         bool
+		applyErr       error
+		rolloutErr     error
+		expRollouts    []time.Duration
+		expErr         string
+	}
+
+	testCases := []testCase{
+		{
+			description: "no rollout timeout",
+		},
+		{
+			description:    "rollout succeeds",
+			rolloutTimeout: time.Minute,
+			expRollouts:    []time.Duration{time.Minute},
+		},
+		{
+			description:    "rollout times out",
+			rolloutTimeout: time.Minute,
+			rolloutErr:     errors.New("Timed out waiting for rollout of deployment/test"),
+			expRollouts:    []time.Duration{time.Minute},
+			expErr:         "Timed out waiting for rollout of deployment/test",
+		},
+		{
+			description:    "dry run",
+			rolloutTimeout: time.Minute,
+			dryRun:         true,
+		},
+		{
+			description:    "apply fails",
+			rolloutTimeout: time.Minute,
+			applyErr:       errors.New("apply failed"),
+			expErr:         "apply failed",
+		},
+	}
+
+	ctx := context.Background()
+
+	for _, testCase := range testCases {
+		kubeClient := &fakeKubeClient{
+			applyErr:   testCase.applyErr,
+			rolloutErr: testCase.rolloutErr,
+		}
+		cc := &KubeClusterClient{
+			clusterConfig: &config.ClusterConfig{
+				RolloutTimeout: testCase.rolloutTimeout,
+			},
+			kubeClient: kubeClient,
+		}
+
+		output, err := cc.execApply(ctx, []string{"test-path"}, "", testCase.dryRun)
+		if testCase.expErr == "" {
+			require.NoError(t, err, testCase.description)
+		} else {
+			require.Error(t, err, testCase.description)
+			assert.Contains(t, err.Error(), testCase.expErr, testCase.description)
+		}
+		assert.Equal(t, "applied", string(output), testCase.description)
+		assert.Equal(
+			t,
+			testCase.expRollouts,
+			kubeClient.rolloutTimeouts,
+			testCase.description,
+		)
+	}
+}
+
+func TestExecApplyAdopt(t *testing.T) {
+	ctx := context.Background()
+
+	for _, adopt := range []bool{false, true} {
+		kubeClient := &fakeKubeClient{}
+		cc := &KubeClusterClient{
+			clusterConfig: &config.ClusterConfig{
+				AdoptExisting: adopt,
+			},
+			kubeClient: kubeClient,
+		}
+
+		output, err := cc.execApply(ctx, []string{"test-path"}, "json", false)
+		require.NoError(t, err)
+		assert.Equal(t, adopt, kubeClient.adopted)
+		if adopt {
+			assert.Equal(t, "adopted", string(output))
+		} else {
+			assert.Equal(t, "applied", string(output))
+		}
+	}
+}
+
+func TestExecDiffRecordDiffs(t *testing.T) {
+	type testCase struct {
+		description           string
+		checkApplyConsistency bool
+		recordDiffs           bool
+		pullRequestKey        string
+		expClusterSHA         string
+		expLastDiffSHA        string
+	}
+
+	testCases := []testCase{
+		{
+			description: "no recording",
+		},
+		{
+			description:           "apply consistency only",
+			checkApplyConsistency: true,
+			expClusterSHA:         "test-sha",
+		},
+		{
+			description:    "record diffs for pull request",
+			recordDiffs:    true,
+			pullRequestKey: "test-cluster__pr123",
+			expLastDiffSHA: "test-sha",
+		},
+		{
+			description: "record diffs without pull request",
+			recordDiffs: true,
+		},
+		{
+			description:           "apply consistency and record diffs",
+			checkApplyConsistency: true,
+			recordDiffs:           true,
+			pullRequestKey:        "test-cluster__pr123",
+			expClusterSHA:         "test-sha",
+			expLastDiffSHA:        "test-sha",
+		},
+	}
+
+	ctx := context.Background()
+
+	for _, testCase := range testCases {
+		kubeStore := store.NewInMemoryStore()
+		cc := &KubeClusterClient{
+			clusterConfig:         &config.ClusterConfig{},
+			headSHA:               "test-sha",
+			clusterKey:            "test-cluster",
+			pullRequestKey:        testCase.pullRequestKey,
+			checkApplyConsistency: testCase.checkApplyConsistency,
+			recordDiffs:           testCase.recordDiffs,
+			kubeClient:            &fakeKubeClient{},
+			kubeStore:             kubeStore,
+		}
+
+		_, err := cc.execDiff(ctx, []string{"test-path"}, false, false, "")
+		require.NoError(t, err, testCase.description)
+
+		clusterValue, err := kubeStore.Get(ctx, "test-cluster")
+		require.NoError(t, err, testCase.description)
+		if testCase.expClusterSHA == "" {
+			assert.Equal(t, "", clusterValue, testCase.description)
+		} else {
+			assert.Nil(t, checkDiffEvent(clusterValue, testCase.expClusterSHA))
+		}
+
+		lastDiffSHA, err := cc.LastDiffSHA(ctx)
+		require.NoError(t, err, testCase.description)
+		assert.Equal(t, testCase.expLastDiffSHA, lastDiffSHA, testCase.description)
+	}
+}
+
+func TestCreateNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "manifests")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"manifests.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: existing-config
+  namespace: existing
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new-config
+  namespace: new
+`,
+		},
+	)
+
+	kubeClient := &fakeKubeClient{
+		jsonOutput: true,
+		namespaces: map[string]struct{}{
+			"existing": {},
+		},
+	}
+	cc := &KubeClusterClient{
+		clusterConfig: &config.ClusterConfig{
+			CreateNamespaces: true,
+		},
+		kubeClient: kubeClient,
+	}
+
+	// Diffs don't create namespaces; the resources in the missing ones are shown as new
+	results, err := cc.DiffStructured(ctx, []string{tempDir}, false, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(results.Results))
+	assert.Equal(t, "v1.ConfigMap.new.new-config", results.Results[0].Name)
+	assert.Equal(t, 1, len(kubeClient.diffedPaths))
+	assert.NotEqual(t, []string{tempDir}, kubeClient.diffedPaths[0])
+
+	// Dry-runs don't create namespaces either; the resources in the missing ones are left out
+	// of the kubectl dry-run and added to its output as new
+	output, err := cc.execApply(ctx, []string{tempDir}, "json", true)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(kubeClient.createdNamespaces))
+	require.Equal(t, 1, len(kubeClient.appliedPaths))
+	assert.NotEqual(t, []string{tempDir}, kubeClient.appliedPaths[0])
+	objs, err := apply.KubeJSONToObjects(output)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(objs))
+	assert.Equal(t, "existing-config", objs[0].Name)
+	assert.Equal(t, "1", objs[0].ResourceVersion)
+	assert.Equal(t, "new-config", objs[1].Name)
+	assert.Equal(t, "", objs[1].ResourceVersion)
+
+	// Rejected applies don't create namespaces
+	kubeStore := store.NewInMemoryStore()
+	require.NoError(
+		t,
+		kubeStore.Set(ctx, "test-cluster", `{"sha":"other-sha","updatedBy":"octocat"}`),
+	)
+	cc.headSHA = "test-sha"
+	cc.clusterKey = "test-cluster"
+	cc.checkApplyConsistency = true
+	cc.kubeStore = kubeStore
+
+	_, err = cc.ApplyStructured(ctx, []string{tempDir}, false)
+	require.Error(t, err)
+	assert.Equal(t, 0, len(kubeClient.createdNamespaces))
+
+	// Real applies create the namespaces once, after the dry-run
+	require.NoError(
+		t,
+		kubeStore.Set(ctx, "test-cluster", `{"sha":"test-sha","updatedBy":"octocat"}`),
+	)
+	kubeClient.appliedPaths = nil
+
+	applyResults, err := cc.ApplyStructured(ctx, []string{tempDir}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"existing", "new"}, kubeClient.createdNamespaces)
+	assert.Equal(
+		t,
+		[][]string{{tempDir}},
+		kubeClient.appliedPaths[1:],
+	)
+	require.Equal(t, 2, len(applyResults))
+	assert.Equal(t, "existing-config", applyResults[0].Name)
+	assert.Equal(t, "1", applyResults[0].OldVersion)
+	assert.Equal(t, "2", applyResults[0].NewVersion)
+	assert.Equal(t, "new-config", applyResults[1].Name)
+	assert.True(t, applyResults[1].IsCreated())
+}
+
+func TestApplyEventMatches(t *testing.T) {
+	type testCase struct {
+		description string
+		storeValue  string
+		headSHA     string
+		subpaths    []string
+		expMatches  bool
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "no recorded apply",
+			storeValue:  "",
+			headSHA:     "sha1",
+			expMatches:  false,
+		},
+		{
+			description: "same SHA and subpaths",
+			storeValue:  `{"sha":"sha1","subpaths":["a","b"]}`,
+			headSHA:     "sha1",
+			subpaths:    []string{"a", "b"},
+			expMatches:  true,
+		},
+		{
+			description: "different SHA",
+			storeValue:  `{"sha":"sha1","subpaths":["a","b"]}`,
+			headSHA:     "sha2",
+			subpaths:    []string{"a", "b"},
+			expMatches:  false,
+		},
+		{
+			description: "different subpaths",
+			storeValue:  `{"sha":"sha1","subpaths":["a"]}`,
+			headSHA:     "sha1",
+			subpaths:    []string{"a", "b"},
+			expMatches:  false,
+		},
+		{
+			description: "bad store value",
+			storeValue:  "not json",
+			headSHA:     "sha1",
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		matches, err := applyEventMatches(
+			testCase.storeValue,
+			testCase.headSHA,
+			testCase.subpaths,
+		)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			require.NoError(t, err, testCase.description)
+			assert.Equal(t, testCase.expMatches, matches, testCase.description)
+		}
+	}
+}
@@ -4,14 +4,21 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/ghodss/yaml"
 	log "github.com/sirupsen/logrus"
 )
 
+// DefaultManagementNamespace is the namespace that kubeapply's locks and stored state are kept
+// in if ManagementNamespace isn't set.
+const DefaultManagementNamespace = "kube-system"
+
 // ClusterConfig represents the configuration for a single Kubernetes cluster in a single
 // region and environment / account.
 type ClusterConfig struct {
@@ -49,6 +56,21 @@ type ClusterConfig struct {
 	// URLs.
 	Charts string `json:"charts"`
 
+	// HelmGlobalValuesOverride indicates whether the global values generated from this config
+	// should override the values in each helm values file, instead of the other way around.
+	// This was the default in older kubeapply versions and is kept only for backwards
+	// compatibility.
+	//
+	// Optional, defaults to false.
+	HelmGlobalValuesOverride bool `json:"helmGlobalValuesOverride"`
+
+	// HelmParallelism is the number of helm processes that should be run in parallel when
+	// expanding the charts for this cluster. This can be lowered for clusters with charts
+	// that use a lot of memory.
+	//
+	// Optional, defaults to the value set on the command-line.
+	HelmParallelism int `json:"helmParallelism"`
+
 	// ProfilePath is the path to the profile directory for this cluster.
 	//
 	// Optional, defaults to "profile" if not set.
@@ -66,22 +88,90 @@ type ClusterConfig struct {
 	// Optional, defaults to "expanded/[env]/[region]" if not set.
 	ExpandedPath string `json:"expandedPath"`
 
+	// ExcludeNamespaces is a list of namespaces whose resources should be skipped in diffs and
+	// applies, e.g. because they're managed by another tool. Resources without an explicit
+	// namespace, including cluster-scoped ones, are never skipped.
+	//
+	// Optional.
+	ExcludeNamespaces []string `json:"excludeNamespaces"`
+
 	// Parameters are key/value pairs to be used for go templating.
 	//
 	// Optional.
 	Parameters map[string]interface{} `json:"parameters"`
 
+	// ParametersSchema is the path to a JSON schema that the parameters, including any
+	// profile-specific overrides, are validated against before expanding. Relative paths are
+	// interpreted relative to the directory of this config.
+	//
+	// Optional, defaults to no validation.
+	ParametersSchema string `json:"parametersSchema"`
+
+	// HelmGlobalParameters are the keys of parameters that should also be passed to every
+	// Helm chart in this cluster under global, e.g. global.accountID. These are added
+	// alongside the global.cluster, global.region, and global.shortRegion values, which
+	// always take precedence. Use "*" to pass all parameters.
+	//
+	// Optional, defaults to no parameters.
+	HelmGlobalParameters []string `json:"helmGlobalParameters"`
+
+	// StarlarkModulePaths are directories that are searched, in order, when resolving
+	// starlark loads that use the module:// scheme. This allows helper modules to be shared
+	// between clusters. Relative paths are interpreted relative to the directory of this
+	// config.
+	//
+	// Optional.
+	StarlarkModulePaths []string `json:"starlarkModulePaths"`
+
 	// GithubIgnore indicates whether kubeapply-lambda webhooks should ignore this cluster.
 	//
 	// Optional, defaults to false.
 	GithubIgnore bool `json:"ignore"`
 
+	// ApplyChangedOnly indicates that webhook diffs and applies in this cluster should only
+	// cover the expanded manifest files that were changed in the pull request, instead of
+	// all of the files in the changed subpaths. This minimizes the blast radius of applies in
+	// sensitive clusters, but the manifests that are applied no longer include any unchanged
+	// dependencies, so it should be used with care.
+	//
+	// Optional, defaults to false, and only applicable to webhooks mode.
+	GithubApplyChangedOnly bool `json:"applyChangedOnly"`
+
 	// ReviewOptional indicates that reviews should not be required for changes in this
 	// cluster even if strict mode is on.
 	//
 	// Optional, and only applicable to webhooks mode.
 	GithubReviewOptional bool `json:"reviewOptional"`
 
+	// ApplyTeams are the Github teams (e.g., "infra-admins" or "segmentio/infra-admins") whose
+	// members are allowed to apply in this cluster via the webhooks. Applies requested by
+	// anyone else are refused, even if the pull request is approved.
+	//
+	// Optional, and only applicable to webhooks mode. Defaults to allowing everyone.
+	ApplyTeams []string `json:"applyTeams"`
+
+	// DisableApply makes this cluster "observe only": diffs and statuses still work, but
+	// applies are refused, both in the webhooks (even via explicit commands) and in the
+	// kubeapply CLI.
+	//
+	// Optional, defaults to false.
+	DisableApply bool `json:"disableApply"`
+
+	// MinApplyInterval is the minimum time between applies in this cluster, in Go duration
+	// format (e.g., "10m"). Applies requested via the webhooks within this interval of the last
+	// one are refused unless they're forced. This guards busy or sensitive clusters against
+	// accidental repeated applies.
+	//
+	// Optional, and only applicable to webhooks mode. Defaults to no minimum interval.
+	MinApplyInterval string `json:"minApplyInterval"`
+
+	// ProtectedResources are resources that kubeapply should never modify, e.g. the cluster's
+	// CNI or critical configmaps in kube-system. Applies that include any matching resources
+	// are refused, even if the resources are in the expanded configs.
+	//
+	// Optional, defaults to no protected resources.
+	ProtectedResources []ProtectedResource `json:"protectedResources"`
+
 	// VersionConstraint is a string version constraint against with the kubeapply binary
 	// will be checked. See https://github.com/Masterminds/semver for details on the expected
 	// format.
@@ -89,6 +179,14 @@ type ClusterConfig struct {
 	// Optional, defaults to no check.
 	VersionConstraint string `json:"versionConstraint"`
 
+	// VersionAllowPrereleases indicates whether pre-release kubeapply builds (e.g., 1.4.0-rc.2)
+	// should be checked against VersionConstraint as if they were the underlying release
+	// (e.g., 1.4.0). Without this, pre-releases only satisfy constraints that themselves
+	// contain a pre-release.
+	//
+	// Optional, defaults to false.
+	VersionAllowPrereleases bool `json:"versionAllowPrereleases"`
+
 	// KubeConfigPath is the path to a kubeconfig that can be used with this cluster.
 	//
 	// Optional, defaults to value set on command-line (when running kubeapply manually) or
@@ -99,9 +197,131 @@ type ClusterConfig struct {
 	// cluster.
 	ServerSideApply bool `json:"serverSideApply"`
 
+	// ServerSideApplyKinds is a list of kinds (e.g., "CustomResourceDefinition") that are
+	// always applied server-side, even if ServerSideApply isn't set. This is useful for large
+	// resources that would otherwise exceed the size limit for the last-applied-configuration
+	// annotation. Only used with the kubectl backend.
+	//
+	// Optional, defaults to applying everything the same way.
+	ServerSideApplyKinds []string `json:"serverSideApplyKinds"`
+
+	// ServerSideDiff sets whether diffs should be done server-side, regardless of the value of
+	// ServerSideApply. Server-side diffs include the effects of defaulting and mutating
+	// admission webhooks.
+	//
+	// Optional, defaults to the value of ServerSideApply.
+	ServerSideDiff bool `json:"serverSideDiff"`
+
+	// FieldManager is the name of the field manager used for server-side applies and diffs.
+	// Setting this makes it clear which fields are owned by kubeapply as opposed to other
+	// controllers.
+	//
+	// Optional, defaults to "kubeapply".
+	FieldManager string `json:"fieldManager"`
+
+	// KubectlAttempts is the maximum number of times that each kubectl command is tried when
+	// it fails with a transient error like a refused connection or API server throttling.
+	// Other errors, e.g. from invalid configs, are never retried.
+	//
+	// Optional, defaults to 3.
+	KubectlAttempts int `json:"kubectlAttempts"`
+
+	// WaveTimeout is how long to wait for the workloads in each apply wave (set via the
+	// kubeapply.segment.io/wave annotation) to finish rolling out before applying the next
+	// wave, in Go duration format (e.g., "5m").
+	//
+	// Optional, defaults to not waiting between waves.
+	WaveTimeout string `json:"waveTimeout"`
+
+	// KubeBackend is the backend used for diffs and applies in this cluster. Valid values are
+	// "kubectl", which shells out to kubectl for each operation, and "client-go", which does
+	// server-side diffs and applies via the kube API directly. The latter requires
+	// ServerSideApply and doesn't support KubectlAttempts or WaveTimeout.
+	//
+	// Optional, defaults to "kubectl".
+	KubeBackend string `json:"kubeBackend"`
+
+	// ManagementNamespace is the namespace that kubeapply's locks and stored state (e.g.,
+	// recorded diffs and applies) are kept in. It must already exist in the cluster.
+	//
+	// Optional, defaults to "kube-system".
+	ManagementNamespace string `json:"managementNamespace"`
+
+	// CommonLabels are labels (e.g., {"team": "infra"}) that are added to every resource when
+	// the cluster configs are expanded. Labels that are already set on a resource take
+	// precedence.
+	//
+	// Optional, defaults to not adding any labels.
+	CommonLabels map[string]string `json:"commonLabels"`
+
+	// CommonAnnotations are annotations that are added to every resource when the cluster
+	// configs are expanded. Annotations that are already set on a resource take precedence.
+	//
+	// Optional, defaults to not adding any annotations.
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+
+	// OwnershipLabels are labels (e.g., {"app.kubernetes.io/managed-by": "kubeapply-cluster1"})
+	// that are added to every resource applied in this cluster, so that the resources owned
+	// by kubeapply can be reliably identified via OwnershipSelector. The labels are also added
+	// to the configs being diffed so that they don't show up as removals.
+	//
+	// Optional, defaults to not adding any labels.
+	OwnershipLabels map[string]string `json:"ownershipLabels"`
+
+	// RecordApply sets whether resources should be annotated with who applied them, the
+	// git SHA of the configs, and the time of the apply.
+	//
+	// Optional, defaults to false.
+	RecordApply bool `json:"recordApply"`
+
+	// RedactKeys are the keys of labels and annotations (e.g., ones with internal URLs or
+	// tokens injected by controllers) whose values are replaced with "[REDACTED]" in both
+	// structured and raw diffs. Other output, e.g. kubectl errors and cluster summaries, isn't
+	// redacted.
+	//
+	// Optional, defaults to not redacting anything.
+	RedactKeys []string `json:"redactKeys"`
+
+	// ShowManagedFields sets whether the managedFields in resource metadata should be kept
+	// in structured diffs. These are stripped by default since they change constantly, but
+	// they can be useful for debugging field ownership conflicts with server-side applies.
+	//
+	// Optional, defaults to false.
+	ShowManagedFields bool `json:"showManagedFields"`
+
+	// ShowHelmHooks sets whether resources with a helm.sh/hook annotation should be included
+	// in structured diffs. For clusters with Charts set, these are excluded by default since
+	// hooks (e.g., jobs) aren't persistent cluster state and would otherwise show up as diffs
+	// every time. Hooks are always shown for other clusters.
+	//
+	// Optional, defaults to false.
+	ShowHelmHooks bool `json:"showHelmHooks"`
+
 	// Subpath is the subset of the expanded configs that we want to diff or apply.
 	Subpaths []string `json:"-"`
 
+	// KindFilters restricts diffs and applies to resources of the given kinds. If empty,
+	// all kinds are considered.
+	KindFilters []string `json:"-"`
+
+	// NameFilters restricts diffs and applies to resources with the given names. Globs
+	// are allowed. If empty, all names are considered.
+	NameFilters []string `json:"-"`
+
+	// CreateNamespaces sets whether the namespaces referenced by the resources being applied
+	// should be created first if they don't exist. Diffs and dry-runs never create them;
+	// instead, the resources in missing namespaces are diffed as if they're all new.
+	CreateNamespaces bool `json:"-"`
+
+	// AdoptExisting sets whether applies should take ownership of resources that already
+	// exist in the cluster, e.g. because they were created by hand or by another tool.
+	AdoptExisting bool `json:"-"`
+
+	// RolloutTimeout is how long to wait after an apply for the rollouts of the applied
+	// workloads to complete. The apply fails if they don't complete in time. If zero, rollouts
+	// aren't waited on.
+	RolloutTimeout time.Duration `json:"-"`
+
 	// Profile is the current profile that's being used for config expansion.
 	Profile *Profile `json:"-"`
 
@@ -111,6 +331,45 @@ type ClusterConfig struct {
 	descriptiveName string
 }
 
+// ProtectedResource matches resources that kubeapply should never apply. Each field is a
+// glob (e.g., "apps/*"), and empty fields match all resources.
+type ProtectedResource struct {
+	// APIVersion is the API group and version, e.g. "v1" or "apps/v1".
+	APIVersion string `json:"apiVersion"`
+
+	// Kind is the resource kind, e.g. "DaemonSet". It's matched case-insensitively.
+	Kind string `json:"kind"`
+
+	// Namespace is the resource namespace. Cluster-scoped resources have an empty namespace.
+	Namespace string `json:"namespace"`
+
+	// Name is the resource name.
+	Name string `json:"name"`
+}
+
+// Validate checks that at least one of the fields in this ProtectedResource is set and that
+// they're all valid globs.
+func (p ProtectedResource) Validate() error {
+	fields := []string{p.APIVersion, p.Kind, p.Namespace, p.Name}
+	set := false
+
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+		set = true
+
+		if _, err := path.Match(field, ""); err != nil {
+			return fmt.Errorf("Invalid glob %s: %+v", field, err)
+		}
+	}
+
+	if !set {
+		return errors.New("At least one of apiVersion, kind, namespace, or name must be set")
+	}
+	return nil
+}
+
 // Profile contains the configuration for a single profile.
 type Profile struct {
 	// Name is the name of the profile.
@@ -124,23 +383,77 @@ type Profile struct {
 	//
 	// Optional.
 	Parameters map[string]interface{} `json:"parameters"`
+
+	// EnabledEnvs is a list of environments in which this profile is enabled. If set, the
+	// profile will be skipped for clusters with envs that are not in this list.
+	//
+	// Optional, defaults to all environments.
+	EnabledEnvs []string `json:"enabledEnvs"`
+
+	// DisabledEnvs is a list of environments in which this profile is disabled. This takes
+	// precedence over EnabledEnvs.
+	//
+	// Optional.
+	DisabledEnvs []string `json:"disabledEnvs"`
+}
+
+// EnabledForEnv returns whether this profile should be expanded in clusters with the
+// argument env.
+func (p Profile) EnabledForEnv(env string) bool {
+	for _, disabledEnv := range p.DisabledEnvs {
+		if disabledEnv == env {
+			return false
+		}
+	}
+
+	if len(p.EnabledEnvs) == 0 {
+		return true
+	}
+
+	for _, enabledEnv := range p.EnabledEnvs {
+		if enabledEnv == env {
+			return true
+		}
+	}
+
+	return false
 }
 
-// LoadClusterConfig loads a config from a path on disk.
+// LoadClusterConfig loads a config from a path on disk. Paths that are http:// or https://
+// URLs are fetched via LoadRemoteClusterConfig with the options from RemoteOptionsFromEnv.
 func LoadClusterConfig(path string, rootPath string) (*ClusterConfig, error) {
+	if IsRemotePath(path) {
+		options, err := RemoteOptionsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return LoadRemoteClusterConfig(path, options)
+	}
+
 	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
+	return parseClusterConfig(bytes, path, rootPath, path)
+}
+
+// parseClusterConfig parses the argument config contents and sets the defaults using
+// the argument path. The source is used in error messages.
+func parseClusterConfig(
+	bytes []byte,
+	path string,
+	rootPath string,
+	source string,
+) (*ClusterConfig, error) {
 	config := &ClusterConfig{}
-	err = yaml.Unmarshal(bytes, &config)
+	err := yaml.Unmarshal(bytes, &config)
 	if err != nil {
 		return nil, err
 	}
 
 	if config == nil || config.Cluster == "" {
-		return nil, fmt.Errorf("File %s is not a valid cluster config", path)
+		return nil, fmt.Errorf("File %s is not a valid cluster config", source)
 	}
 
 	if err := config.SetDefaults(path, rootPath); err != nil {
@@ -194,6 +507,10 @@ func (c *ClusterConfig) SetDefaults(path string, rootPath string) error {
 		c.ProfilePath = filepath.Join(configDir, c.ProfilePath)
 	}
 
+	if c.ParametersSchema != "" && !filepath.IsAbs(c.ParametersSchema) {
+		c.ParametersSchema = filepath.Join(configDir, c.ParametersSchema)
+	}
+
 	if c.ExpandedPath == "" {
 		c.ExpandedPath = filepath.Join(
 			configDir,
@@ -209,6 +526,56 @@ func (c *ClusterConfig) SetDefaults(path string, rootPath string) error {
 		c.ExpandedPath = filepath.Join(configDir, c.ExpandedPath)
 	}
 
+	if c.HelmParallelism < 0 {
+		return fmt.Errorf("Invalid helmParallelism: %d", c.HelmParallelism)
+	}
+
+	if c.WaveTimeout != "" {
+		if _, err := time.ParseDuration(c.WaveTimeout); err != nil {
+			return fmt.Errorf("Invalid waveTimeout: %+v", err)
+		}
+	}
+
+	if c.MinApplyInterval != "" {
+		if _, err := time.ParseDuration(c.MinApplyInterval); err != nil {
+			return fmt.Errorf("Invalid minApplyInterval: %+v", err)
+		}
+	}
+
+	for p, protectedResource := range c.ProtectedResources {
+		if err := protectedResource.Validate(); err != nil {
+			return fmt.Errorf("Invalid protectedResources entry %d: %+v", p, err)
+		}
+	}
+
+	switch c.KubeBackend {
+	case "":
+		c.KubeBackend = "kubectl"
+	case "kubectl":
+	case "client-go":
+		if !c.ServerSideApply {
+			return errors.New("kubeBackend client-go requires serverSideApply to be set")
+		}
+		if c.KubectlAttempts != 0 {
+			return errors.New("kubeBackend client-go does not support kubectlAttempts")
+		}
+		if c.WaveTimeout != "" {
+			return errors.New("kubeBackend client-go does not support waveTimeout")
+		}
+	default:
+		return fmt.Errorf("Invalid kubeBackend: %s", c.KubeBackend)
+	}
+
+	if c.ManagementNamespace == "" {
+		c.ManagementNamespace = DefaultManagementNamespace
+	}
+
+	for i, modulePath := range c.StarlarkModulePaths {
+		if !filepath.IsAbs(modulePath) {
+			c.StarlarkModulePaths[i] = filepath.Join(configDir, modulePath)
+		}
+	}
+
 	return nil
 }
 
@@ -224,6 +591,48 @@ func (c ClusterConfig) ShortRegion() string {
 	return fmt.Sprintf("%s%s%s", components[0][0:2], components[1][0:1], components[2])
 }
 
+// WaveTimeoutDuration returns the parsed WaveTimeout, or 0 if it's not set.
+func (c ClusterConfig) WaveTimeoutDuration() time.Duration {
+	timeout, err := time.ParseDuration(c.WaveTimeout)
+	if err != nil {
+		return 0
+	}
+	return timeout
+}
+
+// MinApplyIntervalDuration returns the parsed MinApplyInterval, or 0 if it's not set.
+func (c ClusterConfig) MinApplyIntervalDuration() time.Duration {
+	interval, err := time.ParseDuration(c.MinApplyInterval)
+	if err != nil {
+		return 0
+	}
+	return interval
+}
+
+// HelmGlobals generates the global values that are passed to every Helm chart in this
+// cluster.
+func (c ClusterConfig) HelmGlobals() map[string]interface{} {
+	globals := map[string]interface{}{}
+
+	for _, key := range c.HelmGlobalParameters {
+		if key == "*" {
+			for paramKey, value := range c.Parameters {
+				globals[paramKey] = value
+			}
+		} else if value, ok := c.Parameters[key]; ok {
+			globals[key] = value
+		} else {
+			log.Warnf("Helm global parameter %s is not set in the cluster parameters", key)
+		}
+	}
+
+	globals["cluster"] = c.Cluster
+	globals["region"] = c.Region
+	globals["shortRegion"] = c.ShortRegion()
+
+	return globals
+}
+
 // CheckVersion checks that the version in the cluster config is compatible with this
 // version of kubeapply.
 func (c ClusterConfig) CheckVersion(version string) error {
@@ -241,6 +650,14 @@ func (c ClusterConfig) CheckVersion(version string) error {
 		return err
 	}
 
+	if c.VersionAllowPrereleases && semVersion.Prerelease() != "" {
+		releaseVersion, err := semVersion.SetPrerelease("")
+		if err != nil {
+			return err
+		}
+		semVersion = &releaseVersion
+	}
+
 	if !constraint.Check(semVersion) {
 		return fmt.Errorf(
 			"kubeapply version (%s) does not satisfy constraint in config (%s). If needed, update by running 'GO111MODULE=\"on\" go get github.com/segmentio/kubeapply/cmd/kubeapply'.",
@@ -252,6 +669,17 @@ func (c ClusterConfig) CheckVersion(version string) error {
 	return nil
 }
 
+// IgnoreHelmHooks returns whether helm hooks should be excluded from the diffs for this
+// cluster. This is only the case for clusters that expand helm charts.
+func (c ClusterConfig) IgnoreHelmHooks() bool {
+	return c.Charts != "" && !c.ShowHelmHooks
+}
+
+// UseServerSideDiff returns whether diffs for this cluster should be done server-side.
+func (c ClusterConfig) UseServerSideDiff() bool {
+	return c.ServerSideApply || c.ServerSideDiff
+}
+
 // AbsSubpaths returns the absolute subpaths of the expanded configs associated with
 // this ClusterConfig.
 func (c ClusterConfig) AbsSubpaths() []string {
@@ -350,6 +778,19 @@ func (c ClusterConfig) SubpathCount() int {
 	return len(c.Subpaths)
 }
 
+// OwnershipSelector returns a kubectl label selector (e.g., "key1=value1,key2=value2") that
+// matches the resources applied with this ClusterConfig's OwnershipLabels. It returns an
+// empty string if OwnershipLabels isn't set.
+func (c ClusterConfig) OwnershipSelector() string {
+	terms := []string{}
+	for key, value := range c.OwnershipLabels {
+		terms = append(terms, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(terms)
+
+	return strings.Join(terms, ",")
+}
+
 // StarParams generates the base starlark params for this ClusterConfig.
 func (c ClusterConfig) StarParams() map[string]interface{} {
 	starParams := map[string]interface{}{
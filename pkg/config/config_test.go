@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -34,6 +35,56 @@ func TestCheckVersion(t *testing.T) {
 			version:  "0.1.0",
 			expMatch: true,
 		},
+		{
+			config:   ClusterConfig{VersionConstraint: ">= 1.3"},
+			version:  "1.4.0-rc.2",
+			expMatch: false,
+		},
+		{
+			config:   ClusterConfig{VersionConstraint: ">= 1.3-0"},
+			version:  "1.4.0-rc.2",
+			expMatch: true,
+		},
+		{
+			config: ClusterConfig{
+				VersionConstraint:       ">= 1.3",
+				VersionAllowPrereleases: true,
+			},
+			version:  "1.4.0-rc.2",
+			expMatch: true,
+		},
+		{
+			config: ClusterConfig{
+				VersionConstraint:       "~1.4",
+				VersionAllowPrereleases: true,
+			},
+			version:  "1.4.1-dev",
+			expMatch: true,
+		},
+		{
+			config: ClusterConfig{
+				VersionConstraint:       ">= 1.5",
+				VersionAllowPrereleases: true,
+			},
+			version:  "1.4.0-rc.2",
+			expMatch: false,
+		},
+		{
+			config: ClusterConfig{
+				VersionConstraint:       ">= 1.3, < 2.0",
+				VersionAllowPrereleases: true,
+			},
+			version:  "2.0.0-dev",
+			expMatch: false,
+		},
+		{
+			config: ClusterConfig{
+				VersionConstraint:       ">= 1.3",
+				VersionAllowPrereleases: true,
+			},
+			version:  "1.4.0",
+			expMatch: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -45,3 +96,290 @@ func TestCheckVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestProfileEnabledForEnv(t *testing.T) {
+	type testCase struct {
+		profile    Profile
+		env        string
+		expEnabled bool
+	}
+
+	testCases := []testCase{
+		{
+			profile:    Profile{},
+			env:        "production",
+			expEnabled: true,
+		},
+		{
+			profile:    Profile{EnabledEnvs: []string{"production"}},
+			env:        "production",
+			expEnabled: true,
+		},
+		{
+			profile:    Profile{EnabledEnvs: []string{"production"}},
+			env:        "stage",
+			expEnabled: false,
+		},
+		{
+			profile:    Profile{DisabledEnvs: []string{"dev"}},
+			env:        "dev",
+			expEnabled: false,
+		},
+		{
+			profile:    Profile{DisabledEnvs: []string{"dev"}},
+			env:        "stage",
+			expEnabled: true,
+		},
+		{
+			profile: Profile{
+				EnabledEnvs:  []string{"dev", "stage"},
+				DisabledEnvs: []string{"dev"},
+			},
+			env:        "dev",
+			expEnabled: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(
+			t,
+			testCase.expEnabled,
+			testCase.profile.EnabledForEnv(testCase.env),
+		)
+	}
+}
+
+func TestHelmGlobals(t *testing.T) {
+	type testCase struct {
+		description      string
+		globalParameters []string
+		expGlobals       map[string]interface{}
+	}
+
+	parameters := map[string]interface{}{
+		"accountID": "123456789",
+		"tier":      "critical",
+		"cluster":   "override",
+	}
+
+	testCases := []testCase{
+		{
+			description: "no parameters",
+			expGlobals: map[string]interface{}{
+				"cluster":     "test-cluster",
+				"region":      "us-west-2",
+				"shortRegion": "usw2",
+			},
+		},
+		{
+			description:      "subset of parameters",
+			globalParameters: []string{"accountID", "missing"},
+			expGlobals: map[string]interface{}{
+				"accountID":   "123456789",
+				"cluster":     "test-cluster",
+				"region":      "us-west-2",
+				"shortRegion": "usw2",
+			},
+		},
+		{
+			description:      "all parameters",
+			globalParameters: []string{"*"},
+			expGlobals: map[string]interface{}{
+				"accountID":   "123456789",
+				"tier":        "critical",
+				"cluster":     "test-cluster",
+				"region":      "us-west-2",
+				"shortRegion": "usw2",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		config := ClusterConfig{
+			Cluster:              "test-cluster",
+			Region:               "us-west-2",
+			Parameters:           parameters,
+			HelmGlobalParameters: testCase.globalParameters,
+		}
+		assert.Equal(t, testCase.expGlobals, config.HelmGlobals(), testCase.description)
+	}
+}
+
+func TestSetDefaultsKubeBackend(t *testing.T) {
+	type testCase struct {
+		description string
+		config      ClusterConfig
+		expBackend  string
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "default",
+			config:      ClusterConfig{},
+			expBackend:  "kubectl",
+		},
+		{
+			description: "client-go",
+			config: ClusterConfig{
+				KubeBackend:     "client-go",
+				ServerSideApply: true,
+			},
+			expBackend: "client-go",
+		},
+		{
+			description: "client-go without server-side apply",
+			config: ClusterConfig{
+				KubeBackend: "client-go",
+			},
+			expErr: true,
+		},
+		{
+			description: "client-go with kubectl attempts",
+			config: ClusterConfig{
+				KubeBackend:     "client-go",
+				ServerSideApply: true,
+				KubectlAttempts: 5,
+			},
+			expErr: true,
+		},
+		{
+			description: "client-go with wave timeout",
+			config: ClusterConfig{
+				KubeBackend:     "client-go",
+				ServerSideApply: true,
+				WaveTimeout:     "5m",
+			},
+			expErr: true,
+		},
+		{
+			description: "unknown backend",
+			config: ClusterConfig{
+				KubeBackend: "unknown",
+			},
+			expErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		config := testCase.config
+		config.Cluster = "test-cluster"
+		config.Env = "test-env"
+		config.Region = "us-west-2"
+		err := config.SetDefaults("/configs/cluster.yaml", "")
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			assert.NoError(t, err, testCase.description)
+			assert.Equal(t, testCase.expBackend, config.KubeBackend, testCase.description)
+		}
+	}
+}
+
+func TestSetDefaultsManagementNamespace(t *testing.T) {
+	config := ClusterConfig{
+		Cluster: "test-cluster",
+		Env:     "test-env",
+		Region:  "us-west-2",
+	}
+	assert.NoError(t, config.SetDefaults("/configs/cluster.yaml", ""))
+	assert.Equal(t, "kube-system", config.ManagementNamespace)
+
+	config.ManagementNamespace = "kubeapply"
+	assert.NoError(t, config.SetDefaults("/configs/cluster.yaml", ""))
+	assert.Equal(t, "kubeapply", config.ManagementNamespace)
+}
+
+func TestSetDefaultsMinApplyInterval(t *testing.T) {
+	config := ClusterConfig{
+		Cluster: "test-cluster",
+		Env:     "test-env",
+		Region:  "us-west-2",
+	}
+	assert.NoError(t, config.SetDefaults("/configs/cluster.yaml", ""))
+	assert.Equal(t, time.Duration(0), config.MinApplyIntervalDuration())
+
+	config.MinApplyInterval = "10m"
+	assert.NoError(t, config.SetDefaults("/configs/cluster.yaml", ""))
+	assert.Equal(t, 10*time.Minute, config.MinApplyIntervalDuration())
+
+	config.MinApplyInterval = "10 minutes"
+	assert.Error(t, config.SetDefaults("/configs/cluster.yaml", ""))
+}
+
+func TestSetDefaultsProtectedResources(t *testing.T) {
+	config := ClusterConfig{
+		Cluster: "test-cluster",
+		Env:     "test-env",
+		Region:  "us-west-2",
+		ProtectedResources: []ProtectedResource{
+			{
+				Kind:      "ConfigMap",
+				Namespace: "kube-system",
+				Name:      "aws-*",
+			},
+		},
+	}
+	assert.NoError(t, config.SetDefaults("/configs/cluster.yaml", ""))
+
+	config.ProtectedResources = append(config.ProtectedResources, ProtectedResource{})
+	assert.Error(t, config.SetDefaults("/configs/cluster.yaml", ""))
+
+	config.ProtectedResources[1] = ProtectedResource{Name: "[bad"}
+	assert.Error(t, config.SetDefaults("/configs/cluster.yaml", ""))
+}
+
+func TestOwnershipSelector(t *testing.T) {
+	config := ClusterConfig{}
+	assert.Equal(t, "", config.OwnershipSelector())
+
+	config.OwnershipLabels = map[string]string{
+		"app.kubernetes.io/managed-by": "kubeapply-cluster1",
+		"example.com/owner":            "infra",
+	}
+	assert.Equal(
+		t,
+		"app.kubernetes.io/managed-by=kubeapply-cluster1,example.com/owner=infra",
+		config.OwnershipSelector(),
+	)
+}
+
+func TestIgnoreHelmHooks(t *testing.T) {
+	type testCase struct {
+		description   string
+		config        ClusterConfig
+		expIgnoreHook bool
+	}
+
+	testCases := []testCase{
+		{
+			description:   "no charts",
+			config:        ClusterConfig{},
+			expIgnoreHook: false,
+		},
+		{
+			description: "charts",
+			config: ClusterConfig{
+				Charts: "file://charts",
+			},
+			expIgnoreHook: true,
+		},
+		{
+			description: "charts with hooks shown",
+			config: ClusterConfig{
+				Charts:        "file://charts",
+				ShowHelmHooks: true,
+			},
+			expIgnoreHook: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(
+			t,
+			testCase.expIgnoreHook,
+			testCase.config.IgnoreHelmHooks(),
+			testCase.description,
+		)
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// EffectiveParameters returns the parameters that are used when expanding the argument
+// profile, i.e. the cluster's parameters with the profile's parameters, if any, merged on top.
+func (c ClusterConfig) EffectiveParameters(profile *Profile) map[string]interface{} {
+	parameters := map[string]interface{}{}
+	for key, value := range c.Parameters {
+		parameters[key] = value
+	}
+	if profile != nil {
+		for key, value := range profile.Parameters {
+			parameters[key] = value
+		}
+	}
+
+	return parameters
+}
+
+// ValidateParameters validates the parameters that are used when expanding the argument
+// profile against the JSON schema in ParametersSchema. The profile's parameters, if any, are
+// merged on top of the cluster's parameters before validating. If ParametersSchema isn't set,
+// this is a no-op.
+func (c ClusterConfig) ValidateParameters(profile *Profile) error {
+	if c.ParametersSchema == "" {
+		return nil
+	}
+
+	schemaPath, err := filepath.Abs(c.ParametersSchema)
+	if err != nil {
+		return err
+	}
+
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewReferenceLoader(fmt.Sprintf("file://%s", filepath.ToSlash(schemaPath))),
+		gojsonschema.NewGoLoader(c.EffectiveParameters(profile)),
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"Error validating parameters against schema %s: %+v",
+			c.ParametersSchema,
+			err,
+		)
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	errorStrs := []string{}
+	for _, resultErr := range result.Errors() {
+		errorStrs = append(
+			errorStrs,
+			fmt.Sprintf("%s: %s", resultErr.Field(), resultErr.Description()),
+		)
+	}
+
+	return fmt.Errorf(
+		"Parameters for cluster %s don't match schema %s: %s",
+		c.DescriptiveName(),
+		c.ParametersSchema,
+		strings.Join(errorStrs, "; "),
+	)
+}
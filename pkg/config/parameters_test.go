@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateParameters(t *testing.T) {
+	type testCase struct {
+		description string
+		schema      string
+		parameters  map[string]interface{}
+		profile     *Profile
+		expErr      string
+	}
+
+	testCases := []testCase{
+		{
+			description: "no schema",
+			parameters: map[string]interface{}{
+				"regoin": "us-west-2",
+			},
+		},
+		{
+			description: "valid parameters",
+			schema:      "testdata/parameters_schema.json",
+			parameters: map[string]interface{}{
+				"accountID": "1234",
+				"replicas":  3,
+			},
+		},
+		{
+			description: "unknown parameter",
+			schema:      "testdata/parameters_schema.json",
+			parameters: map[string]interface{}{
+				"accountID": "1234",
+				"regoin":    "us-west-2",
+			},
+			expErr: "regoin",
+		},
+		{
+			description: "wrong type",
+			schema:      "testdata/parameters_schema.json",
+			parameters: map[string]interface{}{
+				"accountID": "1234",
+				"replicas":  "three",
+			},
+			expErr: "replicas: Invalid type",
+		},
+		{
+			description: "missing parameter",
+			schema:      "testdata/parameters_schema.json",
+			parameters:  map[string]interface{}{},
+			expErr:      "accountID is required",
+		},
+		{
+			description: "profile override",
+			schema:      "testdata/parameters_schema.json",
+			parameters: map[string]interface{}{
+				"accountID": "1234",
+				"replicas":  3,
+			},
+			profile: &Profile{
+				Name: "test-profile",
+				Parameters: map[string]interface{}{
+					"replicas": "three",
+				},
+			},
+			expErr: "replicas: Invalid type",
+		},
+	}
+
+	for _, testCase := range testCases {
+		config := ClusterConfig{
+			Cluster:          "test-cluster",
+			Env:              "test-env",
+			Region:           "us-west-2",
+			Parameters:       testCase.parameters,
+			ParametersSchema: testCase.schema,
+		}
+		require.NoError(t, config.SetDefaults("cluster.yaml", ""), testCase.description)
+
+		err := config.ValidateParameters(testCase.profile)
+		if testCase.expErr != "" {
+			require.Error(t, err, testCase.description)
+			assert.Contains(t, err.Error(), testCase.expErr, testCase.description)
+		} else {
+			assert.NoError(t, err, testCase.description)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const remoteConfigTimeout = 30 * time.Second
+
+// RemoteOptions configures how cluster configs with http:// or https:// paths are loaded.
+type RemoteOptions struct {
+	// BaseDir is the local directory that relative paths in remote configs (e.g., the
+	// profile and expanded paths) are resolved against.
+	//
+	// Optional, defaults to the current working directory.
+	BaseDir string
+
+	// Headers are added to each request, e.g. for authentication.
+	//
+	// Optional.
+	Headers map[string]string
+
+	// CacheDir is a directory where fetched configs are cached. Cached configs are
+	// revalidated with their ETags and are used as a fallback if the server can't be reached.
+	//
+	// Optional, if blank then configs aren't cached.
+	CacheDir string
+}
+
+// RemoteOptionsFromEnv returns the options for loading remote configs that are set in
+// the environment:
+//
+//   - KUBEAPPLY_REMOTE_CONFIG_BASE_DIR: see RemoteOptions.BaseDir
+//   - KUBEAPPLY_REMOTE_CONFIG_HEADERS: a JSON object of request headers, e.g.
+//     {"Authorization": "Bearer abc123"}
+//   - KUBEAPPLY_REMOTE_CONFIG_CACHE_DIR: see RemoteOptions.CacheDir
+func RemoteOptionsFromEnv() (RemoteOptions, error) {
+	options := RemoteOptions{
+		BaseDir:  os.Getenv("KUBEAPPLY_REMOTE_CONFIG_BASE_DIR"),
+		CacheDir: os.Getenv("KUBEAPPLY_REMOTE_CONFIG_CACHE_DIR"),
+	}
+
+	if headersStr := os.Getenv("KUBEAPPLY_REMOTE_CONFIG_HEADERS"); headersStr != "" {
+		if err := json.Unmarshal([]byte(headersStr), &options.Headers); err != nil {
+			return options, fmt.Errorf("Error parsing remote config headers: %+v", err)
+		}
+	}
+
+	return options, nil
+}
+
+// IsRemotePath returns whether the argument cluster config path is an http:// or https://
+// URL.
+func IsRemotePath(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// LoadRemoteClusterConfig fetches a cluster config from the argument URL. Relative paths in
+// the config are resolved as if the config were in the base directory from the options.
+func LoadRemoteClusterConfig(configURL string, options RemoteOptions) (*ClusterConfig, error) {
+	parsedURL, err := url.Parse(configURL)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := fetchRemoteConfig(configURL, options)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := options.BaseDir
+	if baseDir == "" {
+		baseDir, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+	baseDir, err = filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	localPath := filepath.Join(baseDir, path.Base(parsedURL.Path))
+	log.Debugf("Resolving paths in remote config %s relative to %s", configURL, localPath)
+
+	return parseClusterConfig(bytes, localPath, "", configURL)
+}
+
+func fetchRemoteConfig(configURL string, options RemoteOptions) ([]byte, error) {
+	var cachePath string
+	var cached []byte
+	var cachedETag string
+
+	if options.CacheDir != "" {
+		hash := sha256.Sum256([]byte(configURL))
+		cachePath = filepath.Join(options.CacheDir, hex.EncodeToString(hash[:]))
+
+		cached, _ = ioutil.ReadFile(cachePath + ".yaml")
+		if cached != nil {
+			etagBytes, _ := ioutil.ReadFile(cachePath + ".etag")
+			cachedETag = string(etagBytes)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, configURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range options.Headers {
+		req.Header.Set(key, value)
+	}
+	if cachedETag != "" {
+		req.Header.Set("If-None-Match", cachedETag)
+	}
+
+	client := &http.Client{Timeout: remoteConfigTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		if cached != nil {
+			log.Warnf("Error fetching %s, using cached config: %+v", configURL, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		log.Debugf("Using cached config for %s", configURL)
+		return cached, nil
+	} else if resp.StatusCode != http.StatusOK {
+		if cached != nil && resp.StatusCode >= 500 {
+			log.Warnf("Error fetching %s, using cached config: %s", configURL, resp.Status)
+			return cached, nil
+		}
+		return nil, fmt.Errorf("Error fetching %s: %s", configURL, resp.Status)
+	}
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		if err := writeRemoteCache(cachePath, bytes, resp.Header.Get("ETag")); err != nil {
+			log.Warnf("Error caching config for %s: %+v", configURL, err)
+		}
+	}
+
+	return bytes, nil
+}
+
+func writeRemoteCache(cachePath string, contents []byte, etag string) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(cachePath+".yaml", contents, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cachePath+".etag", []byte(etag), 0644)
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRemoteConfig = `
+cluster: test-cluster
+region: us-west-2
+env: staging
+profilePath: ../profile
+`
+
+func TestLoadRemoteClusterConfig(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "remote-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	requests := 0
+	serverDown := false
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				if serverDown {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				if r.Header.Get("Authorization") != "Bearer test-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+
+				w.Header().Set("ETag", `"v1"`)
+				w.Write([]byte(testRemoteConfig))
+			},
+		),
+	)
+	defer server.Close()
+
+	configURL := server.URL + "/configs/staging.yaml"
+	options := RemoteOptions{
+		BaseDir: "/git/repo/clusters",
+		Headers: map[string]string{
+			"Authorization": "Bearer test-token",
+		},
+		CacheDir: cacheDir,
+	}
+
+	assert.True(t, IsRemotePath(configURL))
+	assert.False(t, IsRemotePath("clusters/staging.yaml"))
+
+	clusterConfig, err := LoadRemoteClusterConfig(configURL, options)
+	require.NoError(t, err)
+	assert.Equal(t, "staging:us-west-2:test-cluster", clusterConfig.DescriptiveName())
+	assert.Equal(t, "/git/repo/clusters/staging.yaml", clusterConfig.FullPath())
+	assert.Equal(t, "/git/repo/profile", clusterConfig.ProfilePath)
+	assert.Equal(t, "/git/repo/clusters/expanded/staging/us-west-2", clusterConfig.ExpandedPath)
+
+	// Revalidated via the ETag
+	clusterConfig, err = LoadRemoteClusterConfig(configURL, options)
+	require.NoError(t, err)
+	assert.Equal(t, "test-cluster", clusterConfig.Cluster)
+
+	// Falls back to the cache when the server is down
+	serverDown = true
+	clusterConfig, err = LoadRemoteClusterConfig(configURL, options)
+	require.NoError(t, err)
+	assert.Equal(t, "test-cluster", clusterConfig.Cluster)
+	assert.Equal(t, 3, requests)
+
+	// Fails without the cache
+	_, err = LoadRemoteClusterConfig(
+		configURL,
+		RemoteOptions{
+			BaseDir: "/git/repo/clusters",
+		},
+	)
+	assert.Error(t, err)
+
+	serverDown = false
+	_, err = LoadRemoteClusterConfig(
+		configURL,
+		RemoteOptions{
+			BaseDir:  "/git/repo/clusters",
+			CacheDir: filepath.Join(cacheDir, "other"),
+		},
+	)
+	assert.Error(t, err)
+}
@@ -19,6 +19,7 @@ const (
 	commandApply  command = "apply"
 	commandDiff   command = "diff"
 	commandHelp   command = "help"
+	commandLocks  command = "locks"
 	commandStatus command = "status"
 )
 
@@ -59,6 +60,8 @@ func getCommand(body string) (*eventCommand, error) {
 		cmd = commandDiff
 	case "help":
 		cmd = commandHelp
+	case "locks":
+		cmd = commandLocks
 	case "status":
 		cmd = commandStatus
 	default:
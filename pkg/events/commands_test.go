@@ -77,6 +77,14 @@ func TestGetCommand(t *testing.T) {
 				flags: map[string]string{},
 			},
 		},
+		{
+			body: "kubeapply locks",
+			expCommand: &eventCommand{
+				cmd:   commandLocks,
+				args:  []string{},
+				flags: map[string]string{},
+			},
+		},
 		{
 			body: "  kubeapply apply arg1   arg2  arg3 --key1=value1 --key2   --key3=value3\r\n\r\n",
 			expCommand: &eventCommand{
@@ -2,6 +2,7 @@ package events
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -27,6 +28,10 @@ type WebhookContext struct {
 	commentType       commentType
 	pullRequestEvent  *github.PullRequestEvent
 	issueCommentEvent *github.IssueCommentEvent
+	reviewEvent       *github.PullRequestReviewEvent
+
+	// triggered is set for contexts that come from trigger requests instead of Github
+	triggered bool
 }
 
 // NewWebhookContext converts a webhook object into a WebhookContext, if possible.
@@ -34,6 +39,7 @@ func NewWebhookContext(
 	webhookType string,
 	webhookBody []byte,
 	githubToken string,
+	clientSettings pullreq.GHPullRequestClientSettings,
 ) (*WebhookContext, error) {
 	webhookObj, err := github.ParseWebHook(webhookType, webhookBody)
 	if err != nil {
@@ -69,6 +75,7 @@ func NewWebhookContext(
 			owner,
 			repoName,
 			pullRequestNum,
+			clientSettings,
 		)
 		return &WebhookContext{
 			pullRequestClient: client,
@@ -77,6 +84,32 @@ func NewWebhookContext(
 			pullRequestNum:    pullRequestNum,
 			pullRequestEvent:  event,
 		}, nil
+	case *github.PullRequestReviewEvent:
+		action := event.GetAction()
+
+		// Dismissals are included since they can revoke an approval
+		if action != "submitted" && action != "dismissed" {
+			log.Infof("Got non-matching pull request review event action: %s", action)
+			return nil, nil
+		}
+
+		owner, repoName := parseRepoName(event.GetRepo())
+		pullRequestNum := event.GetPullRequest().GetNumber()
+
+		client := pullreq.NewGHPullRequestClient(
+			githubToken,
+			owner,
+			repoName,
+			pullRequestNum,
+			clientSettings,
+		)
+		return &WebhookContext{
+			pullRequestClient: client,
+			owner:             owner,
+			repo:              repoName,
+			pullRequestNum:    pullRequestNum,
+			reviewEvent:       event,
+		}, nil
 	case *github.IssueCommentEvent:
 		issue := event.GetIssue()
 
@@ -109,6 +142,7 @@ func NewWebhookContext(
 			owner,
 			repoName,
 			pullRequestNum,
+			clientSettings,
 		)
 		return &WebhookContext{
 			pullRequestClient: client,
@@ -124,11 +158,145 @@ func NewWebhookContext(
 	}
 }
 
+// TriggerRequest is a request to run a kubeapply command in a pull request via an API call
+// instead of a pull request comment.
+type TriggerRequest struct {
+	// Owner is the owner of the repo, e.g. "segmentio".
+	Owner string `json:"owner"`
+
+	// Repo is the name of the repo.
+	Repo string `json:"repo"`
+
+	// PullRequestNum is the number of the pull request to run the command in.
+	PullRequestNum int `json:"pr"`
+
+	// Command is the command to run, i.e. "diff", "apply", "status", or "help".
+	Command string `json:"command"`
+
+	// Clusters are the clusters to run the command in, in the same format as in comments. If
+	// unset, the command runs in the default clusters for the pull request.
+	Clusters []string `json:"clusters"`
+}
+
+// TriggerActor is the actor for commands run via trigger requests. Anyone with the trigger
+// token can send these, so they aren't attributed to a Github user.
+const TriggerActor = "kubeapply-trigger"
+
+// NewTriggerWebhookContext converts a JSON-formatted TriggerRequest into a WebhookContext.
+// The context is equivalent to the one for a comment that runs the same command, so it goes
+// through the same handler logic.
+func NewTriggerWebhookContext(
+	triggerBody []byte,
+	githubToken string,
+	clientSettings pullreq.GHPullRequestClientSettings,
+) (*WebhookContext, error) {
+	request := TriggerRequest{}
+	if err := json.Unmarshal(triggerBody, &request); err != nil {
+		return nil, fmt.Errorf("Could not parse trigger request: %+v", err)
+	}
+
+	commentBody, err := request.commentBody()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof(
+		"Got trigger request for %s/%s#%d: %s",
+		request.Owner,
+		request.Repo,
+		request.PullRequestNum,
+		commentBody,
+	)
+
+	client := pullreq.NewGHPullRequestClient(
+		githubToken,
+		request.Owner,
+		request.Repo,
+		request.PullRequestNum,
+		clientSettings,
+	)
+	return &WebhookContext{
+		pullRequestClient: client,
+		owner:             request.Owner,
+		repo:              request.Repo,
+		pullRequestNum:    request.PullRequestNum,
+		commentType:       commentTypeCommand,
+		issueCommentEvent: &github.IssueCommentEvent{
+			Action: github.String("created"),
+			Comment: &github.IssueComment{
+				Body: github.String(commentBody),
+				User: &github.User{
+					Login: github.String(TriggerActor),
+				},
+			},
+		},
+		triggered: true,
+	}, nil
+}
+
+// commentBody validates the request and returns the equivalent kubeapply comment.
+func (r TriggerRequest) commentBody() (string, error) {
+	if r.Owner == "" || r.Repo == "" || r.PullRequestNum <= 0 {
+		return "", errors.New("Trigger request must set owner, repo, and pr")
+	}
+
+	components := append([]string{"kubeapply", r.Command}, r.Clusters...)
+	body := strings.Join(components, " ")
+
+	if _, err := getCommand(body); err != nil {
+		return "", fmt.Errorf("Invalid trigger command: %+v", err)
+	}
+	for _, cluster := range r.Clusters {
+		if cluster == "" ||
+			strings.ContainsAny(cluster, " \n") ||
+			strings.HasPrefix(cluster, "--") {
+			return "", fmt.Errorf("Invalid trigger cluster: %q", cluster)
+		}
+	}
+
+	return body, nil
+}
+
 // Close closes the underlying clients associated with this WebhookContext.
 func (w *WebhookContext) Close() error {
 	return w.pullRequestClient.Close()
 }
 
+// Owner returns the owner of the repo that this webhook is for.
+func (w *WebhookContext) Owner() string {
+	return w.owner
+}
+
+// Repo returns the name of the repo that this webhook is for.
+func (w *WebhookContext) Repo() string {
+	return w.repo
+}
+
+// PullRequestNum returns the number of the pull request that this webhook is for.
+func (w *WebhookContext) PullRequestNum() int {
+	return w.pullRequestNum
+}
+
+// PullRequestClient returns the client for the pull request that this webhook is for.
+func (w *WebhookContext) PullRequestClient() pullreq.PullRequestClient {
+	return w.pullRequestClient
+}
+
+// Actor returns the Github login of the user that triggered this webhook, i.e. the commenter
+// for comment events, the reviewer for review events, and the pull request author for pull
+// request events.
+func (w *WebhookContext) Actor() string {
+	if w.issueCommentEvent != nil {
+		return w.issueCommentEvent.GetComment().GetUser().GetLogin()
+	} else if w.reviewEvent != nil {
+		return w.reviewEvent.GetReview().GetUser().GetLogin()
+	} else if w.pullRequestEvent != nil {
+		return w.pullRequestEvent.GetPullRequest().GetUser().GetLogin()
+	}
+
+	return ""
+}
+
 func parseRepoName(repo *github.Repository) (string, string) {
 	repoFullName := repo.GetFullName()
 	repoComponents := strings.Split(repoFullName, "/")
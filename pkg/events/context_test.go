@@ -6,6 +6,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/google/go-github/v30/github"
+	"github.com/segmentio/kubeapply/pkg/pullreq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -79,6 +80,43 @@ func TestNewWebhookContext(t *testing.T) {
 			expWebhookContext: nil,
 			expErr:            false,
 		},
+		{
+			description: "review submitted",
+			input: &github.PullRequestReviewEvent{
+				Action: aws.String("submitted"),
+				Repo: &github.Repository{
+					FullName: aws.String("segmentio/test-repo"),
+				},
+				PullRequest: &github.PullRequest{
+					Number: aws.Int(52),
+				},
+				Review: &github.PullRequestReview{
+					State: aws.String("approved"),
+				},
+			},
+			webhookType: "pull_request_review",
+			expWebhookContext: &WebhookContext{
+				owner:          "segmentio",
+				repo:           "test-repo",
+				pullRequestNum: 52,
+			},
+			expErr: false,
+		},
+		{
+			description: "review edited",
+			input: &github.PullRequestReviewEvent{
+				Action: aws.String("edited"),
+				Repo: &github.Repository{
+					FullName: aws.String("segmentio/test-repo"),
+				},
+				PullRequest: &github.PullRequest{
+					Number: aws.Int(52),
+				},
+			},
+			webhookType:       "pull_request_review",
+			expWebhookContext: nil,
+			expErr:            false,
+		},
 		{
 			description: "comment created",
 			input: &github.IssueCommentEvent{
@@ -169,6 +207,7 @@ func TestNewWebhookContext(t *testing.T) {
 			testCase.webhookType,
 			inputBytes,
 			"test-github-token",
+			pullreq.GHPullRequestClientSettings{},
 		)
 		if testCase.expErr {
 			assert.NotNil(t, err, testCase.description)
@@ -206,6 +245,16 @@ func TestNewWebhookContext(t *testing.T) {
 				)
 			}
 
+			reviewEvent, ok := testCase.input.(*github.PullRequestReviewEvent)
+			if ok {
+				assert.Equal(
+					t,
+					reviewEvent,
+					result.reviewEvent,
+					testCase.description,
+				)
+			}
+
 			issueCommentEvent, ok := testCase.input.(*github.IssueCommentEvent)
 			if ok {
 				assert.Equal(
@@ -220,3 +269,81 @@ func TestNewWebhookContext(t *testing.T) {
 		}
 	}
 }
+
+func TestNewTriggerWebhookContext(t *testing.T) {
+	type testCase struct {
+		description    string
+		input          string
+		expCommentBody string
+		expActor       string
+		expErr         bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "bad json",
+			input:       "not json",
+			expErr:      true,
+		},
+		{
+			description: "missing pull request",
+			input:       `{"owner": "segmentio", "repo": "test-repo", "command": "diff"}`,
+			expErr:      true,
+		},
+		{
+			description: "unrecognized command",
+			input:       `{"owner": "segmentio", "repo": "test-repo", "pr": 10, "command": "destroy"}`,
+			expErr:      true,
+		},
+		{
+			description: "flag as cluster",
+			input:       `{"owner": "segmentio", "repo": "test-repo", "pr": 10, "command": "apply", "clusters": ["--subpath=foo"]}`,
+			expErr:      true,
+		},
+		{
+			description:    "diff without clusters",
+			input:          `{"owner": "segmentio", "repo": "test-repo", "pr": 10, "command": "diff"}`,
+			expCommentBody: "kubeapply diff",
+			expActor:       "kubeapply-trigger",
+		},
+		{
+			description:    "apply with clusters",
+			input:          `{"owner": "segmentio", "repo": "test-repo", "pr": 10, "command": "apply", "clusters": ["stage:*", "production:us-west-2:cluster1"]}`,
+			expCommentBody: "kubeapply apply stage:* production:us-west-2:cluster1",
+			expActor:       "kubeapply-trigger",
+		},
+		{
+			description:    "actor can't be set",
+			input:          `{"owner": "segmentio", "repo": "test-repo", "pr": 10, "command": "apply", "actor": "team-member"}`,
+			expCommentBody: "kubeapply apply",
+			expActor:       "kubeapply-trigger",
+		},
+	}
+
+	for _, testCase := range testCases {
+		result, err := NewTriggerWebhookContext(
+			[]byte(testCase.input),
+			"test-token",
+			pullreq.GHPullRequestClientSettings{},
+		)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+			continue
+		}
+		require.NoError(t, err, testCase.description)
+
+		assert.Equal(t, "segmentio", result.Owner(), testCase.description)
+		assert.Equal(t, "test-repo", result.Repo(), testCase.description)
+		assert.Equal(t, 10, result.PullRequestNum(), testCase.description)
+		assert.Equal(t, commentTypeCommand, result.commentType, testCase.description)
+		assert.Equal(
+			t,
+			testCase.expCommentBody,
+			result.issueCommentEvent.GetComment().GetBody(),
+			testCase.description,
+		)
+		assert.Equal(t, testCase.expActor, result.Actor(), testCase.description)
+		assert.True(t, result.triggered, testCase.description)
+		assert.NotNil(t, result.pullRequestClient, testCase.description)
+	}
+}
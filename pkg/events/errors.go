@@ -0,0 +1,118 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind is a category of error returned while handling a webhook. It implements error
+// so that each kind can be used as a target for errors.Is, e.g.
+// errors.Is(err, ErrNotApproved).
+type ErrorKind string
+
+const (
+	// ErrBadCommand is used when a comment can't be parsed as a kubeapply command.
+	ErrBadCommand ErrorKind = "bad_command"
+
+	// ErrCommandDisabled is used when a comment has a command that's disabled in the handler
+	// settings or, for applies, in the config of a targeted cluster.
+	ErrCommandDisabled ErrorKind = "command_disabled"
+
+	// ErrStatusNotGreen is used when an apply is blocked by non-green commit statuses.
+	ErrStatusNotGreen ErrorKind = "status_not_green"
+
+	// ErrNotApproved is used when an apply is blocked by a missing review approval.
+	ErrNotApproved ErrorKind = "not_approved"
+
+	// ErrBehind is used when an apply is blocked because the branch is behind its base.
+	ErrBehind ErrorKind = "behind"
+
+	// ErrNotAuthorized is used when an apply is blocked because the commenter isn't in one of
+	// the teams that are allowed to apply in a cluster.
+	ErrNotAuthorized ErrorKind = "not_authorized"
+
+	// ErrApplyTooSoon is used when an apply is blocked because a cluster was applied within
+	// the minimum apply interval in its config.
+	ErrApplyTooSoon ErrorKind = "apply_too_soon"
+
+	// ErrApplyDenied is used when an apply is denied by the pre-apply gate.
+	ErrApplyDenied ErrorKind = "apply_denied"
+
+	// ErrVersionMismatch is used when a cluster config has a version constraint that isn't
+	// satisfied by the running kubeapply version.
+	ErrVersionMismatch ErrorKind = "version_mismatch"
+
+	// ErrKubectl is used when a diff or apply fails in a cluster.
+	ErrKubectl ErrorKind = "kubectl"
+
+	// ErrInternal is used for all other errors.
+	ErrInternal ErrorKind = "internal"
+)
+
+// Error returns the name of this error kind.
+func (k ErrorKind) Error() string {
+	return string(k)
+}
+
+// UserFixable returns whether errors of this kind can be fixed by the pull request author
+// (e.g., by getting an approval), as opposed to internal failures.
+func (k ErrorKind) UserFixable() bool {
+	switch k {
+	case ErrBadCommand,
+		ErrCommandDisabled,
+		ErrStatusNotGreen,
+		ErrNotApproved,
+		ErrBehind,
+		ErrNotAuthorized,
+		ErrApplyTooSoon,
+		ErrApplyDenied,
+		ErrVersionMismatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// HandlerError is an error with an associated ErrorKind.
+type HandlerError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+var _ error = (*HandlerError)(nil)
+
+// Error returns the message of the underlying error.
+func (e *HandlerError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether the target is the kind of this error.
+func (e *HandlerError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// ErrorKindOf returns the kind of the argument error. Errors that weren't created with a
+// kind are treated as ErrInternal.
+func ErrorKindOf(err error) ErrorKind {
+	var handlerErr *HandlerError
+	if errors.As(err, &handlerErr) {
+		return handlerErr.Kind
+	}
+	return ErrInternal
+}
+
+func newHandlerError(kind ErrorKind, format string, args ...interface{}) error {
+	return &HandlerError{
+		Kind: kind,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+func errorKindTag(err error) string {
+	return fmt.Sprintf("error_kind:%s", ErrorKindOf(err))
+}
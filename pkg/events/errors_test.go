@@ -0,0 +1,62 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerErrors(t *testing.T) {
+	type testCase struct {
+		description    string
+		err            error
+		expKind        ErrorKind
+		expUserFixable bool
+		expStatusCode  int
+	}
+
+	testCases := []testCase{
+		{
+			description:    "not approved",
+			err:            multilineError(ErrNotApproved, "line1", "line2"),
+			expKind:        ErrNotApproved,
+			expUserFixable: true,
+			expStatusCode:  400,
+		},
+		{
+			description:    "wrapped kubectl error",
+			err:            fmt.Errorf("wrapped: %w", newHandlerError(ErrKubectl, "boom")),
+			expKind:        ErrKubectl,
+			expUserFixable: false,
+			expStatusCode:  500,
+		},
+		{
+			description:    "plain error",
+			err:            errors.New("plain"),
+			expKind:        ErrInternal,
+			expUserFixable: false,
+			expStatusCode:  500,
+		},
+	}
+
+	for _, testCase := range testCases {
+		kind := ErrorKindOf(testCase.err)
+		assert.Equal(t, testCase.expKind, kind, testCase.description)
+		assert.Equal(t, testCase.expUserFixable, kind.UserFixable(), testCase.description)
+		assert.Equal(
+			t,
+			testCase.expStatusCode,
+			ErrorResponse(testCase.err).StatusCode,
+			testCase.description,
+		)
+		if testCase.expKind != ErrInternal {
+			assert.True(t, errors.Is(testCase.err, testCase.expKind), testCase.description)
+		}
+	}
+
+	err := multilineError(ErrBehind, "line1", "line2")
+	assert.Equal(t, "line1\nline2", err.Error())
+	assert.False(t, errors.Is(err, ErrNotApproved))
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+
+	"github.com/segmentio/kubeapply/pkg/cluster"
+)
+
+// PreApplyGate is an extension point for custom checks that must pass before an apply is
+// run from a webhook, e.g. requiring a ticket link in the pull request or blocking applies
+// during a change freeze. Gates are checked after the built-in status, review, and
+// up-to-date checks.
+type PreApplyGate interface {
+	// AllowApply returns whether the apply in the argument clusters can proceed. If not, the
+	// returned reason is posted in an error comment on the pull request. Errors are treated
+	// as denials.
+	AllowApply(
+		ctx context.Context,
+		webhookContext *WebhookContext,
+		clusterClients []cluster.ClusterClient,
+	) (bool, string, error)
+}
+
+// PreApplyGateFunc is an adapter that allows an ordinary function to be used as a
+// PreApplyGate.
+type PreApplyGateFunc func(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+) (bool, string, error)
+
+var _ PreApplyGate = PreApplyGateFunc(nil)
+
+// AllowApply calls f(ctx, webhookContext, clusterClients).
+func (f PreApplyGateFunc) AllowApply(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+) (bool, string, error) {
+	return f(ctx, webhookContext, clusterClients)
+}
+
+var _ PreApplyGate = (*AllowAllGate)(nil)
+
+// AllowAllGate is a PreApplyGate that allows all applies. It's used if no gate is set.
+type AllowAllGate struct{}
+
+// AllowApply always returns true.
+func (g *AllowAllGate) AllowApply(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+) (bool, string, error) {
+	return true, "", nil
+}
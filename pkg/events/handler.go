@@ -3,14 +3,19 @@ package events
 import (
 	"context"
 	"crypto/sha1"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/segmentio/kubeapply/pkg/cluster"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/notify"
 	"github.com/segmentio/kubeapply/pkg/pullreq"
 	"github.com/segmentio/kubeapply/pkg/stats"
 	log "github.com/sirupsen/logrus"
@@ -43,12 +48,33 @@ type WebhookHandlerSettings struct {
 	// Debug indicates whether we should enable debug-level logging on kubectl calls.
 	Debug bool
 
+	// Quiet indicates whether we should log routine kubectl output at the debug level.
+	Quiet bool
+
 	// Env is the environment for this handler.
 	Env string
 
 	// LogsURL is the URL that should be used
 	LogsURL string
 
+	// MaxDiffLinesPerResource is the maximum number of raw diff lines shown for each resource
+	// in diff comments. The added and removed counts still cover the full diffs.
+	//
+	// Optional, defaults to 0 (i.e., diffs are only clipped by length).
+	MaxDiffLinesPerResource int
+
+	// GroupDiffsByNamespace is whether to group the resources in diff comments by namespace,
+	// with a header for each namespace.
+	//
+	// Optional, defaults to false.
+	GroupDiffsByNamespace bool
+
+	// MaxConcurrentApplies is the maximum number of clusters that are applied in parallel. If
+	// an apply fails, then no further applies are started.
+	//
+	// Optional, defaults to 1 (i.e., clusters are applied one at a time).
+	MaxConcurrentApplies int
+
 	// StrictCheck indicates whether we should block applies on having an approval and all
 	// green statuses.
 	//
@@ -58,9 +84,113 @@ type WebhookHandlerSettings struct {
 	// GreenCIRequired indicates whether CI must be green before allowing applies.
 	GreenCIRequired bool
 
+	// IncrementalDiffs indicates whether diffs should only cover the subpaths that have changed
+	// since the last recorded diff of the same pull request in each cluster. If there's no
+	// such diff for a cluster, then all of its covered subpaths are diffed. Applies always
+	// cover all subpaths.
+	IncrementalDiffs bool
+
+	// DryRunSummaries indicates whether diffs should also run a dry-run apply in each cluster
+	// and include a summary of the resources that would be created, updated, and left
+	// unchanged in the diff comment. This doubles the number of kubectl calls per diff.
+	DryRunSummaries bool
+
+	// DiffOptional indicates whether the automerge workflow can be completed by applies alone,
+	// without a prior kubeapply diff in each cluster. This removes a safety check, so it should
+	// only be used in flows where the changes are validated by other means, e.g. in CI.
+	//
+	// Optional, defaults to false.
+	DiffOptional bool
+
+	// ScopeDirectives indicates whether the default cluster and subpath selections for
+	// commands can be set via "kubeapply:cluster=[glob]" and "kubeapply:subpath=[path]" labels
+	// on the pull request or a "kubeapply" fenced block in its body. Explicit arguments in
+	// command comments take precedence.
+	//
+	// Optional, defaults to false.
+	ScopeDirectives bool
+
+	// ClusterStats indicates whether diffs and applies should also emit stats for each
+	// cluster, tagged with the cluster's env, region, and name. This adds a tag value per
+	// cluster, so it should only be enabled if the number of clusters is bounded.
+	//
+	// Optional, defaults to false.
+	ClusterStats bool
+
+	// StatusJSON indicates whether status comments should also include a machine-readable,
+	// JSON summary of the workloads in each cluster.
+	//
+	// Optional, defaults to false.
+	StatusJSON bool
+
+	// PreApplyGate is checked before applying, after the built-in checks have passed. This
+	// allows for custom apply prerequisites without changes to the handler.
+	//
+	// Optional, defaults to an AllowAllGate.
+	PreApplyGate PreApplyGate
+
+	// MergeMessageTemplate is the Go text/template used for the commit messages of automerged
+	// pull requests. It's executed with a pullreq.MergeMessageData.
+	//
+	// Optional, defaults to pullreq.DefaultMergeMessageTemplate.
+	MergeMessageTemplate string
+
+	// Notifier is sent the results of applies in the clusters covered by a change. It's only
+	// called for applies that were actually attempted, not ones blocked by the pre-apply
+	// checks.
+	//
+	// Optional, defaults to a Slack notifier if SlackWebhookURL is set and a NullNotifier
+	// otherwise.
+	Notifier notify.Notifier
+
+	// Publisher is sent a structured event with the result of each diff and apply in each
+	// cluster, so that downstream systems can react to them.
+	//
+	// Optional, defaults to a NullPublisher.
+	Publisher Publisher
+
 	// ReviewRequired indicates whether a review is required before allowing applies.
 	ReviewRequired bool
 
+	// RepoSettings contains per-repo overrides of the settings above, keyed by the full repo
+	// name (i.e., "owner/repo"). This allows a single handler to serve webhooks from multiple
+	// repos with different settings.
+	//
+	// Optional, if empty or the repo isn't found then the settings above are used as-is.
+	RepoSettings map[string]RepoSettings
+
+	// SlackWebhookURL is the URL of a Slack incoming webhook that apply results are posted to.
+	// Notifications are best-effort; errors posting to Slack do not affect the apply.
+	//
+	// Optional, if blank then no Slack notifications are sent.
+	SlackWebhookURL string
+
+	// SlackEnvs restricts Slack notifications to applies in clusters in these environments.
+	//
+	// Optional, if empty then notifications are sent for all environments.
+	SlackEnvs []string
+
+	// DisabledCommands are the names of the commands (e.g., "apply") that can't be run via
+	// pull request comments. This allows, for instance, production applies to be disabled while
+	// still allowing diffs and status checks.
+	//
+	// Optional, if empty then all commands are allowed.
+	DisabledCommands []string
+
+	// StatusContextPrefix is the prefix used for the contexts of the Github statuses set by
+	// this handler, e.g. "kubeapply" results in statuses like "kubeapply/apply (production)".
+	// This can be changed so that multiple kubeapply deployments can run against the same repo
+	// without their statuses colliding.
+	//
+	// Optional, defaults to "kubeapply".
+	StatusContextPrefix string
+
+	// SummaryMode determines how the cluster summaries for status comments are generated. The
+	// basic mode only requires kubectl, so it can be used in images that don't include python.
+	//
+	// Optional, defaults to kube.SummaryModeFull.
+	SummaryMode kube.SummaryMode
+
 	// UseLocks indicates whether we should use locking to prevent overlapping handler calls
 	// for a cluster.
 	UseLocks bool
@@ -69,12 +199,96 @@ type WebhookHandlerSettings struct {
 	Version string
 }
 
+// RepoSettings stores the overrides for a single repo. Fields that are nil are not overridden.
+type RepoSettings struct {
+	Automerge       *bool   `json:"automerge"`
+	Env             *string `json:"env"`
+	StrictCheck     *bool   `json:"strictCheck"`
+	GreenCIRequired *bool   `json:"greenCIRequired"`
+	ReviewRequired  *bool   `json:"reviewRequired"`
+}
+
+// ParseRepoSettings parses a JSON object of per-repo settings, keyed by "owner/repo".
+func ParseRepoSettings(repoSettingsStr string) (map[string]RepoSettings, error) {
+	repoSettings := map[string]RepoSettings{}
+
+	if strings.TrimSpace(repoSettingsStr) == "" {
+		return repoSettings, nil
+	}
+
+	if err := json.Unmarshal([]byte(repoSettingsStr), &repoSettings); err != nil {
+		return nil, fmt.Errorf("Error parsing repo settings: %+v", err)
+	}
+	for repoName := range repoSettings {
+		if len(strings.Split(repoName, "/")) != 2 {
+			return nil, fmt.Errorf(
+				"Repo settings key %s is not in owner/repo format",
+				repoName,
+			)
+		}
+	}
+
+	return repoSettings, nil
+}
+
+// forRepo returns a copy of these settings with the overrides for the argument repo applied.
+func (s WebhookHandlerSettings) forRepo(owner string, repo string) WebhookHandlerSettings {
+	repoSettings, ok := s.RepoSettings[fmt.Sprintf("%s/%s", owner, repo)]
+	if !ok {
+		return s
+	}
+	log.Infof("Using settings overrides for repo %s/%s", owner, repo)
+
+	if repoSettings.Automerge != nil {
+		s.Automerge = *repoSettings.Automerge
+	}
+	if repoSettings.Env != nil {
+		s.Env = *repoSettings.Env
+	}
+	if repoSettings.StrictCheck != nil {
+		s.StrictCheck = *repoSettings.StrictCheck
+	}
+	if repoSettings.GreenCIRequired != nil {
+		s.GreenCIRequired = *repoSettings.GreenCIRequired
+	}
+	if repoSettings.ReviewRequired != nil {
+		s.ReviewRequired = *repoSettings.ReviewRequired
+	}
+
+	return s
+}
+
 // NewWebhookHandler creates a new WebhookHandler from the provided clients and settings.
 func NewWebhookHandler(
 	statsClient stats.StatsClient,
 	clientGenerator cluster.ClusterClientGenerator,
 	settings WebhookHandlerSettings,
 ) *WebhookHandler {
+	if settings.StatusContextPrefix == "" {
+		settings.StatusContextPrefix = DefaultStatusContextPrefix
+	}
+	if settings.MergeMessageTemplate == "" {
+		settings.MergeMessageTemplate = pullreq.DefaultMergeMessageTemplate
+	}
+	if settings.PreApplyGate == nil {
+		settings.PreApplyGate = &AllowAllGate{}
+	}
+
+	if settings.Publisher == nil {
+		settings.Publisher = &NullPublisher{}
+	}
+
+	if settings.Notifier == nil {
+		if settings.SlackWebhookURL != "" {
+			settings.Notifier = notify.NewSlackNotifier(
+				settings.SlackWebhookURL,
+				settings.SlackEnvs,
+			)
+		} else {
+			settings.Notifier = &notify.NullNotifier{}
+		}
+	}
+
 	return &WebhookHandler{
 		statsClient:     statsClient,
 		clientGenerator: clientGenerator,
@@ -90,8 +304,18 @@ func (whh *WebhookHandler) HandleWebhook(
 ) events.ALBTargetGroupResponse {
 	if webhookContext == nil {
 		return OKResponse("OK")
-	} else if webhookContext.pullRequestEvent != nil {
+	}
+
+	if len(whh.settings.RepoSettings) > 0 {
+		repoHandler := *whh
+		repoHandler.settings = whh.settings.forRepo(webhookContext.owner, webhookContext.repo)
+		whh = &repoHandler
+	}
+
+	if webhookContext.pullRequestEvent != nil {
 		return whh.handlePullRequestEvent(ctx, webhookContext)
+	} else if webhookContext.reviewEvent != nil {
+		return whh.handleReviewEvent(ctx, webhookContext)
 	} else if webhookContext.issueCommentEvent != nil {
 		if webhookContext.commentType == commentTypeCommand {
 			return whh.handleCommandCommentEvent(ctx, webhookContext)
@@ -108,19 +332,19 @@ func (whh *WebhookHandler) handlePullRequestEvent(
 ) events.ALBTargetGroupResponse {
 	err := webhookContext.pullRequestClient.Init(ctx)
 	if err != nil {
-		whh.incrementStat("handler.pull_request.error", webhookContext, "")
+		whh.incrementStat("handler.pull_request.error", webhookContext, "", errorKindTag(err))
 		webhookContext.pullRequestClient.PostErrorComment(ctx, whh.settings.Env, err)
 		return ErrorResponse(err)
 	}
 
 	clusterClients, err := whh.getClusterClients(
 		ctx,
-		webhookContext.pullRequestClient,
+		webhookContext,
 		nil,
 		nil,
 	)
 	if err != nil {
-		whh.incrementStat("handler.pull_request.error", webhookContext, "")
+		whh.incrementStat("handler.pull_request.error", webhookContext, "", errorKindTag(err))
 		webhookContext.pullRequestClient.PostErrorComment(ctx, whh.settings.Env, err)
 		return ErrorResponse(err)
 	}
@@ -138,18 +362,20 @@ func (whh *WebhookHandler) handlePullRequestEvent(
 
 	if action == "opened" {
 		// Post help at the beginning
-		err := whh.runHelp(ctx, webhookContext.pullRequestClient, clusterClients)
+		err := whh.runHelp(ctx, webhookContext, clusterClients)
 		if err != nil {
-			whh.incrementStat("handler.pull_request.error", webhookContext, "help")
+			whh.incrementStat("handler.pull_request.error", webhookContext, "help", errorKindTag(err))
 			return ErrorResponse(err)
 		}
 
 		whh.incrementStat("handler.pull_request.success", webhookContext, "help")
 	}
 
-	err = whh.runDiffs(ctx, webhookContext.pullRequestClient, clusterClients)
+	clusterErrs, err := whh.runDiffs(ctx, webhookContext.pullRequestClient, clusterClients)
+	whh.publishResults(ctx, webhookContext, commandDiff, clusterClients, clusterErrs)
+	whh.incrementClusterStats(webhookContext, commandDiff, clusterClients, clusterErrs, err)
 	if err != nil {
-		whh.incrementStat("handler.pull_request.error", webhookContext, "diff")
+		whh.incrementStat("handler.pull_request.error", webhookContext, "diff", errorKindTag(err))
 		return ErrorResponse(err)
 	}
 
@@ -158,13 +384,61 @@ func (whh *WebhookHandler) handlePullRequestEvent(
 	return OKResponse("OK")
 }
 
+// handleReviewEvent refreshes the apply status after a review is submitted or dismissed so
+// that, if reviews are required, the pull request shows whether it can be applied without
+// waiting for the next apply attempt.
+func (whh *WebhookHandler) handleReviewEvent(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+) events.ALBTargetGroupResponse {
+	if !whh.settings.StrictCheck && !whh.settings.ReviewRequired {
+		return OKResponse("Reviews are not required")
+	}
+
+	err := webhookContext.pullRequestClient.Init(ctx)
+	if err != nil {
+		whh.incrementStat("handler.review.error", webhookContext, "", errorKindTag(err))
+		return ErrorResponse(err)
+	}
+
+	clusterClients, err := whh.getClusterClients(
+		ctx,
+		webhookContext,
+		nil,
+		nil,
+	)
+	if err != nil {
+		whh.incrementStat("handler.review.error", webhookContext, "", errorKindTag(err))
+		return ErrorResponse(err)
+	}
+	if len(clusterClients) == 0 {
+		return OKResponse("No clusters affected by this change")
+	}
+
+	defer func() {
+		for _, clusterClient := range clusterClients {
+			clusterClient.Close()
+		}
+	}()
+
+	err = whh.refreshApplyStatus(ctx, webhookContext.pullRequestClient, clusterClients)
+	if err != nil {
+		whh.incrementStat("handler.review.error", webhookContext, "apply", errorKindTag(err))
+		return ErrorResponse(err)
+	}
+
+	whh.incrementStat("handler.review.success", webhookContext, "apply")
+
+	return OKResponse("OK")
+}
+
 func (whh *WebhookHandler) handleCommandCommentEvent(
 	ctx context.Context,
 	webhookContext *WebhookContext,
 ) events.ALBTargetGroupResponse {
 	err := webhookContext.pullRequestClient.Init(ctx)
 	if err != nil {
-		whh.incrementStat("handler.comment.error", webhookContext, "")
+		whh.incrementStat("handler.comment.error", webhookContext, "", errorKindTag(err))
 		webhookContext.pullRequestClient.PostErrorComment(ctx, whh.settings.Env, err)
 		return ErrorResponse(err)
 	}
@@ -173,18 +447,30 @@ func (whh *WebhookHandler) handleCommandCommentEvent(
 
 	eventCommand, err := getCommand(commentBody)
 	if err != nil {
-		whh.incrementStat("handler.comment.error", webhookContext, "")
+		err = newHandlerError(ErrBadCommand, "Unrecognized command: %+v", err)
+		whh.incrementStat("handler.comment.error", webhookContext, "", errorKindTag(err))
 		webhookContext.pullRequestClient.PostErrorComment(
 			ctx,
 			whh.settings.Env,
 			errors.New("Sorry, I didn't understand; post \"kubeapply help\" for usage."),
 		)
-		return ErrorResponse(errors.New("Unrecognized command"))
+		return ErrorResponse(err)
+	}
+
+	if err := whh.checkCommandEnabled(eventCommand.cmd); err != nil {
+		whh.incrementStat(
+			"handler.comment.error",
+			webhookContext,
+			string(eventCommand.cmd),
+			errorKindTag(err),
+		)
+		webhookContext.pullRequestClient.PostErrorComment(ctx, whh.settings.Env, err)
+		return ErrorResponse(err)
 	}
 
 	clusterClients, err := whh.getClusterClients(
 		ctx,
-		webhookContext.pullRequestClient,
+		webhookContext,
 		eventCommand.args,
 		eventCommand.flags,
 	)
@@ -204,22 +490,27 @@ func (whh *WebhookHandler) handleCommandCommentEvent(
 
 	switch eventCommand.cmd {
 	case commandApply:
-		err = whh.runApply(
+		clusterErrs, err := whh.runApply(
 			ctx,
-			webhookContext.pullRequestClient,
+			webhookContext,
 			clusterClients,
 			eventCommand.flags,
 		)
+		whh.publishResults(ctx, webhookContext, commandApply, clusterClients, clusterErrs)
+		whh.incrementClusterStats(webhookContext, commandApply, clusterClients, clusterErrs, err)
+
 		if err != nil {
-			whh.incrementStat("handler.comment.error", webhookContext, "apply")
+			whh.incrementStat("handler.comment.error", webhookContext, "apply", errorKindTag(err))
 			return ErrorResponse(err)
 		}
 
 		whh.incrementStat("handler.comment.success", webhookContext, "apply")
 	case commandDiff:
-		err = whh.runDiffs(ctx, webhookContext.pullRequestClient, clusterClients)
+		clusterErrs, err := whh.runDiffs(ctx, webhookContext.pullRequestClient, clusterClients)
+		whh.publishResults(ctx, webhookContext, commandDiff, clusterClients, clusterErrs)
+		whh.incrementClusterStats(webhookContext, commandDiff, clusterClients, clusterErrs, err)
 		if err != nil {
-			whh.incrementStat("handler.comment.error", webhookContext, "diff")
+			whh.incrementStat("handler.comment.error", webhookContext, "diff", errorKindTag(err))
 			return ErrorResponse(err)
 		}
 
@@ -227,15 +518,23 @@ func (whh *WebhookHandler) handleCommandCommentEvent(
 	case commandStatus:
 		err = whh.runStatus(ctx, webhookContext.pullRequestClient, clusterClients)
 		if err != nil {
-			whh.incrementStat("handler.comment.error", webhookContext, "status")
+			whh.incrementStat("handler.comment.error", webhookContext, "status", errorKindTag(err))
 			return ErrorResponse(err)
 		}
 
 		whh.incrementStat("handler.comment.success", webhookContext, "status")
+	case commandLocks:
+		err = whh.runLocks(ctx, webhookContext.pullRequestClient, clusterClients)
+		if err != nil {
+			whh.incrementStat("handler.comment.error", webhookContext, "locks", errorKindTag(err))
+			return ErrorResponse(err)
+		}
+
+		whh.incrementStat("handler.comment.success", webhookContext, "locks")
 	case commandHelp:
-		err = whh.runHelp(ctx, webhookContext.pullRequestClient, clusterClients)
+		err = whh.runHelp(ctx, webhookContext, clusterClients)
 		if err != nil {
-			whh.incrementStat("handler.comment.error", webhookContext, "help")
+			whh.incrementStat("handler.comment.error", webhookContext, "help", errorKindTag(err))
 			return ErrorResponse(err)
 		}
 
@@ -245,6 +544,32 @@ func (whh *WebhookHandler) handleCommandCommentEvent(
 	return OKResponse("OK")
 }
 
+// checkCommandEnabled returns an error if the argument command is in the disabled commands
+// in the handler settings.
+func (whh *WebhookHandler) checkCommandEnabled(cmd command) error {
+	for _, disabledCommand := range whh.settings.DisabledCommands {
+		if strings.TrimSpace(disabledCommand) != string(cmd) {
+			continue
+		}
+
+		if whh.settings.Env == "" {
+			return newHandlerError(
+				ErrCommandDisabled,
+				"Command %s is disabled in this environment",
+				cmd,
+			)
+		}
+		return newHandlerError(
+			ErrCommandDisabled,
+			"Command %s is disabled in env %s",
+			cmd,
+			whh.settings.Env,
+		)
+	}
+
+	return nil
+}
+
 type preMergeCondition struct {
 	description string
 	value       bool
@@ -261,7 +586,7 @@ func (whh *WebhookHandler) handleApplyResultCommentEvent(
 
 	err := webhookContext.pullRequestClient.Init(ctx)
 	if err != nil {
-		whh.incrementStat("handler.automerge.error", webhookContext, "")
+		whh.incrementStat("handler.automerge.error", webhookContext, "", errorKindTag(err))
 		webhookContext.pullRequestClient.PostErrorComment(ctx, whh.settings.Env, err)
 		return ErrorResponse(err)
 	}
@@ -273,7 +598,12 @@ func (whh *WebhookHandler) handleApplyResultCommentEvent(
 		},
 		{
 			description: "workflows completed",
-			value:       statusWorkflowCompleted(ctx, webhookContext.pullRequestClient),
+			value: statusWorkflowCompleted(
+				ctx,
+				webhookContext.pullRequestClient,
+				whh.settings.StatusContextPrefix,
+				!whh.settings.DiffOptional,
+			),
 		},
 		{
 			description: "pull request is not a draft",
@@ -299,6 +629,13 @@ func (whh *WebhookHandler) handleApplyResultCommentEvent(
 		}
 	}
 
+	mergeMessage, err := whh.mergeMessage(webhookContext)
+	if err != nil {
+		whh.incrementStat("handler.automerge.error", webhookContext, "", errorKindTag(err))
+		webhookContext.pullRequestClient.PostErrorComment(ctx, whh.settings.Env, err)
+		return ErrorResponse(err)
+	}
+
 	err = webhookContext.pullRequestClient.PostComment(
 		ctx,
 		"🎉 Auto-merging because changes have been successfully applied in all clusters 🎉",
@@ -307,7 +644,7 @@ func (whh *WebhookHandler) handleApplyResultCommentEvent(
 		return ErrorResponse(err)
 	}
 
-	err = webhookContext.pullRequestClient.Merge(ctx)
+	err = webhookContext.pullRequestClient.Merge(ctx, mergeMessage)
 	if err != nil {
 		return ErrorResponse(err)
 	}
@@ -316,13 +653,52 @@ func (whh *WebhookHandler) handleApplyResultCommentEvent(
 	return OKResponse("OK")
 }
 
+// mergeMessage generates the commit message for automerging the pull request in the argument
+// context.
+func (whh *WebhookHandler) mergeMessage(webhookContext *WebhookContext) (string, error) {
+	client := webhookContext.pullRequestClient
+
+	clusterConfigs, err := client.GetCoveredClusters(whh.settings.Env, nil, "")
+	if err != nil {
+		return "", err
+	}
+
+	clusters := []string{}
+	for _, clusterConfig := range clusterConfigs {
+		clusters = append(clusters, clusterConfig.DescriptiveName())
+	}
+
+	return pullreq.FormatMergeMessage(
+		whh.settings.MergeMessageTemplate,
+		pullreq.MergeMessageData{
+			PullRequestNum: webhookContext.pullRequestNum,
+			Title:          client.Title(),
+			Env:            whh.settings.Env,
+			Clusters:       clusters,
+		},
+	)
+}
+
 func (whh *WebhookHandler) getClusterClients(
 	ctx context.Context,
-	client pullreq.PullRequestClient,
+	webhookContext *WebhookContext,
 	selectedClusterGlobStrs []string,
 	flags map[string]string,
 ) ([]cluster.ClusterClient, error) {
 	clusterClients := []cluster.ClusterClient{}
+	client := webhookContext.pullRequestClient
+
+	if whh.settings.ScopeDirectives {
+		directives, err := parseScopeDirectives(client.Labels(), client.Body())
+		if err != nil {
+			return nil, err
+		}
+		selectedClusterGlobStrs, flags = applyScopeDirectives(
+			directives,
+			selectedClusterGlobStrs,
+			flags,
+		)
+	}
 
 	coveredClusters, err := client.GetCoveredClusters(
 		whh.settings.Env,
@@ -340,9 +716,14 @@ func (whh *WebhookHandler) getClusterClients(
 			&cluster.ClusterClientConfig{
 				ClusterConfig:         coveredCluster,
 				HeadSHA:               headSHA,
+				Actor:                 webhookContext.Actor(),
 				CheckApplyConsistency: whh.settings.ApplyConsistencyCheck,
+				RecordDiffs:           whh.settings.IncrementalDiffs,
+				PullRequestNum:        webhookContext.pullRequestNum,
+				SummaryMode:           whh.settings.SummaryMode,
 				UseLocks:              whh.settings.UseLocks,
 				Debug:                 whh.settings.Debug,
+				Quiet:                 whh.settings.Quiet,
 			},
 		)
 		if err != nil {
@@ -358,12 +739,183 @@ func (whh *WebhookHandler) getClusterClients(
 	return clusterClients, nil
 }
 
-func (whh *WebhookHandler) runApply(
+// applyDisabledClusters returns the descriptive names of the argument clusters that have
+// applies disabled in their configs.
+func applyDisabledClusters(clusterClients []cluster.ClusterClient) []string {
+	disabled := []string{}
+
+	for _, clusterClient := range clusterClients {
+		if clusterClient.Config().DisableApply {
+			disabled = append(disabled, clusterClient.Config().DescriptiveName())
+		}
+	}
+
+	return disabled
+}
+
+// checkApplyTeams checks that the actor of the argument webhook is a member of one of the apply
+// teams of each of the argument clusters that restricts applies to teams.
+func checkApplyTeams(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+) error {
+	actor := webhookContext.Actor()
+	unauthorized := []string{}
+
+	for _, clusterClient := range clusterClients {
+		member, err := canApply(ctx, webhookContext, clusterClient.Config())
+		if err != nil {
+			return err
+		}
+
+		if !member {
+			unauthorized = append(
+				unauthorized,
+				fmt.Sprintf(
+					"%s (teams %s)",
+					clusterClient.Config().DescriptiveName(),
+					strings.Join(clusterClient.Config().ApplyTeams, ", "),
+				),
+			)
+		}
+	}
+
+	if len(unauthorized) > 0 && webhookContext.triggered {
+		return multilineError(
+			ErrNotAuthorized,
+			fmt.Sprintf(
+				"Cannot run apply via a trigger request in clusters %s since they only allow applies by team members.",
+				strings.Join(unauthorized, "; "),
+			),
+			"Please ask a member of one of these teams to post the apply command.",
+		)
+	} else if len(unauthorized) > 0 {
+		return multilineError(
+			ErrNotAuthorized,
+			fmt.Sprintf(
+				"Cannot run apply because %s isn't a member of the teams allowed to apply in clusters %s.",
+				actor,
+				strings.Join(unauthorized, "; "),
+			),
+			"Please ask a member of one of these teams to post the apply command.",
+		)
+	}
+
+	return nil
+}
+
+// canApply returns whether the actor of the argument webhook can apply in the argument cluster.
+// Trigger requests can set any command and aren't tied to a Github user, so they can't apply
+// in clusters that restrict applies to teams.
+func canApply(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterConfig *config.ClusterConfig,
+) (bool, error) {
+	if webhookContext.triggered {
+		return len(clusterConfig.ApplyTeams) == 0, nil
+	}
+
+	return inApplyTeams(
+		ctx,
+		webhookContext.pullRequestClient,
+		clusterConfig,
+		webhookContext.Actor(),
+	)
+}
+
+// inApplyTeams returns whether the argument user is a member of one of the apply teams of the
+// argument cluster. Anyone can apply in clusters that don't restrict applies to teams.
+func inApplyTeams(
 	ctx context.Context,
 	client pullreq.PullRequestClient,
+	clusterConfig *config.ClusterConfig,
+	user string,
+) (bool, error) {
+	for _, team := range clusterConfig.ApplyTeams {
+		member, err := client.IsTeamMember(ctx, team, user)
+		if err != nil {
+			return false, fmt.Errorf("Error checking membership of team %s: %+v", team, err)
+		} else if member {
+			return true, nil
+		}
+	}
+
+	return len(clusterConfig.ApplyTeams) == 0, nil
+}
+
+// checkMinApplyIntervals checks that none of the argument clusters that have a minimum apply
+// interval in their configs were applied within that interval. If force is set, then recent
+// applies are logged instead.
+func checkMinApplyIntervals(
+	ctx context.Context,
 	clusterClients []cluster.ClusterClient,
-	flags map[string]string,
+	force bool,
 ) error {
+	now := time.Now()
+	tooSoon := []string{}
+
+	for _, clusterClient := range clusterClients {
+		interval := clusterClient.Config().MinApplyIntervalDuration()
+		if interval <= 0 {
+			continue
+		}
+
+		lastApplyTime, err := clusterClient.LastApplyTime(ctx)
+		if err != nil {
+			return fmt.Errorf(
+				"Error getting last apply time for cluster %s: %+v",
+				clusterClient.Config().DescriptiveName(),
+				err,
+			)
+		} else if lastApplyTime.IsZero() {
+			continue
+		}
+
+		sinceLastApply := now.Sub(lastApplyTime)
+		if sinceLastApply >= interval {
+			continue
+		}
+
+		message := fmt.Sprintf(
+			"%s (last applied %d seconds ago, please wait %d seconds)",
+			clusterClient.Config().DescriptiveName(),
+			int(sinceLastApply.Seconds()),
+			int((interval-sinceLastApply).Seconds())+1,
+		)
+		if force {
+			log.Warnf("Forcing apply in cluster %s", message)
+			continue
+		}
+		tooSoon = append(tooSoon, message)
+	}
+
+	if len(tooSoon) > 0 {
+		return multilineError(
+			ErrApplyTooSoon,
+			fmt.Sprintf(
+				"Cannot run apply because clusters were applied within their minimum apply intervals: %s.",
+				strings.Join(tooSoon, "; "),
+			),
+			"Please try again later or add --force to apply anyway.",
+		)
+	}
+
+	return nil
+}
+
+// runApply runs an apply in the argument clusters, posting the results as a comment. It
+// returns the errors in each of the clusters that were applied, if any were, along with the
+// overall error.
+func (whh *WebhookHandler) runApply(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+	flags map[string]string,
+) (clusterErrors, error) {
+	client := webhookContext.pullRequestClient
+
 	err := client.UpdateStatus(
 		ctx,
 		"pending",
@@ -379,8 +931,9 @@ func (whh *WebhookHandler) runApply(
 	}
 
 	var applyErr error
+	var clusterErrs clusterErrors
 
-	statusOK := statusOKToApply(ctx, client)
+	statusOK := statusOKToApply(ctx, client, whh.settings.StatusContextPrefix)
 	approved := client.Approved(ctx)
 	behindBy := client.BehindBy()
 	applyData := pullreq.ApplyCommentData{
@@ -389,33 +942,40 @@ func (whh *WebhookHandler) runApply(
 		Env:               whh.settings.Env,
 	}
 
-	overrideReviewRequired := true
-
-	for _, clusterClient := range clusterClients {
-		if !clusterClient.Config().GithubReviewOptional {
-			overrideReviewRequired = false
-			break
-		}
-	}
+	overrideReviewRequired := reviewOptional(clusterClients)
 	if overrideReviewRequired {
 		log.Info(
 			"Overriding review required because all clusters in this change have review optional",
 		)
 	}
 
-	if (whh.settings.StrictCheck || whh.settings.GreenCIRequired) && !statusOK {
+	if disabledClusters := applyDisabledClusters(clusterClients); len(disabledClusters) > 0 {
+		applyErr = multilineError(
+			ErrCommandDisabled,
+			fmt.Sprintf(
+				"Cannot run apply because applies are disabled for clusters %s.",
+				strings.Join(disabledClusters, ", "),
+			),
+			"These clusters are observe-only via disableApply in their configs; diffs still work.",
+		)
+	} else if teamsErr := checkApplyTeams(ctx, webhookContext, clusterClients); teamsErr != nil {
+		applyErr = teamsErr
+	} else if (whh.settings.StrictCheck || whh.settings.GreenCIRequired) && !statusOK {
 		applyErr = multilineError(
+			ErrStatusNotGreen,
 			"Cannot run apply because green-ci-required is set to true and commit status is not green.",
 			"Please fix status and try again.",
 		)
 	} else if (whh.settings.StrictCheck || whh.settings.ReviewRequired) &&
 		!approved && !overrideReviewRequired {
 		applyErr = multilineError(
+			ErrNotApproved,
 			"Cannot run apply because review-required is set to true and request is not approved.",
 			"Please get at least one approval and try again.",
 		)
 	} else if behindBy > 0 {
 		applyErr = multilineError(
+			ErrBehind,
 			fmt.Sprintf(
 				"Cannot run apply because branch is behind %s by %d commits.",
 				client.Base(),
@@ -423,44 +983,29 @@ func (whh *WebhookHandler) runApply(
 			),
 			"Please re-merge and try again.",
 		)
+	} else if intervalErr := checkMinApplyIntervals(
+		ctx,
+		clusterClients,
+		boolFlag(flags, "force"),
+	); intervalErr != nil {
+		applyErr = intervalErr
+	} else if gateErr := whh.checkPreApplyGate(ctx, webhookContext, clusterClients); gateErr != nil {
+		applyErr = gateErr
 	} else {
-		for _, clusterClient := range clusterClients {
-			clusterName := clusterClient.Config().DescriptiveName()
-
-			if err := clusterClient.Config().CheckVersion(whh.settings.Version); err != nil {
-				applyErr = fmt.Errorf(
-					"Failed version check for cluster %s: %+v",
-					clusterName,
-					err,
-				)
-				break
-			}
+		applyData.ClusterApplies, clusterErrs, applyErr = whh.applyClusters(
+			ctx,
+			clusterClients,
+			boolFlag(flags, "resume"),
+		)
 
-			applyCtx, cancel := context.WithTimeout(ctx, applyTimeout)
-			defer cancel()
-
-			results, err := clusterClient.ApplyStructured(
-				applyCtx,
-				clusterClient.Config().AbsSubpaths(),
-				clusterClient.Config().ServerSideApply,
-			)
-			if err != nil {
-				applyErr = fmt.Errorf(
-					"Error applying for cluster %s: %+v",
-					clusterName,
-					err,
-				)
-				break
-			}
-
-			applyData.ClusterApplies = append(
-				applyData.ClusterApplies,
-				pullreq.ClusterApply{
-					ClusterConfig: clusterClient.Config(),
-					Results:       results,
-				},
-			)
-		}
+		// Only notify about the clusters that were actually applied; if none were, e.g.
+		// because of a version mismatch, then there's nothing to notify about.
+		whh.notifyApply(
+			ctx,
+			webhookContext,
+			appliedClusterClients(clusterClients, clusterErrs),
+			applyErr,
+		)
 	}
 
 	if applyErr != nil {
@@ -475,7 +1020,7 @@ func (whh *WebhookHandler) runApply(
 			),
 			whh.settings.LogsURL,
 		)
-		return applyErr
+		return clusterErrs, applyErr
 	}
 
 	commentBody, err := pullreq.FormatApplyComment(applyData)
@@ -490,7 +1035,7 @@ func (whh *WebhookHandler) runApply(
 			),
 			whh.settings.LogsURL,
 		)
-		return err
+		return clusterErrs, err
 	}
 
 	err = client.PostComment(ctx, commentBody)
@@ -498,10 +1043,7 @@ func (whh *WebhookHandler) runApply(
 		log.Warnf("Error posting response: %+v", err)
 	}
 
-	noAutoMergeValue, ok := flags["no-auto-merge"]
-	noAutoMerge := ok && (noAutoMergeValue == "" || strings.ToLower(noAutoMergeValue) == "true")
-
-	if noAutoMerge {
+	if boolFlag(flags, "no-auto-merge") {
 		err = client.UpdateStatus(
 			ctx,
 			"failure",
@@ -529,14 +1071,343 @@ func (whh *WebhookHandler) runApply(
 		log.Warnf("Error updating status: %+v", err)
 	}
 
+	return clusterErrs, nil
+}
+
+// refreshApplyStatus sets the apply status based on whether the pull request has the approval
+// that's required to apply it. The status is left as-is if the apply already succeeded.
+func (whh *WebhookHandler) refreshApplyStatus(
+	ctx context.Context,
+	client pullreq.PullRequestClient,
+	clusterClients []cluster.ClusterClient,
+) error {
+	applyContext := whh.commandContext(commandApply)
+
+	statuses, err := client.Statuses(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.Context == applyContext && status.IsSuccess() {
+			log.Info("Apply already succeeded, not updating its status")
+			return nil
+		}
+	}
+
+	var description string
+
+	if client.Approved(ctx) || reviewOptional(clusterClients) {
+		description = fmt.Sprintf(
+			"Approved, ready to run for clusters %s",
+			hashedClusterNames(clusterClients),
+		)
+	} else {
+		description = fmt.Sprintf(
+			"Waiting for approval to run for clusters %s",
+			hashedClusterNames(clusterClients),
+		)
+	}
+
+	return client.UpdateStatus(
+		ctx,
+		"pending",
+		applyContext,
+		description,
+		whh.settings.LogsURL,
+	)
+}
+
+// reviewOptional returns whether all of the argument clusters have reviews marked as optional
+// via GithubReviewOptional, in which case an approval isn't required to apply them.
+func reviewOptional(clusterClients []cluster.ClusterClient) bool {
+	for _, clusterClient := range clusterClients {
+		if !clusterClient.Config().GithubReviewOptional {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPreApplyGate runs the pre-apply gate from the settings, returning an error with the
+// reason if the apply isn't allowed.
+func (whh *WebhookHandler) checkPreApplyGate(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+) error {
+	allowed, reason, err := whh.settings.PreApplyGate.AllowApply(
+		ctx,
+		webhookContext,
+		clusterClients,
+	)
+	if err != nil {
+		return multilineError(
+			ErrInternal,
+			"Cannot run apply because the pre-apply gate failed:",
+			err.Error(),
+		)
+	} else if !allowed {
+		return multilineError(
+			ErrApplyDenied,
+			"Cannot run apply because it was denied by the pre-apply gate:",
+			reason,
+		)
+	}
+
 	return nil
 }
 
+// boolFlag returns whether the argument boolean flag is set in a comment command, either as
+// just --flag or as --flag=true.
+func boolFlag(flags map[string]string, name string) bool {
+	value, ok := flags[name]
+	return ok && (value == "" || strings.ToLower(value) == "true")
+}
+
+// appliedClusterClients returns the cluster clients that applies were attempted in according
+// to the argument cluster errors, i.e. not the ones that were skipped when resuming or that
+// weren't reached because of an earlier failure.
+func appliedClusterClients(
+	clusterClients []cluster.ClusterClient,
+	clusterErrs clusterErrors,
+) []cluster.ClusterClient {
+	applied := []cluster.ClusterClient{}
+	for _, clusterClient := range clusterClients {
+		if _, ok := clusterErrs[clusterClient.Config().DescriptiveName()]; ok {
+			applied = append(applied, clusterClient)
+		}
+	}
+	return applied
+}
+
+// applyClusters applies in each of the argument clusters, running up to
+// MaxConcurrentApplies applies at once. The results are returned in the same order as the
+// clients. If any apply fails, then no further applies are started and the error for the
+// first failed cluster is returned. If resume is true, then clusters that were already
+// applied at the head SHA of the pull request are skipped. The errors in each of the clusters
+// that were applied are also returned; if the applies couldn't be started, these are nil.
+func (whh *WebhookHandler) applyClusters(
+	ctx context.Context,
+	clusterClients []cluster.ClusterClient,
+	resume bool,
+) ([]pullreq.ClusterApply, clusterErrors, error) {
+	for _, clusterClient := range clusterClients {
+		if err := clusterClient.Config().CheckVersion(whh.settings.Version); err != nil {
+			return nil, nil, newHandlerError(
+				ErrVersionMismatch,
+				"Failed version check for cluster %s: %+v",
+				clusterClient.Config().DescriptiveName(),
+				err,
+			)
+		}
+	}
+
+	maxConcurrent := whh.settings.MaxConcurrentApplies
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	clusterApplies := make([]pullreq.ClusterApply, len(clusterClients))
+	applyErrs := make([]error, len(clusterClients))
+	applied := make([]bool, len(clusterClients))
+
+	sem := make(chan struct{}, maxConcurrent)
+	wg := sync.WaitGroup{}
+	mutex := sync.Mutex{}
+	failed := false
+
+	for c, clusterClient := range clusterClients {
+		sem <- struct{}{}
+
+		mutex.Lock()
+		stop := failed
+		mutex.Unlock()
+
+		if stop {
+			<-sem
+			break
+		}
+
+		if resume && whh.appliedAtHead(ctx, clusterClient) {
+			log.Infof(
+				"Skipping cluster %s because it was already applied at this SHA",
+				clusterClient.Config().DescriptiveName(),
+			)
+			clusterApplies[c] = pullreq.ClusterApply{
+				ClusterConfig: clusterClient.Config(),
+				Skipped:       true,
+			}
+			<-sem
+			continue
+		}
+
+		wg.Add(1)
+		applied[c] = true
+
+		go func(c int, clusterClient cluster.ClusterClient) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			applyCtx, cancel := context.WithTimeout(ctx, applyTimeout)
+			defer cancel()
+
+			results, err := clusterClient.ApplyStructured(
+				applyCtx,
+				clusterClient.Config().AbsSubpaths(),
+				clusterClient.Config().ServerSideApply,
+			)
+			if err != nil {
+				mutex.Lock()
+				failed = true
+				mutex.Unlock()
+
+				applyErrs[c] = newHandlerError(
+					ErrKubectl,
+					"Error applying for cluster %s: %+v",
+					clusterClient.Config().DescriptiveName(),
+					err,
+				)
+				return
+			}
+
+			clusterApplies[c] = pullreq.ClusterApply{
+				ClusterConfig: clusterClient.Config(),
+				Results:       results,
+			}
+		}(c, clusterClient)
+	}
+
+	wg.Wait()
+
+	clusterErrs := clusterErrors{}
+	for c, clusterClient := range clusterClients {
+		if applied[c] {
+			clusterErrs[clusterClient.Config().DescriptiveName()] = applyErrs[c]
+		}
+	}
+
+	for _, err := range applyErrs {
+		if err != nil {
+			return nil, clusterErrs, err
+		}
+	}
+
+	return clusterApplies, clusterErrs, nil
+}
+
+// appliedAtHead returns whether the argument cluster was already applied at the head SHA. Errors
+// are logged and treated as the cluster not being applied so that it's applied again.
+func (whh *WebhookHandler) appliedAtHead(
+	ctx context.Context,
+	clusterClient cluster.ClusterClient,
+) bool {
+	applied, err := clusterClient.AppliedAtHead(ctx)
+	if err != nil {
+		log.Warnf(
+			"Error checking previous applies for cluster %s: %+v",
+			clusterClient.Config().DescriptiveName(),
+			err,
+		)
+		return false
+	}
+	return applied
+}
+
+// notifyApply sends notifications about the result of an apply. Notifications are best-effort,
+// so errors are logged but not returned.
+func (whh *WebhookHandler) notifyApply(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+	applyErr error,
+) {
+	// Group clusters by env so that per-env notification filters can be applied
+	envs := []string{}
+	clustersByEnv := map[string][]string{}
+
+	for _, clusterClient := range clusterClients {
+		env := clusterClient.Config().Env
+		if _, ok := clustersByEnv[env]; !ok {
+			envs = append(envs, env)
+		}
+		clustersByEnv[env] = append(
+			clustersByEnv[env],
+			clusterClient.Config().DescriptiveName(),
+		)
+	}
+
+	for _, env := range envs {
+		err := whh.settings.Notifier.NotifyApply(
+			ctx,
+			notify.ApplyResult{
+				Env:            env,
+				Clusters:       clustersByEnv[env],
+				SHA:            webhookContext.pullRequestClient.HeadSHA(),
+				PullRequestURL: pullRequestURL(webhookContext),
+				Err:            applyErr,
+			},
+		)
+		if err != nil {
+			log.Warnf("Error sending apply notification: %+v", err)
+		}
+	}
+}
+
+// publishResults publishes the result of the argument command in each of the argument
+// clusters that it ran in, using each cluster's own outcome in clusterErrs. Clusters that the
+// command didn't get to, e.g. because an earlier cluster failed or the command was rejected
+// before running, are skipped. Publishing is best-effort, so errors are logged but not
+// returned.
+func (whh *WebhookHandler) publishResults(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	cmd command,
+	clusterClients []cluster.ClusterClient,
+	clusterErrs clusterErrors,
+) {
+	for _, clusterClient := range clusterClients {
+		clusterErr, ok := clusterErrs[clusterClient.Config().DescriptiveName()]
+		if !ok {
+			continue
+		}
+
+		event := CommandEvent{
+			Command:        string(cmd),
+			Env:            clusterClient.Config().Env,
+			Cluster:        clusterClient.Config().DescriptiveName(),
+			SHA:            webhookContext.pullRequestClient.HeadSHA(),
+			PullRequestURL: pullRequestURL(webhookContext),
+			Success:        clusterErr == nil,
+		}
+		if clusterErr != nil {
+			event.Error = clusterErr.Error()
+		}
+
+		if err := whh.settings.Publisher.Publish(ctx, event); err != nil {
+			log.Warnf("Error publishing %s event: %+v", cmd, err)
+		}
+	}
+}
+
+func pullRequestURL(webhookContext *WebhookContext) string {
+	return fmt.Sprintf(
+		"https://github.com/%s/%s/pull/%d",
+		webhookContext.owner,
+		webhookContext.repo,
+		webhookContext.pullRequestNum,
+	)
+}
+
+// runDiffs runs a diff in each of the argument clusters, posting the results as a comment. It
+// returns the errors in each of the clusters that were diffed along with the overall error;
+// clusters after the first failed one aren't diffed.
 func (whh *WebhookHandler) runDiffs(
 	ctx context.Context,
 	client pullreq.PullRequestClient,
 	clusterClients []cluster.ClusterClient,
-) error {
+) (clusterErrors, error) {
 	err := client.UpdateStatus(
 		ctx,
 		"pending",
@@ -555,47 +1426,89 @@ func (whh *WebhookHandler) runDiffs(
 		ClusterDiffs:      []pullreq.ClusterDiff{},
 		PullRequestClient: client,
 		Env:               whh.settings.Env,
+
+		MaxDiffLinesPerResource: whh.settings.MaxDiffLinesPerResource,
+		GroupByNamespace:        whh.settings.GroupDiffsByNamespace,
 	}
 
 	var diffErr error
+	clusterErrs := clusterErrors{}
 
 	for _, clusterClient := range clusterClients {
 		clusterName := clusterClient.Config().DescriptiveName()
 
 		if err := clusterClient.Config().CheckVersion(whh.settings.Version); err != nil {
-			diffErr = fmt.Errorf(
+			diffErr = newHandlerError(
+				ErrVersionMismatch,
 				"Failed version check for cluster %s: %+v",
 				clusterName,
 				err,
 			)
+			clusterErrs[clusterName] = diffErr
 			break
 		}
 
 		diffCtx, cancel := context.WithTimeout(ctx, diffTimeout)
 		defer cancel()
 
+		var incremental *incrementalDiff
+		subpaths := clusterClient.Config().AbsSubpaths()
+
+		if whh.settings.IncrementalDiffs {
+			incremental = whh.getIncrementalDiff(ctx, client, clusterClient)
+			if incremental != nil {
+				subpaths = incremental.absSubpaths
+			}
+		}
+
 		results, err := clusterClient.DiffStructured(
 			diffCtx,
-			clusterClient.Config().AbsSubpaths(),
-			clusterClient.Config().ServerSideApply,
+			subpaths,
+			clusterClient.Config().UseServerSideDiff(),
 			"",
 		)
 		if err != nil {
-			diffErr = fmt.Errorf(
+			diffErr = newHandlerError(
+				ErrKubectl,
 				"Error diffing for cluster %s: %+v",
 				clusterName,
 				err,
 			)
+			clusterErrs[clusterName] = diffErr
 			break
 		}
 
-		diffData.ClusterDiffs = append(
-			diffData.ClusterDiffs,
-			pullreq.ClusterDiff{
-				ClusterConfig: clusterClient.Config(),
-				Results:       results,
-			},
-		)
+		clusterDiff := pullreq.ClusterDiff{
+			ClusterConfig:   clusterClient.Config(),
+			Results:         results.Results,
+			HiddenHelmHooks: results.HiddenHelmHooks,
+		}
+		if incremental != nil {
+			clusterDiff.IncrementalSince = incremental.since
+			clusterDiff.IncrementalSubpaths = incremental.subpaths
+		}
+
+		if whh.settings.DryRunSummaries {
+			dryRunResults, err := clusterClient.DryRunStructured(
+				diffCtx,
+				subpaths,
+				clusterClient.Config().UseServerSideDiff(),
+			)
+			if err != nil {
+				diffErr = newHandlerError(
+					ErrKubectl,
+					"Error running dry-run apply for cluster %s: %+v",
+					clusterName,
+					err,
+				)
+				clusterErrs[clusterName] = diffErr
+				break
+			}
+			clusterDiff.DryRunResults = dryRunResults
+		}
+
+		diffData.ClusterDiffs = append(diffData.ClusterDiffs, clusterDiff)
+		clusterErrs[clusterName] = nil
 	}
 
 	if diffErr != nil {
@@ -610,7 +1523,7 @@ func (whh *WebhookHandler) runDiffs(
 			),
 			whh.settings.LogsURL,
 		)
-		return diffErr
+		return clusterErrs, diffErr
 	}
 
 	commentBody, err := pullreq.FormatDiffComment(diffData)
@@ -626,7 +1539,7 @@ func (whh *WebhookHandler) runDiffs(
 			),
 			whh.settings.LogsURL,
 		)
-		return err
+		return clusterErrs, err
 	}
 
 	err = client.PostComment(ctx, commentBody)
@@ -648,7 +1561,117 @@ func (whh *WebhookHandler) runDiffs(
 		log.Warnf("Error updating status: %+v", err)
 	}
 
-	return nil
+	return clusterErrs, nil
+}
+
+// incrementalDiff describes the subset of a cluster's subpaths that are diffed in an
+// incremental diff.
+type incrementalDiff struct {
+	// since is the SHA of the previous diff in the pull request.
+	since string
+
+	// subpaths are the changed subpaths, relative to the cluster's expanded configs.
+	subpaths []string
+
+	// absSubpaths are the absolute versions of subpaths.
+	absSubpaths []string
+}
+
+// getIncrementalDiff returns the subpaths of the argument cluster that have changed since the
+// last recorded diff in the current pull request. If these can't be determined or a full diff
+// is needed, then nil is returned.
+func (whh *WebhookHandler) getIncrementalDiff(
+	ctx context.Context,
+	client pullreq.PullRequestClient,
+	clusterClient cluster.ClusterClient,
+) *incrementalDiff {
+	clusterConfig := clusterClient.Config()
+	clusterName := clusterConfig.DescriptiveName()
+
+	lastDiffSHA, err := clusterClient.LastDiffSHA(ctx)
+	if err != nil {
+		log.Warnf("Error getting last diff SHA for cluster %s: %+v", clusterName, err)
+		return nil
+	} else if lastDiffSHA == "" || lastDiffSHA == client.HeadSHA() {
+		log.Infof("Doing full diff for cluster %s", clusterName)
+		return nil
+	}
+
+	changedFiles, err := client.ChangedFilesSince(ctx, lastDiffSHA)
+	if err != nil {
+		log.Warnf(
+			"Error getting changed files since %s, doing full diff: %+v",
+			lastDiffSHA,
+			err,
+		)
+		return nil
+	}
+
+	changedSubpaths, err := pullreq.IncrementalSubpaths(clusterConfig, changedFiles)
+	if err != nil {
+		log.Warnf("Error getting incremental subpaths for cluster %s: %+v", clusterName, err)
+		return nil
+	}
+
+	// Only diff the changes that are within the subpaths that will be applied
+	result := &incrementalDiff{
+		since:       lastDiffSHA,
+		subpaths:    []string{},
+		absSubpaths: []string{},
+	}
+	added := map[string]struct{}{}
+
+	for _, changedSubpath := range changedSubpaths {
+		absChangedSubpath := filepath.Join(clusterConfig.ExpandedPath, changedSubpath)
+
+		for _, absSubpath := range clusterConfig.AbsSubpaths() {
+			var absDiffSubpath string
+
+			if isSubpath(absSubpath, absChangedSubpath) {
+				absDiffSubpath = absChangedSubpath
+			} else if isSubpath(absChangedSubpath, absSubpath) {
+				absDiffSubpath = absSubpath
+			} else {
+				continue
+			}
+
+			if _, ok := added[absDiffSubpath]; ok {
+				continue
+			}
+			added[absDiffSubpath] = struct{}{}
+
+			relDiffSubpath, err := filepath.Rel(clusterConfig.ExpandedPath, absDiffSubpath)
+			if err != nil {
+				log.Warnf("Error getting relative path for %s: %+v", absDiffSubpath, err)
+				return nil
+			}
+			result.subpaths = append(result.subpaths, relDiffSubpath)
+			result.absSubpaths = append(result.absSubpaths, absDiffSubpath)
+		}
+	}
+
+	if len(result.subpaths) == 0 {
+		log.Infof(
+			"No changes in cluster %s since %s, doing full diff",
+			clusterName,
+			lastDiffSHA,
+		)
+		return nil
+	}
+
+	log.Infof(
+		"Diffing subpaths %+v in cluster %s changed since %s",
+		result.subpaths,
+		clusterName,
+		lastDiffSHA,
+	)
+	return result
+}
+
+// isSubpath returns whether path is equal to or inside of the argument parent path.
+func isSubpath(parent string, path string) bool {
+	relPath, err := filepath.Rel(parent, path)
+	return err == nil && relPath != ".." && !strings.HasPrefix(relPath, "../")
 }
 
 func (whh *WebhookHandler) runStatus(
@@ -698,13 +1721,21 @@ func (whh *WebhookHandler) runStatus(
 			break
 		}
 
-		statusData.ClusterStatuses = append(
-			statusData.ClusterStatuses,
-			pullreq.ClusterStatus{
-				ClusterConfig: clusterClient.Config(),
-				HealthSummary: string(results),
-			},
-		)
+		clusterStatus := pullreq.ClusterStatus{
+			ClusterConfig: clusterClient.Config(),
+			HealthSummary: string(results),
+		}
+
+		if whh.settings.StatusJSON {
+			structuredSummary, err := clusterClient.SummaryStructured(statusCtx)
+			if err != nil {
+				statusErr = fmt.Errorf("Error getting structured status: %+v", err)
+				break
+			}
+			clusterStatus.StructuredSummary = &structuredSummary
+		}
+
+		statusData.ClusterStatuses = append(statusData.ClusterStatuses, clusterStatus)
 	}
 
 	if statusErr != nil {
@@ -761,21 +1792,95 @@ func (whh *WebhookHandler) runStatus(
 	return nil
 }
 
-func (whh *WebhookHandler) runHelp(
+// runLocks posts a comment with the state of the kubeapply locks in each of the argument
+// clusters.
+func (whh *WebhookHandler) runLocks(
 	ctx context.Context,
 	client pullreq.PullRequestClient,
 	clusterClients []cluster.ClusterClient,
 ) error {
+	locksData := pullreq.LocksCommentData{
+		ClusterLocks: []pullreq.ClusterLock{},
+		Env:          whh.settings.Env,
+	}
+	now := time.Now()
+
+	for _, clusterClient := range clusterClients {
+		lock, err := clusterClient.Lock(ctx)
+		if err != nil {
+			err = fmt.Errorf(
+				"Error getting lock for cluster %s: %+v",
+				clusterClient.Config().DescriptiveName(),
+				err,
+			)
+			client.PostErrorComment(ctx, whh.settings.Env, err)
+			return err
+		}
+
+		locksData.ClusterLocks = append(
+			locksData.ClusterLocks,
+			pullreq.ClusterLock{
+				ClusterConfig: clusterClient.Config(),
+				Lock:          lock,
+				Held:          lock != nil && lock.Held(now),
+			},
+		)
+	}
+
+	commentBody, err := pullreq.FormatLocksComment(locksData)
+	if err != nil {
+		return err
+	}
+
+	return client.PostComment(ctx, commentBody)
+}
+
+// runHelp posts a help comment that lists the argument clusters. If any of the clusters
+// restrict applies to teams, then the comment also shows whether the actor of the argument
+// webhook can apply in each one.
+func (whh *WebhookHandler) runHelp(
+	ctx context.Context,
+	webhookContext *WebhookContext,
+	clusterClients []cluster.ClusterClient,
+) error {
+	client := webhookContext.pullRequestClient
+	actor := webhookContext.Actor()
+
 	helpData := pullreq.HelpCommentData{
 		ClusterConfigs: []*config.ClusterConfig{},
 		Env:            whh.settings.Env,
 	}
+	applyAuthorized := map[string]bool{}
+	hasApplyTeams := false
 
 	for _, clusterClient := range clusterClients {
 		helpData.ClusterConfigs = append(
 			helpData.ClusterConfigs,
 			clusterClient.Config(),
 		)
+
+		if len(clusterClient.Config().ApplyTeams) > 0 {
+			hasApplyTeams = true
+		}
+	}
+
+	if hasApplyTeams && actor != "" {
+		for _, clusterClient := range clusterClients {
+			member, err := canApply(ctx, webhookContext, clusterClient.Config())
+			if err != nil {
+				// The authorizations are just informational, so leave them out instead of
+				// failing the whole comment.
+				log.Warnf("Error checking apply teams for help comment: %+v", err)
+				applyAuthorized = nil
+				break
+			}
+			applyAuthorized[clusterClient.Config().DescriptiveName()] = member
+		}
+
+		if applyAuthorized != nil {
+			helpData.Actor = actor
+			helpData.ApplyAuthorized = applyAuthorized
+		}
 	}
 
 	commentBody, err := pullreq.FormatHelpComment(helpData)
@@ -788,13 +1893,19 @@ func (whh *WebhookHandler) runHelp(
 }
 
 func (whh *WebhookHandler) commandContext(cmd command) string {
-	return fmt.Sprintf("kubeapply/%s (%s)", string(cmd), whh.settings.Env)
+	return fmt.Sprintf(
+		"%s/%s (%s)",
+		whh.settings.StatusContextPrefix,
+		string(cmd),
+		whh.settings.Env,
+	)
 }
 
 func (whh *WebhookHandler) incrementStat(
 	name string,
 	webhookContext *WebhookContext,
 	commandStr string,
+	extraTags ...string,
 ) error {
 	tags := []string{
 		fmt.Sprintf("owner:%s", webhookContext.owner),
@@ -803,6 +1914,7 @@ func (whh *WebhookHandler) incrementStat(
 	if commandStr != "" {
 		tags = append(tags, fmt.Sprintf("command:%s", commandStr))
 	}
+	tags = append(tags, extraTags...)
 
 	return whh.statsClient.Update(
 		[]string{name},
@@ -812,8 +1924,59 @@ func (whh *WebhookHandler) incrementStat(
 	)
 }
 
-func multilineError(lines ...string) error {
-	return errors.New(strings.Join(lines, "\n"))
+// clusterErrors records the outcome of a command in each of the clusters that it ran in, keyed
+// by the clusters' descriptive names. Clusters that the command didn't get to, e.g. because an
+// earlier cluster failed, aren't included.
+type clusterErrors map[string]error
+
+// incrementClusterStats increments a success or error stat for the argument command in each
+// of the argument clusters if ClusterStats is set. Each cluster that the command ran in is
+// tagged with its own outcome in clusterErrs, and clusters that the command didn't get to are
+// skipped. If clusterErrs is nil, then the command didn't run in any clusters, e.g. because
+// the pull request wasn't approved, so every cluster gets the command's error.
+func (whh *WebhookHandler) incrementClusterStats(
+	webhookContext *WebhookContext,
+	cmd command,
+	clusterClients []cluster.ClusterClient,
+	clusterErrs clusterErrors,
+	cmdErr error,
+) {
+	if !whh.settings.ClusterStats {
+		return
+	}
+
+	for _, clusterClient := range clusterClients {
+		clusterErr := cmdErr
+		if clusterErrs != nil {
+			var ok bool
+			clusterErr, ok = clusterErrs[clusterClient.Config().DescriptiveName()]
+			if !ok {
+				continue
+			}
+		}
+
+		name := "handler.cluster.success"
+		tags := []string{
+			fmt.Sprintf("env:%s", clusterClient.Config().Env),
+			fmt.Sprintf("region:%s", clusterClient.Config().Region),
+			fmt.Sprintf("cluster:%s", clusterClient.Config().Cluster),
+		}
+		if clusterErr != nil {
+			name = "handler.cluster.error"
+			tags = append(tags, errorKindTag(clusterErr))
+		}
+
+		if err := whh.incrementStat(name, webhookContext, string(cmd), tags...); err != nil {
+			log.Warnf("Error updating %s stat: %+v", name, err)
+		}
+	}
+}
+
+func multilineError(kind ErrorKind, lines ...string) error {
+	return &HandlerError{
+		Kind: kind,
+		Err:  errors.New(strings.Join(lines, "\n")),
+	}
 }
 
 // hashedClusterNames returns a string of comma-separated, hashed cluster names for status
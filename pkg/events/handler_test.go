@@ -2,16 +2,21 @@ package events
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/google/go-github/v30/github"
 	"github.com/segmentio/kubeapply/pkg/cluster"
+	"github.com/segmentio/kubeapply/pkg/cluster/apply"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/notify"
 	"github.com/segmentio/kubeapply/pkg/pullreq"
 	"github.com/segmentio/kubeapply/pkg/stats"
 	"github.com/stretchr/testify/assert"
@@ -36,6 +41,7 @@ func TestHandleWebhook(t *testing.T) {
 		reviewRequired  bool
 		automerge       bool
 		kubectlErr      bool
+		preApplyGate    PreApplyGate
 		input           *WebhookContext
 		expRespStatus   int
 		expMerged       bool
@@ -642,7 +648,7 @@ func TestHandleWebhook(t *testing.T) {
 					},
 				},
 			},
-			expRespStatus: 500,
+			expRespStatus: 400,
 			expComments: []commentMatch{
 				{
 					contains: []string{
@@ -658,6 +664,134 @@ func TestHandleWebhook(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "kubeapply apply allowed by gate",
+			preApplyGate: PreApplyGateFunc(
+				func(
+					ctx context.Context,
+					webhookContext *WebhookContext,
+					clusterClients []cluster.ClusterClient,
+				) (bool, string, error) {
+					return webhookContext.Actor() == "test-user" && len(clusterClients) == 2, "", nil
+				},
+			),
+			input: &WebhookContext{
+				pullRequestClient: &pullreq.FakePullRequestClient{
+					ClusterConfigs:  testClusterConfigs,
+					RequestStatuses: []pullreq.PullRequestStatus{},
+					ApprovedVal:     true,
+					Mergeable:       true,
+				},
+				commentType: commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String("kubeapply apply"),
+						User: &github.User{
+							Login: aws.String("test-user"),
+						},
+					},
+				},
+			},
+			expRespStatus: 200,
+			expComments: []commentMatch{
+				{
+					contains: []string{
+						"Kubeapply apply result (test-env)",
+					},
+				},
+			},
+			expRepoStatuses: []statusMatch{
+				{
+					context: "kubeapply/apply (test-env)",
+					state:   "success",
+				},
+			},
+		},
+		{
+			description: "kubeapply apply denied by gate",
+			preApplyGate: PreApplyGateFunc(
+				func(
+					ctx context.Context,
+					webhookContext *WebhookContext,
+					clusterClients []cluster.ClusterClient,
+				) (bool, string, error) {
+					return false, "Changes are frozen until Monday.", nil
+				},
+			),
+			input: &WebhookContext{
+				pullRequestClient: &pullreq.FakePullRequestClient{
+					ClusterConfigs:  testClusterConfigs,
+					RequestStatuses: []pullreq.PullRequestStatus{},
+					ApprovedVal:     true,
+					Mergeable:       true,
+				},
+				commentType: commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String("kubeapply apply"),
+					},
+				},
+			},
+			expRespStatus: 400,
+			expComments: []commentMatch{
+				{
+					contains: []string{
+						"Error comment: Cannot run apply because it was denied by the pre-apply gate",
+						"Changes are frozen until Monday.",
+					},
+				},
+			},
+			expRepoStatuses: []statusMatch{
+				{
+					context: "kubeapply/apply (test-env)",
+					state:   "failure",
+				},
+			},
+		},
+		{
+			description: "kubeapply apply gate error",
+			preApplyGate: PreApplyGateFunc(
+				func(
+					ctx context.Context,
+					webhookContext *WebhookContext,
+					clusterClients []cluster.ClusterClient,
+				) (bool, string, error) {
+					return true, "", errors.New("lookup failed")
+				},
+			),
+			input: &WebhookContext{
+				pullRequestClient: &pullreq.FakePullRequestClient{
+					ClusterConfigs:  testClusterConfigs,
+					RequestStatuses: []pullreq.PullRequestStatus{},
+					ApprovedVal:     true,
+					Mergeable:       true,
+				},
+				commentType: commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String("kubeapply apply"),
+					},
+				},
+			},
+			expRespStatus: 500,
+			expComments: []commentMatch{
+				{
+					contains: []string{
+						"Cannot run apply because the pre-apply gate failed",
+						"lookup failed",
+					},
+				},
+			},
+			expRepoStatuses: []statusMatch{
+				{
+					context: "kubeapply/apply (test-env)",
+					state:   "failure",
+				},
+			},
+		},
 		{
 			description: "kubeapply apply not approved (not strict)",
 			input: &WebhookContext{
@@ -713,7 +847,7 @@ func TestHandleWebhook(t *testing.T) {
 					},
 				},
 			},
-			expRespStatus: 500,
+			expRespStatus: 400,
 			expComments: []commentMatch{
 				{
 					contains: []string{
@@ -747,7 +881,7 @@ func TestHandleWebhook(t *testing.T) {
 					},
 				},
 			},
-			expRespStatus: 500,
+			expRespStatus: 400,
 			expComments: []commentMatch{
 				{
 					contains: []string{
@@ -781,7 +915,7 @@ func TestHandleWebhook(t *testing.T) {
 					},
 				},
 			},
-			expRespStatus: 500,
+			expRespStatus: 400,
 			expComments: []commentMatch{
 				{
 					contains: []string{
@@ -902,7 +1036,7 @@ func TestHandleWebhook(t *testing.T) {
 					},
 				},
 			},
-			expRespStatus: 500,
+			expRespStatus: 400,
 			expComments: []commentMatch{
 				{
 					contains: []string{
@@ -945,7 +1079,7 @@ func TestHandleWebhook(t *testing.T) {
 					},
 				},
 			},
-			expRespStatus: 500,
+			expRespStatus: 400,
 			expComments: []commentMatch{
 				{
 					contains: []string{
@@ -1242,6 +1376,7 @@ func TestHandleWebhook(t *testing.T) {
 				GreenCIRequired: testCase.greenCIRequired,
 				ReviewRequired:  testCase.reviewRequired,
 				Automerge:       testCase.automerge,
+				PreApplyGate:    testCase.preApplyGate,
 				Debug:           false,
 			},
 		)
@@ -1319,3 +1454,1932 @@ func TestHandleWebhook(t *testing.T) {
 		}
 	}
 }
+
+func TestRepoSettings(t *testing.T) {
+	repoSettings, err := ParseRepoSettings(
+		`{"segmentio/repo1": {"env": "production", "automerge": true, "reviewRequired": true}, "segmentio/repo2": {"strictCheck": false}}`,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(repoSettings))
+
+	settings := WebhookHandlerSettings{
+		Env:          "stage",
+		StrictCheck:  true,
+		RepoSettings: repoSettings,
+	}
+
+	repo1Settings := settings.forRepo("segmentio", "repo1")
+	assert.Equal(t, "production", repo1Settings.Env)
+	assert.True(t, repo1Settings.Automerge)
+	assert.True(t, repo1Settings.StrictCheck)
+	assert.True(t, repo1Settings.ReviewRequired)
+	assert.False(t, repo1Settings.GreenCIRequired)
+
+	repo2Settings := settings.forRepo("segmentio", "repo2")
+	assert.Equal(t, "stage", repo2Settings.Env)
+	assert.False(t, repo2Settings.StrictCheck)
+
+	otherSettings := settings.forRepo("segmentio", "other")
+	assert.Equal(t, "stage", otherSettings.Env)
+	assert.True(t, otherSettings.StrictCheck)
+
+	// Original settings are unchanged
+	assert.Equal(t, "stage", settings.Env)
+	assert.True(t, settings.StrictCheck)
+
+	emptySettings, err := ParseRepoSettings("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(emptySettings))
+
+	_, err = ParseRepoSettings(`{"repo1": {}}`)
+	assert.Error(t, err)
+
+	_, err = ParseRepoSettings(`not json`)
+	assert.Error(t, err)
+}
+
+func TestApplyClustersMaxConcurrency(t *testing.T) {
+	ctx := context.Background()
+	tracker := &concurrencyTracker{}
+
+	clusterClients := []cluster.ClusterClient{}
+	for i := 0; i < 6; i++ {
+		clusterConfig := &config.ClusterConfig{
+			Cluster: fmt.Sprintf("test-cluster%d", i),
+			Region:  "test-region",
+			Env:     "test-env",
+		}
+		require.NoError(
+			t,
+			clusterConfig.SetDefaults(
+				fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+				"/git/repo",
+			),
+		)
+
+		clusterClient, err := cluster.NewFakeClusterClient(
+			ctx,
+			&cluster.ClusterClientConfig{
+				ClusterConfig: clusterConfig,
+			},
+		)
+		require.NoError(t, err)
+		clusterClients = append(
+			clusterClients,
+			&concurrencyTrackingClient{
+				ClusterClient: clusterClient,
+				tracker:       tracker,
+			},
+		)
+	}
+
+	handler := NewWebhookHandler(
+		stats.NewFakeStatsClient(),
+		cluster.NewFakeClusterClient,
+		WebhookHandlerSettings{
+			Version:              "1.2.3",
+			MaxConcurrentApplies: 2,
+		},
+	)
+
+	clusterApplies, clusterErrs, err := handler.applyClusters(ctx, clusterClients, false)
+	require.NoError(t, err)
+	require.Equal(t, 6, len(clusterApplies))
+	assert.Equal(t, 6, len(clusterErrs))
+	for c, clusterApply := range clusterApplies {
+		assert.Equal(
+			t,
+			fmt.Sprintf("test-cluster%d", c),
+			clusterApply.ClusterConfig.Cluster,
+		)
+	}
+
+	assert.Equal(t, 6, tracker.total)
+	assert.Equal(t, 2, tracker.max)
+}
+
+// concurrencyTracker records the maximum number of operations running at once.
+type concurrencyTracker struct {
+	sync.Mutex
+	curr  int
+	max   int
+	total int
+}
+
+func (c *concurrencyTracker) start() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.curr++
+	c.total++
+	if c.curr > c.max {
+		c.max = c.curr
+	}
+}
+
+func (c *concurrencyTracker) end() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.curr--
+}
+
+// concurrencyTrackingClient is a cluster client that records the concurrency of its applies.
+type concurrencyTrackingClient struct {
+	cluster.ClusterClient
+	tracker *concurrencyTracker
+}
+
+func (c *concurrencyTrackingClient) ApplyStructured(
+	ctx context.Context,
+	paths []string,
+	serverSide bool,
+) ([]apply.Result, error) {
+	c.tracker.start()
+	defer c.tracker.end()
+
+	time.Sleep(50 * time.Millisecond)
+	return c.ClusterClient.ApplyStructured(ctx, paths, serverSide)
+}
+
+func TestApplyNotifications(t *testing.T) {
+	type testCase struct {
+		description      string
+		reviewRequired   bool
+		approved         bool
+		kubectlErr       bool
+		expNotifications int
+		expSuccess       bool
+		expClusters      []string
+	}
+
+	testCases := []testCase{
+		{
+			description:      "successful apply",
+			approved:         true,
+			expNotifications: 1,
+			expSuccess:       true,
+			expClusters: []string{
+				"test-env:test-region:test-cluster1",
+				"test-env:test-region:test-cluster2",
+			},
+		},
+		{
+			// The apply stops after the first cluster fails, so the second one isn't included
+			description:      "failed apply",
+			approved:         true,
+			kubectlErr:       true,
+			expNotifications: 1,
+			expSuccess:       false,
+			expClusters:      []string{"test-env:test-region:test-cluster1"},
+		},
+		{
+			description:      "apply blocked before running",
+			reviewRequired:   true,
+			approved:         false,
+			expNotifications: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster: "test-cluster2",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		generator := cluster.NewFakeClusterClient
+		if testCase.kubectlErr {
+			generator = cluster.NewFakeClusterClientError
+		}
+
+		notifier := notify.NewFakeNotifier()
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			generator,
+			WebhookHandlerSettings{
+				Env:            "test-env",
+				Version:        "1.2.3",
+				ReviewRequired: testCase.reviewRequired,
+				Notifier:       notifier,
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:          "segmentio",
+				repo:           "test-repo",
+				pullRequestNum: 123,
+				pullRequestClient: &pullreq.FakePullRequestClient{
+					ClusterConfigs:  clusterConfigs,
+					RequestStatuses: []pullreq.PullRequestStatus{},
+					ApprovedVal:     testCase.approved,
+					Mergeable:       true,
+				},
+				commentType: commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String("kubeapply apply"),
+					},
+				},
+			},
+		)
+
+		require.Equal(
+			t,
+			testCase.expNotifications,
+			len(notifier.ApplyResults),
+			testCase.description,
+		)
+		if testCase.expNotifications == 0 {
+			continue
+		}
+
+		result := notifier.ApplyResults[0]
+		assert.Equal(t, testCase.expSuccess, result.Success(), testCase.description)
+		assert.Equal(t, "test-env", result.Env, testCase.description)
+		assert.Equal(t, testCase.expClusters, result.Clusters, testCase.description)
+		assert.Equal(
+			t,
+			"https://github.com/segmentio/test-repo/pull/123",
+			result.PullRequestURL,
+			testCase.description,
+		)
+	}
+}
+
+func TestIncrementalDiffs(t *testing.T) {
+	type testCase struct {
+		description    string
+		lastDiffSHA    string
+		changedFiles   []string
+		command        string
+		expContains    []string
+		expNotContains []string
+	}
+
+	expandedPath := "/git/repo/clusters/expanded/test-env/test-region"
+
+	testCases := []testCase{
+		{
+			description: "no previous diff",
+			command:     "kubeapply diff",
+			changedFiles: []string{
+				fmt.Sprintf("%s/namespace1/deployment.yaml", expandedPath),
+			},
+			expContains: []string{
+				fmt.Sprintf("with paths [%s]", expandedPath),
+			},
+			expNotContains: []string{
+				"were diffed",
+			},
+		},
+		{
+			description: "previous diff at head",
+			lastDiffSHA: "test-sha",
+			command:     "kubeapply diff",
+			changedFiles: []string{
+				fmt.Sprintf("%s/namespace1/deployment.yaml", expandedPath),
+			},
+			expContains: []string{
+				fmt.Sprintf("with paths [%s]", expandedPath),
+			},
+			expNotContains: []string{
+				"were diffed",
+			},
+		},
+		{
+			description: "changes since previous diff",
+			lastDiffSHA: "old-sha",
+			command:     "kubeapply diff",
+			changedFiles: []string{
+				"/git/repo/clusters/test-cluster1.yaml",
+				fmt.Sprintf("%s/namespace1/deployment.yaml", expandedPath),
+				fmt.Sprintf("%s/namespace2/child/service.yaml", expandedPath),
+			},
+			expContains: []string{
+				fmt.Sprintf(
+					"with paths [%s/namespace1 %s/namespace2/child]",
+					expandedPath,
+					expandedPath,
+				),
+				"Only the subpaths changed since the last diff at `old-sha` were diffed: " +
+					"`namespace1`, `namespace2/child`",
+				"Subpaths (1): *all*",
+			},
+		},
+		{
+			description: "changes outside of selected subpath",
+			lastDiffSHA: "old-sha",
+			command:     "kubeapply diff --subpath=namespace2",
+			changedFiles: []string{
+				fmt.Sprintf("%s/namespace1/deployment.yaml", expandedPath),
+				fmt.Sprintf("%s/namespace2/child/service.yaml", expandedPath),
+			},
+			expContains: []string{
+				fmt.Sprintf("with paths [%s/namespace2/child]", expandedPath),
+				"were diffed: `namespace2/child`",
+			},
+			expNotContains: []string{
+				"namespace1",
+			},
+		},
+		{
+			description: "no changes in selected subpath",
+			lastDiffSHA: "old-sha",
+			command:     "kubeapply diff --subpath=namespace2",
+			changedFiles: []string{
+				fmt.Sprintf("%s/namespace1/deployment.yaml", expandedPath),
+			},
+			expContains: []string{
+				fmt.Sprintf("with paths [%s/namespace2]", expandedPath),
+			},
+			expNotContains: []string{
+				"were diffed",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfig := &config.ClusterConfig{
+			Cluster: "test-cluster1",
+			Region:  "test-region",
+			Env:     "test-env",
+		}
+		require.NoError(
+			t,
+			clusterConfig.SetDefaults("/git/repo/clusters/test-cluster1.yaml", "/git/repo"),
+		)
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  []*config.ClusterConfig{clusterConfig},
+			RequestStatuses: []pullreq.PullRequestStatus{},
+			ChangedFiles:    testCase.changedFiles,
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClientLastDiff(testCase.lastDiffSHA),
+			WebhookHandlerSettings{
+				Env:              "test-env",
+				Version:          "1.2.3",
+				IncrementalDiffs: true,
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.command),
+					},
+				},
+			},
+		)
+
+		require.Equal(t, 1, len(pullRequestClient.Comments), testCase.description)
+		comment := pullRequestClient.Comments[0]
+
+		for _, expContains := range testCase.expContains {
+			assert.Contains(t, comment, expContains, testCase.description)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(t, comment, expNotContains, testCase.description)
+		}
+	}
+}
+
+func TestDryRunSummaries(t *testing.T) {
+	type testCase struct {
+		description     string
+		dryRunSummaries bool
+		expContains     []string
+		expNotContains  []string
+	}
+
+	testCases := []testCase{
+		{
+			description:     "dry-run summaries disabled",
+			dryRunSummaries: false,
+			expNotContains: []string{
+				"Dry-run apply",
+			},
+		},
+		{
+			description:     "dry-run summaries enabled",
+			dryRunSummaries: true,
+			expContains: []string{
+				"Dry-run apply: 1 to create, 0 to update, 1 unchanged",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfig := &config.ClusterConfig{
+			Cluster: "test-cluster1",
+			Region:  "test-region",
+			Env:     "test-env",
+		}
+		require.NoError(
+			t,
+			clusterConfig.SetDefaults("/git/repo/clusters/test-cluster1.yaml", "/git/repo"),
+		)
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  []*config.ClusterConfig{clusterConfig},
+			RequestStatuses: []pullreq.PullRequestStatus{},
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:             "test-env",
+				Version:         "1.2.3",
+				DryRunSummaries: testCase.dryRunSummaries,
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String("kubeapply diff"),
+					},
+				},
+			},
+		)
+
+		require.Equal(t, 1, len(pullRequestClient.Comments), testCase.description)
+		comment := pullRequestClient.Comments[0]
+
+		for _, expContains := range testCase.expContains {
+			assert.Contains(t, comment, expContains, testCase.description)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(t, comment, expNotContains, testCase.description)
+		}
+	}
+}
+
+func TestDisabledCommands(t *testing.T) {
+	type testCase struct {
+		description      string
+		env              string
+		disabledCommands []string
+		command          string
+		expStatus        int
+		expComment       string
+	}
+
+	testCases := []testCase{
+		{
+			description:      "apply disabled in production",
+			env:              "production",
+			disabledCommands: []string{"apply"},
+			command:          "kubeapply apply",
+			expStatus:        400,
+			expComment:       "Command apply is disabled in env production",
+		},
+		{
+			description:      "diff allowed in production",
+			env:              "production",
+			disabledCommands: []string{"apply"},
+			command:          "kubeapply diff",
+			expStatus:        200,
+			expComment:       "Kubeapply diff result (production)",
+		},
+		{
+			description: "apply allowed with no disabled commands",
+			env:         "production",
+			command:     "kubeapply apply",
+			expStatus:   200,
+			expComment:  "Kubeapply apply result (production)",
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfig := &config.ClusterConfig{
+			Cluster: "test-cluster1",
+			Region:  "test-region",
+			Env:     testCase.env,
+		}
+		require.NoError(
+			t,
+			clusterConfig.SetDefaults("/git/repo/clusters/test-cluster1.yaml", "/git/repo"),
+		)
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  []*config.ClusterConfig{clusterConfig},
+			RequestStatuses: []pullreq.PullRequestStatus{},
+			ApprovedVal:     true,
+			Mergeable:       true,
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:              testCase.env,
+				Version:          "1.2.3",
+				DisabledCommands: testCase.disabledCommands,
+			},
+		)
+
+		response := handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.command),
+					},
+				},
+			},
+		)
+
+		assert.Equal(t, testCase.expStatus, response.StatusCode, testCase.description)
+		require.Equal(t, 1, len(pullRequestClient.Comments), testCase.description)
+		assert.Contains(
+			t,
+			pullRequestClient.Comments[0],
+			testCase.expComment,
+			testCase.description,
+		)
+	}
+}
+
+func TestResumeApply(t *testing.T) {
+	type testCase struct {
+		description    string
+		lastApplySHA   string
+		command        string
+		expContains    []string
+		expNotContains []string
+	}
+
+	testCases := []testCase{
+		{
+			description:  "resume with apply at head",
+			lastApplySHA: "test-sha",
+			command:      "kubeapply apply --resume",
+			expContains: []string{
+				"Skipped because this cluster was already applied at this commit",
+			},
+			expNotContains: []string{
+				"apply result for test-cluster1",
+			},
+		},
+		{
+			description:  "resume with apply at older SHA",
+			lastApplySHA: "old-sha",
+			command:      "kubeapply apply --resume",
+			expContains: []string{
+				"apply result for test-cluster1",
+			},
+			expNotContains: []string{
+				"Skipped because",
+			},
+		},
+		{
+			description:  "apply at head without resume",
+			lastApplySHA: "test-sha",
+			command:      "kubeapply apply",
+			expContains: []string{
+				"apply result for test-cluster1",
+			},
+			expNotContains: []string{
+				"Skipped because",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfig := &config.ClusterConfig{
+			Cluster: "test-cluster1",
+			Region:  "test-region",
+			Env:     "test-env",
+		}
+		require.NoError(
+			t,
+			clusterConfig.SetDefaults("/git/repo/clusters/test-cluster1.yaml", "/git/repo"),
+		)
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  []*config.ClusterConfig{clusterConfig},
+			RequestStatuses: []pullreq.PullRequestStatus{},
+			ApprovedVal:     true,
+			Mergeable:       true,
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClientLastApply(testCase.lastApplySHA),
+			WebhookHandlerSettings{
+				Env:     "test-env",
+				Version: "1.2.3",
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.command),
+					},
+				},
+			},
+		)
+
+		require.Equal(t, 1, len(pullRequestClient.Comments), testCase.description)
+		comment := pullRequestClient.Comments[0]
+
+		for _, expContains := range testCase.expContains {
+			assert.Contains(t, comment, expContains, testCase.description)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(t, comment, expNotContains, testCase.description)
+		}
+	}
+}
+
+func TestPublishResults(t *testing.T) {
+	type publishedEvent struct {
+		cluster string
+		success bool
+	}
+
+	type testCase struct {
+		description    string
+		comment        string
+		failedClusters []string
+		reviewRequired bool
+		expCommand     string
+		expEvents      []publishedEvent
+	}
+
+	testCases := []testCase{
+		{
+			description: "successful diff",
+			comment:     "kubeapply diff",
+			expCommand:  "diff",
+			expEvents: []publishedEvent{
+				{cluster: "test-env:test-region:test-cluster1", success: true},
+				{cluster: "test-env:test-region:test-cluster2", success: true},
+			},
+		},
+		{
+			// The diff stops after the first cluster fails, so the second one isn't published
+			description:    "failed diff",
+			comment:        "kubeapply diff",
+			failedClusters: []string{"test-cluster1", "test-cluster2"},
+			expCommand:     "diff",
+			expEvents: []publishedEvent{
+				{cluster: "test-env:test-region:test-cluster1", success: false},
+			},
+		},
+		{
+			description:    "diff fails in one cluster",
+			comment:        "kubeapply diff",
+			failedClusters: []string{"test-cluster2"},
+			expCommand:     "diff",
+			expEvents: []publishedEvent{
+				{cluster: "test-env:test-region:test-cluster1", success: true},
+				{cluster: "test-env:test-region:test-cluster2", success: false},
+			},
+		},
+		{
+			description: "successful apply",
+			comment:     "kubeapply apply",
+			expCommand:  "apply",
+			expEvents: []publishedEvent{
+				{cluster: "test-env:test-region:test-cluster1", success: true},
+				{cluster: "test-env:test-region:test-cluster2", success: true},
+			},
+		},
+		{
+			description:    "apply fails in one cluster",
+			comment:        "kubeapply apply",
+			failedClusters: []string{"test-cluster2"},
+			expCommand:     "apply",
+			expEvents: []publishedEvent{
+				{cluster: "test-env:test-region:test-cluster1", success: true},
+				{cluster: "test-env:test-region:test-cluster2", success: false},
+			},
+		},
+		{
+			description:    "apply rejected before running",
+			comment:        "kubeapply apply",
+			reviewRequired: true,
+			expCommand:     "apply",
+			expEvents:      []publishedEvent{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster: "test-cluster2",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		failedClusters := testCase.failedClusters
+		generator := func(
+			ctx context.Context,
+			clientConfig *cluster.ClusterClientConfig,
+		) (cluster.ClusterClient, error) {
+			for _, failedCluster := range failedClusters {
+				if clientConfig.ClusterConfig.Cluster == failedCluster {
+					return cluster.NewFakeClusterClientError(ctx, clientConfig)
+				}
+			}
+			return cluster.NewFakeClusterClient(ctx, clientConfig)
+		}
+
+		publisher := NewFakePublisher()
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			generator,
+			WebhookHandlerSettings{
+				Env:            "test-env",
+				Version:        "1.2.3",
+				ReviewRequired: testCase.reviewRequired,
+				Publisher:      publisher,
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:          "segmentio",
+				repo:           "test-repo",
+				pullRequestNum: 123,
+				pullRequestClient: &pullreq.FakePullRequestClient{
+					ClusterConfigs:  clusterConfigs,
+					RequestStatuses: []pullreq.PullRequestStatus{},
+					ApprovedVal:     !testCase.reviewRequired,
+					Mergeable:       true,
+				},
+				commentType: commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.comment),
+					},
+				},
+			},
+		)
+
+		events := []publishedEvent{}
+		for _, event := range publisher.Events {
+			events = append(
+				events,
+				publishedEvent{cluster: event.Cluster, success: event.Success},
+			)
+			assert.Equal(t, testCase.expCommand, event.Command, testCase.description)
+			assert.Equal(t, event.Success, event.Error == "", testCase.description)
+			assert.Equal(t, "test-env", event.Env, testCase.description)
+			assert.Equal(t, "test-sha", event.SHA, testCase.description)
+			assert.Equal(
+				t,
+				"https://github.com/segmentio/test-repo/pull/123",
+				event.PullRequestURL,
+				testCase.description,
+			)
+		}
+		assert.Equal(t, testCase.expEvents, events, testCase.description)
+	}
+}
+
+func TestClusterStats(t *testing.T) {
+	type testCase struct {
+		description    string
+		command        string
+		clusterStats   bool
+		failedClusters []string
+		reviewRequired bool
+		expSuccesses   []string
+		expErrors      []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "disabled",
+			command:     "kubeapply diff",
+		},
+		{
+			description:  "successful diff",
+			command:      "kubeapply diff",
+			clusterStats: true,
+			expSuccesses: []string{"test-cluster1", "test-cluster2"},
+		},
+		{
+			description:    "failed diff",
+			command:        "kubeapply diff",
+			clusterStats:   true,
+			failedClusters: []string{"test-cluster1", "test-cluster2"},
+			expErrors:      []string{"test-cluster1"},
+		},
+		{
+			description:    "diff fails in one cluster",
+			command:        "kubeapply diff",
+			clusterStats:   true,
+			failedClusters: []string{"test-cluster2"},
+			expSuccesses:   []string{"test-cluster1"},
+			expErrors:      []string{"test-cluster2"},
+		},
+		{
+			description:    "apply fails in one cluster",
+			command:        "kubeapply apply",
+			clusterStats:   true,
+			failedClusters: []string{"test-cluster2"},
+			expSuccesses:   []string{"test-cluster1"},
+			expErrors:      []string{"test-cluster2"},
+		},
+		{
+			description:    "apply fails before running",
+			command:        "kubeapply apply",
+			clusterStats:   true,
+			reviewRequired: true,
+			expErrors:      []string{"test-cluster1", "test-cluster2"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster: "test-cluster2",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		failedClusters := testCase.failedClusters
+		generator := func(
+			ctx context.Context,
+			clientConfig *cluster.ClusterClientConfig,
+		) (cluster.ClusterClient, error) {
+			for _, failedCluster := range failedClusters {
+				if clientConfig.ClusterConfig.Cluster == failedCluster {
+					return cluster.NewFakeClusterClientError(ctx, clientConfig)
+				}
+			}
+			return cluster.NewFakeClusterClient(ctx, clientConfig)
+		}
+
+		statsClient := stats.NewFakeStatsClient()
+		handler := NewWebhookHandler(
+			statsClient,
+			generator,
+			WebhookHandlerSettings{
+				Env:            "test-env",
+				Version:        "1.2.3",
+				ClusterStats:   testCase.clusterStats,
+				ReviewRequired: testCase.reviewRequired,
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:          "segmentio",
+				repo:           "test-repo",
+				pullRequestNum: 123,
+				pullRequestClient: &pullreq.FakePullRequestClient{
+					ClusterConfigs:  clusterConfigs,
+					RequestStatuses: []pullreq.PullRequestStatus{},
+				},
+				commentType: commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.command),
+					},
+				},
+			},
+		)
+
+		for name, expClusters := range map[string][]string{
+			"handler.cluster.success": testCase.expSuccesses,
+			"handler.cluster.error":   testCase.expErrors,
+		} {
+			clusters := []string{}
+			for _, tags := range statsClient.Tags[name] {
+				for _, tag := range tags {
+					if strings.HasPrefix(tag, "cluster:") {
+						clusters = append(clusters, strings.TrimPrefix(tag, "cluster:"))
+					}
+				}
+			}
+			if len(expClusters) == 0 {
+				expClusters = []string{}
+			}
+			assert.Equal(t, expClusters, clusters, "%s: %s", testCase.description, name)
+		}
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	type testCase struct {
+		description    string
+		statusJSON     bool
+		expContains    []string
+		expNotContains []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "disabled",
+			expContains: []string{
+				"Kubeapply cluster status result",
+				"test-cluster1",
+			},
+			expNotContains: []string{
+				"Current workloads (JSON)",
+			},
+		},
+		{
+			description: "enabled",
+			statusJSON:  true,
+			expContains: []string{
+				"Kubeapply cluster status result",
+				"Current workloads (JSON)",
+				`"namespace": "test-cluster1"`,
+				`"namespace": "test-cluster2"`,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster: "test-cluster2",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  clusterConfigs,
+			RequestStatuses: []pullreq.PullRequestStatus{},
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:        "test-env",
+				Version:    "1.2.3",
+				StatusJSON: testCase.statusJSON,
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:             "segmentio",
+				repo:              "test-repo",
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String("kubeapply status"),
+					},
+				},
+			},
+		)
+
+		require.Equal(t, 1, len(pullRequestClient.Comments), testCase.description)
+		for _, expContains := range testCase.expContains {
+			assert.Contains(
+				t,
+				pullRequestClient.Comments[0],
+				expContains,
+				testCase.description,
+			)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(
+				t,
+				pullRequestClient.Comments[0],
+				expNotContains,
+				testCase.description,
+			)
+		}
+	}
+}
+
+func TestLocks(t *testing.T) {
+	type testCase struct {
+		description     string
+		clientGenerator cluster.ClusterClientGenerator
+		expContains     []string
+		expNotContains  []string
+	}
+
+	testCases := []testCase{
+		{
+			description:     "unlocked",
+			clientGenerator: cluster.NewFakeClusterClient,
+			expContains: []string{
+				"Kubeapply locks",
+				"| `test-env:test-region:test-cluster1` | Unlocked |",
+				"| `test-env:test-region:test-cluster2` | Unlocked |",
+			},
+			expNotContains: []string{
+				"| Locked |",
+			},
+		},
+		{
+			description: "locked",
+			clientGenerator: cluster.NewFakeClusterClientLocked(
+				"kubeapply-1234",
+				time.Now().Add(-time.Minute),
+			),
+			expContains: []string{
+				"Kubeapply locks",
+				"| `test-env:test-region:test-cluster1` | Locked | `kubeapply-1234` |",
+				"| `test-env:test-region:test-cluster2` | Locked | `kubeapply-1234` |",
+			},
+			expNotContains: []string{
+				"Unlocked",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster: "test-cluster2",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  clusterConfigs,
+			RequestStatuses: []pullreq.PullRequestStatus{},
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			testCase.clientGenerator,
+			WebhookHandlerSettings{
+				Env:     "test-env",
+				Version: "1.2.3",
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:             "segmentio",
+				repo:              "test-repo",
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String("kubeapply locks"),
+					},
+				},
+			},
+		)
+
+		require.Equal(t, 1, len(pullRequestClient.Comments), testCase.description)
+		for _, expContains := range testCase.expContains {
+			assert.Contains(
+				t,
+				pullRequestClient.Comments[0],
+				expContains,
+				testCase.description,
+			)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(
+				t,
+				pullRequestClient.Comments[0],
+				expNotContains,
+				testCase.description,
+			)
+		}
+	}
+}
+
+func TestApplyTeams(t *testing.T) {
+	type testCase struct {
+		description    string
+		commenter      string
+		command        string
+		triggered      bool
+		expContains    []string
+		expNotContains []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "commenter in apply team",
+			commenter:   "team-member",
+			command:     "kubeapply apply",
+			expContains: []string{
+				"Kubeapply apply result",
+			},
+			expNotContains: []string{
+				"Error comment",
+			},
+		},
+		{
+			description: "commenter not in apply team",
+			commenter:   "outsider",
+			command:     "kubeapply apply",
+			expContains: []string{
+				"Error comment",
+				"outsider isn't a member of the teams allowed to apply in clusters test-env:test-region:test-cluster2 (teams segmentio/infra-admins)",
+			},
+			expNotContains: []string{
+				"Kubeapply apply result",
+			},
+		},
+		{
+			description: "help for commenter in apply team",
+			commenter:   "team-member",
+			command:     "kubeapply help",
+			expContains: []string{
+				"Can `team-member` apply?",
+				"| `test-env:test-region:test-cluster1` | <ul><li>*all*</li></ul> | ✅ Yes |",
+				"| `test-env:test-region:test-cluster2` | <ul><li>*all*</li></ul> | ✅ Yes |",
+			},
+			expNotContains: []string{
+				"Error comment",
+				"❌",
+			},
+		},
+		{
+			description: "help for commenter not in apply team",
+			commenter:   "outsider",
+			command:     "kubeapply help",
+			expContains: []string{
+				"Can `outsider` apply?",
+				"| `test-env:test-region:test-cluster1` | <ul><li>*all*</li></ul> | ✅ Yes |",
+				"| `test-env:test-region:test-cluster2` | <ul><li>*all*</li></ul> | ❌ No, must be in `segmentio/infra-admins` |",
+			},
+			expNotContains: []string{
+				"Error comment",
+			},
+		},
+		{
+			description: "trigger request with team member login",
+			commenter:   "team-member",
+			command:     "kubeapply apply",
+			triggered:   true,
+			expContains: []string{
+				"Error comment",
+				"Cannot run apply via a trigger request in clusters test-env:test-region:test-cluster2 (teams segmentio/infra-admins)",
+			},
+			expNotContains: []string{
+				"Kubeapply apply result",
+			},
+		},
+		{
+			description: "help for trigger request with team member login",
+			commenter:   "team-member",
+			command:     "kubeapply help",
+			triggered:   true,
+			expContains: []string{
+				"| `test-env:test-region:test-cluster1` | <ul><li>*all*</li></ul> | ✅ Yes |",
+				"| `test-env:test-region:test-cluster2` | <ul><li>*all*</li></ul> | ❌ No, must be in `segmentio/infra-admins` |",
+			},
+			expNotContains: []string{
+				"Error comment",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster:    "test-cluster2",
+				Region:     "test-region",
+				Env:        "test-env",
+				ApplyTeams: []string{"segmentio/infra-admins"},
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  clusterConfigs,
+			RequestStatuses: []pullreq.PullRequestStatus{},
+			ApprovedVal:     true,
+			TeamMembers: map[string][]string{
+				"segmentio/infra-admins": {"team-member"},
+			},
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:     "test-env",
+				Version: "1.2.3",
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:             "segmentio",
+				repo:              "test-repo",
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.command),
+						User: &github.User{
+							Login: aws.String(testCase.commenter),
+						},
+					},
+				},
+				triggered: testCase.triggered,
+			},
+		)
+
+		allComments := strings.Join(pullRequestClient.Comments, "\n")
+		for _, expContains := range testCase.expContains {
+			assert.Contains(t, allComments, expContains, testCase.description)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(t, allComments, expNotContains, testCase.description)
+		}
+	}
+}
+
+func TestDisableApply(t *testing.T) {
+	type testCase struct {
+		description    string
+		command        string
+		expContains    []string
+		expNotContains []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "apply in observe-only cluster",
+			command:     "kubeapply apply",
+			expContains: []string{
+				"Error comment",
+				"applies are disabled for clusters test-env:test-region:test-cluster2",
+			},
+			expNotContains: []string{
+				"Kubeapply apply result",
+			},
+		},
+		{
+			description: "diff in observe-only cluster",
+			command:     "kubeapply diff",
+			expContains: []string{
+				"Kubeapply diff result",
+				"test-cluster1",
+				"test-cluster2",
+			},
+			expNotContains: []string{
+				"Error comment",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster:      "test-cluster2",
+				Region:       "test-region",
+				Env:          "test-env",
+				DisableApply: true,
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  clusterConfigs,
+			RequestStatuses: []pullreq.PullRequestStatus{},
+			ApprovedVal:     true,
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:     "test-env",
+				Version: "1.2.3",
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:             "segmentio",
+				repo:              "test-repo",
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.command),
+					},
+				},
+			},
+		)
+
+		allComments := strings.Join(pullRequestClient.Comments, "\n")
+		for _, expContains := range testCase.expContains {
+			assert.Contains(t, allComments, expContains, testCase.description)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(t, allComments, expNotContains, testCase.description)
+		}
+	}
+}
+
+func TestMinApplyInterval(t *testing.T) {
+	type testCase struct {
+		description    string
+		lastApplyTime  time.Time
+		command        string
+		expContains    []string
+		expNotContains []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "never applied",
+			command:     "kubeapply apply",
+			expContains: []string{
+				"Kubeapply apply result",
+			},
+			expNotContains: []string{
+				"Error comment",
+			},
+		},
+		{
+			description:   "applied before interval",
+			lastApplyTime: time.Now().Add(-time.Hour),
+			command:       "kubeapply apply",
+			expContains: []string{
+				"Kubeapply apply result",
+			},
+			expNotContains: []string{
+				"Error comment",
+			},
+		},
+		{
+			description:   "applied within interval",
+			lastApplyTime: time.Now().Add(-time.Minute),
+			command:       "kubeapply apply",
+			expContains: []string{
+				"Error comment",
+				"minimum apply intervals: test-env:test-region:test-cluster2 (last applied 60 seconds ago",
+			},
+			expNotContains: []string{
+				"Kubeapply apply result",
+				"test-env:test-region:test-cluster1 (last applied",
+			},
+		},
+		{
+			description:   "applied within interval with force",
+			lastApplyTime: time.Now().Add(-time.Minute),
+			command:       "kubeapply apply --force",
+			expContains: []string{
+				"Kubeapply apply result",
+			},
+			expNotContains: []string{
+				"Error comment",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster:          "test-cluster2",
+				Region:           "test-region",
+				Env:              "test-env",
+				MinApplyInterval: "10m",
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  clusterConfigs,
+			RequestStatuses: []pullreq.PullRequestStatus{},
+			ApprovedVal:     true,
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClientLastApplyTime(testCase.lastApplyTime),
+			WebhookHandlerSettings{
+				Env:     "test-env",
+				Version: "1.2.3",
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:             "segmentio",
+				repo:              "test-repo",
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.command),
+					},
+				},
+			},
+		)
+
+		allComments := strings.Join(pullRequestClient.Comments, "\n")
+		for _, expContains := range testCase.expContains {
+			assert.Contains(t, allComments, expContains, testCase.description)
+		}
+		for _, expNotContains := range testCase.expNotContains {
+			assert.NotContains(t, allComments, expNotContains, testCase.description)
+		}
+	}
+}
+
+func TestReviewEvents(t *testing.T) {
+	type testCase struct {
+		description     string
+		reviewRequired  bool
+		approved        bool
+		initStatuses    []pullreq.PullRequestStatus
+		expRepoStatuses []pullreq.PullRequestStatus
+	}
+
+	clusterConfig := &config.ClusterConfig{
+		Cluster: "test-cluster1",
+		Region:  "test-region",
+		Env:     "test-env",
+	}
+	require.NoError(
+		t,
+		clusterConfig.SetDefaults("/git/repo/clusters/test-cluster1.yaml", "/git/repo"),
+	)
+
+	clusterClient, err := cluster.NewFakeClusterClient(
+		context.Background(),
+		&cluster.ClusterClientConfig{ClusterConfig: clusterConfig},
+	)
+	require.NoError(t, err)
+	clusterNames := hashedClusterNames([]cluster.ClusterClient{clusterClient})
+
+	testCases := []testCase{
+		{
+			description:     "review not required",
+			approved:        true,
+			initStatuses:    []pullreq.PullRequestStatus{},
+			expRepoStatuses: []pullreq.PullRequestStatus{},
+		},
+		{
+			description:    "review submitted with approval",
+			reviewRequired: true,
+			approved:       true,
+			initStatuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/apply (test-env)",
+					Description: fmt.Sprintf("Error running for clusters %s", clusterNames),
+					State:       "failure",
+				},
+			},
+			expRepoStatuses: []pullreq.PullRequestStatus{
+				{
+					Context: "kubeapply/apply (test-env)",
+					Description: fmt.Sprintf(
+						"Approved, ready to run for clusters %s",
+						clusterNames,
+					),
+					State: "pending",
+				},
+			},
+		},
+		{
+			description:    "review submitted without approval",
+			reviewRequired: true,
+			approved:       false,
+			initStatuses:   []pullreq.PullRequestStatus{},
+			expRepoStatuses: []pullreq.PullRequestStatus{
+				{
+					Context: "kubeapply/apply (test-env)",
+					Description: fmt.Sprintf(
+						"Waiting for approval to run for clusters %s",
+						clusterNames,
+					),
+					State: "pending",
+				},
+			},
+		},
+		{
+			description:    "review submitted after apply",
+			reviewRequired: true,
+			approved:       true,
+			initStatuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/apply (test-env)",
+					Description: fmt.Sprintf("Successfully ran for clusters %s", clusterNames),
+					State:       "success",
+				},
+			},
+			expRepoStatuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/apply (test-env)",
+					Description: fmt.Sprintf("Successfully ran for clusters %s", clusterNames),
+					State:       "success",
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs:  []*config.ClusterConfig{clusterConfig},
+			RequestStatuses: testCase.initStatuses,
+			ApprovedVal:     testCase.approved,
+		}
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:            "test-env",
+				Version:        "1.2.3",
+				ReviewRequired: testCase.reviewRequired,
+			},
+		)
+
+		resp := handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				owner:             "segmentio",
+				repo:              "test-repo",
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				reviewEvent: &github.PullRequestReviewEvent{
+					Action: aws.String("submitted"),
+					Review: &github.PullRequestReview{
+						State: aws.String("approved"),
+						User: &github.User{
+							Login: aws.String("reviewer"),
+						},
+					},
+				},
+			},
+		)
+
+		assert.Equal(t, 200, resp.StatusCode, testCase.description)
+		assert.Equal(
+			t,
+			testCase.expRepoStatuses,
+			pullRequestClient.RequestStatuses,
+			testCase.description,
+		)
+		assert.Empty(t, pullRequestClient.Comments, testCase.description)
+	}
+}
+
+func TestMergeMessage(t *testing.T) {
+	type testCase struct {
+		description          string
+		mergeMessageTemplate string
+		expMerged            bool
+		expMergeMessage      string
+	}
+
+	testCases := []testCase{
+		{
+			description:     "default template",
+			expMerged:       true,
+			expMergeMessage: "Merged by kubeapply (pull request 123)",
+		},
+		{
+			description:          "invalid template",
+			mergeMessageTemplate: `{{ .Title }} (#{{ .PullRequestNum }}) [{{ .Env }}: {{ join .Clusters ", " }}]`,
+			expMerged:            false,
+		},
+		{
+			description:          "custom template",
+			mergeMessageTemplate: "{{ .Title }} (#{{ .PullRequestNum }})\n\n{{ range .Clusters }}applied: {{ . }}\n{{ end }}",
+			expMerged:            true,
+			expMergeMessage:      "Test change (#123)\n\napplied: test-env:test-region:test-cluster1",
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfig := &config.ClusterConfig{
+			Cluster: "test-cluster1",
+			Region:  "test-region",
+			Env:     "test-env",
+		}
+		require.NoError(
+			t,
+			clusterConfig.SetDefaults("/git/repo/clusters/test-cluster1.yaml", "/git/repo"),
+		)
+
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:                  "test-env",
+				Version:              "1.2.3",
+				Automerge:            true,
+				MergeMessageTemplate: testCase.mergeMessageTemplate,
+			},
+		)
+
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			ClusterConfigs: []*config.ClusterConfig{clusterConfig},
+			RequestStatuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/diff (test-env)",
+					State:       "success",
+					Description: "success for clusters cluster1",
+				},
+				{
+					Context:     "kubeapply/apply (test-env)",
+					State:       "success",
+					Description: "success for clusters cluster1",
+				},
+			},
+			ApprovedVal: true,
+			Mergeable:   true,
+			TitleVal:    "Test change",
+		}
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				pullRequestNum:    123,
+				pullRequestClient: pullRequestClient,
+				commentType:       commentTypeApplyResult,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+				},
+			},
+		)
+
+		assert.Equal(t, testCase.expMerged, pullRequestClient.Merged, testCase.description)
+		assert.Equal(
+			t,
+			testCase.expMergeMessage,
+			pullRequestClient.MergeMessage,
+			testCase.description,
+		)
+	}
+}
+
+func TestScopeDirectives(t *testing.T) {
+	type testCase struct {
+		description     string
+		scopeDirectives bool
+		comment         string
+		labels          []string
+		expClusters     []string
+	}
+
+	testCases := []testCase{
+		{
+			description: "directives disabled",
+			comment:     "kubeapply diff",
+			labels:      []string{"kubeapply:cluster=*:test-cluster1"},
+			expClusters: []string{
+				"test-env:test-region:test-cluster1",
+				"test-env:test-region:test-cluster2",
+			},
+		},
+		{
+			description:     "directives enabled",
+			scopeDirectives: true,
+			comment:         "kubeapply diff",
+			labels:          []string{"kubeapply:cluster=*:test-cluster1"},
+			expClusters: []string{
+				"test-env:test-region:test-cluster1",
+			},
+		},
+		{
+			description:     "directives overridden by command",
+			scopeDirectives: true,
+			comment:         "kubeapply diff *:test-cluster2",
+			labels:          []string{"kubeapply:cluster=*:test-cluster1"},
+			expClusters: []string{
+				"test-env:test-region:test-cluster2",
+			},
+		},
+		{
+			description:     "invalid directive",
+			scopeDirectives: true,
+			comment:         "kubeapply diff",
+			labels:          []string{"kubeapply:bad"},
+			expClusters:     []string{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		clusterConfigs := []*config.ClusterConfig{
+			{
+				Cluster: "test-cluster1",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+			{
+				Cluster: "test-cluster2",
+				Region:  "test-region",
+				Env:     "test-env",
+			},
+		}
+		for _, clusterConfig := range clusterConfigs {
+			require.NoError(
+				t,
+				clusterConfig.SetDefaults(
+					fmt.Sprintf("/git/repo/clusters/%s.yaml", clusterConfig.Cluster),
+					"/git/repo",
+				),
+			)
+		}
+
+		publisher := NewFakePublisher()
+		handler := NewWebhookHandler(
+			stats.NewFakeStatsClient(),
+			cluster.NewFakeClusterClient,
+			WebhookHandlerSettings{
+				Env:             "test-env",
+				Version:         "1.2.3",
+				ScopeDirectives: testCase.scopeDirectives,
+				Publisher:       publisher,
+			},
+		)
+
+		handler.HandleWebhook(
+			context.Background(),
+			&WebhookContext{
+				pullRequestClient: &pullreq.FakePullRequestClient{
+					ClusterConfigs:  clusterConfigs,
+					RequestStatuses: []pullreq.PullRequestStatus{},
+					LabelsVal:       testCase.labels,
+				},
+				commentType: commentTypeCommand,
+				issueCommentEvent: &github.IssueCommentEvent{
+					Action: aws.String("created"),
+					Comment: &github.IssueComment{
+						Body: aws.String(testCase.comment),
+					},
+				},
+			},
+		)
+
+		clusters := []string{}
+		for _, event := range publisher.Events {
+			clusters = append(clusters, event.Cluster)
+		}
+		assert.Equal(t, testCase.expClusters, clusters, testCase.description)
+	}
+}
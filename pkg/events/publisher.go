@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+const (
+	// EventBridgeSource is the source set on the events published to EventBridge.
+	EventBridgeSource = "kubeapply"
+)
+
+// CommandEvent is a structured record of the result of running a command in a single cluster.
+type CommandEvent struct {
+	// Command is the command that was run, e.g. "apply".
+	Command string `json:"command"`
+
+	// Env is the environment of the cluster.
+	Env string `json:"env"`
+
+	// Cluster is the descriptive name of the cluster.
+	Cluster string `json:"cluster"`
+
+	// SHA is the git SHA of the change that the command was run for.
+	SHA string `json:"sha"`
+
+	// PullRequestURL is the URL of the pull request that the command was run in.
+	PullRequestURL string `json:"pullRequestURL"`
+
+	// Success is whether the command succeeded.
+	Success bool `json:"success"`
+
+	// Error is the error message if the command failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Publisher is an interface for publishing the results of commands to external event-driven
+// systems.
+type Publisher interface {
+	Publish(ctx context.Context, event CommandEvent) error
+}
+
+// NullPublisher is a Publisher implementation that does not publish anything.
+type NullPublisher struct {
+}
+
+var _ Publisher = (*NullPublisher)(nil)
+
+// Publish does nothing.
+func (p *NullPublisher) Publish(ctx context.Context, event CommandEvent) error {
+	return nil
+}
+
+// FakePublisher is a fake implementation of Publisher for testing purposes.
+type FakePublisher struct {
+	Events []CommandEvent
+}
+
+var _ Publisher = (*FakePublisher)(nil)
+
+// NewFakePublisher returns a new FakePublisher instance.
+func NewFakePublisher() *FakePublisher {
+	return &FakePublisher{
+		Events: []CommandEvent{},
+	}
+}
+
+// Publish records the argument event.
+func (p *FakePublisher) Publish(ctx context.Context, event CommandEvent) error {
+	p.Events = append(p.Events, event)
+	return nil
+}
+
+// EventBridgePublisher is a Publisher implementation that puts events on an AWS EventBridge
+// bus.
+type EventBridgePublisher struct {
+	client  eventbridgeiface.EventBridgeAPI
+	busName string
+}
+
+var _ Publisher = (*EventBridgePublisher)(nil)
+
+// NewEventBridgePublisher returns a new EventBridgePublisher for the argument bus.
+func NewEventBridgePublisher(
+	sess *session.Session,
+	busName string,
+) *EventBridgePublisher {
+	return &EventBridgePublisher{
+		client:  eventbridge.New(sess),
+		busName: busName,
+	}
+}
+
+// Publish puts the argument event on the EventBridge bus. The detail type is of the form
+// "kubeapply [command]" so that rules can match on specific commands.
+func (p *EventBridgePublisher) Publish(ctx context.Context, event CommandEvent) error {
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	output, err := p.client.PutEventsWithContext(
+		ctx,
+		&eventbridge.PutEventsInput{
+			Entries: []*eventbridge.PutEventsRequestEntry{
+				{
+					EventBusName: aws.String(p.busName),
+					Source:       aws.String(EventBridgeSource),
+					DetailType:   aws.String(fmt.Sprintf("%s %s", EventBridgeSource, event.Command)),
+					Detail:       aws.String(string(detail)),
+				},
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(output.FailedEntryCount) > 0 {
+		for _, entry := range output.Entries {
+			if entry.ErrorCode != nil {
+				return fmt.Errorf(
+					"Error putting event on bus %s: %s (%s)",
+					p.busName,
+					aws.StringValue(entry.ErrorMessage),
+					aws.StringValue(entry.ErrorCode),
+				)
+			}
+		}
+		return fmt.Errorf("Error putting event on bus %s", p.busName)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEventBridgeClient struct {
+	eventbridgeiface.EventBridgeAPI
+
+	inputs    []*eventbridge.PutEventsInput
+	errorCode string
+}
+
+func (c *fakeEventBridgeClient) PutEventsWithContext(
+	ctx aws.Context,
+	input *eventbridge.PutEventsInput,
+	opts ...request.Option,
+) (*eventbridge.PutEventsOutput, error) {
+	c.inputs = append(c.inputs, input)
+
+	if c.errorCode != "" {
+		return &eventbridge.PutEventsOutput{
+			FailedEntryCount: aws.Int64(1),
+			Entries: []*eventbridge.PutEventsResultEntry{
+				{
+					ErrorCode:    aws.String(c.errorCode),
+					ErrorMessage: aws.String("test error"),
+				},
+			},
+		}, nil
+	}
+
+	return &eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(0),
+		Entries: []*eventbridge.PutEventsResultEntry{
+			{
+				EventId: aws.String("test-id"),
+			},
+		},
+	}, nil
+}
+
+func TestEventBridgePublisher(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeEventBridgeClient{}
+	publisher := &EventBridgePublisher{
+		client:  client,
+		busName: "test-bus",
+	}
+
+	event := CommandEvent{
+		Command:        "apply",
+		Env:            "test-env",
+		Cluster:        "test-env:test-region:test-cluster",
+		SHA:            "test-sha",
+		PullRequestURL: "https://github.com/segmentio/test-repo/pull/123",
+		Success:        true,
+	}
+	require.NoError(t, publisher.Publish(ctx, event))
+	require.Equal(t, 1, len(client.inputs))
+	require.Equal(t, 1, len(client.inputs[0].Entries))
+
+	entry := client.inputs[0].Entries[0]
+	assert.Equal(t, "test-bus", aws.StringValue(entry.EventBusName))
+	assert.Equal(t, "kubeapply", aws.StringValue(entry.Source))
+	assert.Equal(t, "kubeapply apply", aws.StringValue(entry.DetailType))
+
+	detail := CommandEvent{}
+	require.NoError(t, json.Unmarshal([]byte(aws.StringValue(entry.Detail)), &detail))
+	assert.Equal(t, event, detail)
+
+	client.errorCode = "InternalFailure"
+	assert.Error(t, publisher.Publish(ctx, event))
+}
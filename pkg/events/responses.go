@@ -2,6 +2,7 @@ package events
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
 	log "github.com/sirupsen/logrus"
@@ -31,13 +32,22 @@ func ForbiddenResponse() events.ALBTargetGroupResponse {
 	}
 }
 
-// ErrorResponse returns a 500 ALB response with a body generated from the provided error.
+// ErrorResponse returns an ALB response with a body generated from the provided error. Errors
+// that can be fixed by the pull request author (see ErrorKind.UserFixable) get a 400 status
+// code; all others get a 500.
 func ErrorResponse(err error) events.ALBTargetGroupResponse {
-	log.Warnf("Returning error response with err: %+v", err)
+	kind := ErrorKindOf(err)
+	log.Warnf("Returning error response with err: %+v (kind:%s)", err, kind)
+
+	statusCode := http.StatusInternalServerError
+	if kind.UserFixable() {
+		statusCode = http.StatusBadRequest
+	}
+
 	return events.ALBTargetGroupResponse{
 		Body:              fmt.Sprintf("Error: %+v", err),
-		StatusCode:        500,
-		StatusDescription: "500 Internal Server Error",
+		StatusCode:        statusCode,
+		StatusDescription: fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
 		IsBase64Encoded:   false,
 		Headers:           map[string]string{},
 	}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Prefix for pull request labels that set scope directives, e.g.
+	// "kubeapply:subpath=team-a".
+	scopeLabelPrefix = "kubeapply:"
+)
+
+var (
+	// Matches fenced blocks with scope directives in pull request bodies, e.g.
+	//
+	//     ```kubeapply
+	//     cluster=stage:*
+	//     subpath=team-a
+	//     ```
+	scopeBlockRegexp = regexp.MustCompile("(?s)```kubeapply[ \t]*\r?\n(.*?)```")
+)
+
+// scopeDirectives are the default cluster and subpath selections for the commands in a pull
+// request. They're used in place of the equivalent command arguments when those aren't set.
+type scopeDirectives struct {
+	// clusterGlobs are matched against the descriptive names of the covered clusters, like
+	// the arguments of a command.
+	clusterGlobs []string
+
+	// subpath is used like the --subpath flag of a command.
+	subpath string
+}
+
+// isEmpty returns whether no directives are set.
+func (s scopeDirectives) isEmpty() bool {
+	return len(s.clusterGlobs) == 0 && s.subpath == ""
+}
+
+// parseScopeDirectives parses the scope directives in the argument pull request labels and
+// body. Each directive is of the form "cluster=[glob]" or "subpath=[path]"; cluster
+// directives can be repeated, but there can only be one subpath.
+func parseScopeDirectives(labels []string, body string) (scopeDirectives, error) {
+	directiveStrs := []string{}
+
+	for _, label := range labels {
+		if strings.HasPrefix(label, scopeLabelPrefix) {
+			directiveStrs = append(directiveStrs, strings.TrimPrefix(label, scopeLabelPrefix))
+		}
+	}
+
+	for _, match := range scopeBlockRegexp.FindAllStringSubmatch(body, -1) {
+		for _, line := range strings.Split(match[1], "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				directiveStrs = append(directiveStrs, line)
+			}
+		}
+	}
+
+	directives := scopeDirectives{}
+
+	for _, directiveStr := range directiveStrs {
+		components := strings.SplitN(directiveStr, "=", 2)
+		if len(components) != 2 || strings.TrimSpace(components[1]) == "" {
+			return directives, newHandlerError(
+				ErrBadCommand,
+				"Invalid scope directive %s; must be of the form cluster=[glob] or subpath=[path]",
+				directiveStr,
+			)
+		}
+
+		key := strings.TrimSpace(components[0])
+		value := strings.TrimSpace(components[1])
+
+		switch key {
+		case "cluster":
+			directives.clusterGlobs = append(directives.clusterGlobs, value)
+		case "subpath":
+			if directives.subpath != "" && directives.subpath != value {
+				return directives, newHandlerError(
+					ErrBadCommand,
+					"Conflicting scope directives: subpath=%s and subpath=%s",
+					directives.subpath,
+					value,
+				)
+			}
+			directives.subpath = value
+		default:
+			return directives, newHandlerError(
+				ErrBadCommand,
+				"Unrecognized scope directive %s; must be cluster or subpath",
+				key,
+			)
+		}
+	}
+
+	return directives, nil
+}
+
+// applyScopeDirectives returns the cluster globs and flags for a command after filling in the
+// ones that aren't set with the argument directives.
+func applyScopeDirectives(
+	directives scopeDirectives,
+	clusterGlobStrs []string,
+	flags map[string]string,
+) ([]string, map[string]string) {
+	if directives.isEmpty() {
+		return clusterGlobStrs, flags
+	}
+
+	if len(clusterGlobStrs) == 0 && len(directives.clusterGlobs) > 0 {
+		log.Infof("Using cluster globs from scope directives: %+v", directives.clusterGlobs)
+		clusterGlobStrs = directives.clusterGlobs
+	}
+
+	if flags["subpath"] == "" && directives.subpath != "" {
+		log.Infof("Using subpath from scope directives: %s", directives.subpath)
+
+		updatedFlags := map[string]string{}
+		for key, value := range flags {
+			updatedFlags[key] = value
+		}
+		updatedFlags["subpath"] = directives.subpath
+		flags = updatedFlags
+	}
+
+	return clusterGlobStrs, flags
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScopeDirectives(t *testing.T) {
+	type testCase struct {
+		description   string
+		labels        []string
+		body          string
+		expDirectives scopeDirectives
+		expErr        bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "no directives",
+			labels:      []string{"bug", "team-a"},
+			body:        "Fixes a bug\n\n```yaml\nsubpath=ignored\n```",
+		},
+		{
+			description: "labels",
+			labels: []string{
+				"bug",
+				"kubeapply:cluster=stage:*",
+				"kubeapply:subpath=team-a",
+				"kubeapply:cluster=production:us-west-2:*",
+			},
+			expDirectives: scopeDirectives{
+				clusterGlobs: []string{"stage:*", "production:us-west-2:*"},
+				subpath:      "team-a",
+			},
+		},
+		{
+			description: "body",
+			body: "Updates team-a\n\n```kubeapply\n# Only stage for now\ncluster = stage:*\n" +
+				"subpath=team-a/*\n```\n",
+			expDirectives: scopeDirectives{
+				clusterGlobs: []string{"stage:*"},
+				subpath:      "team-a/*",
+			},
+		},
+		{
+			description: "labels and body",
+			labels:      []string{"kubeapply:subpath=team-a"},
+			body:        "```kubeapply\ncluster=stage:*\nsubpath=team-a\n```",
+			expDirectives: scopeDirectives{
+				clusterGlobs: []string{"stage:*"},
+				subpath:      "team-a",
+			},
+		},
+		{
+			description: "conflicting subpaths",
+			labels:      []string{"kubeapply:subpath=team-a"},
+			body:        "```kubeapply\nsubpath=team-b\n```",
+			expErr:      true,
+		},
+		{
+			description: "unrecognized directive",
+			labels:      []string{"kubeapply:namespace=team-a"},
+			expErr:      true,
+		},
+		{
+			description: "missing value",
+			body:        "```kubeapply\ncluster\n```",
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		directives, err := parseScopeDirectives(testCase.labels, testCase.body)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+			assert.Equal(t, ErrBadCommand, ErrorKindOf(err), testCase.description)
+		} else {
+			require.NoError(t, err, testCase.description)
+			assert.Equal(t, testCase.expDirectives, directives, testCase.description)
+		}
+	}
+}
+
+func TestApplyScopeDirectives(t *testing.T) {
+	directives := scopeDirectives{
+		clusterGlobs: []string{"stage:*"},
+		subpath:      "team-a",
+	}
+
+	clusterGlobStrs, flags := applyScopeDirectives(
+		directives,
+		[]string{},
+		map[string]string{"resume": "true"},
+	)
+	assert.Equal(t, []string{"stage:*"}, clusterGlobStrs)
+	assert.Equal(t, map[string]string{"resume": "true", "subpath": "team-a"}, flags)
+
+	// Explicit command arguments take precedence
+	clusterGlobStrs, flags = applyScopeDirectives(
+		directives,
+		[]string{"production:*"},
+		map[string]string{"subpath": "team-b"},
+	)
+	assert.Equal(t, []string{"production:*"}, clusterGlobStrs)
+	assert.Equal(t, map[string]string{"subpath": "team-b"}, flags)
+
+	clusterGlobStrs, flags = applyScopeDirectives(scopeDirectives{}, nil, nil)
+	assert.Nil(t, clusterGlobStrs)
+	assert.Nil(t, flags)
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -9,11 +10,22 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// DefaultStatusContextPrefix is the default prefix for the contexts of the statuses set by
+	// kubeapply.
+	DefaultStatusContextPrefix = "kubeapply"
+)
+
 var (
-	contextRegexp     = regexp.MustCompile("kubeapply/(\\S+) [(](\\S+)[)]")
 	descriptionRegexp = regexp.MustCompile("for clusters (\\S+)")
 )
 
+func contextRegexp(statusContextPrefix string) *regexp.Regexp {
+	return regexp.MustCompile(
+		fmt.Sprintf("^%s/(\\S+) [(](\\S+)[)]", regexp.QuoteMeta(statusContextPrefix)),
+	)
+}
+
 func statusAllGreen(
 	ctx context.Context,
 	client pullreq.PullRequestClient,
@@ -38,6 +50,7 @@ func statusAllGreen(
 func statusOKToApply(
 	ctx context.Context,
 	client pullreq.PullRequestClient,
+	statusContextPrefix string,
 ) bool {
 	statuses, err := client.Statuses(ctx)
 	if err != nil {
@@ -46,7 +59,11 @@ func statusOKToApply(
 	}
 
 	for _, status := range statuses {
-		if !strings.Contains(status.Context, "kubeapply/apply") && !status.IsSuccess() {
+		isApply := strings.HasPrefix(
+			status.Context,
+			fmt.Sprintf("%s/%s", statusContextPrefix, commandApply),
+		)
+		if !isApply && !status.IsSuccess() {
 			log.Infof("Non-apply status is not green: %+v", status)
 			return false
 		}
@@ -59,6 +76,8 @@ func statusOKToApply(
 func statusWorkflowCompleted(
 	ctx context.Context,
 	client pullreq.PullRequestClient,
+	statusContextPrefix string,
+	diffRequired bool,
 ) bool {
 	statuses, err := client.Statuses(ctx)
 	if err != nil {
@@ -70,8 +89,12 @@ func statusWorkflowCompleted(
 	diffedClusters := map[string]struct{}{}
 	appliedClusters := map[string]struct{}{}
 
+	prefixRegexp := contextRegexp(statusContextPrefix)
+
 	for _, status := range statuses {
-		contextMatches := contextRegexp.FindStringSubmatch(status.Context)
+		// Only consider statuses with the matching prefix so that multiple kubeapply
+		// deployments can run against the same repo.
+		contextMatches := prefixRegexp.FindStringSubmatch(status.Context)
 		if len(contextMatches) != 3 {
 			continue
 		}
@@ -108,6 +131,11 @@ func statusWorkflowCompleted(
 	for clusterName := range allClusters {
 		_, diffed := diffedClusters[clusterName]
 		_, applied := appliedClusters[clusterName]
+		if !diffRequired {
+			// Only the apply matters
+			diffed = true
+		}
+
 		if !diffed || !applied {
 			log.Warnf(
 				"Cluster %s is not fully diffed and applied: %v, %v",
@@ -119,6 +147,10 @@ func statusWorkflowCompleted(
 		}
 	}
 
-	log.Info("All clusters have been diffed and applied")
+	if diffRequired {
+		log.Info("All clusters have been diffed and applied")
+	} else {
+		log.Info("All clusters have been applied")
+	}
 	return true
 }
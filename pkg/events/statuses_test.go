@@ -10,6 +10,7 @@ import (
 
 func TestStatusOK(t *testing.T) {
 	type testCase struct {
+		statusContextPrefix  string
 		statuses             []pullreq.PullRequestStatus
 		expAllGreen          bool
 		expOKToApply         bool
@@ -195,6 +196,90 @@ func TestStatusOK(t *testing.T) {
 			expOKToApply:         true,
 			expWorkflowCompleted: true,
 		},
+		{
+			// Statuses from a deployment with a different prefix don't count towards
+			// the workflow being completed
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/diff (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+				{
+					Context:     "kubeapply-migration/apply (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+			},
+			expAllGreen:          true,
+			expOKToApply:         true,
+			expWorkflowCompleted: false,
+		},
+		{
+			// A failed apply from a deployment with a different prefix blocks applies
+			// like any other failing status
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/diff (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+				{
+					Context:     "other-kubeapply/apply (stage)",
+					State:       "failure",
+					Description: "failure for clusters cluster1",
+				},
+			},
+			expAllGreen:          false,
+			expOKToApply:         false,
+			expWorkflowCompleted: false,
+		},
+		{
+			statusContextPrefix: "kubeapply-migration",
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply-migration/diff (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+				{
+					Context:     "kubeapply-migration/apply (stage)",
+					State:       "failure",
+					Description: "failure for clusters cluster1",
+				},
+				{
+					Context:     "kubeapply/diff (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster2",
+				},
+			},
+			expAllGreen:          false,
+			expOKToApply:         true,
+			expWorkflowCompleted: false,
+		},
+		{
+			statusContextPrefix: "kubeapply-migration",
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply-migration/diff (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+				{
+					Context:     "kubeapply-migration/apply (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+				{
+					Context:     "kubeapply/diff (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster2",
+				},
+			},
+			expAllGreen:          true,
+			expOKToApply:         true,
+			expWorkflowCompleted: true,
+		},
 	}
 
 	ctx := context.Background()
@@ -204,8 +289,26 @@ func TestStatusOK(t *testing.T) {
 			RequestStatuses: testCase.statuses,
 		}
 
-		okToApply := statusOKToApply(ctx, pullRequestClient)
-		workflowCompleted := statusWorkflowCompleted(ctx, pullRequestClient)
+		statusContextPrefix := testCase.statusContextPrefix
+		if statusContextPrefix == "" {
+			statusContextPrefix = DefaultStatusContextPrefix
+		}
+
+		allGreen := statusAllGreen(ctx, pullRequestClient)
+		okToApply := statusOKToApply(ctx, pullRequestClient, statusContextPrefix)
+		workflowCompleted := statusWorkflowCompleted(
+			ctx,
+			pullRequestClient,
+			statusContextPrefix,
+			true,
+		)
+
+		assert.Equal(
+			t,
+			testCase.expAllGreen,
+			allGreen,
+			"All green, test case %d", index,
+		)
 
 		assert.Equal(
 			t,
@@ -222,3 +325,88 @@ func TestStatusOK(t *testing.T) {
 		)
 	}
 }
+
+func TestStatusWorkflowCompletedDiffOptional(t *testing.T) {
+	type testCase struct {
+		description          string
+		statuses             []pullreq.PullRequestStatus
+		diffRequired         bool
+		expWorkflowCompleted bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "apply without diff, diff required",
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/apply (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+			},
+			diffRequired:         true,
+			expWorkflowCompleted: false,
+		},
+		{
+			description: "apply without diff, diff optional",
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/apply (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+			},
+			diffRequired:         false,
+			expWorkflowCompleted: true,
+		},
+		{
+			description: "diff without apply, diff optional",
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/diff (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1,cluster2",
+				},
+				{
+					Context:     "kubeapply/apply (stage)",
+					State:       "success",
+					Description: "successful for clusters cluster1",
+				},
+			},
+			diffRequired:         false,
+			expWorkflowCompleted: false,
+		},
+		{
+			description: "failed apply, diff optional",
+			statuses: []pullreq.PullRequestStatus{
+				{
+					Context:     "kubeapply/apply (stage)",
+					State:       "failure",
+					Description: "failure for clusters cluster1",
+				},
+			},
+			diffRequired:         false,
+			expWorkflowCompleted: false,
+		},
+	}
+
+	ctx := context.Background()
+
+	for _, testCase := range testCases {
+		pullRequestClient := &pullreq.FakePullRequestClient{
+			RequestStatuses: testCase.statuses,
+		}
+
+		assert.Equal(
+			t,
+			testCase.expWorkflowCompleted,
+			statusWorkflowCompleted(
+				ctx,
+				pullRequestClient,
+				DefaultStatusContextPrefix,
+				testCase.diffRequired,
+			),
+			testCase.description,
+		)
+	}
+}
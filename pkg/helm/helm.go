@@ -36,12 +36,28 @@ type HelmClient struct {
 	Debug bool
 
 	// GlobalValuesPath is an optional path to a set of "global" values that will be used to
-	// supplement the chart-specific values.
+	// supplement the chart-specific values. The global values are used as the base, and the
+	// chart-specific values override them.
 	GlobalValuesPath string
 
+	// GlobalValuesOverride indicates whether the global values should instead override the
+	// chart-specific values. This was the behavior in older versions of kubeapply and is only
+	// kept for backwards compatibility.
+	GlobalValuesOverride bool
+
+	// Lint indicates whether each chart and its values should be checked with helm lint, which
+	// also validates the values against the chart's values.schema.json, before being expanded.
+	// This surfaces problems with a reference to the values file instead of failing in the
+	// middle of helm template.
+	Lint bool
+
 	// Parallelism is the number of helm processes that should be run in parallel.
 	Parallelism int
 
+	// RestoreLimits bounds the time and space used to fetch charts that override their
+	// sources.
+	RestoreLimits util.RestoreLimits
+
 	// RootDir is the root relative to which file URLs will be fetched. Only applies for charts
 	// that override their sources with a file URL.
 	RootDir string
@@ -217,7 +233,7 @@ func (c *HelmClient) generateHelmTemplates(
 	if chartsOverride != "" {
 		log.Debugf("Found charts override: %s", chartsOverride)
 
-		tempDir, err := ioutil.TempDir("", "charts")
+		tempDir, err := util.TempDir("charts")
 		if err != nil {
 			return err
 		}
@@ -229,6 +245,7 @@ func (c *HelmClient) generateHelmTemplates(
 			c.RootDir,
 			chartsOverride,
 			localChartsPath,
+			c.RestoreLimits,
 		)
 		if err != nil {
 			return err
@@ -270,6 +287,18 @@ func (c *HelmClient) generateHelmTemplates(
 
 	chartPath := filepath.Join(localChartsPath, chartNamePath)
 
+	if c.Lint {
+		if err := checkChartExists(chartPath); err != nil {
+			return fmt.Errorf(
+				"Values file %s (part %d/%d) references a missing chart: %+v",
+				hctx.valuesPath,
+				hctx.part+1,
+				hctx.totalParts,
+				err,
+			)
+		}
+	}
+
 	depArgs := []string{
 		"dep",
 		"update",
@@ -286,7 +315,7 @@ func (c *HelmClient) generateHelmTemplates(
 
 	releaseName := getValue(headerComments, releaseNameHeaders...)
 
-	tempValuesDir, err := ioutil.TempDir("", "helm")
+	tempValuesDir, err := util.TempDir("helm")
 	if err != nil {
 		return err
 	}
@@ -296,21 +325,43 @@ func (c *HelmClient) generateHelmTemplates(
 		return err
 	}
 
+	if c.Lint {
+		lintArgs := []string{
+			"lint",
+			fmt.Sprintf("--namespace=%s", templateNamespace),
+		}
+		lintArgs = append(lintArgs, c.valuesArgs(tempValuesPath)...)
+		if c.Debug {
+			lintArgs = append(lintArgs, "--debug")
+		}
+		lintArgs = append(lintArgs, chartPath)
+
+		if err := runHelm(ctx, lintArgs); err != nil {
+			return fmt.Errorf(
+				"Helm lint failed for values file %s (part %d/%d); see the helm output above for details: %+v",
+				hctx.valuesPath,
+				hctx.part+1,
+				hctx.totalParts,
+				err,
+			)
+		}
+	}
+
 	templateArgs := []string{
 		"template",
 		fmt.Sprintf("--namespace=%s", templateNamespace),
-		fmt.Sprintf("--values=%s", tempValuesPath),
-		fmt.Sprintf("--output-dir=%s", filepath.Dir(hctx.valuesPath)),
 	}
+	templateArgs = append(templateArgs, c.valuesArgs(tempValuesPath)...)
+	templateArgs = append(
+		templateArgs,
+		fmt.Sprintf("--output-dir=%s", filepath.Dir(hctx.valuesPath)),
+	)
 	if releaseName != "" {
 		templateArgs = append(templateArgs, fmt.Sprintf("--name-template=%s", releaseName))
 
 		// Include release name in output paths so different ones don't clobber each other
 		templateArgs = append(templateArgs, "--release-name")
 	}
-	if c.GlobalValuesPath != "" {
-		templateArgs = append(templateArgs, fmt.Sprintf("--values=%s", c.GlobalValuesPath))
-	}
 	if c.Debug {
 		templateArgs = append(templateArgs, "--debug")
 	}
@@ -320,6 +371,46 @@ func (c *HelmClient) generateHelmTemplates(
 	return runHelm(ctx, templateArgs)
 }
 
+// valuesArgs returns the --values arguments for helm template. Helm gives precedence to the
+// last values file passed in, so the global values go first unless GlobalValuesOverride is set.
+func (c *HelmClient) valuesArgs(valuesPath string) []string {
+	if c.GlobalValuesPath == "" {
+		return []string{fmt.Sprintf("--values=%s", valuesPath)}
+	}
+
+	if c.GlobalValuesOverride {
+		return []string{
+			fmt.Sprintf("--values=%s", valuesPath),
+			fmt.Sprintf("--values=%s", c.GlobalValuesPath),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf("--values=%s", c.GlobalValuesPath),
+		fmt.Sprintf("--values=%s", valuesPath),
+	}
+}
+
+// checkChartExists returns an error if there isn't a chart at the argument path.
+func checkChartExists(chartPath string) error {
+	info, err := os.Stat(chartPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Chart directory %s does not exist", chartPath)
+	} else if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("Chart path %s is not a directory", chartPath)
+	}
+
+	if _, err := os.Stat(filepath.Join(chartPath, "Chart.yaml")); os.IsNotExist(err) {
+		return fmt.Errorf("Chart directory %s does not contain a Chart.yaml", chartPath)
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func runHelm(ctx context.Context, args []string) error {
 	return util.RunCmdWithPrinters(
 		ctx,
@@ -20,6 +20,7 @@ global:
   cluster: "test-cluster"
   region: "us-west-2"
   shortRegion: "usw2"
+image: "test-image-global"
 `
 
 func TestExpandHelmTemplates(t *testing.T) {
@@ -217,6 +218,36 @@ func TestExpandHelmTemplates(t *testing.T) {
 	}
 }
 
+func TestCheckChartExists(t *testing.T) {
+	assert.NoError(t, checkChartExists("testdata/charts/alb-ingress-controller"))
+	assert.Error(t, checkChartExists("testdata/charts/non-existent"))
+	assert.Error(t, checkChartExists("testdata/charts"))
+	assert.Error(t, checkChartExists("testdata/charts/alb-ingress-controller/Chart.yaml"))
+}
+
+func TestValuesArgs(t *testing.T) {
+	client := &HelmClient{}
+	assert.Equal(
+		t,
+		[]string{"--values=values.yaml"},
+		client.valuesArgs("values.yaml"),
+	)
+
+	client.GlobalValuesPath = "globals.yaml"
+	assert.Equal(
+		t,
+		[]string{"--values=globals.yaml", "--values=values.yaml"},
+		client.valuesArgs("values.yaml"),
+	)
+
+	client.GlobalValuesOverride = true
+	assert.Equal(
+		t,
+		[]string{"--values=values.yaml", "--values=globals.yaml"},
+		client.valuesArgs("values.yaml"),
+	)
+}
+
 func TestGetHeaderComments(t *testing.T) {
 	type testCase struct {
 		contentsLines   []string
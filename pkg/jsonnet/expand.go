@@ -0,0 +1,217 @@
+package jsonnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	gojsonnet "github.com/google/go-jsonnet"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	jsonnetSuffix   = ".jsonnet"
+	libsonnetSuffix = ".libsonnet"
+)
+
+// ExpandJsonnet expands all jsonnet in the root directory, replacing each .jsonnet file with
+// its YAML expansion. Each of the params is available in the jsonnet as an external variable
+// (e.g., std.extVar('cluster')). Library (.libsonnet) files can be imported relative to the
+// files that use them; they're removed after all files are expanded.
+//
+// If sandbox is true, then import and importstr can only read files in the root directory.
+// This should be used when expanding untrusted configs.
+func ExpandJsonnet(expandRoot string, params map[string]interface{}, sandbox bool) error {
+	jsonnetPaths := []string{}
+	libsonnetPaths := []string{}
+
+	var sandboxRoot string
+	if sandbox {
+		var err error
+		sandboxRoot, err = resolvePath(expandRoot)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := filepath.Walk(
+		expandRoot,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			if strings.HasSuffix(path, jsonnetSuffix) {
+				jsonnetPaths = append(jsonnetPaths, path)
+			} else if strings.HasSuffix(path, libsonnetSuffix) {
+				libsonnetPaths = append(libsonnetPaths, path)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Expand after walking so that the new YAML files aren't walked and all libraries are
+	// still around for imports.
+	for _, jsonnetPath := range jsonnetPaths {
+		log.Infof("Processing file %s", jsonnetPath)
+		expandedStr, err := JsonnetToYaml(jsonnetPath, params, sandboxRoot)
+		if err != nil {
+			return fmt.Errorf("Error expanding path %s: %+v", jsonnetPath, err)
+		}
+
+		info, err := os.Stat(jsonnetPath)
+		if err != nil {
+			return err
+		}
+
+		// Replace suffix
+		expandedPath := fmt.Sprintf("%s.yaml", strings.TrimSuffix(jsonnetPath, jsonnetSuffix))
+		err = ioutil.WriteFile(expandedPath, []byte(expandedStr), info.Mode())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Clean up jsonnet files
+	for _, path := range append(jsonnetPaths, libsonnetPaths...) {
+		os.Remove(path)
+	}
+
+	return nil
+}
+
+// JsonnetToYaml evaluates a jsonnet file and converts the result into YAML Kubernetes
+// configs. If the result is an array, then each non-null element is output as a separate
+// YAML document. If sandboxRoot is set, then only files in it can be imported.
+func JsonnetToYaml(
+	path string,
+	params map[string]interface{},
+	sandboxRoot string,
+) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	vm := gojsonnet.MakeVM()
+	if sandboxRoot != "" {
+		vm.Importer(
+			&sandboxImporter{
+				root:     sandboxRoot,
+				importer: &gojsonnet.FileImporter{},
+			},
+		)
+	}
+
+	// Sort the keys so that errors are deterministic
+	keys := []string{}
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// JSON is valid jsonnet, so the params can be passed as code to preserve their types.
+		paramBytes, err := json.Marshal(params[key])
+		if err != nil {
+			return "", fmt.Errorf("Error converting param %s to jsonnet: %+v", key, err)
+		}
+		vm.ExtCode(key, string(paramBytes))
+	}
+
+	jsonStr, err := vm.EvaluateSnippet(path, string(contents))
+	if err != nil {
+		return "", err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		return "", err
+	}
+
+	objs, ok := result.([]interface{})
+	if !ok {
+		objs = []interface{}{result}
+	}
+
+	buf := &bytes.Buffer{}
+
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+
+		objBytes, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(buf, "---\n")
+		if _, err := buf.Write(objBytes); err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// sandboxImporter is a jsonnet importer that only allows imports of files in its root
+// directory. Symlinks are resolved before checking.
+type sandboxImporter struct {
+	root     string
+	importer gojsonnet.Importer
+}
+
+// Import imports the argument path, relative to the file that it's imported from, if it's in
+// the importer's root.
+func (i *sandboxImporter) Import(
+	importedFrom string,
+	importedPath string,
+) (gojsonnet.Contents, string, error) {
+	fullPath := importedPath
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(filepath.Dir(importedFrom), importedPath)
+	}
+
+	resolvedPath, err := resolvePath(fullPath)
+	if err != nil {
+		return gojsonnet.Contents{}, "", err
+	}
+
+	relPath, err := filepath.Rel(i.root, resolvedPath)
+	if err != nil ||
+		relPath == ".." ||
+		strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return gojsonnet.Contents{}, "", fmt.Errorf(
+			"Import %s is outside of the expand root %s",
+			importedPath,
+			i.root,
+		)
+	}
+
+	return i.importer.Import(importedFrom, importedPath)
+}
+
+// resolvePath returns the absolute version of the argument path with any symlinks resolved.
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolvedPath, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolvedPath
+	}
+	return absPath, nil
+}
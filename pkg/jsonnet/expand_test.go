@@ -0,0 +1,184 @@
+package jsonnet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandJsonnet(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "jsonnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"apps/deployment.jsonnet": `
+local lib = import 'lib.libsonnet';
+
+lib.configMap(std.extVar('cluster'), std.extVar('replicas'))
+`,
+			"apps/lib.libsonnet": `
+{
+  configMap(name, replicas):: {
+    apiVersion: 'v1',
+    kind: 'ConfigMap',
+    metadata: {
+      name: name,
+    },
+    data: {
+      replicas: std.toString(replicas * 2),
+    },
+  },
+}
+`,
+			"apps/list.jsonnet": `
+[
+  { apiVersion: 'v1', kind: 'Namespace', metadata: { name: std.extVar('env') } },
+  null,
+  { apiVersion: 'v1', kind: 'Namespace', metadata: { name: 'other' } },
+]
+`,
+			"apps/other.yaml": "key: value\n",
+		},
+	)
+
+	err = ExpandJsonnet(
+		tempDir,
+		map[string]interface{}{
+			"cluster":  "test-cluster",
+			"env":      "test-env",
+			"replicas": 2,
+		},
+		false,
+	)
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadFile(filepath.Join(tempDir, "apps/deployment.yaml"))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"---\napiVersion: v1\ndata:\n  replicas: \"4\"\nkind: ConfigMap\nmetadata:\n  name: test-cluster\n",
+		string(contents),
+	)
+
+	contents, err = ioutil.ReadFile(filepath.Join(tempDir, "apps/list.yaml"))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: test-env\n---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: other\n",
+		string(contents),
+	)
+
+	for _, path := range []string{
+		"apps/deployment.jsonnet",
+		"apps/lib.libsonnet",
+		"apps/list.jsonnet",
+	} {
+		_, err := os.Stat(filepath.Join(tempDir, path))
+		assert.True(t, os.IsNotExist(err), path)
+	}
+
+	contents, err = ioutil.ReadFile(filepath.Join(tempDir, "apps/other.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "key: value\n", string(contents))
+}
+
+func TestExpandJsonnetError(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "jsonnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"bad.jsonnet": "{ name: std.extVar('missing') }",
+		},
+	)
+
+	err = ExpandJsonnet(tempDir, map[string]interface{}{}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad.jsonnet")
+}
+
+func TestExpandJsonnetSandbox(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "jsonnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"outside/secret.txt":      "secret",
+			"root/apps/lib.libsonnet": "{ name: 'test' }",
+			"root/apps/inside.jsonnet": `
+local lib = import 'lib.libsonnet';
+
+{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: lib.name } }
+`,
+		},
+	)
+	outsidePath := filepath.Join(tempDir, "outside/secret.txt")
+
+	sandboxRoot, err := resolvePath(filepath.Join(tempDir, "root"))
+	require.NoError(t, err)
+
+	type testCase struct {
+		description string
+		contents    string
+		sandbox     bool
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "absolute import without sandbox",
+			contents:    fmt.Sprintf("{ data: { secret: importstr '%s' } }", outsidePath),
+		},
+		{
+			description: "absolute import in sandbox",
+			contents:    fmt.Sprintf("{ data: { secret: importstr '%s' } }", outsidePath),
+			sandbox:     true,
+			expErr:      true,
+		},
+		{
+			description: "relative import outside of root in sandbox",
+			contents:    "{ data: { secret: importstr '../../outside/secret.txt' } }",
+			sandbox:     true,
+			expErr:      true,
+		},
+		{
+			description: "import inside of root in sandbox",
+			contents:    "import 'inside.jsonnet'",
+			sandbox:     true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		path := filepath.Join(tempDir, "root/apps/test.jsonnet")
+		require.NoError(t, ioutil.WriteFile(path, []byte(testCase.contents), 0644))
+
+		root := ""
+		if testCase.sandbox {
+			root = sandboxRoot
+		}
+
+		_, err := JsonnetToYaml(path, map[string]interface{}{}, root)
+		if testCase.expErr {
+			require.Error(t, err, testCase.description)
+			assert.Contains(t, err.Error(), "outside of the expand root", testCase.description)
+		} else {
+			assert.NoError(t, err, testCase.description)
+		}
+	}
+}
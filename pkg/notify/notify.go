@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+)
+
+// ApplyResult contains the details of an apply that are sent in notifications.
+type ApplyResult struct {
+	// Env is the environment of the clusters that the apply was run in.
+	Env string
+
+	// Clusters are the descriptive names of the clusters that the apply was run in.
+	Clusters []string
+
+	// SHA is the git SHA of the change that was applied.
+	SHA string
+
+	// PullRequestURL is the URL of the pull request that triggered the apply.
+	PullRequestURL string
+
+	// Err is the error from the apply, or nil if the apply was successful.
+	Err error
+}
+
+// Success returns whether the apply was successful.
+func (r ApplyResult) Success() bool {
+	return r.Err == nil
+}
+
+// Notifier is an interface for sending notifications about kubeapply events to external
+// systems.
+type Notifier interface {
+	NotifyApply(ctx context.Context, result ApplyResult) error
+}
+
+// NullNotifier is a Notifier implementation that does not send any notifications.
+type NullNotifier struct {
+}
+
+var _ Notifier = (*NullNotifier)(nil)
+
+// NotifyApply does nothing.
+func (n *NullNotifier) NotifyApply(ctx context.Context, result ApplyResult) error {
+	return nil
+}
+
+// FakeNotifier is a fake implementation of Notifier for testing purposes.
+type FakeNotifier struct {
+	ApplyResults []ApplyResult
+}
+
+var _ Notifier = (*FakeNotifier)(nil)
+
+// NewFakeNotifier returns a new FakeNotifier instance.
+func NewFakeNotifier() *FakeNotifier {
+	return &FakeNotifier{
+		ApplyResults: []ApplyResult{},
+	}
+}
+
+// NotifyApply records the argument result.
+func (n *FakeNotifier) NotifyApply(ctx context.Context, result ApplyResult) error {
+	n.ApplyResults = append(n.ApplyResults, result)
+	return nil
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	slackTimeout = 10 * time.Second
+)
+
+var _ Notifier = (*SlackNotifier)(nil)
+
+// SlackNotifier is a Notifier implementation that posts messages to a Slack incoming webhook.
+type SlackNotifier struct {
+	webhookURL string
+	envs       map[string]struct{}
+	httpClient *http.Client
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// NewSlackNotifier returns a new SlackNotifier that posts to the argument webhook URL. If envs
+// is non-empty, then notifications are only sent for applies in the listed environments.
+func NewSlackNotifier(webhookURL string, envs []string) *SlackNotifier {
+	envsMap := map[string]struct{}{}
+	for _, env := range envs {
+		envsMap[env] = struct{}{}
+	}
+
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		envs:       envsMap,
+		httpClient: &http.Client{
+			Timeout: slackTimeout,
+		},
+	}
+}
+
+// NotifyApply posts a message about the argument apply result to Slack.
+func (s *SlackNotifier) NotifyApply(ctx context.Context, result ApplyResult) error {
+	if len(s.envs) > 0 {
+		if _, ok := s.envs[result.Env]; !ok {
+			log.Debugf("Not sending slack notification for env %s", result.Env)
+			return nil
+		}
+	}
+
+	messageBytes, err := json.Marshal(
+		slackMessage{
+			Text: formatApplyMessage(result),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		s.webhookURL,
+		bytes.NewReader(messageBytes),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected response from slack: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func formatApplyMessage(result ApplyResult) string {
+	var status string
+	if result.Success() {
+		status = ":white_check_mark: kubeapply apply succeeded"
+	} else {
+		status = ":x: kubeapply apply failed"
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s in env %s", status, result.Env),
+		fmt.Sprintf("*Clusters*: %s", strings.Join(result.Clusters, ", ")),
+		fmt.Sprintf("*SHA*: %s", result.SHA),
+		fmt.Sprintf("*Pull request*: %s", result.PullRequestURL),
+	}
+	if !result.Success() {
+		lines = append(lines, fmt.Sprintf("*Error*: %s", result.Err.Error()))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackNotifier(t *testing.T) {
+	type testCase struct {
+		description string
+		envs        []string
+		result      ApplyResult
+		statusCode  int
+		expMessage  bool
+		expContains []string
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			description: "success",
+			result: ApplyResult{
+				Env:            "production",
+				Clusters:       []string{"cluster1", "cluster2"},
+				SHA:            "abc123",
+				PullRequestURL: "https://github.com/segmentio/repo/pull/1",
+			},
+			statusCode: http.StatusOK,
+			expMessage: true,
+			expContains: []string{
+				"apply succeeded in env production",
+				"cluster1, cluster2",
+				"abc123",
+				"https://github.com/segmentio/repo/pull/1",
+			},
+		},
+		{
+			description: "failure",
+			envs:        []string{"production"},
+			result: ApplyResult{
+				Env:      "production",
+				Clusters: []string{"cluster1"},
+				SHA:      "abc123",
+				Err:      errors.New("kubectl exploded"),
+			},
+			statusCode: http.StatusOK,
+			expMessage: true,
+			expContains: []string{
+				"apply failed in env production",
+				"kubectl exploded",
+			},
+		},
+		{
+			description: "filtered env",
+			envs:        []string{"production"},
+			result: ApplyResult{
+				Env:      "stage",
+				Clusters: []string{"cluster1"},
+				SHA:      "abc123",
+			},
+			statusCode: http.StatusOK,
+			expMessage: false,
+		},
+		{
+			description: "slack error",
+			result: ApplyResult{
+				Env:      "production",
+				Clusters: []string{"cluster1"},
+				SHA:      "abc123",
+			},
+			statusCode: http.StatusInternalServerError,
+			expMessage: true,
+			expErr:     true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		messages := []slackMessage{}
+
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					message := slackMessage{}
+					err := json.NewDecoder(r.Body).Decode(&message)
+					require.NoError(t, err, testCase.description)
+					messages = append(messages, message)
+					w.WriteHeader(testCase.statusCode)
+				},
+			),
+		)
+
+		notifier := NewSlackNotifier(server.URL, testCase.envs)
+		err := notifier.NotifyApply(context.Background(), testCase.result)
+		server.Close()
+
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			assert.NoError(t, err, testCase.description)
+		}
+
+		if !testCase.expMessage {
+			assert.Equal(t, 0, len(messages), testCase.description)
+			continue
+		}
+
+		require.Equal(t, 1, len(messages), testCase.description)
+		for _, containsStr := range testCase.expContains {
+			assert.Contains(t, messages[0].Text, containsStr, testCase.description)
+		}
+	}
+}
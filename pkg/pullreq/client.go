@@ -39,7 +39,7 @@ type PullRequestClient interface {
 	) error
 
 	// Merge merges the client's pull request into the base branch.
-	Merge(ctx context.Context) error
+	Merge(ctx context.Context, message string) error
 
 	// Statuses gets all statuses for the pull request.
 	Statuses(ctx context.Context) ([]PullRequestStatus, error)
@@ -65,6 +65,24 @@ type PullRequestClient interface {
 	// HeadSHA returns the SHA of the head of this pull request.
 	HeadSHA() string
 
+	// Title returns the title of this pull request.
+	Title() string
+
+	// Body returns the description of this pull request.
+	Body() string
+
+	// Labels returns the names of the labels on this pull request.
+	Labels() []string
+
+	// ChangedFilesSince returns the local paths of the files that have changed between the
+	// argument SHA and the head of this pull request.
+	ChangedFilesSince(ctx context.Context, sha string) ([]string, error)
+
+	// IsTeamMember returns whether the argument user is an active member of the argument
+	// Github team. Teams are either "[org]/[team slug]" or just "[team slug]" for teams in the
+	// organization that owns the repo.
+	IsTeamMember(ctx context.Context, team string, user string) (bool, error)
+
 	// Close cleans up the resources behind this pull request.
 	Close() error
 }
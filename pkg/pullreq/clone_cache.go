@@ -0,0 +1,232 @@
+package pullreq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// CloneCache stores copies of pull request clones on local disk so that commands for the
+// same pull request and head SHA can reuse them instead of cloning the repo again. It's only
+// useful in long-lived processes like the webhooks server; the lambda starts with an empty
+// disk for most invocations, so it doesn't use one.
+//
+// Each client gets its own copy of the cached clone, so clients can't interfere with each
+// other or with the cache. The lock is only held while looking up and updating entries; the
+// copies themselves happen outside of it so that a slow copy doesn't block other clients.
+type CloneCache struct {
+	sync.Mutex
+
+	root       string
+	maxEntries int
+	maxAge     time.Duration
+	entries    map[string]*cloneCacheEntry
+
+	// storing contains the keys of the clones that are currently being copied into the cache.
+	storing map[string]struct{}
+}
+
+type cloneCacheEntry struct {
+	path     string
+	storedAt time.Time
+	lastUsed time.Time
+
+	// users is the number of clients that are currently copying from this entry. Evicted
+	// entries aren't removed from disk until this drops to zero.
+	users   int
+	evicted bool
+}
+
+// NewCloneCache returns a new CloneCache that stores clones in the argument root directory.
+// If maxEntries is positive, then the least recently used clones are evicted once there
+// are more than that many. If maxAge is positive, then clones older than it are evicted.
+func NewCloneCache(root string, maxEntries int, maxAge time.Duration) (*CloneCache, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	return &CloneCache{
+		root:       root,
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+		entries:    map[string]*cloneCacheEntry{},
+		storing:    map[string]struct{}{},
+	}, nil
+}
+
+// Restore copies the cached clone for the argument pull request and SHA, if any, into the
+// argument directory. It returns whether the clone was found.
+func (c *CloneCache) Restore(
+	owner string,
+	repo string,
+	pullRequestNum int,
+	sha string,
+	dest string,
+) (bool, error) {
+	key := cloneCacheKey(owner, repo, pullRequestNum, sha)
+	entry := c.acquire(key)
+	if entry == nil {
+		return false, nil
+	}
+	defer c.release(entry)
+
+	log.Infof("Copying cached clone for %s from %s to %s", key, entry.path, dest)
+	if err := util.RecursiveCopy(entry.path, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// acquire returns the entry for the argument key, if any, and marks it as in use so that it
+// isn't removed from disk until it's released.
+func (c *CloneCache) acquire(key string) *cloneCacheEntry {
+	now := time.Now()
+
+	c.Lock()
+	removePaths := c.evict(now)
+	entry, ok := c.entries[key]
+	if ok {
+		entry.users++
+		entry.lastUsed = now
+	}
+	c.Unlock()
+
+	removeClones(removePaths)
+	return entry
+}
+
+// release marks the argument entry as no longer in use, removing it from disk if it was
+// evicted in the meantime.
+func (c *CloneCache) release(entry *cloneCacheEntry) {
+	c.Lock()
+	entry.users--
+	remove := entry.evicted && entry.users == 0
+	c.Unlock()
+
+	if remove {
+		removeClones([]string{entry.path})
+	}
+}
+
+// Store copies the clone in the argument directory into the cache for the argument pull
+// request and SHA, evicting old clones as needed. The clone is copied to a temporary
+// directory first and then renamed into place so that it's never restored partially.
+func (c *CloneCache) Store(
+	owner string,
+	repo string,
+	pullRequestNum int,
+	sha string,
+	src string,
+) error {
+	key := cloneCacheKey(owner, repo, pullRequestNum, sha)
+
+	c.Lock()
+	_, stored := c.entries[key]
+	_, storing := c.storing[key]
+	if !stored && !storing {
+		c.storing[key] = struct{}{}
+	}
+	c.Unlock()
+
+	if stored || storing {
+		// Another client already stored this clone
+		return nil
+	}
+
+	defer func() {
+		c.Lock()
+		delete(c.storing, key)
+		c.Unlock()
+	}()
+
+	nameSuffix := fmt.Sprintf("%s-%d", sha, time.Now().UnixNano())
+	tempPath := filepath.Join(c.root, fmt.Sprintf(".tmp-%s", nameSuffix))
+	path := filepath.Join(c.root, nameSuffix)
+
+	log.Infof("Storing clone for %s in %s", key, path)
+	if err := util.RecursiveCopy(src, tempPath); err != nil {
+		os.RemoveAll(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.RemoveAll(tempPath)
+		return err
+	}
+
+	now := time.Now()
+
+	c.Lock()
+	c.entries[key] = &cloneCacheEntry{
+		path:     path,
+		storedAt: now,
+		lastUsed: now,
+	}
+	removePaths := c.evict(now)
+	c.Unlock()
+
+	removeClones(removePaths)
+	return nil
+}
+
+// evict drops the clones that are older than the max age and then, if there are still too
+// many, the least recently used ones. It returns the paths of the dropped clones that can be
+// removed from disk; clones that are still being copied are removed by their last user
+// instead. The caller must hold the lock.
+func (c *CloneCache) evict(now time.Time) []string {
+	removePaths := []string{}
+	keys := []string{}
+
+	for key, entry := range c.entries {
+		if c.maxAge > 0 && now.Sub(entry.storedAt) > c.maxAge {
+			removePaths = c.drop(key, removePaths)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	if c.maxEntries <= 0 || len(keys) <= c.maxEntries {
+		return removePaths
+	}
+
+	sort.Slice(
+		keys,
+		func(a, b int) bool {
+			return c.entries[keys[a]].lastUsed.Before(c.entries[keys[b]].lastUsed)
+		},
+	)
+	for _, key := range keys[:len(keys)-c.maxEntries] {
+		removePaths = c.drop(key, removePaths)
+	}
+
+	return removePaths
+}
+
+func (c *CloneCache) drop(key string, removePaths []string) []string {
+	log.Infof("Evicting cached clone for %s", key)
+	entry := c.entries[key]
+	delete(c.entries, key)
+
+	entry.evicted = true
+	if entry.users == 0 {
+		removePaths = append(removePaths, entry.path)
+	}
+	return removePaths
+}
+
+func removeClones(paths []string) {
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			log.Warnf("Error removing cached clone in %s: %+v", path, err)
+		}
+	}
+}
+
+func cloneCacheKey(owner string, repo string, pullRequestNum int, sha string) string {
+	return fmt.Sprintf("%s/%s#%d@%s", owner, repo, pullRequestNum, sha)
+}
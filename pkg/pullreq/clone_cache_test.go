@@ -0,0 +1,151 @@
+package pullreq
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kubeapply/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneCache(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "clone_cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"clone1/clusters/cluster1.yaml": "cluster: cluster1\n",
+			"clone2/clusters/cluster2.yaml": "cluster: cluster2\n",
+			"clone3/clusters/cluster3.yaml": "cluster: cluster3\n",
+		},
+	)
+
+	cache, err := NewCloneCache(filepath.Join(tempDir, "cache"), 2, 0)
+	require.NoError(t, err)
+
+	restore := func(sha string) (bool, string) {
+		dest, err := ioutil.TempDir(tempDir, "dest")
+		require.NoError(t, err)
+
+		ok, err := cache.Restore("segmentio", "test-repo", 123, sha, dest)
+		require.NoError(t, err)
+		return ok, dest
+	}
+
+	ok, _ := restore("sha1")
+	assert.False(t, ok)
+
+	require.NoError(
+		t,
+		cache.Store("segmentio", "test-repo", 123, "sha1", filepath.Join(tempDir, "clone1")),
+	)
+	ok, dest := restore("sha1")
+	require.True(t, ok)
+	contents, err := ioutil.ReadFile(filepath.Join(dest, "clusters/cluster1.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "cluster: cluster1\n", string(contents))
+
+	// Different pull requests don't share clones
+	ok, err = cache.Restore("segmentio", "test-repo", 124, "sha1", filepath.Join(tempDir, "other"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The least recently used clone is evicted once there are too many
+	require.NoError(
+		t,
+		cache.Store("segmentio", "test-repo", 123, "sha2", filepath.Join(tempDir, "clone2")),
+	)
+	ok, _ = restore("sha1")
+	require.True(t, ok)
+	require.NoError(
+		t,
+		cache.Store("segmentio", "test-repo", 123, "sha3", filepath.Join(tempDir, "clone3")),
+	)
+
+	ok, _ = restore("sha1")
+	assert.True(t, ok)
+	ok, _ = restore("sha2")
+	assert.False(t, ok)
+	ok, _ = restore("sha3")
+	assert.True(t, ok)
+
+	// Old clones are evicted
+	cache.maxAge = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	ok, _ = restore("sha3")
+	assert.False(t, ok)
+
+	subDirs, err := ioutil.ReadDir(filepath.Join(tempDir, "cache"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(subDirs))
+}
+
+func TestCloneCacheEvictInUse(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "clone_cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	util.WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			"clone1/clusters/cluster1.yaml": "cluster: cluster1\n",
+			"clone2/clusters/cluster2.yaml": "cluster: cluster2\n",
+		},
+	)
+
+	cache, err := NewCloneCache(filepath.Join(tempDir, "cache"), 1, 0)
+	require.NoError(t, err)
+
+	require.NoError(
+		t,
+		cache.Store("segmentio", "test-repo", 123, "sha1", filepath.Join(tempDir, "clone1")),
+	)
+	// Simulate a restore that's still copying the clone
+	entry := cache.acquire(cloneCacheKey("segmentio", "test-repo", 123, "sha1"))
+	require.NotNil(t, entry)
+
+	// Storing the same clone again is a no-op
+	require.NoError(
+		t,
+		cache.Store("segmentio", "test-repo", 123, "sha1", filepath.Join(tempDir, "clone2")),
+	)
+	assert.Equal(t, 1, len(cache.entries))
+
+	// The clone is evicted but stays on disk while it's in use
+	require.NoError(
+		t,
+		cache.Store("segmentio", "test-repo", 123, "sha2", filepath.Join(tempDir, "clone2")),
+	)
+	assert.True(t, entry.evicted)
+	ok, err := util.DirExists(entry.path)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Once the last user is done, the clone is removed
+	cache.release(entry)
+	ok, err = util.DirExists(entry.path)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = cache.Restore(
+		"segmentio",
+		"test-repo",
+		123,
+		"sha1",
+		filepath.Join(tempDir, "dest"),
+	)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	subDirs, err := ioutil.ReadDir(filepath.Join(tempDir, "cache"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(subDirs))
+}
@@ -2,33 +2,70 @@ package pullreq
 
 import (
 	"bytes"
-	"io/ioutil"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"text/template"
+	"time"
+	"unicode/utf8"
 
 	"github.com/segmentio/kubeapply/data"
 	"github.com/segmentio/kubeapply/pkg/cluster/apply"
 	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/store"
+	"github.com/segmentio/kubeapply/pkg/util"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	// Special string used to indicate where we we can split very long comments.
 	kubeapplySplit = "<!-- KUBEAPPLY_SPLIT -->"
+	// Kind used for diff results without a parsed object.
+	unknownKind = "unknown"
+
+	// DefaultMergeMessageTemplate is the default template for the commit messages of
+	// automerged pull requests.
+	DefaultMergeMessageTemplate = "Merged by kubeapply (pull request {{ .PullRequestNum }})"
 )
 
-var templates *template.Template
+var (
+	templates *template.Template
+
+	// Matches the special strings used to mark the start of named sections (e.g., clusters)
+	// in comments; these are used to build an index for comments that are split into chunks.
+	kubeapplySectionRegexp = regexp.MustCompile(`<!-- KUBEAPPLY_SECTION (.+?) -->`)
+)
 
 func init() {
 	var err error
-	templates, err = loadTemplates()
+	templates, err = loadTemplates("")
 	if err != nil {
 		panic(err)
 	}
 }
 
+// LoadCommentTemplates replaces the templates used for formatting comments with the embedded
+// ones plus any overrides in the argument directory. Override files must have the same names as
+// the embedded templates (e.g., diff_comment.gotpl); templates that aren't overridden fall back
+// to the embedded versions. If overridesDir is empty, only the embedded templates are used.
+//
+// This should be called once at startup, before any comments are formatted.
+func LoadCommentTemplates(overridesDir string) error {
+	loadedTemplates, err := loadTemplates(overridesDir)
+	if err != nil {
+		return err
+	}
+
+	templates = loadedTemplates
+	return nil
+}
+
 // ApplyCommentData stores data for templating out a "kubeapply apply" comment result.
 type ApplyCommentData struct {
 	ClusterApplies    []ClusterApply
@@ -40,6 +77,10 @@ type ApplyCommentData struct {
 type ClusterApply struct {
 	ClusterConfig *config.ClusterConfig
 	Results       []apply.Result
+
+	// Skipped indicates whether the cluster was skipped because it was already applied at the
+	// same SHA.
+	Skipped bool
 }
 
 // NumUpdates returns the number of updates that were made as part of the apply.
@@ -55,6 +96,12 @@ func (c ClusterApply) NumUpdates() int {
 	return updates
 }
 
+// NumResources returns the number of resources that were reconciled as part of the apply,
+// including the ones that weren't changed.
+func (c ClusterApply) NumResources() int {
+	return len(c.Results)
+}
+
 // FormatApplyComment generates the body of an apply comment result.
 func FormatApplyComment(commentData ApplyCommentData) (string, error) {
 	out := &bytes.Buffer{}
@@ -76,12 +123,151 @@ type DiffCommentData struct {
 	ClusterDiffs      []ClusterDiff
 	PullRequestClient PullRequestClient
 	Env               string
+
+	// MaxDiffLinesPerResource is the maximum number of raw diff lines shown for each resource.
+	// If zero, then the diffs are only clipped by length.
+	MaxDiffLinesPerResource int
+
+	// GroupByNamespace is whether to group the resources with diffs in each cluster by
+	// namespace, with a header for each namespace.
+	GroupByNamespace bool
 }
 
 // ClusterDiff contains the results of a diff in a single cluster.
 type ClusterDiff struct {
 	ClusterConfig *config.ClusterConfig
 	Results       []diff.Result
+
+	// HiddenHelmHooks is the number of helm hooks with diffs that aren't in Results.
+	HiddenHelmHooks int
+
+	// IncrementalSince is the SHA of the previous diff that this diff is incremental to. If
+	// empty, then all of the cluster's subpaths were diffed.
+	IncrementalSince string
+
+	// IncrementalSubpaths are the subpaths of the expanded configs that were diffed if this
+	// is an incremental diff.
+	IncrementalSubpaths []string
+
+	// DryRunResults are the results of a dry-run apply of the same subpaths as the diff. If
+	// empty, then no dry-run was done.
+	DryRunResults []apply.Result
+}
+
+// ResultGroups returns the results of this diff grouped by namespace if groupByNamespace is
+// true, or a single group with all of the results otherwise.
+func (c ClusterDiff) ResultGroups(groupByNamespace bool) []diff.NamespaceGroup {
+	if groupByNamespace {
+		return diff.GroupByNamespace(c.Results)
+	}
+	return []diff.NamespaceGroup{{Results: c.Results}}
+}
+
+// DryRunCounts is a summary of the changes that a dry-run apply would make.
+type DryRunCounts struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+// DryRunCounts tallies the dry-run apply results for this diff. The dry-run determines which
+// resources would be created; existing resources are counted as updated if they have diffs
+// and unchanged otherwise.
+func (c ClusterDiff) DryRunCounts() DryRunCounts {
+	diffKeys := map[string]struct{}{}
+
+	for _, result := range c.Results {
+		if result.Object != nil {
+			diffKeys[resourceKey(
+				result.Object.Kind,
+				result.Object.Namespace,
+				result.Object.Name,
+			)] = struct{}{}
+		}
+	}
+
+	counts := DryRunCounts{}
+
+	for _, result := range c.DryRunResults {
+		if result.IsCreated() {
+			counts.Created++
+		} else if _, ok := diffKeys[resourceKey(
+			result.Kind,
+			result.Namespace,
+			result.Name,
+		)]; ok {
+			counts.Updated++
+		} else {
+			counts.Unchanged++
+		}
+	}
+
+	return counts
+}
+
+func resourceKey(kind string, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// KindCount is the number of resources of a single kind in a diff.
+type KindCount struct {
+	Kind  string
+	Count int
+}
+
+// KindCounts tallies the results in this diff by kind, sorted in descending order of count.
+// Results without a parsed object are grouped under the "unknown" kind.
+func (c ClusterDiff) KindCounts() []KindCount {
+	countsMap := map[string]int{}
+
+	for _, result := range c.Results {
+		kind := unknownKind
+		if result.Object != nil && result.Object.Kind != "" {
+			kind = result.Object.Kind
+		}
+		countsMap[kind]++
+	}
+
+	kindCounts := []KindCount{}
+	for kind, count := range countsMap {
+		kindCounts = append(kindCounts, KindCount{Kind: kind, Count: count})
+	}
+
+	sort.Slice(kindCounts, func(a, b int) bool {
+		if kindCounts[a].Count != kindCounts[b].Count {
+			return kindCounts[a].Count > kindCounts[b].Count
+		}
+		return kindCounts[a].Kind < kindCounts[b].Kind
+	})
+
+	return kindCounts
+}
+
+// PrettyKindCounts generates a Github-friendly summary of the number of resources with
+// diffs by kind.
+func (c ClusterDiff) PrettyKindCounts() string {
+	kindCountStrs := []string{}
+
+	for _, kindCount := range c.KindCounts() {
+		kindCountStrs = append(
+			kindCountStrs,
+			fmt.Sprintf("`%s` (%d)", kindCount.Kind, kindCount.Count),
+		)
+	}
+
+	return strings.Join(kindCountStrs, ", ")
+}
+
+// PrettyIncrementalSubpaths generates a Github-friendly list of the subpaths that were
+// diffed in an incremental diff.
+func (c ClusterDiff) PrettyIncrementalSubpaths() string {
+	subpathStrs := []string{}
+
+	for _, subpath := range c.IncrementalSubpaths {
+		subpathStrs = append(subpathStrs, fmt.Sprintf("`%s`", subpath))
+	}
+
+	return strings.Join(subpathStrs, ", ")
 }
 
 // FormatDiffComment generates the body of a diff comment result.
@@ -127,6 +313,26 @@ func FormatErrorComment(commentData ErrorCommentData) (string, error) {
 type HelpCommentData struct {
 	ClusterConfigs []*config.ClusterConfig
 	Env            string
+
+	// Actor is the Github login of the user that ApplyAuthorized is for. It's only set if some
+	// of the clusters restrict applies to teams.
+	Actor string
+
+	// ApplyAuthorized is whether Actor can apply in each cluster, keyed by descriptive name.
+	ApplyAuthorized map[string]bool
+}
+
+// ApplyAccess returns a description of whether Actor can apply in the argument cluster.
+func (h HelpCommentData) ApplyAccess(clusterConfig *config.ClusterConfig) string {
+	if h.ApplyAuthorized[clusterConfig.DescriptiveName()] {
+		return "✅ Yes"
+	}
+
+	teamStrs := []string{}
+	for _, team := range clusterConfig.ApplyTeams {
+		teamStrs = append(teamStrs, fmt.Sprintf("`%s`", team))
+	}
+	return fmt.Sprintf("❌ No, must be in %s", strings.Join(teamStrs, " or "))
 }
 
 // FormatHelpComment generates the body of a help comment result.
@@ -156,6 +362,19 @@ type StatusCommentData struct {
 type ClusterStatus struct {
 	ClusterConfig *config.ClusterConfig
 	HealthSummary string
+
+	// StructuredSummary is a machine-readable version of the health summary. If nil, then
+	// it's omitted from the comment.
+	StructuredSummary *kube.StructuredSummary
+}
+
+// StructuredSummaryJSON returns the indented JSON encoding of the structured summary.
+func (c ClusterStatus) StructuredSummaryJSON() (string, error) {
+	summaryBytes, err := json.MarshalIndent(c.StructuredSummary, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(summaryBytes), nil
 }
 
 // FormatStatusComment generates the body of a status comment result.
@@ -174,65 +393,305 @@ func FormatStatusComment(commentData StatusCommentData) (string, error) {
 	return strings.TrimSpace(string(out.Bytes())), nil
 }
 
-func loadTemplates() (*template.Template, error) {
+// LocksCommentData stores data for templating out a "kubeapply locks" comment result.
+type LocksCommentData struct {
+	ClusterLocks []ClusterLock
+	Env          string
+}
+
+// ClusterLock contains the state of the kubeapply lock in a single cluster.
+type ClusterLock struct {
+	ClusterConfig *config.ClusterConfig
+
+	// Lock is the cluster's lock. If nil, then the lock has never been acquired.
+	Lock *store.LockInfo
+
+	// Held indicates whether the lock is currently held, i.e. it has a holder and its lease
+	// hasn't expired.
+	Held bool
+}
+
+// LockedSince returns the time that the lock was acquired at, formatted for comments.
+func (c ClusterLock) LockedSince() string {
+	if c.Lock == nil {
+		return ""
+	}
+	return c.Lock.AcquiredAt.UTC().Format(time.RFC3339)
+}
+
+// FormatLocksComment generates the body of a locks comment result.
+func FormatLocksComment(commentData LocksCommentData) (string, error) {
+	out := &bytes.Buffer{}
+
+	err := templates.ExecuteTemplate(
+		out,
+		"locks_comment.gotpl",
+		commentData,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out.Bytes())), nil
+}
+
+// MergeMessageData stores data for templating out the commit message of an automerged pull
+// request.
+type MergeMessageData struct {
+	PullRequestNum int
+	Title          string
+	Env            string
+
+	// Clusters are the descriptive names of the clusters covered by the pull request.
+	Clusters []string
+}
+
+// FormatMergeMessage generates an automerge commit message from the argument template, which
+// is in Go text/template format.
+func FormatMergeMessage(templateStr string, messageData MergeMessageData) (string, error) {
+	messageTemplate, err := template.New("merge_message").Parse(templateStr)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing merge message template: %+v", err)
+	}
+
+	out := &bytes.Buffer{}
+	if err := messageTemplate.Execute(out, messageData); err != nil {
+		return "", fmt.Errorf("Error executing merge message template: %+v", err)
+	}
+
+	return strings.TrimSpace(string(out.Bytes())), nil
+}
+
+func loadTemplates(overridesDir string) (*template.Template, error) {
 	templates := template.New("base")
 
-	tempDir, err := ioutil.TempDir("", "templates")
+	tempDir, err := util.TempDir("templates")
 	if err != nil {
 		return nil, err
 	}
 	defer os.RemoveAll(tempDir)
 
 	data.RestoreAssets(tempDir, "pkg/pullreq/templates")
-	return templates.ParseGlob(
+	templates, err = templates.ParseGlob(
 		filepath.Join(tempDir, "pkg/pullreq/templates/*.gotpl"),
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	if overridesDir == "" {
+		return templates, nil
+	}
+
+	overridePaths, err := filepath.Glob(filepath.Join(overridesDir, "*.gotpl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(overridePaths) == 0 {
+		log.Warnf("No comment template overrides found in %s", overridesDir)
+		return templates, nil
+	}
+
+	for _, overridePath := range overridePaths {
+		name := filepath.Base(overridePath)
+		if templates.Lookup(name) == nil {
+			return nil, fmt.Errorf(
+				"Template override %s does not match the name of any comment template",
+				overridePath,
+			)
+		}
+
+		log.Infof("Overriding comment template %s with %s", name, overridePath)
+	}
+
+	// Templates parsed later replace earlier ones with the same name
+	return templates.ParseFiles(overridePaths...)
 }
 
+// commentChunks splits the argument comment body into chunks that are at most maxLen bytes
+// long, where possible. Chunks are broken at the kubeapplySplit markers that the templates put
+// between clusters and resources, falling back to newlines for pieces that are too long by
+// themselves. Code fences that are open at the end of a chunk are closed and then reopened in
+// the next one.
 func commentChunks(body string, maxLen int) []string {
+	if len(body) <= maxLen {
+		return []string{body}
+	}
+
 	chunks := []string{}
 
-	if len(body) > maxLen {
-		start := 0
-		end := maxLen
+	for _, chunk := range splitChunks(body, maxLen, []string{kubeapplySplit, "\n"}) {
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
 
-		var splitStr string
+	return repairFences(chunks)
+}
+
+func splitChunks(body string, maxLen int, splitStrs []string) []string {
+	if len(body) <= maxLen {
+		return []string{body}
+	}
+	if len(splitStrs) == 0 {
+		return hardSplitChunks(body, maxLen)
+	}
+
+	splitStr := splitStrs[0]
+	if !strings.Contains(body, splitStr) {
+		return splitChunks(body, maxLen, splitStrs[1:])
+	}
+
+	chunks := []string{}
+	var current string
+	var hasCurrent bool
+
+	for _, piece := range strings.Split(body, splitStr) {
+		if hasCurrent && len(current)+len(splitStr)+len(piece) <= maxLen {
+			current = current + splitStr + piece
+			continue
+		}
+
+		if hasCurrent {
+			chunks = append(chunks, current)
+		}
 
-		if strings.Contains(body, kubeapplySplit) {
-			splitStr = kubeapplySplit
+		if len(piece) > maxLen {
+			chunks = append(chunks, splitChunks(piece, maxLen, splitStrs[1:])...)
+			current = ""
+			hasCurrent = false
 		} else {
-			// Just split on newlines
-			splitStr = "\n"
+			current = piece
+			hasCurrent = true
 		}
+	}
 
-		for start < end {
-			// Try to split at first splitter string after end
-			splitIndex := strings.Index(body[end:], splitStr)
-			if splitIndex > 0 {
-				end += splitIndex
-			}
+	if hasCurrent {
+		chunks = append(chunks, current)
+	}
 
-			chunk := body[start:end]
-			chunks = append(chunks, chunk)
+	return chunks
+}
+
+// hardSplitChunks cuts body into chunks of at most maxLen bytes without breaking up any
+// UTF-8 characters. It's used for single lines that can't be split any other way.
+func hardSplitChunks(body string, maxLen int) []string {
+	chunks := []string{}
 
-			if splitIndex > 0 {
-				// Swallow splitter string
-				start = end + len(splitStr)
-			} else {
-				start = end
-			}
-			end = min(start+maxLen, len(body))
+	for len(body) > maxLen {
+		end := maxLen
+		for end > 0 && !utf8.RuneStart(body[end]) {
+			end--
+		}
+		if end == 0 {
+			// maxLen is smaller than the first character, so just keep it whole
+			_, end = utf8.DecodeRuneInString(body)
 		}
-	} else {
-		chunks = append(chunks, body)
+
+		chunks = append(chunks, body[:end])
+		body = body[end:]
 	}
 
-	return chunks
+	return append(chunks, body)
+}
+
+// repairFences closes any code fences that are left open at the end of a chunk and reopens
+// them at the start of the next one so that the markdown renders properly in each comment.
+func repairFences(chunks []string) []string {
+	repaired := []string{}
+	var openFence string
+
+	for _, chunk := range chunks {
+		if openFence != "" {
+			chunk = openFence + "\n" + chunk
+		}
+
+		openFence = unclosedFence(chunk)
+		if openFence != "" {
+			chunk = chunk + "\n```"
+		}
+
+		repaired = append(repaired, chunk)
+	}
+
+	return repaired
+}
+
+// unclosedFence returns the opening line (e.g., "```diff") of the code fence that's open at the
+// end of the argument body, or an empty string if all fences are closed.
+func unclosedFence(body string) string {
+	var openFence string
+
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if !strings.HasPrefix(line, "```") {
+			continue
+		}
+
+		if openFence == "" {
+			openFence = line
+		} else if line == "```" {
+			openFence = ""
+		}
+	}
+
+	return openFence
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// formatCommentChunks splits the argument comment body via commentChunks and, if there's more
+// than one chunk, adds a header to each. The first chunk also gets an index of the sections
+// (e.g., clusters) in each chunk, based on the section markers in the body.
+func formatCommentChunks(body string, maxLen int) []string {
+	chunks := commentChunks(body, maxLen)
+	if len(chunks) == 1 {
+		return chunks
+	}
+
+	index := commentIndex(chunks)
+	formatted := []string{}
+
+	for c, chunk := range chunks {
+		header := fmt.Sprintf("## Response chunk %d/%d\n", c+1, len(chunks))
+		if c == 0 && index != "" {
+			header += index + "\n"
+		}
+
+		formatted = append(formatted, header+chunk)
 	}
-	return b
+
+	return formatted
+}
+
+func commentIndex(chunks []string) string {
+	lines := []string{}
+	var lastSection string
+
+	for c, chunk := range chunks {
+		sections := []string{}
+		matches := kubeapplySectionRegexp.FindAllStringSubmatchIndex(chunk, -1)
+
+		// The chunk continues the last section if there's content before its first marker
+		if lastSection != "" &&
+			(len(matches) == 0 || strings.TrimSpace(chunk[:matches[0][0]]) != "") {
+			sections = append(sections, fmt.Sprintf("`%s` (continued)", lastSection))
+		}
+		for _, match := range matches {
+			name := chunk[match[2]:match[3]]
+			sections = append(sections, fmt.Sprintf("`%s`", name))
+			lastSection = name
+		}
+
+		if len(sections) > 0 {
+			lines = append(
+				lines,
+				fmt.Sprintf("- Chunk %d: %s", c+1, strings.Join(sections, ", ")),
+			)
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("Contents:\n%s\n", strings.Join(lines, "\n"))
 }
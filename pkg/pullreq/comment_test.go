@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/segmentio/kubeapply/pkg/cluster/apply"
 	"github.com/segmentio/kubeapply/pkg/cluster/diff"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,6 +28,13 @@ func TestApplyComment(t *testing.T) {
 	clusterConfigs := testClusterConfigs(t, profileDir)
 	clusterConfigs[0].Subpaths = []string{"test/subpath"}
 
+	skippedClusterConfig := *clusterConfigs[2]
+	skippedClusterConfig.Cluster = "test-cluster4"
+	require.NoError(
+		t,
+		skippedClusterConfig.SetDefaults("/git/repo/clusters/test-cluster4.yaml", "/git/repo"),
+	)
+
 	pullRequestClient := &FakePullRequestClient{
 		ClusterConfigs: clusterConfigs,
 		ApprovedVal:    true,
@@ -88,6 +98,10 @@ func TestApplyComment(t *testing.T) {
 				},
 			},
 		},
+		{
+			ClusterConfig: &skippedClusterConfig,
+			Skipped:       true,
+		},
 	}
 
 	commentData := ApplyCommentData{
@@ -164,7 +178,17 @@ func TestDiffComment(t *testing.T) {
 			},
 		},
 		{
-			ClusterConfig: clusterConfigs[1],
+			ClusterConfig:       clusterConfigs[1],
+			HiddenHelmHooks:     2,
+			IncrementalSince:    "abc1234",
+			IncrementalSubpaths: []string{"namespace1", "namespace2/child"},
+			DryRunResults: []apply.Result{
+				{
+					Kind:      "kind3",
+					Name:      "name3",
+					Namespace: "namespace3",
+				},
+			},
 		},
 	}
 
@@ -189,6 +213,203 @@ func TestDiffComment(t *testing.T) {
 	}
 }
 
+func TestDiffCommentMaxDiffLines(t *testing.T) {
+	clusterConfig := &config.ClusterConfig{
+		Cluster: "test-cluster",
+		Region:  "test-region",
+		Env:     "test-env",
+	}
+	require.NoError(t, clusterConfig.SetDefaults("/git/repo/clusters/test.yaml", "/git/repo"))
+
+	commentData := DiffCommentData{
+		ClusterDiffs: []ClusterDiff{
+			{
+				ClusterConfig: clusterConfig,
+				Results: []diff.Result{
+					{
+						Name:       "test",
+						RawDiff:    "+line1\n+line2\n+line3",
+						NumAdded:   3,
+						NumRemoved: 0,
+					},
+				},
+			},
+		},
+		PullRequestClient: &FakePullRequestClient{},
+		Env:               "stage",
+
+		MaxDiffLinesPerResource: 1,
+	}
+
+	result, err := FormatDiffComment(commentData)
+	require.NoError(t, err)
+	assert.Contains(t, result, "+line1\n... (2 lines omitted, see logs)")
+	assert.NotContains(t, result, "+line2")
+	assert.Contains(t, result, "(3 lines changed)")
+}
+
+func TestDiffCommentGroupByNamespace(t *testing.T) {
+	clusterConfig := &config.ClusterConfig{
+		Cluster: "test-cluster",
+		Region:  "test-region",
+		Env:     "test-env",
+	}
+	require.NoError(t, clusterConfig.SetDefaults("/git/repo/clusters/test.yaml", "/git/repo"))
+
+	result := func(name string, namespace string) diff.Result {
+		return diff.Result{
+			Name:    name,
+			RawDiff: "+line1",
+			Object: &apply.TypedKubeObj{
+				Kind: "Deployment",
+				KubeMetadata: apply.KubeMetadata{
+					Name:      name,
+					Namespace: namespace,
+				},
+			},
+			NumAdded: 1,
+		}
+	}
+
+	commentData := DiffCommentData{
+		ClusterDiffs: []ClusterDiff{
+			{
+				ClusterConfig: clusterConfig,
+				Results: []diff.Result{
+					result("name1", "namespace2"),
+					result("name2", ""),
+					result("name3", "namespace1"),
+				},
+			},
+		},
+		PullRequestClient: &FakePullRequestClient{},
+		Env:               "stage",
+		GroupByNamespace:  true,
+	}
+
+	comment, err := FormatDiffComment(commentData)
+	require.NoError(t, err)
+
+	headerIndices := []int{
+		strings.Index(comment, "##### Cluster-scoped resources (1)\n\n<details>"),
+		strings.Index(comment, "##### Namespace: `namespace1` (1)\n\n<details>"),
+		strings.Index(comment, "##### Namespace: `namespace2` (1)\n\n<details>"),
+	}
+	for h, headerIndex := range headerIndices {
+		require.True(t, headerIndex >= 0, "header %d missing from %s", h, comment)
+		if h > 0 {
+			assert.True(t, headerIndex > headerIndices[h-1])
+		}
+	}
+	assert.True(t, strings.Index(comment, "name2") < strings.Index(comment, "name3"))
+	assert.True(t, strings.Index(comment, "name3") < strings.Index(comment, "name1"))
+}
+
+func TestClusterDiffKindCounts(t *testing.T) {
+	clusterDiff := ClusterDiff{
+		Results: []diff.Result{
+			{
+				Name:   "test1",
+				Object: &apply.TypedKubeObj{Kind: "Service"},
+			},
+			{
+				Name:   "test2",
+				Object: &apply.TypedKubeObj{Kind: "Deployment"},
+			},
+			{
+				Name: "test3",
+			},
+			{
+				Name:   "test4",
+				Object: &apply.TypedKubeObj{Kind: "Deployment"},
+			},
+		},
+	}
+
+	assert.Equal(
+		t,
+		[]KindCount{
+			{Kind: "Deployment", Count: 2},
+			{Kind: "Service", Count: 1},
+			{Kind: "unknown", Count: 1},
+		},
+		clusterDiff.KindCounts(),
+	)
+	assert.Equal(
+		t,
+		"`Deployment` (2), `Service` (1), `unknown` (1)",
+		clusterDiff.PrettyKindCounts(),
+	)
+}
+
+func TestClusterDiffDryRunCounts(t *testing.T) {
+	clusterDiff := ClusterDiff{
+		Results: []diff.Result{
+			{
+				Name: "test1",
+				Object: &apply.TypedKubeObj{
+					Kind: "Deployment",
+					KubeMetadata: apply.KubeMetadata{
+						Name:      "name1",
+						Namespace: "namespace1",
+					},
+				},
+			},
+			{
+				Name: "test2",
+				Object: &apply.TypedKubeObj{
+					Kind: "Service",
+					KubeMetadata: apply.KubeMetadata{
+						Name:      "name2",
+						Namespace: "namespace1",
+					},
+				},
+			},
+			{
+				Name: "test3",
+			},
+		},
+		DryRunResults: []apply.Result{
+			{
+				Kind:       "Deployment",
+				Name:       "name1",
+				Namespace:  "namespace1",
+				OldVersion: "1234",
+				NewVersion: "1234",
+			},
+			{
+				Kind:      "Service",
+				Name:      "name2",
+				Namespace: "namespace1",
+			},
+			{
+				Kind:       "Deployment",
+				Name:       "name3",
+				Namespace:  "namespace1",
+				OldVersion: "5678",
+				NewVersion: "5678",
+			},
+			{
+				Kind:       "Deployment",
+				Name:       "name1",
+				Namespace:  "namespace2",
+				OldVersion: "5678",
+				NewVersion: "5678",
+			},
+		},
+	}
+
+	assert.Equal(
+		t,
+		DryRunCounts{
+			Created:   1,
+			Updated:   1,
+			Unchanged: 2,
+		},
+		clusterDiff.DryRunCounts(),
+	)
+}
+
 func TestDiffCommentBehind(t *testing.T) {
 	profileDir, err := ioutil.TempDir("", "profile")
 	require.NoError(t, err)
@@ -285,6 +506,50 @@ func TestErrorCommentNotes(t *testing.T) {
 	}
 }
 
+func TestCommentTemplateOverrides(t *testing.T) {
+	overridesDir, err := ioutil.TempDir("", "templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(overridesDir)
+	defer func() {
+		require.NoError(t, LoadCommentTemplates(""))
+	}()
+
+	err = ioutil.WriteFile(
+		filepath.Join(overridesDir, "error_comment.gotpl"),
+		[]byte("Custom error in {{ .Env }}: {{ .Error }}\nSee https://example.com/runbook"),
+		0644,
+	)
+	require.NoError(t, err)
+	require.NoError(t, LoadCommentTemplates(overridesDir))
+
+	result, err := FormatErrorComment(
+		ErrorCommentData{
+			Error: fmt.Errorf("This is an error!"),
+			Env:   "stage",
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"Custom error in stage: This is an error!\nSee https://example.com/runbook",
+		result,
+	)
+
+	// Templates that aren't overridden fall back to the embedded ones
+	result, err = FormatHelpComment(HelpCommentData{Env: "stage"})
+	require.NoError(t, err)
+	assert.Contains(t, result, "kubeapply")
+
+	// Overrides that don't match an existing template are rejected
+	err = ioutil.WriteFile(
+		filepath.Join(overridesDir, "unknown_comment.gotpl"),
+		[]byte("unknown"),
+		0644,
+	)
+	require.NoError(t, err)
+	require.Error(t, LoadCommentTemplates(overridesDir))
+}
+
 func TestHelpComment(t *testing.T) {
 	profileDir, err := ioutil.TempDir("", "profile")
 	require.NoError(t, err)
@@ -313,6 +578,41 @@ func TestHelpComment(t *testing.T) {
 	}
 }
 
+func TestHelpCommentApplyAccess(t *testing.T) {
+	profileDir, err := ioutil.TempDir("", "profile")
+	require.NoError(t, err)
+	defer os.RemoveAll(profileDir)
+
+	clusterConfigs := testClusterConfigs(t, profileDir)
+	clusterConfigs[0].Subpaths = []string{"test/subpath"}
+	clusterConfigs[1].ApplyTeams = []string{"infra", "other-org/sre"}
+
+	commentData := HelpCommentData{
+		ClusterConfigs: clusterConfigs,
+		Env:            "stage",
+		Actor:          "test-user",
+		ApplyAuthorized: map[string]bool{
+			"test-env:test-region:test-cluster1": true,
+			"test-env:test-region:test-cluster2": false,
+			"test-env:test-region:test-cluster3": true,
+		},
+	}
+
+	result, err := FormatHelpComment(commentData)
+	require.NoError(t, err)
+
+	expectedOutput := "testdata/comments/help_apply_access.md"
+
+	if strings.ToLower(regenerateStr) == "true" {
+		err = ioutil.WriteFile(expectedOutput, []byte(result), 0644)
+		require.NoError(t, err)
+	} else {
+		contents, err := ioutil.ReadFile(expectedOutput)
+		require.NoError(t, err)
+		assert.Equal(t, string(contents), result)
+	}
+}
+
 func TestStatusComment(t *testing.T) {
 	profileDir, err := ioutil.TempDir("", "profile")
 	require.NoError(t, err)
@@ -333,6 +633,26 @@ func TestStatusComment(t *testing.T) {
 		{
 			ClusterConfig: clusterConfigs[1],
 			HealthSummary: "test-health-summary2",
+			StructuredSummary: &kube.StructuredSummary{
+				Namespaces: []kube.NamespaceSummary{
+					{
+						Namespace: "apps",
+						TotalPods: 2,
+						PodPhases: map[string]int{"Running": 2},
+					},
+				},
+				Deployments: []kube.DeploymentSummary{
+					{
+						Namespace:       "apps",
+						Name:            "web",
+						Replicas:        2,
+						ReadyReplicas:   2,
+						UpdatedReplicas: 2,
+						Ready:           true,
+					},
+				},
+				Nodes: kube.NodesSummary{Total: 3, Ready: 3},
+			},
 		},
 	}
 
@@ -357,6 +677,58 @@ func TestStatusComment(t *testing.T) {
 	}
 }
 
+func TestLocksComment(t *testing.T) {
+	profileDir, err := ioutil.TempDir("", "profile")
+	require.NoError(t, err)
+	defer os.RemoveAll(profileDir)
+
+	clusterConfigs := testClusterConfigs(t, profileDir)
+	acquiredAt := time.Date(2020, 4, 1, 12, 30, 0, 0, time.UTC)
+
+	locks := []ClusterLock{
+		{
+			ClusterConfig: clusterConfigs[0],
+			Lock: &store.LockInfo{
+				Name:          "test-cluster1",
+				Holder:        "kubeapply-1234",
+				AcquiredAt:    acquiredAt,
+				RenewedAt:     acquiredAt,
+				LeaseDuration: 20 * time.Second,
+			},
+			Held: true,
+		},
+		{
+			ClusterConfig: clusterConfigs[1],
+			Lock: &store.LockInfo{
+				Name:       "test-cluster2",
+				AcquiredAt: acquiredAt,
+			},
+		},
+		{
+			ClusterConfig: clusterConfigs[2],
+		},
+	}
+
+	commentData := LocksCommentData{
+		ClusterLocks: locks,
+		Env:          "stage",
+	}
+
+	result, err := FormatLocksComment(commentData)
+	require.NoError(t, err)
+
+	expectedOutput := "testdata/comments/locks.md"
+
+	if strings.ToLower(regenerateStr) == "true" {
+		err = ioutil.WriteFile(expectedOutput, []byte(result), 0644)
+		require.NoError(t, err)
+	} else {
+		contents, err := ioutil.ReadFile(expectedOutput)
+		require.NoError(t, err)
+		assert.Equal(t, string(contents), result)
+	}
+}
+
 func TestCommentChunks(t *testing.T) {
 	body := "0123456789abcdefghijABC\nDEFGHIJ\nKLMNO"
 
@@ -373,7 +745,8 @@ func TestCommentChunks(t *testing.T) {
 	assert.Equal(
 		t,
 		[]string{
-			"0123456789abcdefghijABC",
+			"0123456789abcdefghij",
+			"ABC",
 			"DEFGHIJ\nKLMNO",
 		},
 		commentChunks(body, 20),
@@ -381,9 +754,11 @@ func TestCommentChunks(t *testing.T) {
 	assert.Equal(
 		t,
 		[]string{
-			"0123456789abcdefghijABC",
-			"DEFGHIJ\nKL",
-			"MNO",
+			"0123456789",
+			"abcdefghij",
+			"ABC",
+			"DEFGHIJ",
+			"KLMNO",
 		},
 		commentChunks(body, 10),
 	)
@@ -391,11 +766,90 @@ func TestCommentChunks(t *testing.T) {
 	assert.Equal(
 		t,
 		[]string{
-			"0123456789abcdefghijABC",
+			"0123456789abcdefghij",
+			"ABC",
 			"DEFGHIJKLMNO",
 		},
 		commentChunks("0123456789abcdefghijABC<!-- KUBEAPPLY_SPLIT -->DEFGHIJKLMNO", 20),
 	)
+
+	// Pieces between split markers are packed together, and pieces that are too long are
+	// split on newlines
+	assert.Equal(
+		t,
+		[]string{
+			"abc<!-- KUBEAPPLY_SPLIT -->def",
+			"0123456789\nABCDEFGHIJ",
+			"KLMNOPQRST",
+			"ghi",
+		},
+		commentChunks(
+			"abc<!-- KUBEAPPLY_SPLIT -->def<!-- KUBEAPPLY_SPLIT -->"+
+				"0123456789\nABCDEFGHIJ\nKLMNOPQRST<!-- KUBEAPPLY_SPLIT -->ghi",
+			30,
+		),
+	)
+
+	// Fences that are open at the end of a chunk are closed and reopened
+	assert.Equal(
+		t,
+		[]string{
+			"```diff\n+line1\n+line2\n```",
+			"```diff\n+line3\n```\nafter",
+		},
+		commentChunks("```diff\n+line1\n+line2\n+line3\n```\nafter", 21),
+	)
+
+	// Lines that are too long are cut without breaking up multi-byte characters
+	assert.Equal(
+		t,
+		[]string{
+			"abcdé",
+			"ééé",
+			"日本",
+			"語",
+		},
+		commentChunks("abcdéééé日本語", 6),
+	)
+}
+
+func TestFormatCommentChunks(t *testing.T) {
+	section1 := "<!-- KUBEAPPLY_SECTION cluster1 -->\nresults1"
+	section2 := "<!-- KUBEAPPLY_SECTION cluster2 -->\nresults2a"
+	section3 := "<!-- KUBEAPPLY_SECTION cluster3 -->\nresults3"
+
+	body := strings.Join(
+		[]string{
+			"title",
+			section1,
+			section2,
+			"results2b",
+			section3,
+		},
+		"<!-- KUBEAPPLY_SPLIT -->",
+	)
+
+	assert.Equal(
+		t,
+		[]string{body},
+		formatCommentChunks(body, 5000),
+	)
+	assert.Equal(
+		t,
+		[]string{
+			"## Response chunk 1/4\n" +
+				"Contents:\n" +
+				"- Chunk 1: `cluster1`\n" +
+				"- Chunk 2: `cluster2`\n" +
+				"- Chunk 3: `cluster2` (continued)\n" +
+				"- Chunk 4: `cluster3`\n\n" +
+				"title<!-- KUBEAPPLY_SPLIT -->" + section1,
+			"## Response chunk 2/4\n" + section2,
+			"## Response chunk 3/4\nresults2b",
+			"## Response chunk 4/4\n" + section3,
+		},
+		formatCommentChunks(body, 75),
+	)
 }
 
 func testClusterConfigs(t *testing.T, profileDir string) []*config.ClusterConfig {
@@ -440,3 +894,32 @@ func testClusterConfigs(t *testing.T, profileDir string) []*config.ClusterConfig
 
 	return clusterConfigs
 }
+
+func TestFormatMergeMessage(t *testing.T) {
+	messageData := MergeMessageData{
+		PullRequestNum: 123,
+		Title:          "Test change",
+		Env:            "stage",
+		Clusters:       []string{"stage:us-west-2:cluster1", "stage:us-west-2:cluster2"},
+	}
+
+	message, err := FormatMergeMessage(DefaultMergeMessageTemplate, messageData)
+	require.NoError(t, err)
+	assert.Equal(t, "Merged by kubeapply (pull request 123)", message)
+
+	message, err = FormatMergeMessage(
+		"{{ .Title }} (#{{ .PullRequestNum }})\n\nApplied in {{ .Env }}:{{ range .Clusters }}\n- {{ . }}{{ end }}",
+		messageData,
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"Test change (#123)\n\nApplied in stage:\n- stage:us-west-2:cluster1\n- stage:us-west-2:cluster2",
+		message,
+	)
+
+	_, err = FormatMergeMessage("{{ .Title", messageData)
+	assert.Error(t, err)
+	_, err = FormatMergeMessage("{{ .NonExistent }}", messageData)
+	assert.Error(t, err)
+}
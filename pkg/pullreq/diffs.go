@@ -1,6 +1,7 @@
 package pullreq
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -23,13 +24,16 @@ import (
 // 5. Drop any clusters that are not associated with any diffs
 // 6. Find the lowest parent among each set of cluster diffs and use this to set the subpath
 //    in the associated cluster config or, if multiSubpaths is set to true, all changed
-//    subpaths.
+//    subpaths. If the cluster config has GithubApplyChangedOnly set, then the changed files
+//    themselves are used as the subpaths instead, and clusters without any changed files
+//    are dropped.
 //
 // There are a few overrides that adjust this behavior:
 // 1. selectedClusterGlobStrs: If set, then clusters in this slice are never dropped, even if they
 //    don't have have any diffs in them.
 // 2. subpathOverride: If set, then this is used for the cluster subpaths instead of the procedure
-//    in step 6 above.
+//    in step 6 above. The override can be a glob (e.g., "team-a/*"), in which case it's
+//    expanded against each cluster's expanded configs into zero or more subpaths.
 func GetCoveredClusters(
 	repoRoot string,
 	diffs []*github.CommitFile,
@@ -38,14 +42,9 @@ func GetCoveredClusters(
 	subpathOverride string,
 	multiSubpaths bool,
 ) ([]*config.ClusterConfig, error) {
-	selectedClusterGlobs := []glob.Glob{}
-
-	for _, globStr := range selectedClusterGlobStrs {
-		globObj, err := glob.Compile(globStr)
-		if err != nil {
-			return nil, err
-		}
-		selectedClusterGlobs = append(selectedClusterGlobs, globObj)
+	selectedClusterGlobs, err := CompileClusterGlobs(selectedClusterGlobStrs)
+	if err != nil {
+		return nil, err
 	}
 
 	changedClusterPaths := map[string][]string{}
@@ -55,7 +54,7 @@ func GetCoveredClusters(
 	configFilesMap := map[string][]string{}
 
 	// Walk repo looking for cluster configs
-	err := filepath.Walk(
+	err = filepath.Walk(
 		repoRoot,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
@@ -86,23 +85,13 @@ func GetCoveredClusters(
 
 					log.Infof("Found cluster config: %s", path)
 
-					if len(selectedClusterGlobs) > 0 {
-						var matches bool
-
-						for _, globObj := range selectedClusterGlobs {
-							if globObj.Match(configObj.DescriptiveName()) {
-								matches = true
-								break
-							}
-						}
-
-						if !matches {
-							log.Infof(
-								"Ignoring cluster %s because selectedClusters is set and cluster is not in set",
-								configObj.DescriptiveName(),
-							)
-							return nil
-						}
+					if len(selectedClusterGlobs) > 0 &&
+						!MatchesClusterGlobs(configObj, selectedClusterGlobs) {
+						log.Infof(
+							"Ignoring cluster %s because selectedClusters is set and cluster is not in set",
+							configObj.DescriptiveName(),
+						)
+						return nil
 					}
 
 					if configObj.GithubIgnore {
@@ -203,14 +192,22 @@ func GetCoveredClusters(
 		config := configsMap[clusterPath]
 
 		if subpathOverride != "" {
-			config.Subpaths = []string{subpathOverride}
+			config.Subpaths, err = expandSubpathOverride(config.ExpandedPath, subpathOverride)
+			if err != nil {
+				return nil, err
+			}
 		} else {
 			relExpandedPath, err := filepath.Rel(repoRoot, config.ExpandedPath)
 			if err != nil {
 				return nil, err
 			}
 
-			if multiSubpaths {
+			if config.GithubApplyChangedOnly && len(changedFiles) > 0 {
+				config.Subpaths, err = changedFileSubpaths(relExpandedPath, changedFiles)
+				if err != nil {
+					return nil, err
+				}
+			} else if multiSubpaths {
 				config.Subpaths, err = lowestParents(relExpandedPath, changedFiles)
 				if err != nil {
 					return nil, err
@@ -241,6 +238,112 @@ func GetCoveredClusters(
 	return changedClusters, nil
 }
 
+// CompileClusterGlobs compiles the argument cluster selectors (e.g., "stage:*1") into globs
+// that can be matched against cluster descriptive names.
+func CompileClusterGlobs(globStrs []string) ([]glob.Glob, error) {
+	globs := []glob.Glob{}
+
+	for _, globStr := range globStrs {
+		globObj, err := glob.Compile(globStr)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, globObj)
+	}
+
+	return globs, nil
+}
+
+// MatchesClusterGlobs returns whether the descriptive name of the argument cluster matches
+// at least one of the argument globs.
+func MatchesClusterGlobs(clusterConfig *config.ClusterConfig, globs []glob.Glob) bool {
+	for _, globObj := range globs {
+		if globObj.Match(clusterConfig.DescriptiveName()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IncrementalSubpaths returns the subpaths of the argument cluster's expanded configs that
+// contain one or more of the argument changed files, which are specified as local paths.
+// Changed files outside of the cluster's expanded configs are ignored. If none of the
+// changed files are in the expanded configs, then an empty slice is returned.
+func IncrementalSubpaths(
+	clusterConfig *config.ClusterConfig,
+	changedFiles []string,
+) ([]string, error) {
+	expandedFiles := []string{}
+
+	for _, changedFile := range changedFiles {
+		relPath, err := filepath.Rel(clusterConfig.ExpandedPath, changedFile)
+		if err != nil {
+			return nil, err
+		}
+		if relPath == ".." || strings.HasPrefix(relPath, "../") {
+			continue
+		}
+		expandedFiles = append(expandedFiles, changedFile)
+	}
+
+	if len(expandedFiles) == 0 {
+		return []string{}, nil
+	}
+
+	return lowestParents(clusterConfig.ExpandedPath, expandedFiles)
+}
+
+// changedFileSubpaths returns the paths of the argument changed files relative to the argument
+// root, in sorted order.
+func changedFileSubpaths(root string, changedFiles []string) ([]string, error) {
+	subpaths := []string{}
+
+	for _, changedFile := range changedFiles {
+		subpath, err := filepath.Rel(root, changedFile)
+		if err != nil {
+			return nil, err
+		}
+		subpaths = append(subpaths, subpath)
+	}
+	sort.Strings(subpaths)
+
+	return subpaths, nil
+}
+
+// expandSubpathOverride expands the argument subpath override, which may be a glob, into
+// the matching subpaths of the argument expanded configs path. Overrides that aren't globs
+// are returned as-is.
+func expandSubpathOverride(expandedPath string, subpathOverride string) ([]string, error) {
+	if !strings.ContainsAny(subpathOverride, "*?[") {
+		return []string{subpathOverride}, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(expandedPath, subpathOverride))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid subpath glob %s: %+v", subpathOverride, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf(
+			"Subpath glob %s does not match anything in %s",
+			subpathOverride,
+			expandedPath,
+		)
+	}
+
+	subpaths := []string{}
+	for _, match := range matches {
+		subpath, err := filepath.Rel(expandedPath, match)
+		if err != nil {
+			return nil, err
+		}
+		subpaths = append(subpaths, subpath)
+	}
+	sort.Strings(subpaths)
+
+	return subpaths, nil
+}
+
 func getExpandedConfigFiles(
 	repoRoot string,
 	configObj *config.ClusterConfig,
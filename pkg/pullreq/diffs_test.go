@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/google/go-github/v30/github"
+	"github.com/segmentio/kubeapply/pkg/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -202,6 +203,88 @@ func TestGetCoveredClusters(t *testing.T) {
 				"subdir1",
 			},
 		},
+		{
+			diffs: []*github.CommitFile{
+				{
+					Filename: aws.String("clusters/clustertype/expanded/cluster1/file1.yaml"),
+				},
+			},
+			subpathOverride: "subdir*",
+			expectedClustersIDs: []string{
+				"stage:us-west-2:cluster1",
+			},
+			expectedSubpaths: []string{
+				"subdir1",
+				"subdir2",
+			},
+		},
+		{
+			diffs: []*github.CommitFile{
+				{
+					Filename: aws.String("clusters/clustertype/expanded/cluster1/file1.yaml"),
+				},
+			},
+			subpathOverride: "subdir1/*/file6.yaml",
+			expectedClustersIDs: []string{
+				"stage:us-west-2:cluster1",
+			},
+			expectedSubpaths: []string{
+				"subdir1/subdir3/file6.yaml",
+			},
+		},
+		{
+			diffs: []*github.CommitFile{
+				{
+					Filename: aws.String("clusters/clustertype/expanded/cluster4/subdir1/file3.yaml"),
+				},
+				{
+					Filename: aws.String("clusters/clustertype/expanded/cluster4/subdir1/file1.yaml"),
+				},
+			},
+			expectedClustersIDs: []string{
+				"stage:us-west-2:cluster4",
+			},
+			expectedSubpaths: []string{
+				"subdir1/file1.yaml",
+				"subdir1/file3.yaml",
+			},
+		},
+		{
+			diffs: []*github.CommitFile{
+				{
+					Filename: aws.String("clusters/clustertype/expanded/cluster1/file1.yaml"),
+				},
+			},
+			selectedClusterGlobStrs: []string{
+				"stage:us-west-2:cluster1",
+				"stage:us-west-2:cluster4",
+			},
+			expectedClustersIDs: []string{
+				"stage:us-west-2:cluster1",
+				"stage:us-west-2:cluster4",
+			},
+			expectedSubpaths: []string{
+				".",
+				".",
+			},
+		},
+		{
+			diffs: []*github.CommitFile{
+				{
+					Filename: aws.String("clusters/clustertype/expanded/cluster1/file1.yaml"),
+				},
+			},
+			selectedClusterGlobStrs: []string{
+				"stage:us-west-2:cluster1",
+				"stage:us-west-2:cluster4*",
+			},
+			expectedClustersIDs: []string{
+				"stage:us-west-2:cluster1",
+			},
+			expectedSubpaths: []string{
+				".",
+			},
+		},
 	}
 
 	for index, testCase := range testCases {
@@ -228,6 +311,24 @@ func TestGetCoveredClusters(t *testing.T) {
 	}
 }
 
+func TestExpandSubpathOverride(t *testing.T) {
+	expandedPath := "testdata/repo/clusters/clustertype/expanded/cluster1"
+
+	subpaths, err := expandSubpathOverride(expandedPath, "subdir1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"subdir1"}, subpaths)
+
+	subpaths, err = expandSubpathOverride(expandedPath, "subdir?")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"subdir1", "subdir2"}, subpaths)
+
+	_, err = expandSubpathOverride(expandedPath, "non-existent-*")
+	assert.Error(t, err)
+
+	_, err = expandSubpathOverride(expandedPath, "subdir[")
+	assert.Error(t, err)
+}
+
 func TestLowestParent(t *testing.T) {
 	type parentTestCase struct {
 		root      string
@@ -379,3 +480,48 @@ func TestLowestParents(t *testing.T) {
 		}
 	}
 }
+
+func TestIncrementalSubpaths(t *testing.T) {
+	type subpathsTestCase struct {
+		changedFiles []string
+		expResult    []string
+	}
+
+	clusterConfig := &config.ClusterConfig{
+		ExpandedPath: "/root/cluster/expanded",
+	}
+
+	testCases := []subpathsTestCase{
+		{
+			changedFiles: []string{},
+			expResult:    []string{},
+		},
+		{
+			changedFiles: []string{
+				"/root/cluster/cluster.yaml",
+				"/root/other/expanded/namespace/file1.yaml",
+			},
+			expResult: []string{},
+		},
+		{
+			changedFiles: []string{
+				"/root/cluster/cluster.yaml",
+				"/root/cluster/expanded/namespace1/file1.yaml",
+				"/root/cluster/expanded/namespace2/child/file2.yaml",
+			},
+			expResult: []string{"namespace1", "namespace2/child"},
+		},
+	}
+
+	for index, testCase := range testCases {
+		result, err := IncrementalSubpaths(clusterConfig, testCase.changedFiles)
+		require.Nil(t, err, "test case %d", index)
+		assert.Equal(
+			t,
+			testCase.expResult,
+			result,
+			"test case %d",
+			index,
+		)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/gobwas/glob"
 	"github.com/segmentio/kubeapply/pkg/config"
 )
 
@@ -21,6 +20,14 @@ type FakePullRequestClient struct {
 	Draft           bool
 	Mergeable       bool
 	Merged          bool
+	MergeMessage    string
+	TitleVal        string
+	BodyVal         string
+	LabelsVal       []string
+	ChangedFiles    []string
+
+	// TeamMembers are the logins of the members of each team, keyed by team.
+	TeamMembers map[string][]string
 }
 
 // Init initializes this client.
@@ -35,31 +42,16 @@ func (prc *FakePullRequestClient) GetCoveredClusters(
 	selectedClusterGlobStrs []string,
 	subpathOverride string,
 ) ([]*config.ClusterConfig, error) {
-	globObjs := []glob.Glob{}
-
-	for _, globStr := range selectedClusterGlobStrs {
-		globObj, err := glob.Compile(globStr)
-		if err != nil {
-			return nil, err
-		}
-		globObjs = append(globObjs, globObj)
+	globObjs, err := CompileClusterGlobs(selectedClusterGlobStrs)
+	if err != nil {
+		return nil, err
 	}
 
 	coveredClusters := []*config.ClusterConfig{}
 
 	for _, clusterConfig := range prc.ClusterConfigs {
-		if len(globObjs) > 0 {
-			var matches bool
-			for _, globObj := range globObjs {
-				if globObj.Match(clusterConfig.DescriptiveName()) {
-					matches = true
-					break
-				}
-			}
-
-			if !matches {
-				continue
-			}
+		if len(globObjs) > 0 && !MatchesClusterGlobs(clusterConfig, globObjs) {
+			continue
 		}
 
 		if env != "" && clusterConfig.Env != env {
@@ -133,8 +125,10 @@ func (prc *FakePullRequestClient) Statuses(
 // Merge does a fake merge of this pull request.
 func (prc *FakePullRequestClient) Merge(
 	ctx context.Context,
+	message string,
 ) error {
 	prc.Merged = true
+	prc.MergeMessage = message
 	return nil
 }
 
@@ -175,6 +169,43 @@ func (prc *FakePullRequestClient) HeadSHA() string {
 	return "test-sha"
 }
 
+// Title returns the title of this pull request.
+func (prc *FakePullRequestClient) Title() string {
+	return prc.TitleVal
+}
+
+// Body returns the description of this pull request.
+func (prc *FakePullRequestClient) Body() string {
+	return prc.BodyVal
+}
+
+// Labels returns the names of the labels on this pull request.
+func (prc *FakePullRequestClient) Labels() []string {
+	return prc.LabelsVal
+}
+
+// ChangedFilesSince returns the fake files that have changed since the argument SHA.
+func (prc *FakePullRequestClient) ChangedFilesSince(
+	ctx context.Context,
+	sha string,
+) ([]string, error) {
+	return prc.ChangedFiles, nil
+}
+
+// IsTeamMember returns whether the argument user is in the fake members of the argument team.
+func (prc *FakePullRequestClient) IsTeamMember(
+	ctx context.Context,
+	team string,
+	user string,
+) (bool, error) {
+	for _, member := range prc.TeamMembers[team] {
+		if member == user {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // Close closes the client.
 func (prc *FakePullRequestClient) Close() error {
 	return nil
@@ -2,15 +2,18 @@ package pullreq
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/google/go-github/v30/github"
 	"github.com/segmentio/kubeapply/pkg/config"
+	"github.com/segmentio/kubeapply/pkg/util"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 	"gopkg.in/src-d/go-git.v4"
@@ -22,6 +25,13 @@ const (
 	// The API allows a slightly higher value, but build in some buffer for formatting,
 	// newline breaks after end, etc.
 	githubMaxCommentLen = 58000
+
+	// Github truncates the files in commit comparisons to this many entries
+	githubMaxComparisonFiles = 300
+
+	// DefaultCloneDepth is the number of commits fetched when cloning the repo if a depth
+	// isn't set explicitly.
+	DefaultCloneDepth = 1
 )
 
 var _ PullRequestClient = (*GHPullRequestClient)(nil)
@@ -49,6 +59,40 @@ type GHPullRequestClient struct {
 	status     *github.CombinedStatus
 
 	clonePath string
+	settings  GHPullRequestClientSettings
+}
+
+// GHPullRequestClientSettings stores the optional settings for a GHPullRequestClient.
+type GHPullRequestClientSettings struct {
+	// CloneDepth is the number of commits of the pull request branch that are fetched when
+	// cloning the repo.
+	//
+	// Optional, defaults to DefaultCloneDepth.
+	CloneDepth int
+
+	// FullClone indicates whether the full history of all branches should be fetched when
+	// cloning the repo. This is slow for big repos, but is needed by tools that look at the
+	// history (e.g., to find the merge base with the base branch). If set, CloneDepth is
+	// ignored.
+	//
+	// Optional, defaults to false.
+	FullClone bool
+
+	// SparseCheckout indicates whether only the directories with changed files should be
+	// checked out when cloning the repo. The files directly in the parents of these
+	// directories, which typically include the cluster configs, are also checked out. This
+	// speeds up clones of huge repos, but commands that target unchanged clusters or subpaths
+	// won't see their configs. If the sparse clone fails, then a regular clone is done
+	// instead.
+	//
+	// Optional, defaults to false.
+	SparseCheckout bool
+
+	// CloneCache is used to reuse the clones of pull requests across commands when their
+	// head SHAs haven't changed.
+	//
+	// Optional, defaults to nil, in which case the repo is cloned for every command.
+	CloneCache *CloneCache
 }
 
 // NewGHPullRequestClient returns a new GHPullRequestClient.
@@ -57,12 +101,14 @@ func NewGHPullRequestClient(
 	owner string,
 	repo string,
 	pullRequestNum int,
+	settings GHPullRequestClientSettings,
 ) *GHPullRequestClient {
 	return &GHPullRequestClient{
 		token:          token,
 		owner:          owner,
 		repo:           repo,
 		pullRequestNum: pullRequestNum,
+		settings:       settings,
 	}
 }
 
@@ -192,40 +238,185 @@ func (prc *GHPullRequestClient) Init(ctx context.Context) error {
 		return err
 	}
 
-	prc.clonePath, err = ioutil.TempDir("", "kubeapply")
+	prc.clonePath, err = util.TempDir("kubeapply")
 	if err != nil {
 		return err
 	}
 
+	headSHA := prc.pullRequest.GetHead().GetSHA()
+
+	if prc.settings.CloneCache != nil {
+		ok, err := prc.settings.CloneCache.Restore(
+			prc.owner,
+			prc.repo,
+			prc.pullRequestNum,
+			headSHA,
+			prc.clonePath,
+		)
+		if err != nil {
+			log.Warnf("Error restoring cached clone, cloning instead: %+v", err)
+			if err := os.RemoveAll(prc.clonePath); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(prc.clonePath, 0755); err != nil {
+				return err
+			}
+		} else if ok {
+			return nil
+		}
+	}
+
+	if err := prc.clone(ctx); err != nil {
+		return err
+	}
+
+	if prc.settings.CloneCache != nil {
+		err = prc.settings.CloneCache.Store(
+			prc.owner,
+			prc.repo,
+			prc.pullRequestNum,
+			headSHA,
+			prc.clonePath,
+		)
+		if err != nil {
+			// The clone is still usable, so just log the error
+			log.Warnf("Error caching clone: %+v", err)
+		}
+	}
+
+	return nil
+}
+
+// clone clones the repo at the pull request branch into the client's clone path.
+func (prc *GHPullRequestClient) clone(ctx context.Context) error {
+	if prc.settings.SparseCheckout {
+		err := prc.sparseClone(ctx)
+		if err == nil {
+			return nil
+		}
+
+		log.Warnf("Error doing sparse clone, falling back to regular clone: %+v", err)
+		if err := os.RemoveAll(prc.clonePath); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(prc.clonePath, 0755); err != nil {
+			return err
+		}
+	}
+
+	cloneOptions := prc.cloneOptions()
+	if prc.settings.FullClone {
+		log.Infof(
+			"Doing full clone of repo at branch %s in %s",
+			prc.branch,
+			prc.clonePath,
+		)
+	} else {
+		log.Infof(
+			"Doing shallow clone of repo at branch %s in %s with depth %d",
+			prc.branch,
+			prc.clonePath,
+			cloneOptions.Depth,
+		)
+	}
+
+	_, err := git.PlainClone(prc.clonePath, false, cloneOptions)
+	return err
+}
+
+// sparseClone clones the repo at the pull request branch with only the directories that
+// contain changed files checked out.
+func (prc *GHPullRequestClient) sparseClone(ctx context.Context) error {
+	dirs := sparseCheckoutDirs(prc.files)
 	log.Infof(
-		"Doing shallow clone of repo at branch %s in %s",
+		"Doing sparse clone of repo at branch %s in %s with dirs %+v",
 		prc.branch,
 		prc.clonePath,
+		dirs,
 	)
-	_, err = git.PlainClone(
+
+	depth := 0
+	if !prc.settings.FullClone {
+		depth = prc.settings.CloneDepth
+		if depth <= 0 {
+			depth = DefaultCloneDepth
+		}
+	}
+
+	// Pass the token via the environment so that it isn't in the git command-line arguments
+	credentials := base64.StdEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("abc123:%s", prc.token)),
+	)
+
+	return util.SparseCloneRepo(
+		ctx,
+		fmt.Sprintf("https://github.com/%s/%s.git", prc.owner, prc.repo),
 		prc.clonePath,
-		false,
-		&git.CloneOptions{
-			URL: fmt.Sprintf(
-				"https://github.com/%s/%s.git",
-				prc.owner,
-				prc.repo,
-			),
-			Progress:      os.Stdout,
-			ReferenceName: plumbing.NewBranchReferenceName(prc.branch),
-			Auth: &http.BasicAuth{
-				Username: "abc123", // This can be anything except an empty string
-				Password: prc.token,
+		util.SparseCloneOptions{
+			Branch: prc.branch,
+			Depth:  depth,
+			Dirs:   dirs,
+			Env: []string{
+				"GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=http.extraHeader",
+				fmt.Sprintf("GIT_CONFIG_VALUE_0=Authorization: Basic %s", credentials),
 			},
-			SingleBranch: true,
-			Depth:        1,
 		},
 	)
-	if err != nil {
-		return err
+}
+
+// sparseCheckoutDirs returns the directories that contain the argument changed files. For
+// renamed files, the directories of both the old and new paths are included.
+func sparseCheckoutDirs(files []*github.CommitFile) []string {
+	dirsMap := map[string]struct{}{}
+
+	for _, file := range files {
+		for _, path := range []string{file.GetFilename(), file.GetPreviousFilename()} {
+			if path == "" {
+				continue
+			}
+			dir := filepath.Dir(path)
+			if dir != "." {
+				dirsMap[dir] = struct{}{}
+			}
+		}
 	}
 
-	return nil
+	dirs := []string{}
+	for dir := range dirsMap {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// cloneOptions returns the options for cloning the repo at the pull request branch based on
+// the client's settings.
+func (prc *GHPullRequestClient) cloneOptions() *git.CloneOptions {
+	options := &git.CloneOptions{
+		URL: fmt.Sprintf(
+			"https://github.com/%s/%s.git",
+			prc.owner,
+			prc.repo,
+		),
+		Progress:      os.Stdout,
+		ReferenceName: plumbing.NewBranchReferenceName(prc.branch),
+		Auth: &http.BasicAuth{
+			Username: "abc123", // This can be anything except an empty string
+			Password: prc.token,
+		},
+	}
+
+	if !prc.settings.FullClone {
+		options.SingleBranch = true
+		options.Depth = prc.settings.CloneDepth
+		if options.Depth <= 0 {
+			options.Depth = DefaultCloneDepth
+		}
+	}
+
+	return options
 }
 
 // GetCoveredClusters returns the configs of the clusters potentially affected by
@@ -247,7 +438,7 @@ func (prc *GHPullRequestClient) GetCoveredClusters(
 
 // PostComment posts a comment to this pull request using the Github API.
 func (prc *GHPullRequestClient) PostComment(ctx context.Context, body string) error {
-	bodyChunks := commentChunks(body, githubMaxCommentLen)
+	bodyChunks := formatCommentChunks(body, githubMaxCommentLen)
 	var err error
 
 	for b, bodyChunk := range bodyChunks {
@@ -266,15 +457,6 @@ func (prc *GHPullRequestClient) PostComment(ctx context.Context, body string) er
 			chunkSnippet,
 		)
 
-		if len(bodyChunks) > 1 {
-			bodyChunk = fmt.Sprintf(
-				"## Response chunk %d/%d\n%s",
-				b+1,
-				len(bodyChunks),
-				bodyChunk,
-			)
-		}
-
 		_, _, err = prc.Client.Issues.CreateComment(
 			ctx,
 			prc.owner,
@@ -362,13 +544,14 @@ func (prc *GHPullRequestClient) UpdateStatus(
 // Merge merges this pull request via the Github API.
 func (prc *GHPullRequestClient) Merge(
 	ctx context.Context,
+	message string,
 ) error {
 	_, _, err := prc.Client.PullRequests.Merge(
 		ctx,
 		prc.owner,
 		prc.repo,
 		prc.pullRequestNum,
-		fmt.Sprintf("Merged by kubeapply (pull request %d)", prc.pullRequestNum),
+		message,
 		&github.PullRequestOptions{
 			MergeMethod: "squash",
 		},
@@ -448,6 +631,98 @@ func (prc *GHPullRequestClient) HeadSHA() string {
 	return "unknown"
 }
 
+// Title returns the title of this pull request.
+func (prc *GHPullRequestClient) Title() string {
+	return prc.pullRequest.GetTitle()
+}
+
+// Body returns the description of this pull request.
+func (prc *GHPullRequestClient) Body() string {
+	return prc.pullRequest.GetBody()
+}
+
+// Labels returns the names of the labels on this pull request.
+func (prc *GHPullRequestClient) Labels() []string {
+	labels := []string{}
+	for _, label := range prc.issue.Labels {
+		labels = append(labels, label.GetName())
+	}
+	return labels
+}
+
+// ChangedFilesSince returns the local paths of the files that have changed between the
+// argument SHA and the head of this pull request. For renamed files, both the old and new
+// paths are returned. An error is returned if Github truncated the list of changed files.
+func (prc *GHPullRequestClient) ChangedFilesSince(
+	ctx context.Context,
+	sha string,
+) ([]string, error) {
+	comparison, _, err := prc.Client.Repositories.CompareCommits(
+		ctx,
+		prc.owner,
+		prc.repo,
+		sha,
+		prc.HeadSHA(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return comparisonFiles(prc.clonePath, comparison.Files)
+}
+
+// IsTeamMember returns whether the argument user is an active member of the argument Github
+// team. Pending invitations don't count as memberships.
+func (prc *GHPullRequestClient) IsTeamMember(
+	ctx context.Context,
+	team string,
+	user string,
+) (bool, error) {
+	org, slug := parseTeam(prc.owner, team)
+
+	membership, resp, err := prc.Client.Teams.GetTeamMembershipBySlug(ctx, org, slug, user)
+	if resp != nil && resp.StatusCode == 404 {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return membership.GetState() == "active", nil
+}
+
+// parseTeam splits the argument team into an organization and a slug. Teams without an
+// organization are assumed to be in the argument default one.
+func parseTeam(defaultOrg string, team string) (string, string) {
+	if components := strings.SplitN(team, "/", 2); len(components) == 2 {
+		return components[0], components[1]
+	}
+	return defaultOrg, team
+}
+
+// comparisonFiles converts the files in a Github commit comparison to local paths in the
+// argument root. For renamed files, both the old and new paths are returned.
+func comparisonFiles(root string, files []*github.CommitFile) ([]string, error) {
+	if len(files) >= githubMaxComparisonFiles {
+		return nil, fmt.Errorf(
+			"Comparison was truncated by Github (%d or more files)",
+			githubMaxComparisonFiles,
+		)
+	}
+
+	changedFiles := []string{}
+	for _, file := range files {
+		changedFiles = append(changedFiles, filepath.Join(root, file.GetFilename()))
+		if file.GetPreviousFilename() != "" {
+			changedFiles = append(
+				changedFiles,
+				filepath.Join(root, file.GetPreviousFilename()),
+			)
+		}
+	}
+
+	return changedFiles, nil
+}
+
 // Close closes this client.
 func (prc *GHPullRequestClient) Close() error {
 	if prc.clonePath != "" {
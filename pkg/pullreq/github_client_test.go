@@ -0,0 +1,152 @@
+package pullreq
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/go-github/v30/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparisonFiles(t *testing.T) {
+	changedFiles, err := comparisonFiles(
+		"/clone",
+		[]*github.CommitFile{
+			{
+				Filename: aws.String("clusters/expanded/namespace1/file1.yaml"),
+			},
+			{
+				Filename:         aws.String("clusters/expanded/namespace3/file2.yaml"),
+				PreviousFilename: aws.String("clusters/expanded/namespace2/file2.yaml"),
+			},
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"/clone/clusters/expanded/namespace1/file1.yaml",
+			"/clone/clusters/expanded/namespace3/file2.yaml",
+			"/clone/clusters/expanded/namespace2/file2.yaml",
+		},
+		changedFiles,
+	)
+
+	truncatedFiles := []*github.CommitFile{}
+	for i := 0; i < githubMaxComparisonFiles; i++ {
+		truncatedFiles = append(
+			truncatedFiles,
+			&github.CommitFile{
+				Filename: aws.String(fmt.Sprintf("file%d.yaml", i)),
+			},
+		)
+	}
+	_, err = comparisonFiles("/clone", truncatedFiles)
+	require.Error(t, err)
+}
+
+func TestCloneOptions(t *testing.T) {
+	type testCase struct {
+		description     string
+		settings        GHPullRequestClientSettings
+		expDepth        int
+		expSingleBranch bool
+	}
+
+	testCases := []testCase{
+		{
+			description:     "default",
+			expDepth:        1,
+			expSingleBranch: true,
+		},
+		{
+			description: "explicit depth",
+			settings: GHPullRequestClientSettings{
+				CloneDepth: 50,
+			},
+			expDepth:        50,
+			expSingleBranch: true,
+		},
+		{
+			description: "full clone",
+			settings: GHPullRequestClientSettings{
+				CloneDepth: 50,
+				FullClone:  true,
+			},
+			expDepth:        0,
+			expSingleBranch: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		prc := NewGHPullRequestClient(
+			"test-token",
+			"segmentio",
+			"test-repo",
+			123,
+			testCase.settings,
+		)
+		prc.branch = "test-branch"
+
+		options := prc.cloneOptions()
+		assert.Equal(
+			t,
+			"https://github.com/segmentio/test-repo.git",
+			options.URL,
+			testCase.description,
+		)
+		assert.Equal(
+			t,
+			"refs/heads/test-branch",
+			options.ReferenceName.String(),
+			testCase.description,
+		)
+		assert.Equal(t, testCase.expDepth, options.Depth, testCase.description)
+		assert.Equal(
+			t,
+			testCase.expSingleBranch,
+			options.SingleBranch,
+			testCase.description,
+		)
+	}
+}
+
+func TestSparseCheckoutDirs(t *testing.T) {
+	assert.Equal(
+		t,
+		[]string{
+			"clusters/clustertype/expanded/namespace1",
+			"clusters/clustertype/expanded/namespace2",
+			"clusters/clustertype/expanded/namespace3",
+		},
+		sparseCheckoutDirs(
+			[]*github.CommitFile{
+				{
+					Filename: aws.String("README.md"),
+				},
+				{
+					Filename: aws.String("clusters/clustertype/expanded/namespace1/file1.yaml"),
+				},
+				{
+					Filename: aws.String("clusters/clustertype/expanded/namespace1/file2.yaml"),
+				},
+				{
+					Filename:         aws.String("clusters/clustertype/expanded/namespace3/file3.yaml"),
+					PreviousFilename: aws.String("clusters/clustertype/expanded/namespace2/file3.yaml"),
+				},
+			},
+		),
+	)
+}
+
+func TestParseTeam(t *testing.T) {
+	org, slug := parseTeam("segmentio", "infra-admins")
+	assert.Equal(t, "segmentio", org)
+	assert.Equal(t, "infra-admins", slug)
+
+	org, slug = parseTeam("segmentio", "other-org/admins")
+	assert.Equal(t, "other-org", org)
+	assert.Equal(t, "admins", slug)
+}
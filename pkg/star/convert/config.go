@@ -46,8 +46,10 @@ func (a Arg) DefaultValueStr() string {
 		return fmt.Sprintf(`"%s"`, v)
 	case int, int8, int16, int32, int64:
 		return fmt.Sprintf("%d", v)
-	case float32, float64:
-		return fmt.Sprintf("%f", v)
+	case float32:
+		return floatToStar(float64(v))
+	case float64:
+		return floatToStar(v)
 	case bool:
 		if v {
 			return "True"
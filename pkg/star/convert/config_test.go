@@ -0,0 +1,57 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgDefaultValueStr(t *testing.T) {
+	type testCase struct {
+		defaultValue interface{}
+		expStr       string
+	}
+
+	testCases := []testCase{
+		{
+			defaultValue: "value",
+			expStr:       `"value"`,
+		},
+		{
+			defaultValue: 3,
+			expStr:       "3",
+		},
+		{
+			defaultValue: 0.5,
+			expStr:       "0.5",
+		},
+		{
+			defaultValue: float32(0.25),
+			expStr:       "0.25",
+		},
+		{
+			defaultValue: 2.0,
+			expStr:       "2.0",
+		},
+		{
+			defaultValue: 1e21,
+			expStr:       "1e+21",
+		},
+		{
+			defaultValue: true,
+			expStr:       "True",
+		},
+		{
+			defaultValue: nil,
+			expStr:       "None",
+		},
+	}
+
+	for _, testCase := range testCases {
+		arg := Arg{
+			Name:         "test",
+			DefaultValue: testCase.defaultValue,
+		}
+		assert.Equal(t, testCase.expStr, arg.DefaultValueStr())
+	}
+}
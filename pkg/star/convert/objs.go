@@ -163,6 +163,8 @@ func walkObj(
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		fmt.Fprintf(out, "%d", val.Int())
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprint(out, floatToStar(val.Float()))
 	case reflect.Slice:
 		fmt.Fprintf(out, "[\n")
 
@@ -297,6 +299,16 @@ func walkObj(
 	return nil
 }
 
+// floatToStar returns the starlark representation of a float. Whole numbers get a
+// trailing ".0" so that they're not interpreted as ints.
+func floatToStar(value float64) string {
+	str := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".eEn") {
+		str += ".0"
+	}
+	return str
+}
+
 func indentToLevel(level int) string {
 	components := []string{}
 
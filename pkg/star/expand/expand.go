@@ -3,6 +3,7 @@ package expand
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/gogo/protobuf/proto"
 	"github.com/segmentio/kubeapply/pkg/star/expand/skymod"
+	"github.com/segmentio/kubeapply/pkg/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/stripe/skycfg"
 	"github.com/stripe/skycfg/gogocompat"
@@ -29,10 +31,11 @@ const k8sAPIPrefix = "k8s.io.api."
 var k8sProtoMagic = []byte("k8s\x00")
 
 // ExpandStar expands all starlark in the root directory, replacing each
-// file with its YAML expansion.
+// file with its YAML expansion. The module roots are searched for module:// loads.
 func ExpandStar(
 	expandRoot string,
 	clusterRoot string,
+	moduleRoots []string,
 	params map[string]interface{},
 ) error {
 	starPaths := []string{}
@@ -64,9 +67,9 @@ func ExpandStar(
 			}
 
 			log.Infof("Processing file %s", path)
-			expandedStr, err := StarToYaml(path, clusterRoot, params)
+			expandedStr, err := StarToYaml(path, clusterRoot, moduleRoots, params)
 			if err != nil {
-				return err
+				return fmt.Errorf("Error expanding path %s: %+v", path, withBacktrace(err))
 			}
 
 			// Replace suffix
@@ -84,8 +87,13 @@ func ExpandStar(
 }
 
 // StarToYaml converts a starlark file into a YAML Kubernetes file.
-func StarToYaml(path string, root string, params map[string]interface{}) (string, error) {
-	objs, err := StarToObjs(path, root, params)
+func StarToYaml(
+	path string,
+	root string,
+	moduleRoots []string,
+	params map[string]interface{},
+) (string, error) {
+	objs, err := StarToObjs(path, root, moduleRoots, params)
 	if err != nil {
 		return "", err
 	}
@@ -117,30 +125,21 @@ func StarToYaml(path string, root string, params map[string]interface{}) (string
 }
 
 // StarToObjs converts a starlark file into one or more Kubernetes
-// objects.
+// objects. Loads with the file:// scheme and a leading "//" are resolved relative to root,
+// and loads with the module:// scheme are resolved by searching the module roots in order.
 func StarToObjs(
 	path string,
 	root string,
+	moduleRoots []string,
 	params map[string]interface{},
 ) ([]runtime.Object, error) {
-	reader, err := NewURLFileReader(root)
+	reader, err := NewURLFileReader(root, moduleRoots)
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
 
-	config, err := skycfg.Load(
-		context.Background(),
-		path,
-		skycfg.WithFileReader(reader),
-		skycfg.WithProtoRegistry(gogocompat.ProtoRegistry()),
-		skycfg.WithGlobals(
-			map[string]starlark.Value{
-				"util": skymod.UtilModule(),
-				"yaml": skymod.YamlModule(),
-			},
-		),
-	)
+	config, err := loadStarConfig(path, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -194,6 +193,34 @@ func StarToObjs(
 	return outputObjs, nil
 }
 
+// loadStarConfig loads the starlark file at the argument path, with the kubeapply-specific
+// modules available as globals.
+func loadStarConfig(path string, reader skycfg.FileReader) (*skycfg.Config, error) {
+	return skycfg.Load(
+		context.Background(),
+		path,
+		skycfg.WithFileReader(reader),
+		skycfg.WithProtoRegistry(gogocompat.ProtoRegistry()),
+		skycfg.WithGlobals(
+			map[string]starlark.Value{
+				"util": skymod.UtilModule(),
+				"yaml": skymod.YamlModule(),
+			},
+		),
+	)
+}
+
+// withBacktrace converts the argument starlark error into one that includes the full
+// backtrace, if possible. Syntax errors already include their locations, but evaluation
+// errors only have them in their backtraces.
+func withBacktrace(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
+
 // StarStrToObjs converts a starlark string into one or more Kubernetes
 // objects. It's intended for testing.
 func StarStrToObjs(
@@ -201,7 +228,7 @@ func StarStrToObjs(
 	root string,
 	params map[string]interface{},
 ) ([]runtime.Object, error) {
-	tempDir, err := ioutil.TempDir("", "star")
+	tempDir, err := util.TempDir("star")
 	if err != nil {
 		return nil, err
 	}
@@ -214,7 +241,7 @@ func StarStrToObjs(
 		return nil, err
 	}
 
-	return StarToObjs(starPath, root, params)
+	return StarToObjs(starPath, root, nil, params)
 }
 
 // GoToStarValue converts the given go interface to the equivalent
@@ -246,11 +273,10 @@ func GoToStarValue(obj interface{}) (starlark.Value, error) {
 	case uint64:
 		return starlark.MakeUint(uint(v)), nil
 
-	// Starlark doesn't support floats; just convert them to ints
 	case float32:
-		return starlark.MakeInt(int(v)), nil
+		return starlark.Float(v), nil
 	case float64:
-		return starlark.MakeInt(int(v)), nil
+		return starlark.Float(v), nil
 
 	default:
 		rVal := reflect.ValueOf(obj)
@@ -1,11 +1,16 @@
 package expand
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 
 	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 	appsv1 "k8s.io/api/apps/v1"
@@ -41,6 +46,28 @@ def main(ctx):
 		},
 		{
 			starStr: `
+def main(ctx):
+  return [
+    util.rawYaml(
+      {
+        'cpu': ctx.vars['cpu'],
+        'doubled': ctx.vars['cpu'] * 2,
+        'replicas': int(ctx.vars['replicas']),
+      },
+    ),
+  ]`,
+			params: map[string]interface{}{
+				"cpu":      0.25,
+				"replicas": 3.0,
+			},
+			expObjs: []runtime.Object{
+				&runtime.Unknown{
+					Raw: []byte("cpu: 0.25\ndoubled: 0.5\nreplicas: 3\n"),
+				},
+			},
+		},
+		{
+			starStr: `
 corev1 = proto.package("k8s.io.api.core.v1")
 metav1 = proto.package("k8s.io.apimachinery.pkg.apis.meta.v1")
 
@@ -187,7 +214,7 @@ def main(ctx):
 }
 
 func TestStarToObjsEndToEnd(t *testing.T) {
-	objs, err := StarToObjs("./testdata/app.star", "testdata", nil)
+	objs, err := StarToObjs("./testdata/app.star", "testdata", nil, nil)
 	assert.Nil(t, err)
 
 	assert.Equal(t, 3, len(objs))
@@ -197,6 +224,71 @@ func TestStarToObjsEndToEnd(t *testing.T) {
 	assert.IsType(t, &corev1.Service{}, objs[2])
 }
 
+func TestStarToObjsModules(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "modules")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	starPath := filepath.Join(tempDir, "app.star")
+	require.NoError(
+		t,
+		ioutil.WriteFile(
+			starPath,
+			[]byte(`
+load("module://k8s/labels.star", "app_labels")
+
+def main(ctx):
+  return [util.rawYaml({"labels": app_labels("nginx", ctx.vars["env"])})]
+`),
+			0644,
+		),
+	)
+
+	objs, err := StarToObjs(
+		starPath,
+		tempDir,
+		[]string{"testdata/non-existent", "testdata/modules"},
+		map[string]interface{}{"env": "stage"},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]runtime.Object{
+			&runtime.Unknown{
+				Raw: []byte("labels:\n  app: nginx\n  env: stage\n"),
+			},
+		},
+		objs,
+	)
+
+	_, err = StarToObjs(starPath, tempDir, []string{"testdata"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Could not find module k8s/labels.star")
+}
+
+func TestExpandStarErrors(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "expand")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	badPath := filepath.Join(tempDir, "subdir", "bad.star")
+	require.NoError(t, os.MkdirAll(filepath.Dir(badPath), 0755))
+	require.NoError(
+		t,
+		ioutil.WriteFile(
+			badPath,
+			[]byte("def main(ctx):\n  return helper()\n\ndef helper():\n  return 1 // 0\n"),
+			0644,
+		),
+	)
+
+	err = ExpandStar(tempDir, tempDir, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("Error expanding path %s", badPath))
+	assert.Contains(t, err.Error(), "bad.star:2:16: in main")
+	assert.Contains(t, err.Error(), "bad.star:5:12: in helper")
+}
+
 type goToStarValueTestCase struct {
 	goVal      interface{}
 	expErr     bool
@@ -224,6 +316,14 @@ func TestGoToStarValue(t *testing.T) {
 			}(),
 			expStarVal: starlark.MakeInt(4123),
 		},
+		{
+			goVal:      0.5,
+			expStarVal: starlark.Float(0.5),
+		},
+		{
+			goVal:      float32(1.25),
+			expStarVal: starlark.Float(1.25),
+		},
 		{
 			goVal: []interface{}{"elem1", "elem2", 1234},
 			expStarVal: starlark.NewList(
@@ -0,0 +1,64 @@
+package expand
+
+import (
+	"fmt"
+
+	"go.starlark.net/starlark"
+)
+
+// LintResult contains the results of linting a single starlark file.
+type LintResult struct {
+	// Path is the path of the linted file.
+	Path string
+
+	// Err is set if the file couldn't be parsed or loaded, or if it has an invalid main
+	// function.
+	Err error
+
+	// MissingMain is set if the file doesn't have a main(ctx) entrypoint. This is expected
+	// for library files that are only loaded by other files, but such files are skipped
+	// during expansion.
+	MissingMain bool
+}
+
+// LintStarFile loads the starlark file at the argument path without running its main
+// function. This catches syntax errors, bad loads, and errors in top-level statements
+// without requiring any parameters or cluster context. As with StarToObjs, root and
+// moduleRoots are used for resolving loads.
+func LintStarFile(path string, root string, moduleRoots []string) LintResult {
+	result := LintResult{
+		Path: path,
+	}
+
+	reader, err := NewURLFileReader(root, moduleRoots)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer reader.Close()
+
+	config, err := loadStarConfig(path, reader)
+	if err != nil {
+		result.Err = withBacktrace(err)
+		return result
+	}
+
+	mainVal, ok := config.Locals()["main"]
+	if !ok {
+		result.MissingMain = true
+		return result
+	}
+
+	mainFunc, ok := mainVal.(*starlark.Function)
+	if !ok {
+		result.Err = fmt.Errorf("main must be a function (got a %s)", mainVal.Type())
+	} else if mainFunc.NumParams() != 1 {
+		result.Err = fmt.Errorf(
+			"%s: main must take a single ctx argument (takes %d)",
+			mainFunc.Position(),
+			mainFunc.NumParams(),
+		)
+	}
+
+	return result
+}
@@ -0,0 +1,68 @@
+package expand
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintStarFile(t *testing.T) {
+	result := LintStarFile("testdata/app.star", "testdata", nil)
+	assert.NoError(t, result.Err)
+	assert.False(t, result.MissingMain)
+
+	result = LintStarFile("testdata/deploy.star", "testdata", nil)
+	assert.NoError(t, result.Err)
+	assert.True(t, result.MissingMain)
+
+	tempDir, err := ioutil.TempDir("", "lint")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	type testCase struct {
+		description string
+		starStr     string
+		expErr      string
+	}
+
+	testCases := []testCase{
+		{
+			description: "syntax error",
+			starStr:     "def main(ctx)\n  return []\n",
+			expErr:      "bad.star:2:1",
+		},
+		{
+			description: "undefined name",
+			starStr:     "x = undefined_thing\n\ndef main(ctx):\n  return []\n",
+			expErr:      "undefined",
+		},
+		{
+			description: "top-level evaluation error",
+			starStr:     "x = 1 // 0\n\ndef main(ctx):\n  return []\n",
+			expErr:      "bad.star:1",
+		},
+		{
+			description: "main without ctx",
+			starStr:     "def main():\n  return []\n",
+			expErr:      "main must take a single ctx argument",
+		},
+		{
+			description: "main is not a function",
+			starStr:     "main = 1\n",
+			expErr:      "main must be a function",
+		},
+	}
+
+	for _, testCase := range testCases {
+		path := filepath.Join(tempDir, "bad.star")
+		require.NoError(t, ioutil.WriteFile(path, []byte(testCase.starStr), 0644))
+
+		result := LintStarFile(path, tempDir, nil)
+		require.Error(t, result.Err, testCase.description)
+		assert.Contains(t, result.Err.Error(), testCase.expErr, testCase.description)
+	}
+}
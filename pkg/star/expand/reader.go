@@ -17,9 +17,10 @@ import (
 var urlRegex = regexp.MustCompile("^([a-zA-Z0-9._-]+)://(.*)$")
 
 type urlFileReader struct {
-	root     string
-	repoDirs map[repoRef]string
-	tempDir  string
+	root        string
+	moduleRoots []string
+	repoDirs    map[repoRef]string
+	tempDir     string
 }
 
 type repoRef struct {
@@ -40,16 +41,18 @@ func (r repoRef) dirName() string {
 var _ skycfg.FileReader = (*urlFileReader)(nil)
 
 // NewURLFileReader returns a skycfg FileReader that reads files from local disk, resolving
-// remote URLs if needed.
-func NewURLFileReader(root string) (*urlFileReader, error) {
-	tempDir, err := ioutil.TempDir("", "files")
+// remote URLs if needed. Names with the module:// scheme are resolved by searching each of
+// the argument module roots, in order.
+func NewURLFileReader(root string, moduleRoots []string) (*urlFileReader, error) {
+	tempDir, err := util.TempDir("files")
 	if err != nil {
 		return nil, err
 	}
 
 	return &urlFileReader{
-		root:    root,
-		tempDir: tempDir,
+		root:        root,
+		moduleRoots: moduleRoots,
+		tempDir:     tempDir,
 	}, nil
 }
 
@@ -94,6 +97,20 @@ func (r *urlFileReader) Resolve(
 		)
 		log.Debugf("Resolving name %s to path relative to this file: %s", name, resolve)
 		return resolve, nil
+	case "module":
+		for _, moduleRoot := range r.moduleRoots {
+			resolve := filepath.Join(moduleRoot, remainder)
+			if _, err := os.Stat(resolve); err == nil {
+				log.Debugf("Resolving name %s to path in module root: %s", name, resolve)
+				return resolve, nil
+			}
+		}
+
+		return "", fmt.Errorf(
+			"Could not find module %s in module roots %+v",
+			remainder,
+			r.moduleRoots,
+		)
 	case "git", "git-https":
 		ref := repoRef{}
 
@@ -46,12 +46,16 @@ func (n *NullStatsClient) Update(
 // FakeStatsClient is a fake implementation of StatsClient for testing purposes.
 type FakeStatsClient struct {
 	Stats map[string]float64
+
+	// Tags are the tags of each update, keyed by stat name.
+	Tags map[string][][]string
 }
 
 // NewFakeStatsClient returns a new FakeStatsClient instance.
 func NewFakeStatsClient() *FakeStatsClient {
 	return &FakeStatsClient{
 		Stats: map[string]float64{},
+		Tags:  map[string][][]string{},
 	}
 }
 
@@ -68,6 +72,7 @@ func (s *FakeStatsClient) Update(
 
 	for n := 0; n < len(names); n++ {
 		s.Stats[names[n]] += values[n]
+		s.Tags[names[n]] = append(s.Tags[names[n]], tags)
 	}
 
 	return nil
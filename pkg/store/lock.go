@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	coordv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
@@ -21,6 +25,9 @@ import (
 
 const (
 	kubeLockerReleaseTimeout = 10 * time.Second
+
+	// lockLeasePrefix is the prefix of the names of the leases that back KubeLocker locks.
+	lockLeasePrefix = "kubeapply-lock-"
 )
 
 // Locker is an interface for structs that can acquire and release locks.
@@ -126,7 +133,7 @@ func (k *KubeLocker) Acquire(ctx context.Context, name string) error {
 	k.lockCompletions[name] = make(chan struct{}, 1)
 	k.objLock.Unlock()
 
-	leaseName := fmt.Sprintf("kubeapply-lock-%s", name)
+	leaseName := lockLeasePrefix + name
 
 	lock := &resourcelock.LeaseLock{
 		LeaseMeta: metav1.ObjectMeta{
@@ -222,3 +229,107 @@ func (k *KubeLocker) releaseHelper(name string) error {
 	delete(k.lockCancellations, name)
 	return nil
 }
+
+// LockInfo describes the state of a lock managed by a KubeLocker.
+type LockInfo struct {
+	// Name is the name of the lock, e.g. the cluster that it's for.
+	Name string
+
+	// Holder is the ID of the client holding the lock. It's empty if the lock was released.
+	Holder string
+
+	// AcquiredAt is when the current holder acquired the lock.
+	AcquiredAt time.Time
+
+	// RenewedAt is when the current holder last renewed the lock.
+	RenewedAt time.Time
+
+	// LeaseDuration is how long the lock is held for after each renewal.
+	LeaseDuration time.Duration
+}
+
+// Held returns whether the lock is held as of the argument time, i.e. it has a holder and its
+// lease hasn't expired.
+func (l LockInfo) Held(now time.Time) bool {
+	return l.Holder != "" && now.Before(l.RenewedAt.Add(l.LeaseDuration))
+}
+
+// List returns the locks in the locker's namespace, sorted by name. This includes locks that
+// were released or whose leases have expired.
+func (k *KubeLocker) List(ctx context.Context) ([]LockInfo, error) {
+	leases, err := k.coordinationClient.Leases(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	locks := []LockInfo{}
+
+	for _, lease := range leases.Items {
+		if !strings.HasPrefix(lease.Name, lockLeasePrefix) {
+			continue
+		}
+
+		locks = append(locks, leaseLockInfo(lease))
+	}
+
+	sort.Slice(locks, func(a, b int) bool {
+		return locks[a].Name < locks[b].Name
+	})
+
+	return locks, nil
+}
+
+// Get returns the lock with the argument name or nil if there's no lease for it, i.e. it was
+// never acquired or was force-released.
+func (k *KubeLocker) Get(ctx context.Context, name string) (*LockInfo, error) {
+	lease, err := k.coordinationClient.Leases(k.namespace).Get(
+		ctx,
+		lockLeasePrefix+name,
+		metav1.GetOptions{},
+	)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	lock := leaseLockInfo(*lease)
+	return &lock, nil
+}
+
+// ForceRelease releases the lock with the argument name regardless of which client holds it
+// by deleting its lease. This is intended for recovering from clients that crashed while
+// holding a lock; if the holder is still running, it might keep operating as if it held the
+// lock.
+func (k *KubeLocker) ForceRelease(ctx context.Context, name string) error {
+	log.Infof("Force-releasing lock with name %s", name)
+
+	err := k.coordinationClient.Leases(k.namespace).Delete(
+		ctx,
+		lockLeasePrefix+name,
+		metav1.DeleteOptions{},
+	)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("No lock found with name %s", name)
+	}
+	return err
+}
+
+func leaseLockInfo(lease coordinationv1.Lease) LockInfo {
+	lock := LockInfo{
+		Name: strings.TrimPrefix(lease.Name, lockLeasePrefix),
+	}
+	if lease.Spec.HolderIdentity != nil {
+		lock.Holder = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.AcquireTime != nil {
+		lock.AcquiredAt = lease.Spec.AcquireTime.Time
+	}
+	if lease.Spec.RenewTime != nil {
+		lock.RenewedAt = lease.Spec.RenewTime.Time
+	}
+	if lease.Spec.LeaseDurationSeconds != nil {
+		lock.LeaseDuration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	return lock
+}
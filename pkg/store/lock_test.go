@@ -9,6 +9,9 @@ import (
 	"github.com/segmentio/kubeapply/pkg/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 const kubeConfigTestPath = "../../.kube/kind-kubeapply-test.yaml"
@@ -51,3 +54,82 @@ func TestKubeLocker(t *testing.T) {
 	err = locker1.Acquire(acquireCtx3, "test-key")
 	require.Nil(t, err)
 }
+
+func TestKubeLockerListAndForceRelease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	holder := "client1"
+	duration := int32(20)
+	acquireTime := metav1.NewMicroTime(now.Add(-time.Minute))
+	renewTime := metav1.NewMicroTime(now.Add(-5 * time.Second))
+	staleRenewTime := metav1.NewMicroTime(now.Add(-time.Hour))
+
+	client := fake.NewSimpleClientset(
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kubeapply-lock-cluster2",
+				Namespace: "kube-system",
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &acquireTime,
+				RenewTime:            &staleRenewTime,
+			},
+		},
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kubeapply-lock-cluster1",
+				Namespace: "kube-system",
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &acquireTime,
+				RenewTime:            &renewTime,
+			},
+		},
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other-lease",
+				Namespace: "kube-system",
+			},
+		},
+	)
+
+	locker := &KubeLocker{
+		id:                 "client2",
+		namespace:          "kube-system",
+		lockCancellations:  map[string]context.CancelFunc{},
+		lockCompletions:    map[string]chan struct{}{},
+		coordinationClient: client.CoordinationV1(),
+	}
+
+	locks, err := locker.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(locks))
+	assert.Equal(t, "cluster1", locks[0].Name)
+	assert.Equal(t, "client1", locks[0].Holder)
+	assert.Equal(t, 20*time.Second, locks[0].LeaseDuration)
+	assert.True(t, locks[0].Held(now))
+	assert.Equal(t, "cluster2", locks[1].Name)
+	assert.False(t, locks[1].Held(now))
+
+	lock, err := locker.Get(ctx, "cluster1")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.Equal(t, locks[0], *lock)
+
+	require.NoError(t, locker.ForceRelease(ctx, "cluster1"))
+	assert.Error(t, locker.ForceRelease(ctx, "cluster1"))
+
+	lock, err = locker.Get(ctx, "cluster1")
+	require.NoError(t, err)
+	assert.Nil(t, lock)
+
+	locks, err = locker.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(locks))
+	assert.Equal(t, "cluster2", locks[0].Name)
+}
@@ -1,16 +1,18 @@
 package util
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -23,6 +25,16 @@ var (
 	s3URLRegex = regexp.MustCompile("^s3://([a-zA-Z0-9._-]+)/(.*)$")
 )
 
+// RestoreLimits bounds the resources used by RestoreData. Zero values mean no limit.
+type RestoreLimits struct {
+	// Timeout is the maximum amount of time that a restore can take.
+	Timeout time.Duration
+
+	// MaxSize is the maximum size, in bytes, of the restored data. This applies to both
+	// the fetched archives and their unarchived contents.
+	MaxSize int64
+}
+
 // RestoreData generates a local version of the resource(s) at the argument URL. Currently, it
 // supports the schemes "file://", "http://", "https://", "git://", "git-https://", and "s3://".
 //
@@ -33,11 +45,93 @@ var (
 //
 // The rootDir argument is used in the file case as the base for relative file URLs. It is
 // unused in other cases.
+//
+// If the restore exceeds the argument limits, it's aborted with an error.
 func RestoreData(
 	ctx context.Context,
 	rootDir string,
 	url string,
 	destDir string,
+	limits RestoreLimits,
+) error {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	destExists, err := DirExists(destDir)
+	if err != nil {
+		return err
+	}
+
+	var sizeBefore int64
+	if limits.MaxSize > 0 && destExists {
+		// The destination might not be empty, so only count what's added to it
+		sizeBefore, err = dirSize(destDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = restoreData(ctx, rootDir, url, destDir, limits.MaxSize)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("Timed out after %s restoring data from %s", limits.Timeout, url)
+	} else if err != nil {
+		return err
+	}
+
+	// Archives are checked while they're unarchived, but other sources, e.g. git repos, can
+	// only be checked once they've been restored.
+	if limits.MaxSize > 0 {
+		sizeAfter, err := dirSize(destDir)
+		if err != nil {
+			return err
+		}
+		if size := sizeAfter - sizeBefore; size > limits.MaxSize {
+			if !destExists {
+				if err := os.RemoveAll(destDir); err != nil {
+					log.Warnf("Error removing %s: %+v", destDir, err)
+				}
+			}
+			return fmt.Errorf(
+				"Data restored from %s is %d bytes, which exceeds the maximum size of %d bytes",
+				url,
+				size,
+				limits.MaxSize,
+			)
+		}
+	}
+
+	return nil
+}
+
+// LocalPath returns the absolute path of the local file or directory that the argument
+// RestoreData URL refers to, resolving relative paths against rootDir. The second return value
+// is false if the URL refers to a remote resource.
+func LocalPath(rootDir string, url string) (string, bool) {
+	matches := urlRegex.FindStringSubmatch(url)
+	if len(matches) != 3 {
+		return filepathForURL(rootDir, url), true
+	} else if matches[1] != "file" {
+		return "", false
+	}
+	return filepathForURL(rootDir, matches[2]), true
+}
+
+func filepathForURL(rootDir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(rootDir, path)
+}
+
+func restoreData(
+	ctx context.Context,
+	rootDir string,
+	url string,
+	destDir string,
+	maxSize int64,
 ) error {
 	matches := urlRegex.FindStringSubmatch(url)
 	if len(matches) != 3 {
@@ -55,16 +149,28 @@ func RestoreData(
 
 	switch scheme {
 	case "file":
-		var absPath string
+		absPath := filepathForURL(rootDir, remainder)
 
-		if filepath.IsAbs(remainder) {
-			absPath = remainder
-		} else {
-			absPath = filepath.Join(rootDir, remainder)
+		if isArchive {
+			if err := checkFileSize(absPath, maxSize); err != nil {
+				return err
+			}
+			return unarchiveTarGz(ctx, absPath, destDir, maxSize)
 		}
 
-		if isArchive {
-			return unarchiveTarGz(ctx, absPath, destDir)
+		if maxSize > 0 {
+			size, err := dirSize(absPath)
+			if err != nil {
+				return err
+			}
+			if size > maxSize {
+				return fmt.Errorf(
+					"Directory %s is %d bytes, which exceeds the maximum size of %d bytes",
+					absPath,
+					size,
+					maxSize,
+				)
+			}
 		}
 
 		return RecursiveCopy(absPath, destDir)
@@ -97,16 +203,21 @@ func RestoreData(
 	case "http", "https":
 		log.Debugf("Getting http url: %s", url)
 
-		resp, err := http.Get(url)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			return err
 		}
+		defer resp.Body.Close()
+
 		if resp.StatusCode >= 300 {
 			return fmt.Errorf("Non-200 response code from request: %d", resp.StatusCode)
 		}
 
-		defer resp.Body.Close()
-		return unarchiveReader(ctx, resp.Body, destDir)
+		return unarchiveReader(ctx, resp.Body, destDir, url, maxSize)
 	case "s3":
 		log.Debugf("Getting s3 archive: %s", url)
 
@@ -128,7 +239,7 @@ func RestoreData(
 			return err
 		}
 		defer resp.Body.Close()
-		return unarchiveReader(ctx, resp.Body, destDir)
+		return unarchiveReader(ctx, resp.Body, destDir, url, maxSize)
 	default:
 		return fmt.Errorf("Unrecognized resource url: %s", url)
 	}
@@ -144,23 +255,38 @@ func ParseS3URL(url string) (string, string, error) {
 	return matches[1], matches[2], nil
 }
 
-func unarchiveReader(ctx context.Context, reader io.Reader, destDir string) error {
-	contents, err := ioutil.ReadAll(reader)
+func unarchiveReader(
+	ctx context.Context,
+	reader io.Reader,
+	destDir string,
+	url string,
+	maxSize int64,
+) error {
+	tempFile, err := TempFile("output")
 	if err != nil {
-		return nil
+		return err
 	}
+	defer os.Remove(tempFile.Name())
 
-	tempFile, err := ioutil.TempFile("", "output")
-	if err != nil {
-		return nil
+	if maxSize > 0 {
+		// Read one byte past the limit so that we can tell if it was exceeded
+		reader = io.LimitReader(reader, maxSize+1)
 	}
-	defer os.Remove(tempFile.Name())
 
-	_, err = tempFile.Write(contents)
+	written, err := io.Copy(tempFile, reader)
 	if err != nil {
+		tempFile.Close()
 		log.Printf("Error writing to tempfile: %+v", err)
 		return err
 	}
+	if maxSize > 0 && written > maxSize {
+		tempFile.Close()
+		return fmt.Errorf(
+			"Archive at %s exceeds the maximum size of %d bytes",
+			url,
+			maxSize,
+		)
+	}
 
 	err = tempFile.Close()
 	if err != nil {
@@ -168,28 +294,250 @@ func unarchiveReader(ctx context.Context, reader io.Reader, destDir string) erro
 		return nil
 	}
 
-	err = os.MkdirAll(destDir, 0755)
+	return unarchiveTarGz(ctx, tempFile.Name(), destDir, maxSize)
+}
+
+// unarchiveTarGz unarchives the tar.gz archive at the argument source path into the argument
+// destination directory. If maxSize is positive, then the extraction is aborted as soon as the
+// unarchived contents exceed it, and everything that was created by the extraction is removed.
+// This keeps highly-compressed archives from filling up the disk.
+func unarchiveTarGz(ctx context.Context, source string, dest string, maxSize int64) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(&contextReader{ctx: ctx, reader: file})
+	if err != nil {
+		return fmt.Errorf("Error reading archive %s: %+v", source, err)
+	}
+	defer gzipReader.Close()
+
+	extractor := &tarExtractor{
+		source:  source,
+		dest:    dest,
+		maxSize: maxSize,
+	}
+	if err := extractor.extract(tar.NewReader(gzipReader)); err != nil {
+		extractor.cleanUp()
+		return err
+	}
+
+	return nil
+}
+
+// tarExtractor extracts the contents of a tar archive while keeping track of what it creates.
+type tarExtractor struct {
+	source  string
+	dest    string
+	maxSize int64
+
+	// written is the number of bytes written so far.
+	written int64
+
+	// created contains the paths created by the extraction, in the order that they were
+	// created.
+	created []string
+}
+
+func (e *tarExtractor) extract(tarReader *tar.Reader) error {
+	if err := e.mkdirAll(e.dest); err != nil {
+		return err
+	}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Error reading archive %s: %+v", e.source, err)
+		}
+
+		path, err := e.destPath(header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = e.mkdirAll(path)
+		case tar.TypeReg, tar.TypeRegA:
+			err = e.writeFile(path, tarReader, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = e.symlink(path, header.Linkname)
+		default:
+			log.Debugf(
+				"Skipping %s in archive %s since it's not a file, directory, or symlink",
+				header.Name,
+				e.source,
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// destPath returns the path that the argument archive entry should be extracted to. Entries
+// outside of the destination directory aren't allowed.
+func (e *tarExtractor) destPath(name string) (string, error) {
+	path := filepath.Join(e.dest, name)
+	if !pathInDir(path, e.dest) {
+		return "", fmt.Errorf("Archive %s contains invalid path %s", e.source, name)
+	}
+	return path, nil
+}
+
+func (e *tarExtractor) mkdirAll(path string) error {
+	missing := []string{}
+
+	for subPath := path; ; subPath = filepath.Dir(subPath) {
+		if _, err := os.Lstat(subPath); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		missing = append(missing, subPath)
+		if subPath == filepath.Dir(subPath) {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	for m := len(missing) - 1; m >= 0; m-- {
+		e.created = append(e.created, missing[m])
+	}
+	return nil
+}
+
+func (e *tarExtractor) writeFile(path string, reader io.Reader, mode os.FileMode) error {
+	if err := e.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		e.created = append(e.created, path)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if e.maxSize > 0 {
+		// Read one byte past the limit so that we can tell if it was exceeded
+		reader = io.LimitReader(reader, e.maxSize-e.written+1)
+	}
+
+	written, err := io.Copy(file, reader)
+	e.written += written
 	if err != nil {
 		return err
 	}
 
-	return unarchiveTarGz(ctx, tempFile.Name(), destDir)
+	if e.maxSize > 0 && e.written > e.maxSize {
+		return fmt.Errorf(
+			"Unarchived contents of %s exceed the maximum size of %d bytes",
+			e.source,
+			e.maxSize,
+		)
+	}
+	return nil
+}
+
+func (e *tarExtractor) symlink(path string, target string) error {
+	// Don't allow links out of the destination directory since later entries could be
+	// written through them.
+	if filepath.IsAbs(target) || !pathInDir(filepath.Join(filepath.Dir(path), target), e.dest) {
+		return fmt.Errorf(
+			"Archive %s contains link %s to %s outside of the archive",
+			e.source,
+			path,
+			target,
+		)
+	}
+
+	if err := e.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := os.Symlink(target, path); err != nil {
+		return err
+	}
+	e.created = append(e.created, path)
+	return nil
+}
+
+// cleanUp removes everything that was created by the extraction.
+func (e *tarExtractor) cleanUp() {
+	for c := len(e.created) - 1; c >= 0; c-- {
+		if err := os.RemoveAll(e.created[c]); err != nil {
+			log.Warnf("Error removing %s: %+v", e.created[c], err)
+		}
+	}
+}
+
+// pathInDir returns whether the argument path is the argument directory or inside of it.
+func pathInDir(path string, dir string) bool {
+	relPath, err := filepath.Rel(dir, path)
+	return err == nil && relPath != ".." &&
+		!strings.HasPrefix(relPath, ".."+string(filepath.Separator))
 }
 
-func unarchiveTarGz(ctx context.Context, source string, dest string) error {
-	tarArgs := []string{
-		"-xzf",
-		source,
-		"-C",
-		dest,
+// contextReader is a reader that fails once its context is done.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return r.reader.Read(p)
+}
 
-	cmd := exec.CommandContext(ctx, "tar", tarArgs...)
-	output, err := cmd.CombinedOutput()
+// checkFileSize returns an error if the file at the argument path is larger than maxSize.
+func checkFileSize(path string, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
 
+	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("Error running tar (%+v): %s", err, string(output))
+		return err
+	}
+	if info.Size() > maxSize {
+		return fmt.Errorf(
+			"Archive %s is %d bytes, which exceeds the maximum size of %d bytes",
+			path,
+			info.Size(),
+			maxSize,
+		)
 	}
 
 	return nil
 }
+
+// dirSize returns the total size of the regular files in the argument directory.
+func dirSize(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.Walk(
+		dir,
+		func(subPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				size += info.Size()
+			}
+			return nil
+		},
+	)
+
+	return size, err
+}
@@ -1,6 +1,9 @@
 package util
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -12,6 +15,7 @@ import (
 	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,6 +25,7 @@ func TestRestoreData(t *testing.T) {
 	type testCase struct {
 		description   string
 		url           string
+		limits        RestoreLimits
 		errExpected   bool
 		destPath      string
 		expectedFiles []string
@@ -54,6 +59,13 @@ func TestRestoreData(t *testing.T) {
 	testServer := httptest.NewServer(
 		http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow.tar.gz" {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(5 * time.Second):
+					}
+					return
+				}
 				if r.URL.Path != "/test-file.tar.gz" {
 					http.Error(w, "Not found", 404)
 					return
@@ -116,12 +128,61 @@ func TestRestoreData(t *testing.T) {
 				"text_inputs/dir2/file3.txt",
 			},
 		},
+		{
+			description: "http archive within max size",
+			url:         fmt.Sprintf("%s/test-file.tar.gz", testServer.URL),
+			limits: RestoreLimits{
+				MaxSize: 1024 * 1024,
+			},
+			destPath: filepath.Join(tempDir, "http_outputs_limited"),
+			expectedFiles: []string{
+				"text_inputs/dir1/file1.txt",
+				"text_inputs/dir1/file2.txt",
+				"text_inputs/dir2/file3.txt",
+			},
+		},
+		{
+			description: "http archive over max size",
+			url:         fmt.Sprintf("%s/test-file.tar.gz", testServer.URL),
+			limits: RestoreLimits{
+				MaxSize: 10,
+			},
+			destPath:    filepath.Join(tempDir, "http_outputs_too_big"),
+			errExpected: true,
+		},
+		{
+			description: "http archive timeout",
+			url:         fmt.Sprintf("%s/slow.tar.gz", testServer.URL),
+			limits: RestoreLimits{
+				Timeout: 50 * time.Millisecond,
+			},
+			destPath:    filepath.Join(tempDir, "http_outputs_slow"),
+			errExpected: true,
+		},
+		{
+			description: "tar archive over max size",
+			url:         fmt.Sprintf("file://%s", tarInput),
+			limits: RestoreLimits{
+				MaxSize: 10,
+			},
+			destPath:    filepath.Join(tempDir, "tar_outputs_too_big"),
+			errExpected: true,
+		},
+		{
+			description: "basic files over max size",
+			url:         textInputsDir,
+			limits: RestoreLimits{
+				MaxSize: 20,
+			},
+			destPath:    filepath.Join(tempDir, "text_outputs_too_big"),
+			errExpected: true,
+		},
 	}
 
 	ctx := context.Background()
 
 	for _, testCase := range testCases {
-		err := RestoreData(ctx, ".", testCase.url, testCase.destPath)
+		err := RestoreData(ctx, ".", testCase.url, testCase.destPath, testCase.limits)
 		if testCase.errExpected {
 			assert.NotNil(t, err, testCase.description)
 		} else {
@@ -135,6 +196,59 @@ func TestRestoreData(t *testing.T) {
 	}
 }
 
+func TestRestoreDataCompressedOverMaxSize(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "data")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// The archive is tiny, but its contents are much larger than the max size
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range []string{"bomb/zeros1", "bomb/zeros2"} {
+		require.Nil(
+			t,
+			tarWriter.WriteHeader(
+				&tar.Header{
+					Name:     name,
+					Mode:     0644,
+					Size:     1024 * 1024,
+					Typeflag: tar.TypeReg,
+				},
+			),
+		)
+		_, err = tarWriter.Write(make([]byte, 1024*1024))
+		require.Nil(t, err)
+	}
+	require.Nil(t, tarWriter.Close())
+	require.Nil(t, gzipWriter.Close())
+
+	archivePath := filepath.Join(tempDir, "bomb.tar.gz")
+	require.Nil(t, ioutil.WriteFile(archivePath, buffer.Bytes(), 0644))
+
+	destDir := filepath.Join(tempDir, "outputs")
+	WriteFiles(t, destDir, map[string]string{"existing.txt": "existing contents"})
+
+	limits := RestoreLimits{MaxSize: 512 * 1024}
+	require.Less(t, int64(buffer.Len()), limits.MaxSize)
+
+	err = RestoreData(context.Background(), ".", archivePath, destDir, limits)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "exceed the maximum size")
+
+	// Only the files that were there before are left
+	assert.Equal(t, []string{"existing.txt"}, allSubpaths(t, destDir))
+	_, err = os.Stat(filepath.Join(destDir, "bomb"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Destination directories that were created by the restore are removed entirely
+	newDestDir := filepath.Join(tempDir, "new_outputs")
+	err = RestoreData(context.Background(), ".", archivePath, newDestDir, limits)
+	require.NotNil(t, err)
+	_, err = os.Stat(newDestDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
 func allSubpaths(t *testing.T, root string) []string {
 	paths := []string{}
 
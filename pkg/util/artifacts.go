@@ -0,0 +1,128 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ArtifactConfigFile is the name of the cluster config in an artifact.
+	ArtifactConfigFile = "cluster.yaml"
+
+	// ArtifactExpandedDir is the name of the directory with the expanded configs in an
+	// artifact.
+	ArtifactExpandedDir = "expanded"
+
+	artifactMetadataFile = "kubeapply-artifact.json"
+)
+
+// ArtifactMetadata describes the contents of an artifact.
+type ArtifactMetadata struct {
+	// Cluster is the descriptive name of the cluster that the artifact was created for.
+	Cluster string `json:"cluster"`
+
+	// CreatedAt is the time that the artifact was created.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateArtifact packages the argument cluster config and expanded configs, along with the
+// argument metadata, into a tar.gz archive at artifactPath. The archive can be unpacked
+// with ExtractArtifact.
+func CreateArtifact(
+	ctx context.Context,
+	artifactPath string,
+	configPath string,
+	expandedPath string,
+	metadata ArtifactMetadata,
+) error {
+	stagingDir, err := TempDir("artifact")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	err = RecursiveCopy(expandedPath, filepath.Join(stagingDir, ArtifactExpandedDir))
+	if err != nil {
+		return err
+	}
+
+	configBytes, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(stagingDir, ArtifactConfigFile), configBytes, 0644)
+	if err != nil {
+		return err
+	}
+
+	metadataBytes, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(stagingDir, artifactMetadataFile), metadataBytes, 0644)
+	if err != nil {
+		return err
+	}
+
+	absArtifactPath, err := filepath.Abs(artifactPath)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "tar", "-czf", absArtifactPath, "-C", stagingDir, ".")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Error running tar (%+v): %s", err, string(output))
+	}
+
+	return nil
+}
+
+// ExtractArtifact unpacks the artifact at artifactPath into destDir and returns its metadata.
+// The cluster config and expanded configs are in ArtifactConfigFile and ArtifactExpandedDir,
+// respectively, inside of destDir.
+func ExtractArtifact(
+	ctx context.Context,
+	artifactPath string,
+	destDir string,
+) (ArtifactMetadata, error) {
+	metadata := ArtifactMetadata{}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return metadata, err
+	}
+	if err := unarchiveTarGz(ctx, artifactPath, destDir, 0); err != nil {
+		return metadata, err
+	}
+
+	metadataBytes, err := ioutil.ReadFile(filepath.Join(destDir, artifactMetadataFile))
+	if err != nil {
+		return metadata, fmt.Errorf(
+			"Artifact %s does not appear to have been created by kubeapply: %+v",
+			artifactPath,
+			err,
+		)
+	}
+	if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+		return metadata, err
+	}
+
+	ok, err := DirExists(filepath.Join(destDir, ArtifactExpandedDir))
+	if err != nil {
+		return metadata, err
+	} else if !ok {
+		return metadata, fmt.Errorf(
+			"Artifact %s does not contain an %s directory",
+			artifactPath,
+			ArtifactExpandedDir,
+		)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifacts(t *testing.T) {
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "artifacts")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	configPath := filepath.Join(tempDir, "cluster.yaml")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte("cluster: test\n"), 0644))
+
+	expandedPath := filepath.Join(tempDir, "expanded")
+	WriteFiles(
+		t,
+		expandedPath,
+		map[string]string{
+			"ns1/deployment.yaml": "kind: Deployment",
+			"ns2/service.yaml":    "kind: Service",
+		},
+	)
+
+	metadata := ArtifactMetadata{
+		Cluster:   "stage:us-west-2:test",
+		CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	artifactPath := filepath.Join(tempDir, "artifact.tar.gz")
+	err = CreateArtifact(ctx, artifactPath, configPath, expandedPath, metadata)
+	require.NoError(t, err)
+
+	extractedPath := filepath.Join(tempDir, "extracted")
+	extractedMetadata, err := ExtractArtifact(ctx, artifactPath, extractedPath)
+	require.NoError(t, err)
+	assert.Equal(t, metadata, extractedMetadata)
+
+	assert.Equal(
+		t,
+		[]string{
+			"cluster.yaml",
+			"expanded/ns1/deployment.yaml",
+			"expanded/ns2/service.yaml",
+			"kubeapply-artifact.json",
+		},
+		allSubpaths(t, extractedPath),
+	)
+
+	_, err = ExtractArtifact(ctx, configPath, filepath.Join(tempDir, "bad"))
+	assert.Error(t, err)
+}
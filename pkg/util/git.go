@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -77,7 +78,91 @@ func CloneRepo(ctx context.Context, url string, ref string, path string) error {
 	return nil
 }
 
+// SparseCloneOptions stores the options for SparseCloneRepo.
+type SparseCloneOptions struct {
+	// Branch is the branch to clone.
+	Branch string
+
+	// Depth is the number of commits to fetch. If zero, the full history of all branches
+	// is fetched.
+	Depth int
+
+	// Dirs are the directories, relative to the root of the repo, to check out. The files
+	// directly in the root and in the parents of these directories are checked out too.
+	Dirs []string
+
+	// Env are extra environment variables to set when running git, e.g. for passing
+	// credentials without including them in the command-line arguments.
+	Env []string
+}
+
+// SparseCloneRepo makes a clone of the argument repo with only a subset of the directories
+// checked out. The blobs for the files that aren't checked out are not fetched, so this is
+// much faster than a full clone for big repos.
+func SparseCloneRepo(
+	ctx context.Context,
+	url string,
+	path string,
+	options SparseCloneOptions,
+) error {
+	log.Debugf(
+		"Making sparse clone of %s at branch=%s in %s with dirs %+v",
+		url,
+		options.Branch,
+		path,
+		options.Dirs,
+	)
+
+	cloneArgs := []string{
+		"clone",
+		"--filter=blob:none",
+		"--no-checkout",
+		"--branch",
+		options.Branch,
+	}
+	if options.Depth > 0 {
+		cloneArgs = append(
+			cloneArgs,
+			"--depth",
+			fmt.Sprintf("%d", options.Depth),
+			"--single-branch",
+		)
+	}
+	cloneArgs = append(cloneArgs, url, path)
+
+	err := runGitWithEnv(ctx, cloneArgs, "", options.Env)
+	if err != nil {
+		return fmt.Errorf("Error cloning repo: %+v", err)
+	}
+
+	err = runGitWithEnv(ctx, []string{"sparse-checkout", "init", "--cone"}, path, options.Env)
+	if err != nil {
+		return fmt.Errorf("Error initializing sparse checkout: %+v", err)
+	}
+
+	err = runGitWithEnv(
+		ctx,
+		append([]string{"sparse-checkout", "set"}, options.Dirs...),
+		path,
+		options.Env,
+	)
+	if err != nil {
+		return fmt.Errorf("Error setting sparse checkout dirs: %+v", err)
+	}
+
+	err = runGitWithEnv(ctx, []string{"checkout", options.Branch}, path, options.Env)
+	if err != nil {
+		return fmt.Errorf("Error checking out branch: %+v", err)
+	}
+
+	return nil
+}
+
 func runGit(ctx context.Context, args []string, dir string) error {
+	return runGitWithEnv(ctx, args, dir, nil)
+}
+
+func runGitWithEnv(ctx context.Context, args []string, dir string, extraEnv []string) error {
 	log.Debugf("Running git with args %+v", args)
 
 	cmd := exec.CommandContext(
@@ -86,6 +171,9 @@ func runGit(ctx context.Context, args []string, dir string) error {
 		args...,
 	)
 	cmd.Dir = dir
+	if len(extraEnv) > 0 {
+		cmd.Env = append(os.Environ(), extraEnv...)
+	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Error running git: %s", string(out))
@@ -93,3 +181,78 @@ func runGit(ctx context.Context, args []string, dir string) error {
 
 	return nil
 }
+
+// GetHeadSHA returns the SHA of the HEAD commit in the git repo that contains the argument
+// directory.
+func GetHeadSHA(ctx context.Context, dir string) (string, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		"git",
+		"rev-parse",
+		"HEAD",
+	)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Error getting HEAD SHA: %+v", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetChangedFiles returns the paths, relative to the argument directory, of the files that
+// differ between the merge base of the argument ref and HEAD and the current working tree.
+func GetChangedFiles(ctx context.Context, dir string, baseRef string) ([]string, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		"git",
+		"diff",
+		"--name-only",
+		"--relative",
+		"--merge-base",
+		baseRef,
+	)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting changed files: %+v", err)
+	}
+
+	files := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" {
+			files = append(files, trimmed)
+		}
+	}
+
+	return files, nil
+}
+
+// GetRepoRoot returns the root of the git repo that contains the argument directory.
+func GetRepoRoot(ctx context.Context, dir string) (string, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		"git",
+		"rev-parse",
+		"--show-toplevel",
+	)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Error getting repo root: %+v", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// AddWorktree checks out the argument ref of the git repo in repoDir into a detached worktree
+// at path. The worktree should be removed via RemoveWorktree when it's no longer needed.
+func AddWorktree(ctx context.Context, repoDir string, ref string, path string) error {
+	return runGit(ctx, []string{"worktree", "add", "--detach", path, ref}, repoDir)
+}
+
+// RemoveWorktree removes the worktree at the argument path, including any changes in it.
+func RemoveWorktree(ctx context.Context, repoDir string, path string) error {
+	return runGit(ctx, []string{"worktree", "remove", "--force", path}, repoDir)
+}
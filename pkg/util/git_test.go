@@ -0,0 +1,80 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparseCloneRepo(t *testing.T) {
+	ctx := context.Background()
+
+	repoDir, err := ioutil.TempDir("", "repo")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	files := []string{
+		"root.yaml",
+		"clusters/clustertype/cluster1.yaml",
+		"clusters/clustertype/expanded/namespace1/file1.yaml",
+		"clusters/clustertype/expanded/namespace2/file2.yaml",
+		"other/file3.yaml",
+	}
+	for _, file := range files {
+		path := filepath.Join(repoDir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte("contents"), 0644))
+	}
+
+	commands := [][]string{
+		{"init"},
+		{"checkout", "-b", "test-branch"},
+		{"add", "."},
+		{
+			"-c", "user.name=test",
+			"-c", "user.email=test@example.com",
+			"commit", "-m", "Initial commit",
+		},
+	}
+	for _, command := range commands {
+		cmd := exec.CommandContext(ctx, "git", command...)
+		cmd.Dir = repoDir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	cloneDir, err := ioutil.TempDir("", "clone")
+	require.NoError(t, err)
+	defer os.RemoveAll(cloneDir)
+
+	err = SparseCloneRepo(
+		ctx,
+		"file://"+repoDir,
+		cloneDir,
+		SparseCloneOptions{
+			Branch: "test-branch",
+			Depth:  1,
+			Dirs:   []string{"clusters/clustertype/expanded/namespace1"},
+		},
+	)
+	require.NoError(t, err)
+
+	expExists := map[string]bool{
+		"root.yaml":                          true,
+		"clusters/clustertype/cluster1.yaml": true,
+		"clusters/clustertype/expanded/namespace1/file1.yaml": true,
+		"clusters/clustertype/expanded/namespace2/file2.yaml": false,
+		"other/file3.yaml": false,
+	}
+	for file, exists := range expExists {
+		fileExists, err := FileExists(filepath.Join(cloneDir, file))
+		require.NoError(t, err)
+		assert.Equal(t, exists, fileExists, file)
+	}
+}
@@ -0,0 +1,252 @@
+package util
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	log "github.com/sirupsen/logrus"
+)
+
+type ignoreRule struct {
+	pattern  string
+	glob     glob.Glob
+	negate   bool
+	dirOnly  bool
+	anchored bool
+
+	// rootGlob is set for patterns with a leading "**/", which also match at the root of the
+	// tree.
+	rootGlob glob.Glob
+}
+
+// IgnoreRules is a set of gitignore-style rules used to exclude files from a directory tree.
+//
+// The supported syntax is a subset of the gitignore one:
+//
+//  1. Blank lines and lines starting with "#" are skipped
+//  2. A leading "!" negates the pattern, i.e. re-includes files excluded by previous rules
+//  3. A trailing "/" restricts the pattern to directories
+//  4. Patterns containing a "/" (other than a trailing one) are matched against the full path
+//     relative to the root; other patterns are matched against each path component
+//  5. Globs are supported, including "**" for matching across directories; a leading "**/"
+//     also matches at the root, e.g. "**/tmp" matches both "tmp" and "a/b/tmp"
+//
+// As in gitignore, the last matching rule wins.
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// LoadIgnoreRules loads the rules in the argument ignore file paths. Files that don't exist
+// are skipped.
+func LoadIgnoreRules(paths ...string) (*IgnoreRules, error) {
+	ignoreRules := &IgnoreRules{}
+
+	for _, path := range paths {
+		ok, err := FileExists(path)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+
+		log.Debugf("Loading ignore rules from %s", path)
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if err := ignoreRules.AddRule(scanner.Text()); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+		file.Close()
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ignoreRules, nil
+}
+
+// AddRule parses and adds a single rule line.
+func (r *IgnoreRules) AddRule(line string) error {
+	pattern := strings.TrimSpace(line)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil
+	}
+
+	rule := ignoreRule{
+		pattern: pattern,
+	}
+
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		rule.anchored = true
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+
+	globObj, err := glob.Compile(pattern, '/')
+	if err != nil {
+		return err
+	}
+	rule.glob = globObj
+
+	if rule.anchored && strings.HasPrefix(pattern, "**/") {
+		rule.rootGlob, err = glob.Compile(pattern[3:], '/')
+		if err != nil {
+			return err
+		}
+	}
+
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// IsEmpty returns whether there are no rules in this set.
+func (r *IgnoreRules) IsEmpty() bool {
+	return len(r.rules) == 0
+}
+
+// Ignored returns whether the file at the argument path, which is relative to the root of the
+// tree, should be ignored.
+func (r *IgnoreRules) Ignored(relPath string) bool {
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	components := strings.Split(relPath, "/")
+
+	ignored := false
+
+	for _, rule := range r.rules {
+		if rule.matches(components) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+func (r ignoreRule) matches(components []string) bool {
+	for c := 0; c < len(components); c++ {
+		isFile := c == len(components)-1
+
+		if isFile && r.dirOnly {
+			continue
+		}
+
+		var candidate string
+		if r.anchored {
+			candidate = strings.Join(components[0:c+1], "/")
+		} else {
+			candidate = components[c]
+		}
+
+		if r.glob.Match(candidate) {
+			return true
+		}
+		if r.rootGlob != nil && r.rootGlob.Match(candidate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoveIgnored removes all of the files in rootDir that are matched by the rules in the
+// argument ignore files. The ignore files themselves are also removed if they're inside of
+// rootDir, as are any directories that are empty after the removals.
+func RemoveIgnored(rootDir string, ignorePaths ...string) error {
+	ignoreRules, err := LoadIgnoreRules(ignorePaths...)
+	if err != nil {
+		return err
+	}
+
+	ignorePathsMap := map[string]struct{}{}
+	for _, ignorePath := range ignorePaths {
+		ignorePathsMap[filepath.Clean(ignorePath)] = struct{}{}
+	}
+
+	pathsToRemove := []string{}
+
+	err = filepath.Walk(
+		rootDir,
+		func(subPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			if _, ok := ignorePathsMap[filepath.Clean(subPath)]; ok {
+				pathsToRemove = append(pathsToRemove, subPath)
+				return nil
+			}
+
+			relPath, err := filepath.Rel(rootDir, subPath)
+			if err != nil {
+				return err
+			}
+			if ignoreRules.Ignored(relPath) {
+				pathsToRemove = append(pathsToRemove, subPath)
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range pathsToRemove {
+		log.Debugf("Removing ignored file %s", path)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if err := removeEmptyParents(rootDir, filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeEmptyParents removes dir and each of its parents, up to but not including rootDir,
+// until a non-empty directory is found.
+func removeEmptyParents(rootDir string, dir string) error {
+	rootDir = filepath.Clean(rootDir)
+
+	for dir = filepath.Clean(dir); dir != rootDir; dir = filepath.Dir(dir) {
+		if !strings.HasPrefix(dir, rootDir+string(filepath.Separator)) {
+			return nil
+		}
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+
+		log.Debugf("Removing empty directory %s", dir)
+		if err := os.Remove(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	ignoreRules := &IgnoreRules{}
+
+	for _, line := range []string{
+		"# comment",
+		"",
+		"*.txt",
+		"!keep.txt",
+		"apps/envoy/",
+		"/monitoring/*.yaml",
+		"**/tmp-*.yaml",
+	} {
+		require.NoError(t, ignoreRules.AddRule(line))
+	}
+
+	type testCase struct {
+		path       string
+		expIgnored bool
+	}
+
+	testCases := []testCase{
+		{path: "notes.txt", expIgnored: true},
+		{path: "apps/notes.txt", expIgnored: true},
+		{path: "apps/keep.txt", expIgnored: false},
+		{path: "apps/envoy/deployment.yaml", expIgnored: true},
+		{path: "apps/envoy", expIgnored: false},
+		{path: "apps/echoserver/deployment.yaml", expIgnored: false},
+		{path: "monitoring/deployment.yaml", expIgnored: true},
+		{path: "monitoring/sub/deployment.yaml", expIgnored: false},
+		{path: "other/monitoring/deployment.yaml", expIgnored: false},
+		{path: "apps/sub/tmp-deployment.yaml", expIgnored: true},
+		{path: "tmp-deployment.yaml", expIgnored: true},
+		{path: "apps/deployment.yaml", expIgnored: false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(
+			t,
+			testCase.expIgnored,
+			ignoreRules.Ignored(testCase.path),
+			"Unexpected result for path %s",
+			testCase.path,
+		)
+	}
+}
+
+func TestRemoveIgnored(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "ignore")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	WriteFiles(
+		t,
+		tempDir,
+		map[string]string{
+			".kubeapplyignore":                "apps/envoy/\n*.txt\n",
+			"apps/envoy/deployment.yaml":      "contents",
+			"apps/echoserver/deployment.yaml": "contents",
+			"apps/echoserver/notes.txt":       "contents",
+			"docs/notes.txt":                  "contents",
+		},
+	)
+
+	err = RemoveIgnored(
+		tempDir,
+		filepath.Join(tempDir, ".kubeapplyignore"),
+		filepath.Join(tempDir, "non-existent-file"),
+	)
+	require.NoError(t, err)
+
+	remaining := []string{}
+	err = filepath.Walk(
+		tempDir,
+		func(subPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			relPath, err := filepath.Rel(tempDir, subPath)
+			if err != nil {
+				return err
+			}
+			remaining = append(remaining, relPath)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	sort.Strings(remaining)
+
+	assert.Equal(t, []string{"apps/echoserver/deployment.yaml"}, remaining)
+
+	for _, removedDir := range []string{"apps/envoy", "docs"} {
+		ok, err := DirExists(filepath.Join(tempDir, removedDir))
+		require.NoError(t, err)
+		assert.False(t, ok, "Expected %s to be removed", removedDir)
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,6 +10,38 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// TempDirEnvVar is the env variable with the base directory for the temporary files and
+// directories created by kubeapply. If unset, the system default (e.g., /tmp) is used.
+const TempDirEnvVar = "KUBEAPPLY_TMPDIR"
+
+// TempDir creates a new temporary directory in the base directory from TempDirEnvVar. The
+// base directory is created if it doesn't exist.
+func TempDir(pattern string) (string, error) {
+	baseDir, err := tempBaseDir()
+	if err != nil {
+		return "", err
+	}
+	return ioutil.TempDir(baseDir, pattern)
+}
+
+// TempFile creates a new temporary file in the base directory from TempDirEnvVar. The
+// base directory is created if it doesn't exist.
+func TempFile(pattern string) (*os.File, error) {
+	baseDir, err := tempBaseDir()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.TempFile(baseDir, pattern)
+}
+
+func tempBaseDir() (string, error) {
+	baseDir := os.Getenv(TempDirEnvVar)
+	if baseDir == "" {
+		return "", nil
+	}
+	return baseDir, os.MkdirAll(baseDir, 0755)
+}
+
 // FileExists returns whether the given path exists and is a file.
 func FileExists(path string) (bool, error) {
 	info, err := os.Stat(path)
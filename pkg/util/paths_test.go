@@ -0,0 +1,45 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempDir(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "base")
+	require.NoError(t, err)
+	defer os.RemoveAll(baseDir)
+
+	prevValue, prevSet := os.LookupEnv(TempDirEnvVar)
+	defer func() {
+		if prevSet {
+			os.Setenv(TempDirEnvVar, prevValue)
+		} else {
+			os.Unsetenv(TempDirEnvVar)
+		}
+	}()
+
+	// The base directory is created if it doesn't exist
+	tempBase := filepath.Join(baseDir, "nested/tmp")
+	require.NoError(t, os.Setenv(TempDirEnvVar, tempBase))
+
+	tempDir, err := TempDir("test")
+	require.NoError(t, err)
+	assert.Equal(t, tempBase, filepath.Dir(tempDir))
+
+	tempFile, err := TempFile("test")
+	require.NoError(t, err)
+	defer tempFile.Close()
+	assert.Equal(t, tempBase, filepath.Dir(tempFile.Name()))
+
+	require.NoError(t, os.Unsetenv(TempDirEnvVar))
+	tempDir, err = TempDir("test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	assert.Equal(t, filepath.Clean(os.TempDir()), filepath.Dir(tempDir))
+}
@@ -24,16 +24,50 @@ var (
 		"urlEncode":  url.QueryEscape,
 		"merge":      merge,
 	}
+
+	// Sprig functions that are removed in sandbox mode since they can read the environment or
+	// hit the network.
+	sandboxRemovedFuncs = []string{
+		"env",
+		"expandenv",
+		"getHostByName",
+	}
 )
 
+// templateOptions are the options used when templating a single file.
+type templateOptions struct {
+	// strict causes missing keys to be errors.
+	strict bool
+
+	// sandboxRoot, if set, restricts the template functions to a safe subset and prevents
+	// files outside of this directory from being read via fileContents, etc.
+	sandboxRoot string
+}
+
 // ApplyTemplate runs golang templating on all files in the provided path,
 // replacing them in-place with their templated versions.
+//
+// If sandbox is true, then the Sprig functions that read the environment or hit the network
+// are removed, and the functions that read other files (e.g., fileContents) are restricted to
+// files in dir. This should be used when expanding untrusted configs.
 func ApplyTemplate(
 	dir string,
 	data interface{},
 	deleteSources bool,
 	strict bool,
+	sandbox bool,
 ) error {
+	options := templateOptions{
+		strict: strict,
+	}
+	if sandbox {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		options.sandboxRoot = absDir
+	}
+
 	return filepath.Walk(
 		dir,
 		func(subPath string, info os.FileInfo, err error) error {
@@ -52,7 +86,7 @@ func ApplyTemplate(
 			}
 			defer outFile.Close()
 
-			err = applyTemplateFile(subPath, data, true, strict, outFile)
+			err = applyTemplateFile(subPath, data, true, options, outFile)
 			if err != nil {
 				// Wrap the error so that we can provide more context
 				return fmt.Errorf("Error expanding path %s: %+v", subPath, err)
@@ -74,23 +108,29 @@ func applyTemplateFile(
 	path string,
 	data interface{},
 	allowContents bool,
-	strict bool,
+	options templateOptions,
 	out io.Writer,
 ) error {
 	templateFuncs := sprig.TxtFuncMap()
 
+	if options.sandboxRoot != "" {
+		for _, name := range sandboxRemovedFuncs {
+			delete(templateFuncs, name)
+		}
+	}
+
 	for key, value := range extraTemplateFuncs {
 		templateFuncs[key] = value
 	}
 
 	if allowContents {
-		templateFuncs["fileContents"] = fileContentsGenerator(path, data, strict)
-		templateFuncs["configMapEntry"] = configMapEntryGenerator(path, data, strict)
-		templateFuncs["configMapEntries"] = configMapEntriesGenerator(path, data, strict)
+		templateFuncs["fileContents"] = fileContentsGenerator(path, data, options)
+		templateFuncs["configMapEntry"] = configMapEntryGenerator(path, data, options)
+		templateFuncs["configMapEntries"] = configMapEntriesGenerator(path, data, options)
 	}
 
 	tmpl := template.New(filepath.Base(path)).Funcs(templateFuncs)
-	if strict {
+	if options.strict {
 		tmpl = tmpl.Option("missingkey=error")
 	}
 
@@ -103,18 +143,50 @@ func applyTemplateFile(
 	return tmpl.Execute(out, data)
 }
 
+// checkPath returns an error if the argument path is outside of the sandbox root. Symlinks
+// are resolved before checking.
+func (o templateOptions) checkPath(path string) error {
+	if o.sandboxRoot == "" {
+		return nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if resolvedPath, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolvedPath
+	}
+	root := o.sandboxRoot
+	if resolvedRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolvedRoot
+	}
+
+	relPath, err := filepath.Rel(root, absPath)
+	if err != nil ||
+		relPath == ".." ||
+		strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("Path %s is outside of the templating root %s", path, o.sandboxRoot)
+	}
+	return nil
+}
+
 func fileContentsGenerator(
 	templatePath string,
 	data interface{},
-	strict bool,
+	options templateOptions,
 ) func(string) (string, error) {
 	return func(relPath string) (string, error) {
 		configPath := filepath.Join(
 			filepath.Dir(templatePath),
 			relPath,
 		)
+		if err := options.checkPath(configPath); err != nil {
+			return "", err
+		}
+
 		buf := &bytes.Buffer{}
-		err := applyTemplateFile(configPath, data, false, strict, buf)
+		err := applyTemplateFile(configPath, data, false, options, buf)
 		if err != nil {
 			return "", err
 		}
@@ -126,15 +198,19 @@ func fileContentsGenerator(
 func configMapEntryGenerator(
 	templatePath string,
 	data interface{},
-	strict bool,
+	options templateOptions,
 ) func(string) (string, error) {
 	return func(relPath string) (string, error) {
 		configPath := filepath.Join(
 			filepath.Dir(templatePath),
 			relPath,
 		)
+		if err := options.checkPath(configPath); err != nil {
+			return "", err
+		}
+
 		buf := &bytes.Buffer{}
-		err := applyTemplateFile(configPath, data, false, strict, buf)
+		err := applyTemplateFile(configPath, data, false, options, buf)
 		if err != nil {
 			return "", err
 		}
@@ -162,7 +238,7 @@ func configMapEntryGenerator(
 func configMapEntriesGenerator(
 	templatePath string,
 	data interface{},
-	strict bool,
+	options templateOptions,
 ) func(string) (string, error) {
 	return func(relPath string) (string, error) {
 		outputLines := []string{}
@@ -171,6 +247,9 @@ func configMapEntriesGenerator(
 			filepath.Dir(templatePath),
 			relPath,
 		)
+		if err := options.checkPath(dirPath); err != nil {
+			return "", err
+		}
 
 		dirFiles, err := ioutil.ReadDir(dirPath)
 		if err != nil {
@@ -190,7 +269,7 @@ func configMapEntriesGenerator(
 				dirFile.Name(),
 			)
 			buf := &bytes.Buffer{}
-			err := applyTemplateFile(configPath, data, false, strict, buf)
+			err := applyTemplateFile(configPath, data, false, options, buf)
 			if err != nil {
 				return "", err
 			}
@@ -27,6 +27,7 @@ func TestApplyTemplate(t *testing.T) {
 		},
 		true,
 		false,
+		false,
 	)
 	require.Nil(t, err)
 
@@ -99,10 +100,103 @@ func TestApplyTemplateStrict(t *testing.T) {
 		},
 		true,
 		true,
+		false,
 	)
 	require.Error(t, err)
 }
 
+func TestApplyTemplateSandbox(t *testing.T) {
+	type testCase struct {
+		description string
+		contents    string
+		sandbox     bool
+		expOutput   string
+		expErr      bool
+	}
+
+	os.Setenv("KUBEAPPLY_TEMPLATE_TEST_VAR", "test-value")
+	defer os.Unsetenv("KUBEAPPLY_TEMPLATE_TEST_VAR")
+
+	testCases := []testCase{
+		{
+			description: "env without sandbox",
+			contents:    `key: {{ env "KUBEAPPLY_TEMPLATE_TEST_VAR" }}`,
+			expOutput:   "key: test-value",
+		},
+		{
+			description: "env in sandbox",
+			contents:    `key: {{ env "KUBEAPPLY_TEMPLATE_TEST_VAR" }}`,
+			sandbox:     true,
+			expErr:      true,
+		},
+		{
+			description: "safe functions in sandbox",
+			contents:    `key: {{ "value" | upper }} {{ urlEncode "a b" }}`,
+			sandbox:     true,
+			expOutput:   "key: VALUE a+b",
+		},
+		{
+			description: "file in root in sandbox",
+			contents:    `key: {{ fileContents "configs/test.txt" }}`,
+			sandbox:     true,
+			expOutput:   "key: inside",
+		},
+		{
+			description: "file outside of root without sandbox",
+			contents:    `key: {{ fileContents "../outside.txt" }}`,
+			expOutput:   "key: outside",
+		},
+		{
+			description: "file outside of root in sandbox",
+			contents:    `key: {{ fileContents "../outside.txt" }}`,
+			sandbox:     true,
+			expErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tempDir, err := ioutil.TempDir("", "templates")
+		require.NoError(t, err)
+		defer os.RemoveAll(tempDir)
+
+		rootDir := filepath.Join(tempDir, "root")
+		require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "configs"), 0755))
+		require.NoError(
+			t,
+			ioutil.WriteFile(filepath.Join(tempDir, "outside.txt"), []byte("outside"), 0644),
+		)
+		require.NoError(
+			t,
+			ioutil.WriteFile(
+				filepath.Join(rootDir, "configs", "test.txt"),
+				[]byte("inside"),
+				0644,
+			),
+		)
+		require.NoError(
+			t,
+			ioutil.WriteFile(
+				filepath.Join(rootDir, "test.gotpl.yaml"),
+				[]byte(testCase.contents),
+				0644,
+			),
+		)
+
+		err = ApplyTemplate(rootDir, map[string]string{}, true, false, testCase.sandbox)
+		if testCase.expErr {
+			assert.Error(t, err, testCase.description)
+		} else {
+			require.NoError(t, err, testCase.description)
+			assert.Equal(
+				t,
+				testCase.expOutput,
+				fileContents(t, filepath.Join(rootDir, "test.yaml")),
+				testCase.description,
+			)
+		}
+	}
+}
+
 func getAllFiles(t *testing.T, path string) []string {
 	allFiles := []string{}
 
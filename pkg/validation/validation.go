@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	_ "github.com/open-policy-agent/opa/rego"
+	"github.com/segmentio/kubeapply/pkg/cluster/kube"
 	"github.com/segmentio/kubeapply/pkg/util"
 )
 
@@ -49,7 +50,9 @@ func (k *KubeValidator) RunChecks(
 				return err
 			}
 
-			if info.IsDir() || !strings.HasSuffix(subPath, ".yaml") {
+			if info.IsDir() ||
+				!strings.HasSuffix(subPath, ".yaml") ||
+				filepath.Base(subPath) == kube.ApplyOrderFile {
 				return nil
 			}
 
@@ -1,4 +1,36 @@
 package version
 
+import (
+	"fmt"
+	"runtime"
+)
+
 // Version stores the current kubeapply version.
 const Version = "0.1.0"
+
+// BuildDate is the time that kubeapply was built at. It's set at build-time.
+var BuildDate = "unknown"
+
+// Info contains the details of the current kubeapply build.
+type Info struct {
+	Version   string `json:"version"`
+	Ref       string `json:"ref"`
+	GoVersion string `json:"goVersion"`
+	BuildDate string `json:"buildDate"`
+}
+
+// GetInfo returns the build details for the current binary. The ref is the git reference
+// that the binary was built from, which is set at build-time in the main package.
+func GetInfo(ref string) Info {
+	return Info{
+		Version:   Version,
+		Ref:       ref,
+		GoVersion: runtime.Version(),
+		BuildDate: BuildDate,
+	}
+}
+
+// String returns a short, human-readable version of the build details.
+func (i Info) String() string {
+	return fmt.Sprintf("v%s (ref:%s)", i.Version, i.Ref)
+}